@@ -0,0 +1,288 @@
+// Package analyze 将快照中的日志按错误签名归类
+// 日志消息中的数字、地址、路径等可变内容被替换为占位符，得到相同模板的日志归为同一个签名
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"logsnap/collector"
+	"logsnap/collector/report"
+	"logsnap/encryption"
+	"logsnap/logentry"
+	"logsnap/snapshot"
+)
+
+const (
+	// maxExamples 每个签名保留的示例日志数量
+	maxExamples = 3
+	// maxSignatures 最多记录的签名数量，超出后新出现的签名只计入 Skipped
+	maxSignatures = 10000
+	// maxLineLength 每行只读取开头的这些字节
+	maxLineLength = 4096
+	// minSpan 计算出现频率时时间覆盖范围的下限，避免只有少量日志时频率被放大
+	minSpan = time.Minute
+)
+
+// Options 分析选项
+type Options struct {
+	Identity    *encryption.Identity // 解密加密快照使用的私钥
+	MinSeverity logentry.Severity    // 只归类不低于该级别的日志
+	Top         int                  // 最多返回的签名数量，0 表示不限制
+	// Processors 日志所属的处理器，按程序选用处理器声明的时间戳格式，没有所属处理器的文件使用通用格式
+	Processors []collector.LogProcessor
+}
+
+// Example 签名的示例日志
+type Example struct {
+	Program string `json:"program"` // 程序
+	File    string `json:"file"`    // 相对快照目录的文件路径
+	Line    string `json:"line"`    // 原始日志行
+}
+
+// Signature 模板相同的一类日志
+type Signature struct {
+	Template string            `json:"template"` // 日志消息的模板
+	Severity logentry.Severity `json:"-"`        // 日志级别
+	Level    string            `json:"severity"` // 日志级别名称
+	Count    int               `json:"count"`    // 出现次数
+	First    time.Time         `json:"first"`    // 第一次出现的时间
+	Last     time.Time         `json:"last"`     // 最后一次出现的时间
+	Programs []string          `json:"programs"` // 出现该日志的程序
+	Examples []Example         `json:"examples"` // 示例日志
+}
+
+// Result 分析结果
+type Result struct {
+	Path       string       `json:"path"`       // 快照或目录路径
+	Files      int          `json:"files"`      // 分析的文件数
+	Entries    int          `json:"entries"`    // 参与归类的日志条数
+	Skipped    int          `json:"skipped"`    // 签名数量超出上限未归类的日志条数
+	Total      int          `json:"total"`      // 签名总数，Top 限制前
+	Start      time.Time    `json:"start"`      // 所有可识别时间戳的日志中最早的时间（不限级别）
+	End        time.Time    `json:"end"`        // 所有可识别时间戳的日志中最晚的时间（不限级别）
+	Signatures []*Signature `json:"signatures"` // 按级别和出现次数排序的签名
+}
+
+// Span 返回日志的时间覆盖范围，不足 minSpan 时按 minSpan 计算
+func (r *Result) Span() time.Duration {
+	if span := r.End.Sub(r.Start); span > minSpan {
+		return span
+	}
+	return minSpan
+}
+
+// Analyze 读取快照或目录中的所有日志并按签名归类
+func Analyze(path string, opts Options) (*Result, error) {
+	analyzer := NewAnalyzer(opts.MinSeverity)
+	analyzer.UseProcessors(opts.Processors)
+	err := snapshot.Walk(path, opts.Identity, func(name, program string, r io.Reader) error {
+		if isGenerated(name) {
+			return nil
+		}
+		return analyzer.Add(program, name, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := analyzer.Result(opts.Top)
+	result.Path = path
+	return result, nil
+}
+
+// isGenerated 判断文件是否为快照自身生成的文件，这些文件不是原始日志
+func isGenerated(name string) bool {
+	switch name {
+	case report.FileName, collector.TimelineFileName:
+		return true
+	}
+	return false
+}
+
+// Analyzer 按签名归类日志
+type Analyzer struct {
+	minSeverity logentry.Severity
+	layouts     map[string][]logentry.Layout
+	signatures  map[string]*Signature
+	files       int
+	entries     int
+	skipped     int
+	start       time.Time
+	end         time.Time
+}
+
+// NewAnalyzer 创建分析器，只归类不低于 minSeverity 的日志
+func NewAnalyzer(minSeverity logentry.Severity) *Analyzer {
+	return &Analyzer{
+		minSeverity: minSeverity,
+		layouts:     make(map[string][]logentry.Layout),
+		signatures:  make(map[string]*Signature),
+	}
+}
+
+// UseProcessors 按程序选用处理器声明的时间戳格式
+// 快照清单以处理器名称记录程序，没有清单时以快照目录下的第一级目录作为程序，两者都对应到处理器
+func (a *Analyzer) UseProcessors(processors []collector.LogProcessor) {
+	for _, p := range processors {
+		layouts := p.GetTimeLayouts()
+		if len(layouts) == 0 {
+			continue
+		}
+		a.layouts[p.GetName()] = layouts
+		dir := strings.Trim(filepath.ToSlash(filepath.Clean(p.GetOutputDir())), "/")
+		if dir != "" && dir != "." {
+			a.layouts[strings.SplitN(dir, "/", 2)[0]] = layouts
+		}
+	}
+}
+
+// Add 读取一个日志文件并归类其中的日志
+// 没有可识别时间戳的行是上一条日志的后续行（如异常堆栈），不单独归类
+func (a *Analyzer) Add(program, name string, r io.Reader) error {
+	a.files++
+	fileSeverity := logentry.SeverityFromFileName(name)
+	reader := bufio.NewReaderSize(r, maxLineLength)
+	for {
+		line, err := readLine(reader)
+		if len(line) > 0 {
+			a.observe(program, name, line, fileSeverity)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", name, err)
+		}
+	}
+}
+
+// observe 归类一行日志
+func (a *Analyzer) observe(program, name string, line []byte, fileSeverity logentry.Severity) {
+	entry, ok := a.parse(program, line)
+	if !ok {
+		return
+	}
+	if a.start.IsZero() || entry.Time.Before(a.start) {
+		a.start = entry.Time
+	}
+	if entry.Time.After(a.end) {
+		a.end = entry.Time
+	}
+	if entry.Severity == logentry.SeverityUnknown {
+		entry.Severity = fileSeverity
+	}
+	if entry.Severity < a.minSeverity {
+		return
+	}
+	a.entries++
+
+	template := logentry.Template(string(line[entry.Offset:]))
+	key := signatureKey(entry.Severity, template)
+	signature := a.signatures[key]
+	if signature == nil {
+		if len(a.signatures) >= maxSignatures {
+			a.skipped++
+			return
+		}
+		signature = &Signature{
+			Template: template,
+			Severity: entry.Severity,
+			Level:    entry.Severity.String(),
+			First:    entry.Time,
+			Last:     entry.Time,
+		}
+		a.signatures[key] = signature
+	}
+
+	signature.Count++
+	if entry.Time.Before(signature.First) {
+		signature.First = entry.Time
+	}
+	if entry.Time.After(signature.Last) {
+		signature.Last = entry.Time
+	}
+	if !containsString(signature.Programs, program) {
+		signature.Programs = append(signature.Programs, program)
+	}
+	if len(signature.Examples) < maxExamples {
+		signature.Examples = append(signature.Examples, Example{
+			Program: program,
+			File:    name,
+			Line:    strings.ToValidUTF8(string(line), "�"),
+		})
+	}
+}
+
+// parse 使用程序所属处理器的时间戳格式识别日志行，没有所属处理器的文件使用通用格式
+func (a *Analyzer) parse(program string, line []byte) (logentry.Entry, bool) {
+	if layouts, ok := a.layouts[program]; ok {
+		return logentry.ParseLayouts(line, layouts)
+	}
+	return logentry.Parse(line)
+}
+
+// Result 返回按级别（高到低）和出现次数（多到少）排序的签名，top 大于 0 时只返回前 top 个
+func (a *Analyzer) Result(top int) *Result {
+	result := &Result{
+		Files:   a.files,
+		Entries: a.entries,
+		Skipped: a.skipped,
+		Total:   len(a.signatures),
+		Start:   a.start,
+		End:     a.end,
+	}
+	for _, signature := range a.signatures {
+		sort.Strings(signature.Programs)
+		result.Signatures = append(result.Signatures, signature)
+	}
+	sort.Slice(result.Signatures, func(i, j int) bool {
+		si, sj := result.Signatures[i], result.Signatures[j]
+		if si.Severity != sj.Severity {
+			return si.Severity > sj.Severity
+		}
+		if si.Count != sj.Count {
+			return si.Count > sj.Count
+		}
+		if !si.First.Equal(sj.First) {
+			return si.First.Before(sj.First)
+		}
+		return si.Template < sj.Template
+	})
+	if top > 0 && len(result.Signatures) > top {
+		result.Signatures = result.Signatures[:top]
+	}
+	return result
+}
+
+// readLine 读取一行，去掉行尾的换行符，超出 maxLineLength 的部分被丢弃
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		line = append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			_, err = reader.ReadSlice('\n')
+		}
+	}
+	return bytes.TrimRight(line, "\r\n"), err
+}
+
+// signatureKey 返回签名的键，级别和模板都相同的日志属于同一个签名
+func signatureKey(severity logentry.Severity, template string) string {
+	return severity.String() + "\x00" + template
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
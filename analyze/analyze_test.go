@@ -0,0 +1,97 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"logsnap/collector"
+	hmiServerProcessor "logsnap/collector/processor/hmi_server"
+	"logsnap/logentry"
+)
+
+func TestAnalyzer_GroupsAndRanks(t *testing.T) {
+	analyzer := NewAnalyzer(logentry.SeverityWarning)
+	analyzer.Add("xyz-hmi", "xyz_hmi/app.log", strings.NewReader(
+		"I20250228 10:00:01.000000 11 a.cpp:1] start\n"+
+			"W20250228 10:00:02.000000 11 a.cpp:5] slow frame 35ms\n"+
+			"W20250228 10:00:03.000000 11 a.cpp:5] slow frame 41ms\n"+
+			"W20250228 10:00:04.000000 12 a.cpp:5] slow frame 38ms\n"+
+			"E20250228 10:00:05.000000 11 a.cpp:9] open /data/task/1.json failed\n"+
+			"    at stack frame\n"))
+	analyzer.Add("xyz-server", "xyz_server/server.log", strings.NewReader(
+		"2025-02-28 10:01:00.000 | ERROR    | open /data/task/2.json failed\n"))
+
+	result := analyzer.Result(0)
+	if result.Files != 2 || result.Entries != 5 || len(result.Signatures) != 3 {
+		t.Fatalf("统计错误: files=%d entries=%d signatures=%d", result.Files, result.Entries, len(result.Signatures))
+	}
+
+	// ERROR 排在 WARNING 之前，即使 WARNING 出现次数更多
+	first := result.Signatures[0]
+	if first.Severity != logentry.SeverityError {
+		t.Errorf("第一个签名应为 ERROR, 实际 %s: %s", first.Level, first.Template)
+	}
+	warning := result.Signatures[2]
+	if warning.Count != 3 || warning.Template != "<NUM> a.cpp:<NUM>] slow frame <NUM>ms" {
+		t.Errorf("WARNING 签名错误: %+v", warning)
+	}
+	if !warning.First.Equal(time.Date(2025, 2, 28, 10, 0, 2, 0, time.Local)) ||
+		!warning.Last.Equal(time.Date(2025, 2, 28, 10, 0, 4, 0, time.Local)) {
+		t.Errorf("首次和最后出现时间错误: %v ~ %v", warning.First, warning.Last)
+	}
+	if len(warning.Examples) != maxExamples || warning.Examples[0].File != "xyz_hmi/app.log" {
+		t.Errorf("示例日志错误: %+v", warning.Examples)
+	}
+
+	if top := analyzer.Result(1); len(top.Signatures) != 1 || top.Total != 3 {
+		t.Errorf("Top 限制错误: %d/%d", len(top.Signatures), top.Total)
+	}
+}
+
+func TestAnalyze_Directory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "xyz_hmi"), 0755)
+	os.WriteFile(filepath.Join(dir, "xyz_hmi", "app.log"),
+		[]byte("E20250228 10:00:05.000000 11 a.cpp:9] connect 10.0.0.1:80 failed\n"), 0644)
+	// 快照生成的时间线与原始日志重复，不参与归类
+	os.WriteFile(filepath.Join(dir, "timeline.log"),
+		[]byte("E20250228 10:00:05.000000 11 a.cpp:9] connect 10.0.0.1:80 failed\n"), 0644)
+
+	result, err := Analyze(dir, Options{MinSeverity: logentry.SeverityError})
+	if err != nil {
+		t.Fatalf("分析失败: %v", err)
+	}
+	if len(result.Signatures) != 1 || result.Signatures[0].Count != 1 {
+		t.Fatalf("应只有一个出现一次的签名: %+v", result.Signatures)
+	}
+	if programs := result.Signatures[0].Programs; len(programs) != 1 || programs[0] != "xyz_hmi" {
+		t.Errorf("没有清单时应以目录作为程序: %v", programs)
+	}
+}
+
+func TestAnalyzer_UsesProcessorLayouts(t *testing.T) {
+	server := hmiServerProcessor.NewHMIServerLogProcessor("", "/xyz_max_hmi/server")
+	analyzer := NewAnalyzer(logentry.SeverityError)
+	analyzer.UseProcessors([]collector.LogProcessor{server})
+
+	// 处理器的格式要求毫秒和分隔符，缺少的行属于上一条日志
+	owned := "2025-02-28 10:01:00.000 | ERROR    | open /data/task/2.json failed\n" +
+		"2025-02-28 10:01:00 ERROR not a new entry\n"
+	analyzer.Add(server.GetName(), "xyz_max_hmi/server/server.log", strings.NewReader(owned))
+	analyzer.Add("xyz_max_hmi", "xyz_max_hmi/server/server.log", strings.NewReader(owned))
+	// 没有所属处理器的文件使用通用格式
+	analyzer.Add("other", "other/app.log", strings.NewReader("2025-02-28 10:01:00 ERROR generic entry\n"))
+
+	result := analyzer.Result(0)
+	if result.Entries != 3 || len(result.Signatures) != 2 {
+		t.Fatalf("统计错误: entries=%d signatures=%+v", result.Entries, result.Signatures)
+	}
+	for _, signature := range result.Signatures {
+		if strings.Contains(signature.Template, "not a new entry") {
+			t.Errorf("处理器格式之外的行不应单独归类: %s", signature.Template)
+		}
+	}
+}
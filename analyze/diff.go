@@ -0,0 +1,170 @@
+package analyze
+
+import (
+	"sort"
+
+	"logsnap/collector"
+	"logsnap/encryption"
+	"logsnap/logentry"
+)
+
+// 签名的变化类型
+const (
+	ChangeNew       = "new"       // 只在故障快照中出现
+	ChangeIncreased = "increased" // 出现频率明显升高
+	ChangeGone      = "gone"      // 只在基线快照中出现
+)
+
+const (
+	// DefaultThreshold 默认的频率升高倍数阈值
+	DefaultThreshold = 2.0
+	// minIncreasedCount 判定频率升高时故障快照中的最少出现次数，避免偶发日志被误报
+	minIncreasedCount = 3
+)
+
+// DiffOptions 快照对比选项
+type DiffOptions struct {
+	Identity    *encryption.Identity // 解密加密快照使用的私钥
+	MinSeverity logentry.Severity    // 只对比不低于该级别的日志
+	Threshold   float64              // 频率升高倍数阈值，不大于 1 时使用 DefaultThreshold
+	// Processors 日志所属的处理器，见 Options.Processors
+	Processors []collector.LogProcessor
+}
+
+// Change 一个签名在两个快照之间的变化
+type Change struct {
+	Kind          string            `json:"kind"`           // 变化类型
+	Template      string            `json:"template"`       // 日志消息的模板
+	Severity      logentry.Severity `json:"-"`              // 日志级别
+	Level         string            `json:"severity"`       // 日志级别名称
+	BaselineCount int               `json:"baseline_count"` // 基线快照中的出现次数
+	IncidentCount int               `json:"incident_count"` // 故障快照中的出现次数
+	BaselineRate  float64           `json:"baseline_rate"`  // 基线快照中每小时的出现次数
+	IncidentRate  float64           `json:"incident_rate"`  // 故障快照中每小时的出现次数
+	Ratio         float64           `json:"ratio"`          // 频率倍数，只在频率升高时有效
+	Programs      []string          `json:"programs"`       // 出现该日志的程序
+	Example       *Example          `json:"example"`        // 示例日志，优先取故障快照中的
+}
+
+// DiffResult 快照对比结果
+type DiffResult struct {
+	Baseline     string    `json:"baseline"`      // 基线快照路径
+	Incident     string    `json:"incident"`      // 故障快照路径
+	BaselineSpan float64   `json:"baseline_span"` // 基线快照日志的时间覆盖范围（小时）
+	IncidentSpan float64   `json:"incident_span"` // 故障快照日志的时间覆盖范围（小时）
+	Threshold    float64   `json:"threshold"`     // 频率升高倍数阈值
+	Changes      []*Change `json:"changes"`       // 按变化类型、级别和频率排序的变化
+}
+
+// Diff 对比基线快照和故障快照中的签名，找出新出现、消失和频率明显升高的签名
+// 两个快照的时间覆盖范围通常不同，频率按每小时的出现次数计算
+func Diff(baselinePath, incidentPath string, opts DiffOptions) (*DiffResult, error) {
+	analyzeOpts := Options{Identity: opts.Identity, MinSeverity: opts.MinSeverity, Processors: opts.Processors}
+	baseline, err := Analyze(baselinePath, analyzeOpts)
+	if err != nil {
+		return nil, err
+	}
+	incident, err := Analyze(incidentPath, analyzeOpts)
+	if err != nil {
+		return nil, err
+	}
+	return DiffResults(baseline, incident, opts.Threshold), nil
+}
+
+// DiffResults 对比两个分析结果，结果需包含全部签名（Top 为 0）
+func DiffResults(baseline, incident *Result, threshold float64) *DiffResult {
+	if threshold <= 1 {
+		threshold = DefaultThreshold
+	}
+	baselineHours := baseline.Span().Hours()
+	incidentHours := incident.Span().Hours()
+	result := &DiffResult{
+		Baseline:     baseline.Path,
+		Incident:     incident.Path,
+		BaselineSpan: baselineHours,
+		IncidentSpan: incidentHours,
+		Threshold:    threshold,
+	}
+
+	baselineSignatures := make(map[string]*Signature, len(baseline.Signatures))
+	for _, signature := range baseline.Signatures {
+		baselineSignatures[signatureKey(signature.Severity, signature.Template)] = signature
+	}
+
+	for _, signature := range incident.Signatures {
+		key := signatureKey(signature.Severity, signature.Template)
+		old := baselineSignatures[key]
+		delete(baselineSignatures, key)
+
+		change := newChange(signature)
+		change.IncidentCount = signature.Count
+		change.IncidentRate = float64(signature.Count) / incidentHours
+		if old == nil {
+			change.Kind = ChangeNew
+			result.Changes = append(result.Changes, change)
+			continue
+		}
+
+		change.BaselineCount = old.Count
+		change.BaselineRate = float64(old.Count) / baselineHours
+		change.Ratio = change.IncidentRate / change.BaselineRate
+		if change.Ratio >= threshold && signature.Count >= minIncreasedCount {
+			change.Kind = ChangeIncreased
+			change.Programs = mergePrograms(change.Programs, old.Programs)
+			result.Changes = append(result.Changes, change)
+		}
+	}
+
+	for _, signature := range baselineSignatures {
+		change := newChange(signature)
+		change.Kind = ChangeGone
+		change.BaselineCount = signature.Count
+		change.BaselineRate = float64(signature.Count) / baselineHours
+		result.Changes = append(result.Changes, change)
+	}
+
+	kindOrder := map[string]int{ChangeNew: 0, ChangeIncreased: 1, ChangeGone: 2}
+	sort.Slice(result.Changes, func(i, j int) bool {
+		ci, cj := result.Changes[i], result.Changes[j]
+		if ci.Kind != cj.Kind {
+			return kindOrder[ci.Kind] < kindOrder[cj.Kind]
+		}
+		if ci.Severity != cj.Severity {
+			return ci.Severity > cj.Severity
+		}
+		ri, rj := ci.IncidentRate, cj.IncidentRate
+		if ci.Kind == ChangeGone {
+			ri, rj = ci.BaselineRate, cj.BaselineRate
+		}
+		if ri != rj {
+			return ri > rj
+		}
+		return ci.Template < cj.Template
+	})
+	return result
+}
+
+func newChange(signature *Signature) *Change {
+	change := &Change{
+		Template: signature.Template,
+		Severity: signature.Severity,
+		Level:    signature.Level,
+		Programs: append([]string(nil), signature.Programs...),
+	}
+	if len(signature.Examples) > 0 {
+		example := signature.Examples[0]
+		change.Example = &example
+	}
+	return change
+}
+
+// mergePrograms 合并两个已排序的程序列表
+func mergePrograms(a, b []string) []string {
+	for _, program := range b {
+		if !containsString(a, program) {
+			a = append(a, program)
+		}
+	}
+	sort.Strings(a)
+	return a
+}
@@ -0,0 +1,73 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"logsnap/logentry"
+)
+
+// analyzeLines 归类日志内容，日志覆盖 10:00 到 11:00 的一小时
+func analyzeLines(lines ...string) *Result {
+	analyzer := NewAnalyzer(logentry.SeverityWarning)
+	content := "I20250228 10:00:00.000000 1 a.cpp:1] begin\n" + strings.Join(lines, "\n") +
+		"\nI20250228 11:00:00.000000 1 a.cpp:1] end\n"
+	analyzer.Add("xyz-hmi", "xyz_hmi/app.log", strings.NewReader(content))
+	return analyzer.Result(0)
+}
+
+func repeat(n int, format string) []string {
+	var lines []string
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf(format, i%60))
+	}
+	return lines
+}
+
+func TestDiffResults(t *testing.T) {
+	baseline := analyzeLines(append(
+		repeat(4, "W20250228 10:%02d:00.000000 1 a.cpp:5] slow frame"),
+		"E20250228 10:30:00.000000 1 a.cpp:7] legacy failure",
+		"W20250228 10:31:00.000000 1 a.cpp:8] steady warning",
+	)...)
+	incident := analyzeLines(append(
+		repeat(12, "W20250228 10:%02d:00.000000 1 a.cpp:5] slow frame"),
+		"E20250228 10:40:00.000000 1 b.cpp:3] connect 10.0.0.1:80 refused",
+		"W20250228 10:41:00.000000 1 a.cpp:8] steady warning",
+	)...)
+
+	result := DiffResults(baseline, incident, 0)
+	if result.Threshold != DefaultThreshold || result.IncidentSpan != 1 {
+		t.Fatalf("阈值或时间覆盖范围错误: %+v", result)
+	}
+	if len(result.Changes) != 3 {
+		t.Fatalf("应有 3 个变化, 实际 %d: %+v", len(result.Changes), result.Changes)
+	}
+
+	kinds := []string{ChangeNew, ChangeIncreased, ChangeGone}
+	for i, change := range result.Changes {
+		if change.Kind != kinds[i] {
+			t.Errorf("第 %d 个变化应为 %s, 实际 %s: %s", i+1, kinds[i], change.Kind, change.Template)
+		}
+	}
+	if increased := result.Changes[1]; increased.BaselineCount != 4 || increased.IncidentCount != 12 || increased.Ratio != 3 {
+		t.Errorf("频率升高统计错误: %+v", increased)
+	}
+}
+
+func TestDiffResults_NormalizesBySpan(t *testing.T) {
+	baseline := analyzeLines(repeat(10, "W20250228 10:%02d:00.000000 1 a.cpp:5] slow frame")...)
+
+	// 故障快照只覆盖 30 分钟，出现次数相同意味着频率翻倍
+	analyzer := NewAnalyzer(logentry.SeverityWarning)
+	analyzer.Add("xyz-hmi", "xyz_hmi/app.log", strings.NewReader(strings.Join(
+		repeat(10, "W20250228 10:%02d:00.000000 1 a.cpp:5] slow frame"), "\n")+
+		"\nI20250228 10:30:00.000000 1 a.cpp:1] end\n"))
+	incident := analyzer.Result(0)
+
+	result := DiffResults(baseline, incident, 0)
+	if len(result.Changes) != 1 || result.Changes[0].Kind != ChangeIncreased || result.Changes[0].Ratio != 2 {
+		t.Fatalf("按时间覆盖范围归一化后频率应翻倍: %+v", result.Changes)
+	}
+}
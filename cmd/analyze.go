@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"logsnap/analyze"
+	"logsnap/encryption"
+	"logsnap/logentry"
+
+	"github.com/urfave/cli/v2"
+)
+
+// analyzeAction 处理analyze命令，将快照中的日志按错误签名归类
+func analyzeAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("请指定要分析的快照文件或目录")
+	}
+
+	opts := analyze.Options{Top: c.Int("top")}
+	opts.MinSeverity = logentry.ParseSeverity(c.String("level"))
+	if opts.MinSeverity == logentry.SeverityUnknown {
+		return fmt.Errorf("无效的日志级别: %s, 可选 debug, info, warning, error, fatal", c.String("level"))
+	}
+	if identityPath := c.String("identity"); identityPath != "" {
+		identity, err := encryption.LoadIdentity(identityPath)
+		if err != nil {
+			return err
+		}
+		opts.Identity = identity
+	}
+	processors, err := supportedProcessors()
+	if err != nil {
+		return err
+	}
+	opts.Processors = processors
+
+	result, err := analyze.Analyze(c.Args().First(), opts)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	printAnalysis(result)
+	return nil
+}
+
+// printAnalysis 以表格形式显示签名，表格之后列出每个签名的示例日志
+func printAnalysis(result *analyze.Result) {
+	fmt.Printf("分析了 %d 个文件，%d 条日志归为 %d 个签名", result.Files, result.Entries, result.Total)
+	if len(result.Signatures) < result.Total {
+		fmt.Printf("，显示前 %d 个", len(result.Signatures))
+	}
+	fmt.Println()
+	if result.Skipped > 0 {
+		fmt.Printf("签名数量超出上限，%d 条日志未归类\n", result.Skipped)
+	}
+	if len(result.Signatures) == 0 {
+		return
+	}
+
+	fmt.Println()
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "#\t级别\t次数\t首次出现\t最后出现\t程序\t签名")
+	for i, signature := range result.Signatures {
+		fmt.Fprintf(table, "%d\t%s\t%d\t%s\t%s\t%s\t%s\n", i+1, signature.Level, signature.Count,
+			signature.First.Format(inspectTimeLayout), signature.Last.Format(inspectTimeLayout),
+			strings.Join(signature.Programs, ","), signature.Template)
+	}
+	table.Flush()
+
+	fmt.Println("\n示例:")
+	for i, signature := range result.Signatures {
+		for _, example := range signature.Examples {
+			fmt.Printf("#%d %s: %s\n", i+1, example.File, example.Line)
+		}
+	}
+}
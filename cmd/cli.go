@@ -3,6 +3,8 @@ package cmd
 import (
 	"os"
 
+	"logsnap/analyze"
+
 	"github.com/urfave/cli/v2"
 )
 
@@ -60,7 +62,42 @@ func Execute() error {
 						Name:    "output-dir",
 						Aliases: []string{"o"},
 						Value:   "",
-						Usage:   "输出目录 (可选，默认当前目录)",
+						Usage:   "输出目录 (可选，默认当前目录；上传并保留本地快照时默认为快照存储目录)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "encrypt-to",
+						Usage: "使用接收者公钥加密快照，可指定多次 (例如: logsnap-pub-...)",
+					},
+					&cli.StringFlag{
+						Name:  "sign-key",
+						Usage: "使用签名私钥对快照校验文件签名 (私钥文件路径)",
+					},
+					&cli.StringFlag{
+						Name:  "level",
+						Usage: "只收集不低于该级别的日志 (debug, info, warning, error, fatal)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "grep",
+						Aliases: []string{"g"},
+						Usage:   "只收集内容匹配正则表达式的日志，可指定多次 (匹配任意一个即可)",
+					},
+					&cli.BoolFlag{
+						Name:  "timeline",
+						Usage: "在快照中生成按时间合并所有程序日志的 timeline.log",
+					},
+					&cli.BoolFlag{
+						Name:  "no-report",
+						Usage: "不在快照中生成 report.html 摘要报告",
+					},
+					&cli.StringFlag{
+						Name:  "max-size",
+						Usage: "快照的最大大小，例如：100M (超出时优先保留 ERROR/FATAL 和较新的日志，其余日志被截断或丢弃)",
+						Value: "",
+					},
+					&cli.StringFlag{
+						Name:  "max-volume-size",
+						Usage: "单个快照分卷的最大大小，例如：200M, 1G (不指定则不分卷)",
+						Value: "",
 					},
 					&cli.StringSliceFlag{
 						Name:    "program",
@@ -79,6 +116,22 @@ func Execute() error {
 						Name:  "this-week",
 						Usage: "收集本周的日志 (从本周一 00:00:00 开始)",
 					},
+					&cli.StringFlag{
+						Name:  "share-expiry",
+						Usage: "分享链接的有效期，覆盖上传配置中的 share_expiry (例如: 24h, 3d)",
+					},
+					&cli.IntFlag{
+						Name:  "share-downloads",
+						Usage: "分享链接允许下载的次数，0 表示不限制，覆盖上传配置中的 share_downloads",
+					},
+					&cli.StringFlag{
+						Name:  "share-password",
+						Usage: "分享链接的提取码，覆盖上传配置中的 share_password",
+					},
+					&cli.BoolFlag{
+						Name:  "share-preview",
+						Usage: "分享链接是否允许预览，覆盖上传配置中的 share_preview",
+					},
 					&cli.BoolFlag{
 						Name:  "skip-version-check",
 						Usage: "跳过版本检查",
@@ -140,6 +193,350 @@ func Execute() error {
 					},
 				},
 			},
+			{
+				Name:   "keygen",
+				Usage:  "生成用于加密快照的密钥对",
+				Action: keygenAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "私钥文件路径 (不指定则输出到终端)",
+					},
+					&cli.BoolFlag{
+						Name:  "sign",
+						Usage: "生成用于签名快照校验文件的密钥对，而不是加密密钥对",
+					},
+				},
+			},
+			{
+				Name:      "decrypt",
+				Usage:     "使用私钥解密快照",
+				ArgsUsage: "<加密的快照文件>",
+				Action:    decryptAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "identity",
+						Aliases:  []string{"i"},
+						Usage:    "私钥文件路径",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "解密后的文件路径 (默认去掉 .enc 后缀)",
+					},
+				},
+			},
+			{
+				Name:      "verify",
+				Usage:     "校验快照的完整性",
+				ArgsUsage: "<快照文件>",
+				Action:    verifyAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "identity",
+						Aliases: []string{"i"},
+						Usage:   "私钥文件路径，用于校验加密快照的内容",
+					},
+					&cli.StringSliceFlag{
+						Name:  "trusted-key",
+						Usage: "可信的签名公钥，可指定多次 (例如: logsnap-sig-...)",
+					},
+				},
+			},
+			{
+				Name:      "inspect",
+				Usage:     "查看快照的清单、文件和日志时间范围",
+				ArgsUsage: "<快照文件>",
+				Action:    inspectAction,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "以JSON格式输出，便于脚本处理",
+					},
+					&cli.StringFlag{
+						Name:    "identity",
+						Aliases: []string{"i"},
+						Usage:   "私钥文件路径，用于查看加密快照",
+					},
+				},
+			},
+			{
+				Name:      "analyze",
+				Usage:     "将快照中的日志按错误签名归类",
+				ArgsUsage: "<快照文件|目录>",
+				Action:    analyzeAction,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "以JSON格式输出，便于脚本处理",
+					},
+					&cli.StringFlag{
+						Name:    "level",
+						Aliases: []string{"l"},
+						Value:   "warning",
+						Usage:   "只归类不低于该级别的日志 (debug, info, warning, error, fatal)",
+					},
+					&cli.IntFlag{
+						Name:    "top",
+						Aliases: []string{"n"},
+						Value:   50,
+						Usage:   "最多显示的签名数量，0 表示全部显示",
+					},
+					&cli.StringFlag{
+						Name:    "identity",
+						Aliases: []string{"i"},
+						Usage:   "私钥文件路径，用于分析加密快照",
+					},
+				},
+			},
+			{
+				Name:      "diff",
+				Usage:     "对比基线快照和故障快照中的错误签名",
+				ArgsUsage: "<基线快照> <故障快照>",
+				Action:    diffAction,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "以JSON格式输出，便于脚本处理",
+					},
+					&cli.StringFlag{
+						Name:    "level",
+						Aliases: []string{"l"},
+						Value:   "warning",
+						Usage:   "只对比不低于该级别的日志 (debug, info, warning, error, fatal)",
+					},
+					&cli.Float64Flag{
+						Name:  "threshold",
+						Value: analyze.DefaultThreshold,
+						Usage: "每小时出现次数升高到基线的多少倍时视为频率明显升高",
+					},
+					&cli.StringFlag{
+						Name:    "identity",
+						Aliases: []string{"i"},
+						Usage:   "私钥文件路径，用于对比加密快照",
+					},
+				},
+			},
+			{
+				Name:   "tail",
+				Usage:  "实时跟踪程序的日志，日志轮转后自动切换到新文件",
+				Action: tailAction,
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Name:    "log-dir",
+						Aliases: []string{"l"},
+						Value:   "~/xyz_log",
+						Usage:   "日志目录路径 (默认: ~/xyz_log)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "program",
+						Aliases: []string{"p"},
+						Usage:   "要跟踪的程序日志，例如：xyz-studio-max, 不指定则全部)",
+					},
+					&cli.StringFlag{
+						Name:  "level",
+						Usage: "只显示不低于该级别的日志 (debug, info, warning, error, fatal)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "grep",
+						Aliases: []string{"g"},
+						Usage:   "只显示内容匹配正则表达式的日志，可指定多次 (匹配任意一个即可)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-color",
+						Usage: "不使用颜色区分日志级别和来源",
+					},
+				},
+			},
+			{
+				Name:   "watch",
+				Usage:  "持续监视程序日志，满足触发规则时自动收集快照",
+				Action: watchAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "rules",
+						Aliases: []string{"r"},
+						Usage:   "触发规则文件路径 (默认: 配置目录中的 watch.json，不存在时在程序崩溃时触发)",
+					},
+					&cli.PathFlag{
+						Name:    "log-dir",
+						Aliases: []string{"l"},
+						Value:   "~/xyz_log",
+						Usage:   "日志目录路径 (默认: ~/xyz_log)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "program",
+						Aliases: []string{"p"},
+						Usage:   "要监视和收集的程序日志，例如：xyz-studio-max, 不指定则全部)",
+					},
+					&cli.StringFlag{
+						Name:    "output-dir",
+						Aliases: []string{"o"},
+						Value:   "",
+						Usage:   "输出目录 (可选，默认为配置目录中的快照存储目录)",
+					},
+					&cli.BoolFlag{
+						Name:    "upload",
+						Aliases: []string{"u"},
+						Usage:   "是否将快照上传到云端",
+					},
+					&cli.BoolFlag{
+						Name:    "keep-local-snapshot",
+						Aliases: []string{"k"},
+						Usage:   "上传后是否保留本地日志快照",
+					},
+					&cli.StringSliceFlag{
+						Name:  "encrypt-to",
+						Usage: "使用接收者公钥加密快照，可指定多次 (例如: logsnap-pub-...)",
+					},
+					&cli.StringFlag{
+						Name:  "max-size",
+						Usage: "快照的最大大小，例如：100M",
+					},
+					&cli.StringFlag{
+						Name:  "config-dir",
+						Usage: "配置目录路径 (默认: ~/.logsnap)",
+						Value: "",
+					},
+				},
+			},
+			{
+				Name:   "schedule",
+				Usage:  "按配置中的计划定期收集快照，并清理过期的本地快照",
+				Action: scheduleAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "schedules",
+						Usage: "定期收集配置文件路径 (默认: 配置目录中的 schedule.json)",
+					},
+					&cli.PathFlag{
+						Name:    "log-dir",
+						Aliases: []string{"l"},
+						Value:   "~/xyz_log",
+						Usage:   "日志目录路径 (默认: ~/xyz_log)",
+					},
+					&cli.BoolFlag{
+						Name:  "list",
+						Usage: "列出所有计划及其下次执行时间，不执行",
+					},
+					&cli.StringFlag{
+						Name:  "run",
+						Usage: "立即执行一次指定的计划后退出",
+					},
+					&cli.StringFlag{
+						Name:  "config-dir",
+						Usage: "配置目录路径 (默认: ~/.logsnap)",
+						Value: "",
+					},
+				},
+			},
+			{
+				Name:   "upload",
+				Usage:  "查看上传队列，或重试上传之前上传失败的快照",
+				Action: uploadAction,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "retry-pending",
+						Usage: "立即重试上传队列中的所有快照，不等待重试间隔",
+					},
+					&cli.StringFlag{
+						Name:  "share-expiry",
+						Usage: "分享链接的有效期，覆盖上传配置中的 share_expiry (例如: 24h, 3d)",
+					},
+					&cli.IntFlag{
+						Name:  "share-downloads",
+						Usage: "分享链接允许下载的次数，0 表示不限制，覆盖上传配置中的 share_downloads",
+					},
+					&cli.StringFlag{
+						Name:  "share-password",
+						Usage: "分享链接的提取码，覆盖上传配置中的 share_password",
+					},
+					&cli.BoolFlag{
+						Name:  "share-preview",
+						Usage: "分享链接是否允许预览，覆盖上传配置中的 share_preview",
+					},
+					&cli.StringFlag{
+						Name:  "config-dir",
+						Usage: "配置目录路径 (默认: ~/.logsnap)",
+						Value: "",
+					},
+				},
+			},
+			{
+				Name:  "snapshots",
+				Usage: "管理本机收集的快照",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "列出快照的时间范围、程序、大小和上传状态",
+						Action: snapshotsListAction,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "以JSON格式输出，便于脚本处理",
+							},
+							&cli.StringFlag{
+								Name:  "config-dir",
+								Usage: "配置目录路径 (默认: ~/.logsnap)",
+								Value: "",
+							},
+						},
+					},
+					{
+						Name:      "show",
+						Usage:     "显示快照的详细信息",
+						ArgsUsage: "<快照名称>",
+						Action:    snapshotsShowAction,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "以JSON格式输出，便于脚本处理",
+							},
+							&cli.StringFlag{
+								Name:  "config-dir",
+								Usage: "配置目录路径 (默认: ~/.logsnap)",
+								Value: "",
+							},
+						},
+					},
+					{
+						Name:   "prune",
+						Usage:  "删除早于指定时间的快照及其本地文件",
+						Action: snapshotsPruneAction,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "older-than",
+								Usage:    "删除多久以前创建的快照，例如：7d, 12h",
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "只列出将被删除的快照，不删除",
+							},
+							&cli.StringFlag{
+								Name:  "config-dir",
+								Usage: "配置目录路径 (默认: ~/.logsnap)",
+								Value: "",
+							},
+						},
+					},
+					{
+						Name:      "open",
+						Usage:     "打开快照所在目录，本地文件已删除时打开分享链接",
+						ArgsUsage: "<快照名称>",
+						Action:    snapshotsOpenAction,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "config-dir",
+								Usage: "配置目录路径 (默认: ~/.logsnap)",
+								Value: "",
+							},
+						},
+					},
+				},
+			},
 			{
 				Name:   "supported-programs",
 				Usage:  "显示支持的程序列表",
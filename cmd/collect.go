@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"logsnap/config"
 	"logsnap/remote"
 	"logsnap/service"
+	"logsnap/store"
 	"logsnap/utils"
 
 	"github.com/sirupsen/logrus"
@@ -106,10 +108,28 @@ func collectAction(c *cli.Context) error {
 	logrus.Infof("startTime: %v", startTimeVal)
 	logrus.Infof("endTime: %v", endTimeVal)
 
-	// 设置输出目录
+	configDir, err := resolveConfigDir(c.String("config-dir"))
+	if err != nil {
+		return err
+	}
+	storeDir := store.DefaultDir(configDir)
+
+	// 设置输出目录，上传后保留的本地快照默认保存在快照存储目录中
 	outputDir := c.String("output-dir")
 	if outputDir == "" {
 		outputDir = "."
+		if c.Bool("upload") && c.Bool("keep-local-snapshot") {
+			outputDir = storeDir
+		}
+	}
+
+	maxVolumeSize, err := parseSizeArg(c.String("max-volume-size"))
+	if err != nil {
+		return err
+	}
+	maxSize, err := parseSizeArg(c.String("max-size"))
+	if err != nil {
+		return err
 	}
 
 	// 创建配置对象
@@ -120,9 +140,19 @@ func collectAction(c *cli.Context) error {
 		KeepLocalSnap:    c.Bool("keep-local-snapshot"),
 		OutputDir:        outputDir,
 		SkipVersionCheck: c.Bool("skip-version-check"),
-		ConfigDir:        c.String("config-dir"),
+		ConfigDir:        configDir,
 		LogRootDir:       c.String("log-dir"),
 		Programs:         c.StringSlice("program"),
+		MaxVolumeSize:    maxVolumeSize,
+		MaxSize:          maxSize,
+		Timeline:         c.Bool("timeline"),
+		NoReport:         c.Bool("no-report"),
+		Level:            c.String("level"),
+		Grep:             c.StringSlice("grep"),
+		EncryptTo:        c.StringSlice("encrypt-to"),
+		SigningKeyPath:   c.String("sign-key"),
+		StoreDir:         storeDir,
+		Source:           "collect",
 	}
 
 	// 如果指定了程序，记录日志
@@ -137,11 +167,11 @@ func collectAction(c *cli.Context) error {
 	remoteConfig := remote.NewConfigManager(localConfig)
 
 	// 简单模式，使用直接调用方式
-	return runInSimpleMode(&serviceConfig, remoteConfig)
+	return runInSimpleMode(&serviceConfig, remoteConfig, shareOptionsFromFlags(c))
 }
 
 // runInSimpleMode 在简单模式下执行收集操作
-func runInSimpleMode(config *service.Config, remoteConfig *remote.ConfigManager) error {
+func runInSimpleMode(config *service.Config, remoteConfig *remote.ConfigManager, share shareOptions) error {
 	// 检查版本更新（如果未跳过版本检查）
 	if !config.SkipVersionCheck {
 		hasUpdate, latestVersion, downloadURL, forceUpdate, updateMessage, err := remoteConfig.CheckForUpdates()
@@ -190,7 +220,18 @@ func runInSimpleMode(config *service.Config, remoteConfig *remote.ConfigManager)
 		return fmt.Errorf("获取上传配置失败: %v", err)
 	}
 
+	share.apply(uploadConfig)
+
 	snapPath, uploadURL, err := service.CollectAndUploadLogs(config, uploadConfig)
+	// 上次运行时未上传成功的快照在本次运行时继续上传，未指定上传时不访问网络
+	if config.ShouldUpload {
+		defer retryPendingUploads(config.ConfigDir, uploadConfig)
+	}
+	if errors.Is(err, service.ErrUploadQueued) {
+		logrus.Warnf("%v", err)
+		logrus.Warnf("快照已保存至: %s，将在下次运行时自动重试上传，也可以执行 logsnap upload --retry-pending 立即重试", snapPath)
+		return nil
+	}
 	if err != nil {
 		// 特殊处理需要重启的错误
 		if strings.Contains(err.Error(), "程序已更新到最新版本") {
@@ -207,7 +248,9 @@ func runInSimpleMode(config *service.Config, remoteConfig *remote.ConfigManager)
 		if !config.KeepLocalSnap {
 			os.RemoveAll(snapPath)
 		}
-		logrus.Infof("日志已上传至: %s", uploadURL)
+		for _, url := range strings.Split(uploadURL, "\n") {
+			logrus.Infof("日志已上传至: %s", url)
+		}
 	}
 
 	return nil
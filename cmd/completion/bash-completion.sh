@@ -8,7 +8,7 @@ _logsnap_completion() {
   
   # 完成 logsnap 命令的补全
   if [[ ${COMP_CWORD} -eq 1 ]]; then
-    opts="collect update version supported-programs completion help"
+    opts="collect update version supported-programs keygen decrypt verify inspect analyze diff tail watch schedule snapshots upload completion help"
     COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
     return 0
   fi
@@ -26,7 +26,7 @@ _logsnap_completion() {
         COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
         return 0
       else
-        opts="--time -t --start-time -s --end-time -e --log-dir -l --upload -u --keep-local-snapshot -k --output-dir -o --program -p --today --yesterday --this-week --skip-version-check --config-dir --simple --interactive -I"
+        opts="--time -t --start-time -s --end-time -e --log-dir -l --upload -u --keep-local-snapshot -k --output-dir -o --max-volume-size --program -p --today --yesterday --this-week --skip-version-check --config-dir --simple --interactive -I --encrypt-to --sign-key --max-size --timeline --no-report --level --grep -g --share-expiry --share-downloads --share-password --share-preview"
         COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
       fi
       ;;
@@ -38,6 +38,50 @@ _logsnap_completion() {
       opts="--simple -s"
       COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
       ;;
+    keygen)
+      opts="--output -o --sign"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    decrypt)
+      opts="--identity -i --output -o"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    verify)
+      opts="--identity -i --trusted-key"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    inspect)
+      opts="--json --identity -i"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    analyze)
+      opts="--json --level -l --top -n --identity -i"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    diff)
+      opts="--json --level -l --threshold --identity -i"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    tail)
+      opts="--log-dir -l --program -p --level --grep -g --no-color"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    watch)
+      opts="--rules -r --log-dir -l --program -p --output-dir -o --upload -u --keep-local-snapshot -k --encrypt-to --max-size --config-dir"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    schedule)
+      opts="--schedules --log-dir -l --list --run --config-dir"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    snapshots)
+      opts="list show prune open --json --older-than --dry-run --config-dir"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
+    upload)
+      opts="--retry-pending --config-dir --share-expiry --share-downloads --share-password --share-preview"
+      COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+      ;;
     completion)
       opts="bash zsh fish powershell install"
       COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
@@ -13,6 +13,17 @@ complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'collect' -d '收集
 complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'update' -d '检查并更新程序到最新版本'
 complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'supported-programs' -d '显示支持的程序列表'
 complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'version' -d '显示当前版本信息'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'keygen' -d '生成用于加密快照的密钥对'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'decrypt' -d '使用私钥解密快照'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'verify' -d '校验快照的完整性'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'inspect' -d '查看快照的清单、文件和日志时间范围'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'analyze' -d '将快照中的日志按错误签名归类'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'diff' -d '对比基线快照和故障快照中的错误签名'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'tail' -d '实时跟踪程序的日志'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'watch' -d '持续监视程序日志并自动收集快照'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'schedule' -d '按计划定期收集快照'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'snapshots' -d '管理本机收集的快照'
+complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'upload' -d '查看或重试上传队列'
 complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'completion' -d '生成自动补全脚本'
 complete -f -c logsnap -n '__fish_logsnap_no_subcommand' -a 'help' -d '显示帮助信息'
 
@@ -24,6 +35,7 @@ complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'log-dir' -s
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'upload' -s 'u' -d '是否上传到云端'
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'keep-local-snapshot' -s 'k' -d '是否保留本地日志快照'
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'output-dir' -s 'o' -d '输出目录'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'max-volume-size' -d '单个快照分卷的最大大小'
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'program' -s 'p' -d '要收集的程序日志'
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'today' -d '收集今天的日志'
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'yesterday' -d '收集昨天的日志'
@@ -32,6 +44,17 @@ complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'skip-version
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'config-dir' -d '配置目录路径'
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'simple' -d '使用简单模式，不显示终端动画'
 complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'interactive' -s 'I' -d '启用交互模式，通过UI配置选项'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'encrypt-to' -d '使用接收者公钥加密快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'sign-key' -d '使用签名私钥对快照校验文件签名'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'max-size' -d '快照的最大大小'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'timeline' -d '生成按时间合并的日志时间线'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'no-report' -d '不生成摘要报告'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'level' -d '只收集不低于该级别的日志'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'grep' -s 'g' -d '只收集内容匹配正则表达式的日志'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'share-expiry' -d '分享链接的有效期'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'share-downloads' -d '分享链接允许下载的次数'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'share-password' -d '分享链接的提取码'
+complete -f -c logsnap -n '__fish_seen_subcommand_from collect' -l 'share-preview' -d '分享链接是否允许预览'
 
 # update 子命令补全
 complete -f -c logsnap -n '__fish_seen_subcommand_from update' -l 'force' -s 'f' -d '强制更新，不询问确认'
@@ -41,6 +64,77 @@ complete -f -c logsnap -n '__fish_seen_subcommand_from update' -l 'config-dir' -
 # version 子命令补全
 complete -f -c logsnap -n '__fish_seen_subcommand_from version' -l 'simple' -s 's' -d '使用简单模式显示版本信息，不使用TUI界面'
 
+# keygen 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from keygen' -l 'output' -s 'o' -d '私钥文件路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from keygen' -l 'sign' -d '生成签名密钥对'
+
+# decrypt 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from decrypt' -l 'identity' -s 'i' -d '私钥文件路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from decrypt' -l 'output' -s 'o' -d '解密后的文件路径'
+
+# verify 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from verify' -l 'identity' -s 'i' -d '私钥文件路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from verify' -l 'trusted-key' -d '可信的签名公钥'
+
+# inspect 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from inspect' -l 'json' -d '以JSON格式输出'
+complete -f -c logsnap -n '__fish_seen_subcommand_from inspect' -l 'identity' -s 'i' -d '私钥文件路径'
+
+# analyze 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from analyze' -l 'json' -d '以JSON格式输出'
+complete -f -c logsnap -n '__fish_seen_subcommand_from analyze' -l 'level' -s 'l' -d '最低日志级别'
+complete -f -c logsnap -n '__fish_seen_subcommand_from analyze' -l 'top' -s 'n' -d '最多显示的签名数量'
+complete -f -c logsnap -n '__fish_seen_subcommand_from analyze' -l 'identity' -s 'i' -d '私钥文件路径'
+
+# diff 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from diff' -l 'json' -d '以JSON格式输出'
+complete -f -c logsnap -n '__fish_seen_subcommand_from diff' -l 'level' -s 'l' -d '最低日志级别'
+complete -f -c logsnap -n '__fish_seen_subcommand_from diff' -l 'threshold' -d '频率升高的倍数阈值'
+complete -f -c logsnap -n '__fish_seen_subcommand_from diff' -l 'identity' -s 'i' -d '私钥文件路径'
+
+# tail 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from tail' -l 'log-dir' -s 'l' -d '日志目录路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from tail' -l 'program' -s 'p' -d '要跟踪的程序日志'
+complete -f -c logsnap -n '__fish_seen_subcommand_from tail' -l 'level' -d '只显示不低于该级别的日志'
+complete -f -c logsnap -n '__fish_seen_subcommand_from tail' -l 'grep' -s 'g' -d '只显示内容匹配正则表达式的日志'
+complete -f -c logsnap -n '__fish_seen_subcommand_from tail' -l 'no-color' -d '不使用颜色'
+
+# watch 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'rules' -s 'r' -d '触发规则文件路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'log-dir' -s 'l' -d '日志目录路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'program' -s 'p' -d '要监视的程序日志'
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'output-dir' -s 'o' -d '输出目录'
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'upload' -s 'u' -d '上传快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'keep-local-snapshot' -s 'k' -d '保留本地快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'encrypt-to' -d '使用接收者公钥加密快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'max-size' -d '快照的最大大小'
+complete -f -c logsnap -n '__fish_seen_subcommand_from watch' -l 'config-dir' -d '配置目录路径'
+
+# schedule 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from schedule' -l 'schedules' -d '定期收集配置文件路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from schedule' -l 'log-dir' -s 'l' -d '日志目录路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from schedule' -l 'list' -d '列出所有计划'
+complete -f -c logsnap -n '__fish_seen_subcommand_from schedule' -l 'run' -d '立即执行一次指定的计划'
+complete -f -c logsnap -n '__fish_seen_subcommand_from schedule' -l 'config-dir' -d '配置目录路径'
+
+# snapshots 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from snapshots' -a 'list' -d '列出快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from snapshots' -a 'show' -d '显示快照的详细信息'
+complete -f -c logsnap -n '__fish_seen_subcommand_from snapshots' -a 'prune' -d '删除早于指定时间的快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from snapshots' -a 'open' -d '打开快照所在目录或分享链接'
+complete -f -c logsnap -n '__fish_seen_subcommand_from snapshots' -l 'json' -d '以JSON格式输出'
+complete -f -c logsnap -n '__fish_seen_subcommand_from snapshots' -l 'older-than' -d '删除多久以前创建的快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from snapshots' -l 'dry-run' -d '只列出将被删除的快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from snapshots' -l 'config-dir' -d '配置目录路径'
+
+# upload 子命令补全
+complete -f -c logsnap -n '__fish_seen_subcommand_from upload' -l 'retry-pending' -d '立即重试上传队列中的所有快照'
+complete -f -c logsnap -n '__fish_seen_subcommand_from upload' -l 'config-dir' -d '配置目录路径'
+complete -f -c logsnap -n '__fish_seen_subcommand_from upload' -l 'share-expiry' -d '分享链接的有效期'
+complete -f -c logsnap -n '__fish_seen_subcommand_from upload' -l 'share-downloads' -d '分享链接允许下载的次数'
+complete -f -c logsnap -n '__fish_seen_subcommand_from upload' -l 'share-password' -d '分享链接的提取码'
+complete -f -c logsnap -n '__fish_seen_subcommand_from upload' -l 'share-preview' -d '分享链接是否允许预览'
+
 # completion 子命令补全
 complete -f -c logsnap -n '__fish_seen_subcommand_from completion' -a 'bash' -d '生成 Bash 自动补全脚本'
 complete -f -c logsnap -n '__fish_seen_subcommand_from completion' -a 'zsh' -d '生成 Zsh 自动补全脚本'
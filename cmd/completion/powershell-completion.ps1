@@ -8,6 +8,17 @@ Register-ArgumentCompleter -Native -CommandName logsnap -ScriptBlock {
         'update' = '检查并更新程序到最新版本'
         'supported-programs' = '显示支持的程序列表'
         'version' = '显示当前版本信息'
+        'keygen' = '生成用于加密快照的密钥对'
+        'decrypt' = '使用私钥解密快照'
+        'verify' = '校验快照的完整性'
+        'inspect' = '查看快照的清单、文件和日志时间范围'
+        'analyze' = '将快照中的日志按错误签名归类'
+        'diff' = '对比基线快照和故障快照中的错误签名'
+        'tail' = '实时跟踪程序的日志'
+        'watch' = '持续监视程序日志并自动收集快照'
+        'schedule' = '按计划定期收集快照'
+        'snapshots' = '管理本机收集的快照'
+        'upload' = '查看或重试上传队列'
         'completion' = '生成自动补全脚本'
         'help' = '显示帮助信息'
     }
@@ -20,6 +31,7 @@ Register-ArgumentCompleter -Native -CommandName logsnap -ScriptBlock {
         '--upload', '-u'
         '--keep-local-snapshot', '-k'
         '--output-dir', '-o'
+        '--max-volume-size'
         '--program', '-p'
         '--today'
         '--yesterday'
@@ -28,6 +40,17 @@ Register-ArgumentCompleter -Native -CommandName logsnap -ScriptBlock {
         '--config-dir'
         '--simple'
         '--interactive', '-I'
+        '--encrypt-to'
+        '--sign-key'
+        '--max-size'
+        '--timeline'
+        '--no-report'
+        '--level'
+        '--grep', '-g'
+        '--share-expiry'
+        '--share-downloads'
+        '--share-password'
+        '--share-preview'
     )
     
     $updateOpts = @(
@@ -40,6 +63,88 @@ Register-ArgumentCompleter -Native -CommandName logsnap -ScriptBlock {
         '--simple', '-s'
     )
     
+    $keygenOpts = @(
+        '--output', '-o'
+        '--sign'
+    )
+    
+    $decryptOpts = @(
+        '--identity', '-i'
+        '--output', '-o'
+    )
+    
+    $verifyOpts = @(
+        '--identity', '-i'
+        '--trusted-key'
+    )
+    
+    $inspectOpts = @(
+        '--json'
+        '--identity', '-i'
+    )
+    
+    $analyzeOpts = @(
+        '--json'
+        '--level', '-l'
+        '--top', '-n'
+        '--identity', '-i'
+    )
+    
+    $diffOpts = @(
+        '--json'
+        '--level', '-l'
+        '--threshold'
+        '--identity', '-i'
+    )
+    
+    $tailOpts = @(
+        '--log-dir', '-l'
+        '--program', '-p'
+        '--level'
+        '--grep', '-g'
+        '--no-color'
+    )
+    
+    $watchOpts = @(
+        '--rules', '-r'
+        '--log-dir', '-l'
+        '--program', '-p'
+        '--output-dir', '-o'
+        '--upload', '-u'
+        '--keep-local-snapshot', '-k'
+        '--encrypt-to'
+        '--max-size'
+        '--config-dir'
+    )
+    
+    $scheduleOpts = @(
+        '--schedules'
+        '--log-dir', '-l'
+        '--list'
+        '--run'
+        '--config-dir'
+    )
+    
+    $snapshotsOpts = @(
+        'list'
+        'show'
+        'prune'
+        'open'
+        '--json'
+        '--older-than'
+        '--dry-run'
+        '--config-dir'
+    )
+    
+    $uploadOpts = @(
+        '--retry-pending'
+        '--config-dir'
+        '--share-expiry'
+        '--share-downloads'
+        '--share-password'
+        '--share-preview'
+    )
+    
     $completionOpts = @(
         'bash'
         'zsh'
@@ -81,6 +186,61 @@ Register-ArgumentCompleter -Native -CommandName logsnap -ScriptBlock {
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
             }
         }
+        'keygen' {
+            return $keygenOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'decrypt' {
+            return $decryptOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'verify' {
+            return $verifyOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'inspect' {
+            return $inspectOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'analyze' {
+            return $analyzeOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'diff' {
+            return $diffOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'tail' {
+            return $tailOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'watch' {
+            return $watchOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'schedule' {
+            return $scheduleOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'snapshots' {
+            return $snapshotsOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
+        'upload' {
+            return $uploadOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+            }
+        }
         'completion' {
             return $completionOpts | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
                 [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
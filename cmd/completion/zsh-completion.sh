@@ -9,6 +9,17 @@ _logsnap_commands() {
     'update:检查并更新程序到最新版本'
     'supported-programs:显示支持的程序列表'
     'version:显示当前版本信息'
+    'keygen:生成用于加密快照的密钥对'
+    'decrypt:使用私钥解密快照'
+    'verify:校验快照的完整性'
+    'inspect:查看快照的清单、文件和日志时间范围'
+    'analyze:将快照中的日志按错误签名归类'
+    'diff:对比基线快照和故障快照中的错误签名'
+    'tail:实时跟踪程序的日志'
+    'watch:持续监视程序日志并自动收集快照'
+    'schedule:按计划定期收集快照'
+    'snapshots:管理本机收集的快照'
+    'upload:查看或重试上传队列'
     'completion:生成自动补全脚本'
     'help:显示帮助信息'
   )
@@ -32,6 +43,7 @@ _logsnap_collect_options() {
     '-k[是否保留本地日志快照]'
     '--output-dir[输出目录]:输出目录:_files -/'
     '-o[输出目录]:输出目录:_files -/'
+    '--max-volume-size[单个快照分卷的最大大小]:大小:'
     '--program[要收集的程序日志]:程序:(xyz-hmi xyz-bin-packing xyz-max-hmi-server xyz-studio-max)'
     '-p[要收集的程序日志]:程序:(xyz-hmi xyz-bin-packing xyz-max-hmi-server xyz-studio-max)'
     '--today[收集今天的日志]'
@@ -42,6 +54,18 @@ _logsnap_collect_options() {
     '--simple[使用简单模式，不显示终端动画]'
     '--interactive[启用交互模式，通过UI配置选项]'
     '-I[启用交互模式，通过UI配置选项]'
+    '--encrypt-to[使用接收者公钥加密快照]'
+    '--sign-key[使用签名私钥对快照校验文件签名]'
+    '--max-size[快照的最大大小]'
+    '--timeline[生成按时间合并的日志时间线]'
+    '--no-report[不生成摘要报告]'
+    '--level[只收集不低于该级别的日志]'
+    '--grep[只收集内容匹配正则表达式的日志]'
+    '-g[只收集内容匹配正则表达式的日志]'
+    '--share-expiry[分享链接的有效期]'
+    '--share-downloads[分享链接允许下载的次数]'
+    '--share-password[分享链接的提取码]'
+    '--share-preview[分享链接是否允许预览]'
   )
   _arguments -s : $options
 }
@@ -67,6 +91,149 @@ _logsnap_version_options() {
   _arguments -s : $options
 }
 
+_logsnap_keygen_options() {
+  local -a options
+  options=(
+    '--output[私钥文件路径]'
+    '-o[私钥文件路径]'
+    '--sign[生成签名密钥对]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_decrypt_options() {
+  local -a options
+  options=(
+    '--identity[私钥文件路径]'
+    '-i[私钥文件路径]'
+    '--output[解密后的文件路径]'
+    '-o[解密后的文件路径]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_verify_options() {
+  local -a options
+  options=(
+    '--identity[私钥文件路径]'
+    '-i[私钥文件路径]'
+    '--trusted-key[可信的签名公钥]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_inspect_options() {
+  local -a options
+  options=(
+    '1:子命令:(list show prune open)'
+    '--json[以JSON格式输出]'
+    '--identity[私钥文件路径]'
+    '-i[私钥文件路径]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_analyze_options() {
+  local -a options
+  options=(
+    '--json[以JSON格式输出]'
+    '--level[最低日志级别]'
+    '-l[最低日志级别]'
+    '--top[最多显示的签名数量]'
+    '-n[最多显示的签名数量]'
+    '--identity[私钥文件路径]'
+    '-i[私钥文件路径]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_diff_options() {
+  local -a options
+  options=(
+    '--json[以JSON格式输出]'
+    '--level[最低日志级别]'
+    '-l[最低日志级别]'
+    '--threshold[频率升高的倍数阈值]'
+    '--identity[私钥文件路径]'
+    '-i[私钥文件路径]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_tail_options() {
+  local -a options
+  options=(
+    '--log-dir[日志目录路径]'
+    '-l[日志目录路径]'
+    '--program[要跟踪的程序日志]'
+    '-p[要跟踪的程序日志]'
+    '--level[只显示不低于该级别的日志]'
+    '--grep[只显示内容匹配正则表达式的日志]'
+    '-g[只显示内容匹配正则表达式的日志]'
+    '--no-color[不使用颜色]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_watch_options() {
+  local -a options
+  options=(
+    '--rules[触发规则文件路径]'
+    '-r[触发规则文件路径]'
+    '--log-dir[日志目录路径]'
+    '-l[日志目录路径]'
+    '--program[要监视的程序日志]'
+    '-p[要监视的程序日志]'
+    '--output-dir[输出目录]'
+    '-o[输出目录]'
+    '--upload[上传快照]'
+    '-u[上传快照]'
+    '--keep-local-snapshot[保留本地快照]'
+    '-k[保留本地快照]'
+    '--encrypt-to[使用接收者公钥加密快照]'
+    '--max-size[快照的最大大小]'
+    '--config-dir[配置目录路径]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_schedule_options() {
+  local -a options
+  options=(
+    '--schedules[定期收集配置文件路径]'
+    '--log-dir[日志目录路径]'
+    '-l[日志目录路径]'
+    '--list[列出所有计划]'
+    '--run[立即执行一次指定的计划]'
+    '--config-dir[配置目录路径]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_snapshots_options() {
+  local -a options
+  options=(
+    '--json[以JSON格式输出]'
+    '--older-than[删除多久以前创建的快照]'
+    '--dry-run[只列出将被删除的快照]'
+    '--config-dir[配置目录路径]'
+  )
+  _arguments -s : $options
+}
+
+_logsnap_upload_options() {
+  local -a options
+  options=(
+    '--retry-pending[立即重试上传队列中的所有快照]'
+    '--config-dir[配置目录路径]'
+    '--share-expiry[分享链接的有效期]'
+    '--share-downloads[分享链接允许下载的次数]'
+    '--share-password[分享链接的提取码]'
+    '--share-preview[分享链接是否允许预览]'
+  )
+  _arguments -s : $options
+}
+
 _logsnap_completion_options() {
   local -a options
   options=(
@@ -102,6 +269,39 @@ _logsnap() {
         version)
           _logsnap_version_options
           ;;
+        keygen)
+          _logsnap_keygen_options
+          ;;
+        decrypt)
+          _logsnap_decrypt_options
+          ;;
+        verify)
+          _logsnap_verify_options
+          ;;
+        inspect)
+          _logsnap_inspect_options
+          ;;
+        analyze)
+          _logsnap_analyze_options
+          ;;
+        diff)
+          _logsnap_diff_options
+          ;;
+        tail)
+          _logsnap_tail_options
+          ;;
+        watch)
+          _logsnap_watch_options
+          ;;
+        schedule)
+          _logsnap_schedule_options
+          ;;
+        snapshots)
+          _logsnap_snapshots_options
+          ;;
+        upload)
+          _logsnap_upload_options
+          ;;
         completion)
           _logsnap_completion_options
           ;;
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"logsnap/encryption"
+	"logsnap/snapshot"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// keygenAction 处理keygen命令，生成加密快照或签名校验文件使用的密钥对
+func keygenAction(c *cli.Context) error {
+	var publicKey, privateKey string
+	if c.Bool("sign") {
+		key, err := snapshot.GenerateSigningKey()
+		if err != nil {
+			return err
+		}
+		publicKey, privateKey = snapshot.EncodeVerifyKey(key), snapshot.EncodeSigningKey(key)
+	} else {
+		identity, err := encryption.GenerateIdentity()
+		if err != nil {
+			return err
+		}
+		publicKey, privateKey = identity.Recipient().String(), identity.String()
+	}
+
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339), publicKey, privateKey)
+
+	output := c.String("output")
+	if output == "" {
+		fmt.Print(content)
+		return nil
+	}
+
+	// 私钥文件只允许当前用户读写，且不覆盖已有文件
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("创建私钥文件失败: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		return fmt.Errorf("写入私钥文件失败: %w", err)
+	}
+
+	fmt.Printf("私钥已保存至: %s\n", output)
+	fmt.Printf("公钥: %s\n", publicKey)
+	return nil
+}
+
+// decryptAction 处理decrypt命令，使用私钥解密快照
+func decryptAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("请指定要解密的快照文件")
+	}
+	input := c.Args().First()
+
+	identity, err := encryption.LoadIdentity(c.String("identity"))
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	if output == "" {
+		if !strings.HasSuffix(input, encryption.FileExtension) {
+			return fmt.Errorf("无法确定输出文件名，请使用 --output 指定")
+		}
+		output = strings.TrimSuffix(input, encryption.FileExtension)
+	}
+	if _, err := os.Stat(output); err == nil {
+		return fmt.Errorf("输出文件已存在: %s", output)
+	}
+
+	if err := encryption.DecryptFile(input, output, identity); err != nil {
+		return err
+	}
+
+	logrus.Infof("快照已解密至: %s", output)
+	return nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"logsnap/analyze"
+	"logsnap/encryption"
+	"logsnap/logentry"
+
+	"github.com/urfave/cli/v2"
+)
+
+// diffAction 处理diff命令，对比基线快照和故障快照中的错误签名
+func diffAction(c *cli.Context) error {
+	if c.Args().Len() != 2 {
+		return fmt.Errorf("请指定基线快照和故障快照")
+	}
+
+	opts := analyze.DiffOptions{Threshold: c.Float64("threshold")}
+	opts.MinSeverity = logentry.ParseSeverity(c.String("level"))
+	if opts.MinSeverity == logentry.SeverityUnknown {
+		return fmt.Errorf("无效的日志级别: %s, 可选 debug, info, warning, error, fatal", c.String("level"))
+	}
+	if identityPath := c.String("identity"); identityPath != "" {
+		identity, err := encryption.LoadIdentity(identityPath)
+		if err != nil {
+			return err
+		}
+		opts.Identity = identity
+	}
+	processors, err := supportedProcessors()
+	if err != nil {
+		return err
+	}
+	opts.Processors = processors
+
+	result, err := analyze.Diff(c.Args().Get(0), c.Args().Get(1), opts)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	printDiff(result)
+	return nil
+}
+
+// diffKindNames 变化类型的显示名称
+var diffKindNames = map[string]string{
+	analyze.ChangeNew:       "新出现",
+	analyze.ChangeIncreased: "频率升高",
+	analyze.ChangeGone:      "已消失",
+}
+
+// printDiff 以表格形式显示签名的变化
+func printDiff(result *analyze.DiffResult) {
+	fmt.Printf("基线: %s (日志覆盖 %s)\n", result.Baseline, formatHours(result.BaselineSpan))
+	fmt.Printf("故障: %s (日志覆盖 %s)\n", result.Incident, formatHours(result.IncidentSpan))
+	if len(result.Changes) == 0 {
+		fmt.Println("\n没有新出现、消失或频率明显升高的签名")
+		return
+	}
+
+	fmt.Println()
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "变化\t级别\t基线次数\t故障次数\t频率(次/小时)\t程序\t签名")
+	for _, change := range result.Changes {
+		rate := fmt.Sprintf("%.1f → %.1f", change.BaselineRate, change.IncidentRate)
+		if change.Kind == analyze.ChangeIncreased {
+			rate += fmt.Sprintf(" (×%.1f)", change.Ratio)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", diffKindNames[change.Kind], change.Level,
+			change.BaselineCount, change.IncidentCount, rate, strings.Join(change.Programs, ","), change.Template)
+	}
+	table.Flush()
+}
+
+// formatHours 将小时数格式化为时间长度
+func formatHours(hours float64) string {
+	return time.Duration(hours * float64(time.Hour)).Round(time.Second).String()
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"logsnap/encryption"
+	"logsnap/snapshot"
+
+	"github.com/urfave/cli/v2"
+)
+
+// inspectTimeLayout 查看快照时显示的时间格式
+const inspectTimeLayout = "2006-01-02 15:04:05"
+
+// inspectAction 处理inspect命令，显示快照的清单、文件和日志时间覆盖范围
+func inspectAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("请指定要查看的快照文件")
+	}
+
+	var opts snapshot.InspectOptions
+	if identityPath := c.String("identity"); identityPath != "" {
+		identity, err := encryption.LoadIdentity(identityPath)
+		if err != nil {
+			return err
+		}
+		opts.Identity = identity
+	}
+
+	inspection, err := snapshot.Inspect(c.Args().First(), opts)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(inspection)
+	}
+	printInspection(inspection)
+	return nil
+}
+
+// printInspection 以文本形式显示快照概览和每个程序的文件树
+func printInspection(inspection *snapshot.Inspection) {
+	format := inspection.Format
+	if inspection.Encrypted {
+		format += ", 已加密"
+	}
+	if len(inspection.Volumes) > 1 {
+		format += fmt.Sprintf(", %d 个分卷", len(inspection.Volumes))
+	}
+	fmt.Printf("快照: %s (%s)\n", filepath.Base(inspection.Path), format)
+
+	trimmed := make(map[string]string)
+	if manifest := inspection.Manifest; manifest != nil {
+		fmt.Printf("logsnap 版本: %s\n", manifest.Version)
+		fmt.Printf("主机: %s\n", manifest.Hostname)
+		fmt.Printf("创建时间: %s\n", manifest.CreatedAt.Local().Format(inspectTimeLayout))
+		fmt.Printf("采集范围: %s ~ %s\n", manifest.StartTime.Local().Format(inspectTimeLayout),
+			manifest.EndTime.Local().Format(inspectTimeLayout))
+		fmt.Printf("程序: %s\n", strings.Join(manifest.Programs, ", "))
+		if manifest.MaxSize > 0 {
+			fmt.Printf("大小上限: %s, %d 个文件被裁剪\n", formatSize(uint64(manifest.MaxSize)), len(manifest.Trimmed))
+		}
+		for _, file := range manifest.Trimmed {
+			trimmed[file.Name[strings.Index(file.Name, "/")+1:]] = file.Action
+		}
+	} else {
+		fmt.Println("快照中没有清单，可能由旧版本的 logsnap 生成")
+	}
+
+	for _, program := range inspection.Programs {
+		name := program.Name
+		if name == "" {
+			name = "(快照)"
+		}
+		fmt.Printf("\n%s  %d 个文件, %s, %d 行, 日志时间 %s\n", name, len(program.Files),
+			formatSize(program.Size), program.Lines, formatCoverage(program.First, program.Last))
+
+		root := &inspectNode{}
+		for i := range program.Files {
+			root.add(strings.Split(program.Files[i].Name, "/"), &program.Files[i])
+		}
+		root.print("", trimmed)
+	}
+
+	var dropped []string
+	for name, action := range trimmed {
+		if action == snapshot.TrimDropped {
+			dropped = append(dropped, name)
+		}
+	}
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		fmt.Println("\n因超出大小上限被丢弃的文件:")
+		for _, name := range dropped {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+}
+
+// formatCoverage 格式化日志时间覆盖范围
+func formatCoverage(first, last *time.Time) string {
+	if first == nil || last == nil {
+		return "-"
+	}
+	return first.Local().Format(inspectTimeLayout) + " ~ " + last.Local().Format(inspectTimeLayout)
+}
+
+// inspectNode 文件树中的目录或文件
+type inspectNode struct {
+	name     string
+	file     *snapshot.InspectedFile
+	children []*inspectNode
+}
+
+// add 按路径将文件加入树中
+func (n *inspectNode) add(parts []string, file *snapshot.InspectedFile) {
+	if len(parts) == 1 {
+		n.children = append(n.children, &inspectNode{name: parts[0], file: file})
+		return
+	}
+	for _, child := range n.children {
+		if child.file == nil && child.name == parts[0] {
+			child.add(parts[1:], file)
+			return
+		}
+	}
+	child := &inspectNode{name: parts[0]}
+	n.children = append(n.children, child)
+	child.add(parts[1:], file)
+}
+
+// print 显示子节点，prefix 为当前层级的缩进
+func (n *inspectNode) print(prefix string, trimmed map[string]string) {
+	for i, child := range n.children {
+		branch, indent := "├── ", "│   "
+		if i == len(n.children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if child.file == nil {
+			fmt.Printf("%s%s%s/\n", prefix, branch, child.name)
+			child.print(prefix+indent, trimmed)
+			continue
+		}
+
+		file := child.file
+		note := ""
+		if trimmed[file.Name] == snapshot.TrimTruncated {
+			note = " (已截断)"
+		}
+		if file.Volume > 0 {
+			note += fmt.Sprintf(" [分卷 %d]", file.Volume)
+		}
+		fmt.Printf("%s%s%s  %s, %d 行, %s%s\n", prefix, branch, child.name,
+			formatSize(file.Size), file.Lines, formatCoverage(file.First, file.Last), note)
+	}
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"logsnap/config"
+	"logsnap/remote"
+	"logsnap/schedule"
+	"logsnap/service"
+	"logsnap/store"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// scheduleAction 处理schedule命令，按配置中的计划定期收集快照
+func scheduleAction(c *cli.Context) error {
+	configDir, err := resolveConfigDir(c.String("config-dir"))
+	if err != nil {
+		return err
+	}
+	path := c.String("schedules")
+	if path == "" {
+		path = filepath.Join(configDir, "schedule.json")
+	}
+	scheduleConfig, err := schedule.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	remoteConfig := remote.NewConfigManager(config.NewLocalConfig())
+	scheduler, err := schedule.New(scheduleConfig, store.DefaultDir(configDir),
+		func(ctx context.Context, job *schedule.Job, start, end time.Time) error {
+			return collectUnattended(remoteConfig, &service.Config{
+				StartTime:        &start,
+				EndTime:          &end,
+				ShouldUpload:     job.Schedule.Upload,
+				KeepLocalSnap:    job.Schedule.KeepLocalSnapshot,
+				OutputDir:        job.OutputDir,
+				SkipVersionCheck: true,
+				ConfigDir:        configDir,
+				LogRootDir:       c.String("log-dir"),
+				Programs:         job.Schedule.Programs,
+				MaxSize:          job.MaxSize,
+				Timeline:         job.Schedule.Timeline,
+				Level:            job.Schedule.Level,
+				Grep:             job.Schedule.Grep,
+				StoreDir:         store.DefaultDir(configDir),
+				Source:           "schedule:" + job.Name,
+			})
+		})
+	if err != nil {
+		return err
+	}
+	scheduler.SetStateDirs(configDir, store.DefaultDir(configDir))
+
+	if c.Bool("list") {
+		printSchedules(scheduler.Jobs())
+		return nil
+	}
+	if name := c.String("run"); name != "" {
+		for _, job := range scheduler.Jobs() {
+			if job.Name == name {
+				scheduler.Execute(context.Background(), job, time.Now())
+				return nil
+			}
+		}
+		return fmt.Errorf("未找到计划: %s", name)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go runUploadQueue(ctx, remoteConfig, configDir)
+	logrus.Infof("开始执行 %s 中的 %d 个定期收集计划", path, len(scheduler.Jobs()))
+	return scheduler.Run(ctx)
+}
+
+// printSchedules 以表格形式显示计划及其下次执行时间
+func printSchedules(jobs []*schedule.Job) {
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "计划\tcron\t范围\t下次执行\t输出目录")
+	now := time.Now()
+	for _, job := range jobs {
+		next := "-"
+		if t := job.Cron.Next(now); !t.IsZero() {
+			next = t.Format(inspectTimeLayout)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", job.Name, job.Cron, job.Schedule.Range, next, job.OutputDir)
+	}
+	table.Flush()
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"logsnap/remote"
+	"logsnap/store"
+	"logsnap/utils"
+
+	"github.com/urfave/cli/v2"
+)
+
+// openStore 打开配置目录中的快照存储
+func openStore(c *cli.Context) (*store.Store, error) {
+	configDir, err := resolveConfigDir(c.String("config-dir"))
+	if err != nil {
+		return nil, err
+	}
+	return store.Open(store.DefaultDir(configDir))
+}
+
+// snapshotsListAction 处理snapshots list命令，列出存储中的快照
+func snapshotsListAction(c *cli.Context) error {
+	s, err := openStore(c)
+	if err != nil {
+		return err
+	}
+	entries, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Println("没有记录任何快照")
+		return nil
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "快照\t时间范围\t程序\t大小\t本地\t上传\t链接")
+	for _, entry := range entries {
+		link := "-"
+		if len(entry.Upload.URLs) > 0 {
+			link = entry.Upload.URLs[0]
+			if len(entry.Upload.URLs) > 1 {
+				link += fmt.Sprintf(" (共 %d 个)", len(entry.Upload.URLs))
+			}
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.ID, formatWindow(entry.Start, entry.End),
+			formatPrograms(entry.Programs), formatSize(uint64(entry.Size)), localStatus(&entry),
+			uploadStatus(entry.Upload), link)
+	}
+	return table.Flush()
+}
+
+// snapshotsShowAction 处理snapshots show命令，显示快照的详细信息
+func snapshotsShowAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("请指定快照名称")
+	}
+	s, err := openStore(c)
+	if err != nil {
+		return err
+	}
+	entry, err := s.Get(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entry)
+	}
+
+	fmt.Printf("快照: %s\n", entry.ID)
+	fmt.Printf("时间范围: %s\n", formatWindow(entry.Start, entry.End))
+	fmt.Printf("程序: %s\n", strings.Join(entry.Programs, ", "))
+	fmt.Printf("大小: %s\n", formatSize(uint64(entry.Size)))
+	fmt.Printf("创建时间: %s\n", entry.CreatedAt.Format(inspectTimeLayout))
+	if entry.Source != "" {
+		fmt.Printf("来源: %s\n", entry.Source)
+	}
+	if entry.Encrypted {
+		fmt.Println("加密: 是")
+	}
+	fmt.Printf("本地: %s\n", localStatus(entry))
+	for _, file := range entry.Files {
+		fmt.Printf("  %s\n", file)
+	}
+	fmt.Printf("上传: %s\n", uploadStatus(entry.Upload))
+	if entry.Upload.UploadedAt != nil {
+		fmt.Printf("上传时间: %s\n", entry.Upload.UploadedAt.Format(inspectTimeLayout))
+	}
+	if entry.Upload.Error != "" {
+		fmt.Printf("失败原因: %s\n", remote.MaskSecrets(entry.Upload.Error))
+	}
+	for _, url := range entry.Upload.URLs {
+		fmt.Printf("  %s\n", url)
+	}
+	return nil
+}
+
+// snapshotsPruneAction 处理snapshots prune命令，删除早于指定时间的快照
+func snapshotsPruneAction(c *cli.Context) error {
+	olderThan, err := utils.ParseDuration(c.String("older-than"))
+	if err != nil {
+		return err
+	}
+	if olderThan <= 0 {
+		return fmt.Errorf("请通过 --older-than 指定要删除多久以前的快照，例如 7d")
+	}
+	s, err := openStore(c)
+	if err != nil {
+		return err
+	}
+
+	dryRun := c.Bool("dry-run")
+	pruned, err := s.Prune(time.Now().Add(-olderThan), dryRun)
+	var size int64
+	for _, entry := range pruned {
+		size += entry.Size
+		if dryRun {
+			fmt.Printf("将删除: %s\n", entry.ID)
+		} else {
+			fmt.Printf("已删除: %s\n", entry.ID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("共 %d 个快照，%s\n", len(pruned), formatSize(uint64(size)))
+	return nil
+}
+
+// snapshotsOpenAction 处理snapshots open命令，用系统默认程序打开快照所在目录，本地文件已删除时打开分享链接
+func snapshotsOpenAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("请指定快照名称")
+	}
+	s, err := openStore(c)
+	if err != nil {
+		return err
+	}
+	entry, err := s.Get(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	target := ""
+	switch {
+	case entry.Local():
+		target = filepath.Dir(entry.Path)
+	case len(entry.Upload.URLs) > 0:
+		target = entry.Upload.URLs[0]
+	default:
+		return fmt.Errorf("快照 %s 的本地文件已删除，也没有分享链接", entry.ID)
+	}
+	fmt.Println(target)
+	return openWithSystem(target)
+}
+
+// openWithSystem 使用系统默认程序打开文件、目录或链接
+func openWithSystem(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("explorer", target)
+	case "darwin":
+		cmd = exec.Command("open", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("打开 %s 失败: %w", target, err)
+	}
+	return nil
+}
+
+// formatWindow 格式化快照的时间范围，同一天时结束时间只显示时分秒
+func formatWindow(start, end time.Time) string {
+	if start.Year() == end.Year() && start.YearDay() == end.YearDay() {
+		return start.Format(inspectTimeLayout) + " ~ " + end.Format("15:04:05")
+	}
+	return start.Format(inspectTimeLayout) + " ~ " + end.Format(inspectTimeLayout)
+}
+
+// formatPrograms 格式化程序列表，较多时只显示数量
+func formatPrograms(programs []string) string {
+	if len(programs) > 2 {
+		return fmt.Sprintf("%d 个程序", len(programs))
+	}
+	return strings.Join(programs, ",")
+}
+
+// localStatus 返回快照本地文件的状态
+func localStatus(entry *store.Entry) string {
+	if entry.Local() {
+		return "是"
+	}
+	return "已删除"
+}
+
+// uploadStatus 返回上传状态的显示名称
+func uploadStatus(upload store.Upload) string {
+	switch upload.Status {
+	case store.UploadDone:
+		return "已上传"
+	case store.UploadFailed:
+		return "失败"
+	case store.UploadPending:
+		return "等待重试"
+	}
+	return "未上传"
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"logsnap/collector"
 	"logsnap/collector/factory"
 
 	"github.com/urfave/cli/v2"
@@ -15,3 +16,16 @@ func supportedProgramsAction(c *cli.Context) error {
 	}
 	return nil
 }
+
+// supportedProcessors 创建所有支持的程序的处理器，分析快照时用于识别各程序日志的时间戳格式
+func supportedProcessors() ([]collector.LogProcessor, error) {
+	var processors []collector.LogProcessor
+	for _, processorType := range factory.GetSupportedProcessorTypes() {
+		processor, err := factory.CreateProcessor(processorType, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("创建 %s 处理器失败: %w", processorType, err)
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
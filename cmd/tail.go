@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"logsnap/collector"
+	"logsnap/collector/factory"
+	"logsnap/logentry"
+	"logsnap/tail"
+
+	"github.com/urfave/cli/v2"
+)
+
+// ANSI 颜色
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorGray   = "\033[90m"
+)
+
+// sourceColors 来源标签使用的颜色，按程序顺序循环使用
+var sourceColors = []string{"\033[36m", "\033[32m", "\033[35m", "\033[34m", "\033[96m", "\033[92m"}
+
+// tailAction 处理tail命令，实时跟踪程序的日志
+func tailAction(c *cli.Context) error {
+	filter, err := logentry.NewFilter(c.String("level"), c.StringSlice("grep"))
+	if err != nil {
+		return err
+	}
+
+	sources, err := tailSources(c.String("log-dir"), c.StringSlice("program"))
+	if err != nil {
+		return err
+	}
+	colors := make(map[string]string)
+	for i, source := range sources {
+		colors[source.Name] = sourceColors[i%len(sourceColors)]
+	}
+
+	color := !c.Bool("no-color") && isTerminal(os.Stdout)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return tail.New(sources, filter).Run(ctx, func(entry tail.Entry) {
+		if !color {
+			fmt.Printf("[%s %s] %s\n", entry.Source, entry.File, entry.Text)
+			return
+		}
+		fmt.Printf("%s[%s %s]%s %s%s%s\n", colors[entry.Source], entry.Source, entry.File, colorReset,
+			severityColor(entry.Severity), entry.Text, colorReset)
+	})
+}
+
+// severityColor 返回日志级别对应的颜色，INFO 使用终端默认颜色
+func severityColor(severity logentry.Severity) string {
+	switch {
+	case severity >= logentry.SeverityError:
+		return colorRed
+	case severity == logentry.SeverityWarning:
+		return colorYellow
+	case severity == logentry.SeverityDebug:
+		return colorGray
+	}
+	return ""
+}
+
+// isTerminal 判断文件是否为终端，输出重定向到文件或管道时不使用颜色
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// tailSources 返回要跟踪的程序及其日志目录，programs 为空时返回所有支持的程序
+func tailSources(logDir string, programs []string) ([]tail.Source, error) {
+	if len(programs) == 0 {
+		for _, processorType := range factory.GetSupportedProcessorTypes() {
+			programs = append(programs, string(processorType))
+		}
+	}
+
+	var sources []tail.Source
+	for _, program := range programs {
+		processor, err := factory.CreateProcessor(collector.ProcessorType(program), logDir, "")
+		if err != nil {
+			return nil, fmt.Errorf("创建 %s 处理器失败: %w", program, err)
+		}
+		dir, err := processor.GetLogPath()
+		if err != nil {
+			return nil, fmt.Errorf("获取 %s 的日志目录失败: %w", program, err)
+		}
+		sources = append(sources, tail.Source{Name: program, Dir: dir})
+	}
+	return sources, nil
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"logsnap/config"
+	"logsnap/queue"
+	"logsnap/remote"
+	"logsnap/service"
+	"logsnap/store"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// uploadQueueInterval watch、schedule 长时间运行时检查上传队列的间隔
+const uploadQueueInterval = time.Minute
+
+// uploadAction 处理upload命令，查看上传队列或重试上传队列中的快照
+func uploadAction(c *cli.Context) error {
+	configDir, err := resolveConfigDir(c.String("config-dir"))
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("retry-pending") {
+		q, err := queue.Open(configDir)
+		if err != nil {
+			return err
+		}
+		items, err := q.List()
+		if err != nil {
+			return err
+		}
+		printUploadQueue(items)
+		return nil
+	}
+
+	remoteConfig := remote.NewConfigManager(config.NewLocalConfig())
+	uploadConfig, err := remoteConfig.GetUploadConfig()
+	if err != nil {
+		return fmt.Errorf("获取上传配置失败: %w", err)
+	}
+	shareOptionsFromFlags(c).apply(uploadConfig)
+	results, err := service.RetryPendingUploads(configDir, store.DefaultDir(configDir), uploadConfig, true)
+	if len(results) == 0 && err == nil {
+		fmt.Println("上传队列为空")
+		return nil
+	}
+
+	failed := 0
+	for _, result := range results {
+		switch {
+		case result.Err == nil:
+			fmt.Printf("%s: 上传成功\n", result.Item.ID)
+			for _, url := range result.URLs {
+				fmt.Printf("  %s\n", url)
+			}
+		case result.Dropped:
+			failed++
+			fmt.Printf("%s: %s，已从上传队列中移除\n", result.Item.ID, remote.MaskSecrets(result.Err.Error()))
+		default:
+			failed++
+			fmt.Printf("%s: 上传失败 (已尝试 %d 次): %s\n", result.Item.ID, result.Item.Attempts, remote.MaskSecrets(result.Err.Error()))
+			fmt.Printf("  下次重试: %s\n", result.Item.NextAttempt.Format(inspectTimeLayout))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个快照上传失败", failed)
+	}
+	return nil
+}
+
+// printUploadQueue 以表格形式显示上传队列
+func printUploadQueue(items []queue.Item) {
+	if len(items) == 0 {
+		fmt.Println("上传队列为空")
+		return
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "快照\t加入时间\t尝试次数\t下次重试\t失败原因")
+	for _, item := range items {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\t%s\n", item.ID, item.QueuedAt.Format(inspectTimeLayout),
+			item.Attempts, item.NextAttempt.Format(inspectTimeLayout), remote.MaskSecrets(item.LastError))
+	}
+	table.Flush()
+}
+
+// shareOptions 命令行指定的分享链接策略，未指定的参数为 nil，使用上传配置中的值
+type shareOptions struct {
+	expiry    *string
+	downloads *int
+	password  *string
+	preview   *bool
+}
+
+// shareOptionsFromFlags 读取 --share-* 参数
+func shareOptionsFromFlags(c *cli.Context) shareOptions {
+	var options shareOptions
+	if c.IsSet("share-expiry") {
+		expiry := c.String("share-expiry")
+		options.expiry = &expiry
+	}
+	if c.IsSet("share-downloads") {
+		downloads := c.Int("share-downloads")
+		options.downloads = &downloads
+	}
+	if c.IsSet("share-password") {
+		password := c.String("share-password")
+		options.password = &password
+	}
+	if c.IsSet("share-preview") {
+		preview := c.Bool("share-preview")
+		options.preview = &preview
+	}
+	return options
+}
+
+// apply 使用命令行参数覆盖默认上传提供商配置的分享链接策略
+func (o shareOptions) apply(uploadConfig *remote.UploadConfig) {
+	defaultProvider := uploadConfig.GetDefaultProvider()
+	if defaultProvider == nil {
+		return
+	}
+	for i := range uploadConfig.Providers {
+		provider := &uploadConfig.Providers[i]
+		if provider.DisplayName() != defaultProvider.DisplayName() {
+			continue
+		}
+		if o.expiry != nil {
+			provider.ShareExpiry = *o.expiry
+		}
+		if o.downloads != nil {
+			provider.ShareDownloads = *o.downloads
+		}
+		if o.password != nil {
+			provider.SharePassword = *o.password
+		}
+		if o.preview != nil {
+			provider.SharePreview = *o.preview
+		}
+	}
+}
+
+// retryPendingUploads 重试已到重试时间的排队快照，并在日志中输出每个快照的结果
+func retryPendingUploads(configDir string, uploadConfig *remote.UploadConfig) {
+	results, err := service.RetryPendingUploads(configDir, store.DefaultDir(configDir), uploadConfig, false)
+	for _, result := range results {
+		switch {
+		case result.Err == nil:
+			for _, url := range result.URLs {
+				logrus.Infof("排队的快照 %s 已上传至: %s", result.Item.ID, url)
+			}
+		case result.Dropped:
+			logrus.Warnf("排队的快照 %s %v，已从上传队列中移除", result.Item.ID, result.Err)
+		default:
+			logrus.Warnf("排队的快照 %s 上传失败 (已尝试 %d 次): %v，将于 %s 重试", result.Item.ID,
+				result.Item.Attempts, result.Err, result.Item.NextAttempt.Format(inspectTimeLayout))
+		}
+	}
+	if err != nil {
+		logrus.Warnf("重试上传失败: %v", err)
+	}
+}
+
+// runUploadQueue 在 watch、schedule 长时间运行期间定期重试上传队列，直到 ctx 结束
+func runUploadQueue(ctx context.Context, remoteConfig *remote.ConfigManager, configDir string) {
+	ticker := time.NewTicker(uploadQueueInterval)
+	defer ticker.Stop()
+	for {
+		if hasDueUploads(configDir, time.Now()) {
+			// 上传配置可能在长时间运行期间更新，每次重试时重新获取
+			uploadConfig, err := remoteConfig.GetUploadConfig()
+			if err != nil {
+				logrus.Warnf("获取上传配置失败: %v", err)
+			} else {
+				retryPendingUploads(configDir, uploadConfig)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// hasDueUploads 返回上传队列中是否有已到重试时间的快照
+func hasDueUploads(configDir string, now time.Time) bool {
+	q, err := queue.Open(configDir)
+	if err != nil {
+		return false
+	}
+	items, err := q.List()
+	if err != nil {
+		logrus.Warnf("读取上传队列失败: %v", err)
+		return false
+	}
+	for _, item := range items {
+		if !now.Before(item.NextAttempt) {
+			return true
+		}
+	}
+	return false
+}
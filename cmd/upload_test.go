@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"logsnap/constants"
+	"logsnap/queue"
+
+	"github.com/sirupsen/logrus"
+)
+
+// captureOutput 执行 fn 并返回期间写入标准输出和日志的内容
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("创建管道失败: %v", err)
+	}
+	stdout, logOutput := os.Stdout, logrus.StandardLogger().Out
+	var logged bytes.Buffer
+	os.Stdout = writer
+	logrus.SetOutput(&logged)
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		done <- data
+	}()
+
+	fn()
+
+	writer.Close()
+	os.Stdout = stdout
+	logrus.SetOutput(logOutput)
+	return string(<-done) + logged.String()
+}
+
+func TestUploadActionMasksSecrets(t *testing.T) {
+	const secret = "s3cret-from-env"
+	t.Setenv("LOGSNAP_TEST_DAV_PASSWORD", secret)
+
+	// WebDAV服务器在错误响应中带上了客户端发送的密码
+	dav := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PROPFIND" {
+			w.WriteHeader(http.StatusMultiStatus)
+			fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:"><d:response><d:href>/</d:href>`+
+				`<d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop>`+
+				`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`)
+			return
+		}
+		user, password, _ := r.BasicAuth()
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "invalid credentials %s:%s", user, password)
+	}))
+	defer dav.Close()
+
+	uploadConfig, _ := json.Marshal(map[string]interface{}{
+		"providers": []map[string]string{{
+			"provider": "webdav",
+			"endpoint": dav.URL,
+			"username": "admin",
+			"password": "env:LOGSNAP_TEST_DAV_PASSWORD",
+		}},
+		"default_provider": "webdav",
+	})
+	configServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(uploadConfig)
+	}))
+	defer configServer.Close()
+	configURL := constants.UploadConfigURL
+	constants.UploadConfigURL = configServer.URL
+	defer func() { constants.UploadConfigURL = configURL }()
+
+	configDir := t.TempDir()
+	snapPath := filepath.Join(configDir, "logsnap_20240301_100000_20240301_110000.zip")
+	if err := os.WriteFile(snapPath, []byte("zip"), 0644); err != nil {
+		t.Fatalf("写入快照失败: %v", err)
+	}
+	q, err := queue.Open(configDir)
+	if err != nil {
+		t.Fatalf("打开上传队列失败: %v", err)
+	}
+	item := queue.Item{ID: "logsnap_20240301_100000_20240301_110000", Files: []string{snapPath}, KeepLocal: true}
+	if err := q.Add(item, time.Now()); err != nil {
+		t.Fatalf("加入上传队列失败: %v", err)
+	}
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	var retryErr error
+	output := captureOutput(t, func() {
+		os.Args = []string{"logsnap", "upload", "--retry-pending", "--config-dir", configDir}
+		retryErr = Execute()
+		os.Args = []string{"logsnap", "upload", "--config-dir", configDir}
+		Execute()
+	})
+
+	if retryErr == nil {
+		t.Fatalf("上传失败时应返回错误")
+	}
+	if !strings.Contains(output, "invalid credentials admin:******") {
+		t.Fatalf("输出中应包含屏蔽后的失败原因: %s", output)
+	}
+	if strings.Contains(output, secret) || strings.Contains(retryErr.Error(), secret) {
+		t.Fatalf("输出中不应出现凭证: %s", output)
+	}
+	data, _ := os.ReadFile(filepath.Join(configDir, queue.FileName))
+	if strings.Contains(string(data), secret) {
+		t.Fatalf("上传队列中不应保存凭证: %s", data)
+	}
+}
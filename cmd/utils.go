@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+
+	"logsnap/utils"
 )
 
 // parseTimeArg 解析时间参数，支持多种时间单位
@@ -41,3 +43,23 @@ func parseTimeArg(timeArg string) (int, error) {
 		return 0, fmt.Errorf("不支持的时间单位: %s", unit)
 	}
 }
+
+// parseSizeArg 解析大小参数，支持 B/K/M/G 单位（按 1024 换算），例如 200M, 1G
+func parseSizeArg(sizeArg string) (int64, error) {
+	return utils.ParseSize(sizeArg)
+}
+
+// formatSize 将字节数格式化为易读的大小，例如 1.5 MB
+func formatSize(size uint64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
@@ -32,3 +32,35 @@ func TestParseTimeArg(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSizeArg(t *testing.T) {
+	tests := []struct {
+		name     string
+		sizeArg  string
+		expected int64
+		wantErr  bool
+	}{
+		{"空值", "", 0, false},
+		{"字节", "512", 512, false},
+		{"千字节", "500K", 500 << 10, false},
+		{"兆字节", "200M", 200 << 20, false},
+		{"兆字节带B", "200MB", 200 << 20, false},
+		{"吉字节小写", "1g", 1 << 30, false},
+		{"无效格式", "abc", 0, true},
+		{"小数", "1.5G", 0, true},
+		{"未知单位", "10T", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSizeArg(tt.sizeArg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSizeArg() 错误 = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.expected {
+				t.Errorf("parseSizeArg() = %v, 期望 %v", got, tt.expected)
+			}
+		})
+	}
+}
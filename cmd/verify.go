@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"logsnap/encryption"
+	"logsnap/snapshot"
+
+	"github.com/urfave/cli/v2"
+)
+
+// verifyAction 处理verify命令，校验快照的完整性
+func verifyAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("请指定要校验的快照文件")
+	}
+
+	var opts snapshot.VerifyOptions
+	if identityPath := c.String("identity"); identityPath != "" {
+		identity, err := encryption.LoadIdentity(identityPath)
+		if err != nil {
+			return err
+		}
+		opts.Identity = identity
+	}
+	for _, key := range c.StringSlice("trusted-key") {
+		trusted, err := snapshot.ParseVerifyKey(key)
+		if err != nil {
+			return err
+		}
+		opts.TrustedKeys = append(opts.TrustedKeys, trusted)
+	}
+
+	report, err := snapshot.Verify(c.Args().First(), opts)
+	if err != nil {
+		return err
+	}
+
+	for _, check := range report.Checks {
+		mark := "✓"
+		if check.Skipped {
+			mark = "-"
+		} else if !check.OK {
+			mark = "✗"
+		}
+		fmt.Printf("%s %s: %s\n", mark, check.Name, check.Message)
+	}
+
+	if !report.OK() {
+		return fmt.Errorf("快照校验失败")
+	}
+	fmt.Println("快照校验通过")
+	return nil
+}
@@ -43,7 +43,7 @@ func showVersionInSimpleMode(remoteConfig *remote.ConfigManager) error {
 	// 尝试获取远程配置，检查是否有更新
 	hasUpdate, latestVersion, _, _, _, err := remoteConfig.CheckForUpdates()
 	if err != nil {
-		fmt.Printf("检查更新失败: %v\n", err)
+		fmt.Printf("检查更新失败: %s\n", remote.MaskSecrets(err.Error()))
 	} else if hasUpdate {
 		fmt.Printf("发现新版本: %s (可以使用 'logsnap update' 命令更新)\n", latestVersion)
 	} else {
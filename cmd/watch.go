@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"logsnap/config"
+	"logsnap/remote"
+	"logsnap/service"
+	"logsnap/store"
+	"logsnap/tail"
+	"logsnap/watch"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// watchAction 处理watch命令，日志满足触发规则时自动收集快照
+func watchAction(c *cli.Context) error {
+	configDir, err := resolveConfigDir(c.String("config-dir"))
+	if err != nil {
+		return err
+	}
+
+	watchConfig, err := loadWatchConfig(c.String("rules"), configDir)
+	if err != nil {
+		return err
+	}
+
+	maxSize, err := parseSizeArg(c.String("max-size"))
+	if err != nil {
+		return err
+	}
+	storeDir := store.DefaultDir(configDir)
+	outputDir := c.String("output-dir")
+	if outputDir == "" {
+		outputDir = storeDir
+	}
+
+	remoteConfig := remote.NewConfigManager(config.NewLocalConfig())
+	collect := func(ctx context.Context, snapshot watch.Snapshot) error {
+		start, end := snapshot.Start, snapshot.End
+		serviceConfig := &service.Config{
+			StartTime:        &start,
+			EndTime:          &end,
+			ShouldUpload:     c.Bool("upload"),
+			KeepLocalSnap:    c.Bool("keep-local-snapshot"),
+			OutputDir:        outputDir,
+			SkipVersionCheck: true,
+			ConfigDir:        configDir,
+			LogRootDir:       c.String("log-dir"),
+			Programs:         c.StringSlice("program"),
+			MaxSize:          maxSize,
+			EncryptTo:        c.StringSlice("encrypt-to"),
+			StoreDir:         storeDir,
+			Source:           "watch",
+		}
+		return collectUnattended(remoteConfig, serviceConfig)
+	}
+
+	watcher, err := watch.New(watchConfig, collect)
+	if err != nil {
+		return err
+	}
+	sources, err := tailSources(c.String("log-dir"), c.StringSlice("program"))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go runUploadQueue(ctx, remoteConfig, configDir)
+	logrus.Infof("开始监视 %d 个程序的日志，共 %d 条触发规则", len(sources), len(watchConfig.Rules))
+	return watcher.Run(ctx, tail.New(sources, nil))
+}
+
+// loadWatchConfig 加载监视规则，未指定规则文件且配置目录中没有 watch.json 时使用默认规则
+func loadWatchConfig(path, configDir string) (*watch.Config, error) {
+	if path != "" {
+		return watch.LoadConfig(path)
+	}
+	path = filepath.Join(configDir, "watch.json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		logrus.Infof("未找到监视规则 %s，使用默认规则：任意程序崩溃时收集快照", path)
+		return watch.DefaultConfig(), nil
+	}
+	return watch.LoadConfig(path)
+}
+
+// resolveConfigDir 返回配置目录，未指定时使用 ~/.logsnap
+func resolveConfigDir(configDir string) (string, error) {
+	if configDir != "" {
+		return configDir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("获取用户主目录失败: %w", err)
+	}
+	return filepath.Join(homeDir, ".logsnap"), nil
+}
+
+// collectUnattended 在长时间运行的模式（watch、schedule）中收集一次快照，不检查版本更新
+func collectUnattended(remoteConfig *remote.ConfigManager, serviceConfig *service.Config) error {
+	// 上传配置可能在长时间运行期间更新，每次收集时重新获取
+	uploadConfig, err := remoteConfig.GetUploadConfig()
+	if err != nil {
+		return fmt.Errorf("获取上传配置失败: %w", err)
+	}
+	snapPath, uploadURL, err := service.CollectAndUploadLogs(serviceConfig, uploadConfig)
+	if errors.Is(err, service.ErrUploadQueued) {
+		logrus.Warnf("%v，快照已保存至: %s，稍后自动重试", err, snapPath)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logrus.Infof("日志收集完成，已保存至: %s", snapPath)
+	if serviceConfig.ShouldUpload && uploadURL != "" {
+		if !serviceConfig.KeepLocalSnap {
+			os.RemoveAll(snapPath)
+		}
+		for _, url := range strings.Split(uploadURL, "\n") {
+			logrus.Infof("日志已上传至: %s", url)
+		}
+	}
+	return nil
+}
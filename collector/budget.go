@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"logsnap/collector/utils"
+	"logsnap/logentry"
+	"logsnap/snapshot"
+)
+
+// budgetOutput 限制快照大小时使用的输出目标
+// 处理器输出的文件先压缩暂存，同时统计其中的日志级别和时间范围，
+// 所有处理器完成后再按优先级写入快照
+type budgetOutput struct {
+	pool  *utils.ZipEntryPool
+	mu    sync.Mutex
+	stats map[*utils.ZipStreamEntry]*logentry.Stats
+}
+
+func newBudgetOutput() *budgetOutput {
+	return &budgetOutput{
+		pool:  utils.NewZipEntryPool(),
+		stats: make(map[*utils.ZipStreamEntry]*logentry.Stats),
+	}
+}
+
+func (o *budgetOutput) Create(name string) (OutputFile, error) {
+	if name == "" {
+		return nil, fmt.Errorf("输出文件名不能为空")
+	}
+	entry, err := o.pool.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &budgetFile{output: o, entry: entry, stats: &logentry.Stats{}}, nil
+}
+
+// budgetFile 写入暂存条目的同时统计日志级别
+type budgetFile struct {
+	output *budgetOutput
+	entry  *utils.ZipStreamEntry
+	stats  *logentry.Stats
+}
+
+func (f *budgetFile) Write(p []byte) (int, error) {
+	n, err := f.entry.Write(p)
+	f.stats.Write(p[:n])
+	return n, err
+}
+
+func (f *budgetFile) Commit() error {
+	f.stats.Flush()
+	if err := f.entry.Commit(); err != nil {
+		return err
+	}
+	f.output.mu.Lock()
+	f.output.stats[f.entry] = f.stats
+	f.output.mu.Unlock()
+	return nil
+}
+
+func (f *budgetFile) Discard() error {
+	return f.entry.Discard()
+}
+
+// rankedEntry 参与排序的暂存条目
+type rankedEntry struct {
+	entry    *utils.ZipStreamEntry
+	program  string
+	priority int // 处理器优先级，数值越小越优先
+	severity logentry.Severity
+	latest   time.Time
+}
+
+// severityTier 返回日志级别的排序档位，ERROR 和 FATAL 同档
+func severityTier(severity logentry.Severity) int {
+	switch severity {
+	case logentry.SeverityFatal, logentry.SeverityError:
+		return 2
+	case logentry.SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// rank 对暂存条目排序，越靠前越优先保留
+// 依次比较：日志级别（ERROR/FATAL 优先）、最新日志时间（精确到小时，越新越优先）、处理器优先级（按配置顺序）
+func (o *budgetOutput) rank(processors []LogProcessor, snapDirName string) []rankedEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ranked []rankedEntry
+	for _, entry := range o.pool.Entries() {
+		stats := o.stats[entry]
+		r := rankedEntry{entry: entry}
+		r.program, r.priority = entryProcessor(processors, snapDirName, entry.Name())
+
+		r.severity = logentry.SeverityFromFileName(entry.Name())
+		r.latest = entry.Modified()
+		if stats != nil {
+			if severity := stats.MaxSeverity(); severity > r.severity {
+				r.severity = severity
+			}
+			if !stats.Latest.IsZero() {
+				r.latest = stats.Latest
+			}
+		}
+		ranked = append(ranked, r)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if tierA, tierB := severityTier(a.severity), severityTier(b.severity); tierA != tierB {
+			return tierA > tierB
+		}
+		if hourA, hourB := a.latest.Truncate(time.Hour), b.latest.Truncate(time.Hour); !hourA.Equal(hourB) {
+			return hourA.After(hourB)
+		}
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		return a.entry.Name() < b.entry.Name()
+	})
+	return ranked
+}
+
+// entryProcessor 根据条目所在的输出目录找到产生它的处理器，返回处理器名称和在处理器列表中的位置
+func entryProcessor(processors []LogProcessor, snapDirName, name string) (string, int) {
+	rel := strings.TrimPrefix(name, snapDirName+"/")
+	for i, p := range processors {
+		dir := strings.Trim(filepath.ToSlash(filepath.Clean(p.GetOutputDir())), "/")
+		if dir == "" || dir == "." {
+			continue
+		}
+		if rel == dir || strings.HasPrefix(rel, dir+"/") {
+			return p.GetName(), i
+		}
+	}
+	return path.Dir(rel), len(processors)
+}
+
+// fitToBudget 按优先级选出不超过快照大小上限的条目并写入 writer
+// 被裁剪的文件在写入前通过 setTrimmed 传出，使每个分卷的清单都能记录完整的裁剪列表
+func (o *budgetOutput) fitToBudget(writer *utils.ZipVolumeWriter, maxSize int64, processors []LogProcessor,
+	snapDirName string, setTrimmed func([]snapshot.TrimmedFile)) error {
+	ranked := o.rank(processors, snapDirName)
+	ordered := make([]*utils.ZipStreamEntry, 0, len(ranked))
+	byName := make(map[string]rankedEntry, len(ranked))
+	for _, r := range ranked {
+		ordered = append(ordered, r.entry)
+		byName[r.entry.Name()] = r
+	}
+
+	selected, trimmed, err := o.pool.Fit(ordered, writer.Budget(maxSize))
+	if err != nil {
+		return err
+	}
+
+	files := make([]snapshot.TrimmedFile, 0, len(trimmed))
+	for _, t := range trimmed {
+		r := byName[t.Name]
+		files = append(files, snapshot.TrimmedFile{
+			Name:     t.Name,
+			Action:   t.Action,
+			Size:     t.Size,
+			KeptSize: t.KeptSize,
+			Program:  r.program,
+			Severity: r.severity.String(),
+		})
+	}
+	setTrimmed(files)
+
+	return o.pool.WriteTo(writer, selected)
+}
@@ -2,11 +2,21 @@ package collector
 
 import (
 	"archive/zip"
+	"crypto/ed25519"
+	"encoding/json"
 	"fmt"
+	"io"
+	"logsnap/collector/report"
 	"logsnap/collector/utils"
+	"logsnap/encryption"
+	"logsnap/logentry"
+	"logsnap/snapshot"
+	"logsnap/version"
 	"os"
+	"path"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -41,6 +51,9 @@ type LogProcessor interface {
 	// GetOutputDir 返回日志文件的输出目录
 	GetOutputDir() string
 
+	// GetTimeLayouts 返回处理器过滤日志时使用的行时间戳格式，分析快照时按程序选用
+	GetTimeLayouts() []logentry.Layout
+
 	// Collect 处理日志文件，提取指定时间范围内的日志
 	// 参数:
 	//   - startTime: 开始时间
@@ -53,10 +66,43 @@ type LogProcessor interface {
 	Collect(startTime, endTime time.Time, outputDir string) (outputPath string, results []FileProcessResult, err error)
 }
 
+// outputRootSeq 用于生成唯一的虚拟输出根目录
+var outputRootSeq uint64
+
+// spillMemoryLimit 一次收集中所有条目在内存中缓存压缩数据的总量上限，与并行的处理器数量无关
+const spillMemoryLimit = 64 << 20
+
 // Collector 负责收集和打包日志
 type Collector struct {
 	logProcessors []LogProcessor
-	outputDir     string // 最终ZIP文件的输出目录
+	outputDir     string                  // 最终ZIP文件的输出目录
+	maxVolumeSize int64                   // 单个分卷的最大字节数，0 表示不分卷
+	maxSize       int64                   // 快照的最大字节数，0 表示不限制
+	timeline      bool                    // 是否生成合并时间线
+	report        bool                    // 是否生成摘要报告
+	filter        *logentry.Filter        // 日志条目的级别和内容过滤条件，为空时不过滤
+	recipients    []*encryption.Recipient // 快照接收者公钥，为空时不加密
+	signingKey    ed25519.PrivateKey      // 校验文件的签名私钥，为空时不签名
+}
+
+// Snapshot 描述一次收集生成的快照文件
+type Snapshot struct {
+	Path        string   // 快照主文件：未分卷时为ZIP文件，分卷时为分卷索引文件
+	Volumes     []string // 所有分卷ZIP文件的路径，未分卷时只有一个
+	IndexPath   string   // 分卷索引文件路径，未分卷时为空
+	SidecarPath string   // 校验文件路径
+}
+
+// Files 返回快照包含的所有文件，包括分卷索引和校验文件
+func (s *Snapshot) Files() []string {
+	files := append([]string(nil), s.Volumes...)
+	if s.IndexPath != "" {
+		files = append(files, s.IndexPath)
+	}
+	if s.SidecarPath != "" {
+		files = append(files, s.SidecarPath)
+	}
+	return files
 }
 
 // NewCollector 创建新的收集器
@@ -64,6 +110,7 @@ func NewCollector(logProcessors []LogProcessor, outputDir string) *Collector {
 	return &Collector{
 		logProcessors: logProcessors,
 		outputDir:     outputDir,
+		report:        true,
 	}
 }
 
@@ -120,16 +167,62 @@ func (c *Collector) SetOutputDir(outputDir string) {
 	logrus.Infof("已设置输出目录: %s", outputDir)
 }
 
+// SetMaxVolumeSize 设置单个分卷的最大字节数，0 表示不分卷
+func (c *Collector) SetMaxVolumeSize(size int64) {
+	c.maxVolumeSize = size
+}
+
+// SetMaxSize 设置快照的最大字节数，0 表示不限制
+// 超出时按日志级别、时间和处理器顺序排序，优先级低的文件被截断或丢弃
+func (c *Collector) SetMaxSize(size int64) {
+	c.maxSize = size
+}
+
+// SetTimeline 设置是否在快照中生成按时间合并所有程序日志的 timeline.log
+func (c *Collector) SetTimeline(enabled bool) {
+	c.timeline = enabled
+}
+
+// SetReport 设置是否在快照中生成 report.html 摘要报告，默认生成
+func (c *Collector) SetReport(enabled bool) {
+	c.report = enabled
+}
+
+// SetFilter 设置日志条目的过滤条件，只有满足条件的条目写入快照
+func (c *Collector) SetFilter(filter *logentry.Filter) {
+	c.filter = filter
+}
+
+// SetRecipients 设置快照接收者公钥，设置后快照使用接收者公钥加密
+func (c *Collector) SetRecipients(recipients []*encryption.Recipient) {
+	c.recipients = recipients
+}
+
+// SetSigningKey 设置校验文件的签名私钥
+func (c *Collector) SetSigningKey(key ed25519.PrivateKey) {
+	c.signingKey = key
+}
+
 // GetOutputDir 获取输出目录
 func (c *Collector) GetOutputDir() string {
 	return c.outputDir
 }
 
-// Collect 收集指定时间范围内的日志（多线程版本）
+// Collect 收集指定时间范围内的日志，返回快照文件路径
+// 分卷时返回分卷索引文件路径，使用 CollectSnapshot 可以获取所有分卷
 func (c *Collector) Collect(startTime, endTime time.Time) (string, error) {
+	snap, err := c.CollectSnapshot(startTime, endTime)
+	if err != nil {
+		return "", err
+	}
+	return snap.Path, nil
+}
+
+// CollectSnapshot 收集指定时间范围内的日志（多线程版本）
+func (c *Collector) CollectSnapshot(startTime, endTime time.Time) (*Snapshot, error) {
 	// 验证时间范围
 	if endTime.Before(startTime) {
-		return "", fmt.Errorf("结束时间不能早于开始时间")
+		return nil, fmt.Errorf("结束时间不能早于开始时间")
 	}
 
 	// 创建快照目录名
@@ -137,30 +230,152 @@ func (c *Collector) Collect(startTime, endTime time.Time) (string, error) {
 		endTime.Format("20060102_150405"))
 	snapFileZipName := fmt.Sprintf("%s.zip", snapFileDirName)
 
-	// 创建临时目录用于处理日志文件
-	tempDir, err := os.MkdirTemp("", "logsnap_*")
-	if err != nil {
-		return "", fmt.Errorf("创建临时目录失败: %w", err)
+	// 查看有多少个处理器
+	processorCount := len(c.logProcessors)
+	logrus.Infof("有 %d 个解析器", processorCount)
+
+	if processorCount == 0 {
+		return nil, fmt.Errorf("没有配置日志处理器")
+	}
+
+	// 确定最终ZIP文件的路径
+	var snapPath string
+	if c.outputDir != "" {
+		// 确保输出目录存在
+		if err := os.MkdirAll(c.outputDir, 0755); err != nil {
+			return nil, fmt.Errorf("创建输出目录失败: %w", err)
+		}
+		snapPath = filepath.Join(c.outputDir, snapFileZipName)
+	} else {
+		// 使用当前目录
+		snapPath = snapFileZipName
+	}
+
+	// 处理器直接将结果写入ZIP文件，超过分卷大小时切换到新的分卷
+	var pathFunc func(volume int) string
+	if c.maxVolumeSize > 0 {
+		pathFunc = func(volume int) string {
+			return filepath.Join(filepath.Dir(snapPath), snapshot.VolumeFileName(snapFileDirName, volume)) + c.fileSuffix()
+		}
+	} else {
+		pathFunc = func(int) string { return snapPath + c.fileSuffix() }
+	}
+
+	programs := make([]string, 0, processorCount)
+	for _, processor := range c.logProcessors {
+		programs = append(programs, processor.GetName())
+	}
+	hostname, _ := os.Hostname()
+	createdAt := time.Now()
+	var trimmed []snapshot.TrimmedFile
+
+	// 每个分卷关闭前写入清单，分卷可以独立查看
+	finalizer := func(volume int, writer *utils.ZipStreamWriter) error {
+		manifest := snapshot.Manifest{
+			Version:   version.GetVersion(),
+			CreatedAt: createdAt,
+			Hostname:  hostname,
+			StartTime: startTime,
+			EndTime:   endTime,
+			Programs:  programs,
+			Files:     []snapshot.ManifestFile{},
+		}
+		if c.maxVolumeSize > 0 {
+			manifest.Volume = volume
+		}
+		if c.maxSize > 0 {
+			manifest.MaxSize = c.maxSize
+			manifest.Trimmed = trimmed
+		}
+		for _, entry := range writer.Entries() {
+			file := snapshot.ManifestFile{
+				Name:           entry.Name,
+				Size:           entry.Size,
+				CompressedSize: entry.CompressedSize,
+				Modified:       entry.Modified,
+				SHA256:         entry.SHA256,
+			}
+			if program, index := entryProcessor(c.logProcessors, snapFileDirName, entry.Name); index < processorCount {
+				file.Program = program
+			}
+			manifest.Files = append(manifest.Files, file)
+		}
+		return writeJSONEntry(writer, path.Join(snapFileDirName, snapshot.ManifestFileName), manifest)
 	}
-	// 函数结束时删除临时目录
-	defer os.RemoveAll(tempDir)
 
-	// 创建快照目录
-	targetDir := filepath.Join(tempDir, snapFileDirName)
-	if err := os.Mkdir(targetDir, 0700); err != nil {
-		return "", fmt.Errorf("创建快照目录失败: %w", err)
+	// 条目压缩数据超出内存额度时溢出到快照旁的工作目录，时间线的临时文件也在其中，不占用系统临时目录
+	workDir := filepath.Join(filepath.Dir(snapPath), "."+snapFileDirName+".partial")
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return nil, fmt.Errorf("创建工作目录失败: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+	spill := utils.NewSpillArea(workDir, spillMemoryLimit)
+	if len(c.recipients) > 0 {
+		// 工作目录中的临时文件使用只保存在内存中的随机密钥加密
+		if err := spill.EnableEncryption(); err != nil {
+			return nil, err
+		}
 	}
 
-	logrus.Infof("使用快照目录: %s", targetDir)
+	zipWriter := utils.NewZipVolumeWriter(c.maxVolumeSize, pathFunc, finalizer)
+	zipWriter.SetSpillArea(spill)
+	if len(c.recipients) > 0 {
+		// 分卷内容在写入磁盘前加密；溢出的压缩数据和时间线临时文件也已加密，收集过程中磁盘上不会出现明文日志
+		zipWriter.SetWrapper(func(w io.Writer) (io.WriteCloser, error) {
+			return encryption.NewWriter(w, c.recipients)
+		})
+		logrus.Infof("快照将加密给 %d 个接收者", len(c.recipients))
+	}
 
-	// 查看有多少个处理器
-	processorCount := len(c.logProcessors)
-	logrus.Infof("有 %d 个解析器", processorCount)
+	// 失败时清理未完成的ZIP文件
+	completed := false
+	defer func() {
+		if !completed {
+			zipWriter.Abort()
+		}
+	}()
 
-	if processorCount == 0 {
-		return "", fmt.Errorf("没有配置日志处理器")
+	// 注册虚拟输出根目录，处理器写入该目录下的文件都会直接进入ZIP文件
+	// 该目录不会在磁盘上创建
+	outputRoot := filepath.Join(os.TempDir(), fmt.Sprintf(streamRootPrefix+"%d_%d",
+		os.Getpid(), atomic.AddUint64(&outputRootSeq, 1)))
+	// 限制快照大小时先暂存所有输出，处理器全部完成后再按优先级写入
+	var output Output = &zipOutput{writer: zipWriter}
+	var budget *budgetOutput
+	if c.maxSize > 0 {
+		budget = newBudgetOutput()
+		budget.pool.SetSpillArea(spill)
+		defer budget.pool.Release()
+		output = budget
 	}
 
+	// 生成合并时间线时，处理器输出同时复制到工作目录中的临时文件，所有处理器完成后再归并
+	var timeline *timelineOutput
+	if c.timeline {
+		timeline = newTimelineOutput(output, spill)
+		defer timeline.cleanup()
+		output = timeline
+	}
+
+	// 生成报告时统计每个程序的日志量和错误
+	var reporting *reportOutput
+	if c.report {
+		reporting = newReportOutput(output, c.logProcessors, snapFileDirName)
+		output = reporting
+	}
+
+	// 过滤在最外层进行，报告、时间线和大小预算只统计满足条件的日志
+	if c.filter.Active() {
+		output = newFilterOutput(output, c.filter)
+	}
+
+	RegisterOutput(outputRoot, output)
+	defer UnregisterOutput(outputRoot)
+
+	targetDir := filepath.Join(outputRoot, snapFileDirName)
+
+	logrus.Infof("开始创建快照: %s", snapPath)
+
 	// 创建等待组和结果通道
 	var wg sync.WaitGroup
 	resultChan := make(chan ProcessorResult, processorCount)
@@ -232,7 +447,9 @@ func (c *Collector) Collect(startTime, endTime time.Time) (string, error) {
 	totalMatchCount := 0
 
 	// 从通道读取结果
+	var processorResults []ProcessorResult
 	for result := range resultChan {
+		processorResults = append(processorResults, result)
 		if result.err == nil && result.outputPath != "" {
 			totalLineCount += result.GetTotalLines()
 			totalMatchCount += result.GetMatchLines()
@@ -241,72 +458,191 @@ func (c *Collector) Collect(startTime, endTime time.Time) (string, error) {
 
 	hasFiles := totalLineCount > 0 && totalMatchCount > 0
 
-	// 检查快照目录中的文件
-	files, err := os.ReadDir(targetDir)
-	if err != nil {
-		logrus.Warnf("无法读取快照目录: %v", err)
-	} else {
-		logrus.Infof("快照目录中有 %d 个文件/目录:", len(files))
-		for _, f := range files {
-			info, _ := f.Info()
-			if info != nil {
-				logrus.Infof("  - %s (大小: %d 字节, 目录: %v)", f.Name(), info.Size(), f.IsDir())
-			} else {
-				logrus.Infof("  - %s", f.Name())
-			}
+	if timeline != nil && hasFiles {
+		count, err := timeline.writeTimeline(c.logProcessors, snapFileDirName)
+		switch {
+		case err != nil:
+			logrus.Warnf("生成合并时间线失败: %v", err)
+		case count == 0:
+			logrus.Infof("没有可识别时间戳的日志，未生成合并时间线")
+		default:
+			logrus.Infof("已生成合并时间线 %s，共 %d 条日志", TimelineFileName, count)
+		}
+	}
+
+	if budget != nil && hasFiles {
+		logrus.Infof("快照大小上限: %d 字节", c.maxSize)
+		budgetSize := c.maxSize
+		if reporting != nil {
+			budgetSize -= reportReserve
+		}
+		err := budget.fitToBudget(zipWriter, budgetSize, c.logProcessors, snapFileDirName,
+			func(files []snapshot.TrimmedFile) { trimmed = files })
+		if err != nil {
+			return nil, fmt.Errorf("创建日志快照失败: %w", err)
+		}
+		if len(trimmed) > 0 {
+			logrus.Warnf("快照超出大小上限，已裁剪 %d 个文件，详见清单", len(trimmed))
 		}
 	}
 
+	if reporting != nil && hasFiles {
+		base := report.Report{
+			Version:   version.GetVersion(),
+			Hostname:  hostname,
+			CreatedAt: createdAt,
+			StartTime: startTime,
+			EndTime:   endTime,
+		}
+		if timeline != nil {
+			base.Timeline = TimelineFileName
+		}
+		if err := writeReport(zipWriter, snapFileDirName, reporting.build(base, processorResults, trimmed)); err != nil {
+			logrus.Warnf("生成摘要报告失败: %v", err)
+		} else {
+			logrus.Infof("已生成摘要报告 %s", report.FileName)
+		}
+	}
+
+	// 检查写入ZIP文件的条目
+	entries := zipWriter.Entries()
+	logrus.Infof("快照中有 %d 个文件:", len(entries))
+	for _, entry := range entries {
+		logrus.Infof("  - %s (大小: %d 字节, 压缩后: %d 字节)", entry.Name, entry.Size, entry.CompressedSize)
+	}
+
 	// 如果没有收集到任何文件，返回错误
 	if !hasFiles {
 		logrus.Infof("没有找到任何匹配的日志文件")
-		return "", fmt.Errorf("指定时间范围内没有找到任何日志")
+		return nil, fmt.Errorf("指定时间范围内没有找到任何日志")
 	}
 
-	// 确定最终ZIP文件的路径
-	var snapPath string
-	if c.outputDir != "" {
-		// 确保输出目录存在
-		if err := os.MkdirAll(c.outputDir, 0755); err != nil {
-			return "", fmt.Errorf("创建输出目录失败: %w", err)
+	// 写入中央目录，完成所有分卷
+	if err := zipWriter.Close(); err != nil {
+		logrus.Errorf("创建ZIP文件失败: %v", err)
+		return nil, fmt.Errorf("创建日志快照失败: %w", err)
+	}
+
+	volumes := zipWriter.Volumes()
+	snap := &Snapshot{}
+	for _, volume := range volumes {
+		snap.Volumes = append(snap.Volumes, volume.Path)
+	}
+
+	// 分卷时在分卷旁写入索引文件
+	if c.maxVolumeSize > 0 {
+		index := &snapshot.Index{
+			Version:   version.GetVersion(),
+			CreatedAt: createdAt,
+			Hostname:  hostname,
+			StartTime: startTime,
+			EndTime:   endTime,
+			Programs:  programs,
 		}
-		snapPath = filepath.Join(c.outputDir, snapFileZipName)
+		if c.maxSize > 0 {
+			index.MaxSize = c.maxSize
+			index.Trimmed = trimmed
+		}
+		for _, volume := range volumes {
+			indexVolume := snapshot.IndexVolume{
+				Volume: volume.Index,
+				Name:   filepath.Base(volume.Path),
+				Size:   volume.Size,
+				Files:  []string{},
+			}
+			for _, entry := range volume.Entries {
+				indexVolume.Files = append(indexVolume.Files, entry.Name)
+			}
+			index.Volumes = append(index.Volumes, indexVolume)
+		}
+
+		snap.IndexPath = filepath.Join(filepath.Dir(snapPath), snapFileDirName+snapshot.IndexFileSuffix) + c.fileSuffix()
+		if err := c.writeIndex(snap.IndexPath, index); err != nil {
+			return nil, err
+		}
+		snap.Path = snap.IndexPath
+		logrus.Infof("已创建 %d 个分卷，索引文件: %s", len(volumes), snap.IndexPath)
 	} else {
-		// 使用当前目录
-		snapPath = snapFileZipName
+		snap.Path = snap.Volumes[0]
 	}
 
-	logrus.Infof("开始创建ZIP文件: %s", snapPath)
-
-	// 压缩收集的日志
-	err = utils.ZipDirectory(tempDir, snapPath)
-	if err != nil {
-		logrus.Errorf("创建ZIP文件失败: %v", err)
-		return "", fmt.Errorf("创建日志快照失败: %w", err)
+	// 在快照旁写入校验文件，记录所有快照文件的 SHA-256
+	sidecarPath := snapshot.SidecarPath(snap.Path)
+	if err := snapshot.WriteSidecar(sidecarPath, snap.Files(), c.signingKey); err != nil {
+		return nil, err
 	}
+	snap.SidecarPath = sidecarPath
+	logrus.Infof("已写入校验文件: %s", snap.SidecarPath)
+	completed = true
 
-	// 验证生成的ZIP文件
-	fileInfo, err := os.Stat(snapPath)
-	if err != nil {
-		logrus.Errorf("无法访问生成的ZIP文件: %v", err)
-		return "", fmt.Errorf("无法访问生成的ZIP文件: %w", err)
+	// 验证生成的ZIP文件，加密的快照无法直接打开
+	if len(c.recipients) > 0 {
+		return snap, nil
+	}
+	for _, volumePath := range snap.Volumes {
+		zipReader, err := zip.OpenReader(volumePath)
+		if err != nil {
+			logrus.Warnf("无法打开生成的ZIP文件进行验证: %v", err)
+			continue
+		}
+		zipReader.Close()
+		logrus.Infof("ZIP文件验证成功: %s", volumePath)
 	}
 
-	logrus.Infof("已创建ZIP文件: %s, 大小: %d 字节", snapPath, fileInfo.Size())
+	return snap, nil
+}
 
-	if fileInfo.Size() == 0 {
-		logrus.Warnf("生成的ZIP文件为空")
-		os.Remove(snapPath)
-		return "", fmt.Errorf("生成的ZIP文件为空")
+// fileSuffix 返回快照文件名的额外后缀，加密时为 .enc
+func (c *Collector) fileSuffix() string {
+	if len(c.recipients) > 0 {
+		return encryption.FileExtension
 	}
+	return ""
+}
 
-	// 尝试验证ZIP文件
-	_, err = zip.OpenReader(snapPath)
+// writeIndex 写入分卷索引，加密时索引同样加密
+func (c *Collector) writeIndex(indexPath string, index *snapshot.Index) error {
+	if len(c.recipients) == 0 {
+		return snapshot.WriteIndex(indexPath, index)
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
 	if err != nil {
-		logrus.Warnf("无法打开生成的ZIP文件进行验证: %v", err)
-	} else {
-		logrus.Infof("ZIP文件验证成功")
+		return fmt.Errorf("序列化分卷索引失败: %w", err)
+	}
+	file, err := os.Create(indexPath)
+	if err != nil {
+		return fmt.Errorf("写入分卷索引失败: %w", err)
+	}
+	w, err := encryption.NewWriter(file, c.recipients)
+	if err == nil {
+		if _, err = w.Write(data); err == nil {
+			err = w.Close()
+		}
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
 	}
+	if err != nil {
+		os.Remove(indexPath)
+		return fmt.Errorf("写入分卷索引失败: %w", err)
+	}
+	return nil
+}
 
-	return snapPath, nil
+// writeJSONEntry 将对象序列化为JSON写入ZIP条目
+func writeJSONEntry(writer *utils.ZipStreamWriter, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 %s 失败: %w", name, err)
+	}
+	entry, err := writer.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := entry.Write(data); err != nil {
+		entry.Discard()
+		return fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	return entry.Commit()
 }
@@ -1,8 +1,17 @@
 package collector
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"logsnap/encryption"
+	"logsnap/logentry"
+	"logsnap/snapshot"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +39,10 @@ func (m *MockLogProcessor) GetOutputDir() string {
 	return args.String(0)
 }
 
+func (m *MockLogProcessor) GetTimeLayouts() []logentry.Layout {
+	return nil
+}
+
 func (m *MockLogProcessor) Collect(startTime, endTime time.Time, outputDir string) (string, []FileProcessResult, error) {
 	args := m.Called(startTime, endTime, outputDir)
 	return args.String(0), args.Get(1).([]FileProcessResult), args.Error(2)
@@ -206,6 +219,7 @@ func TestCollect(t *testing.T) {
 	processor1 := new(MockLogProcessor)
 	processor1.On("GetName").Return("处理器1")
 	processor1.On("GetLogPath").Return("/logs/test1.log", nil)
+	processor1.On("GetOutputDir").Return("output1")
 	processor1.On("Collect", mock.Anything, mock.Anything, mock.Anything).Return(
 		filepath.Join(tempDir, "output1.log"),
 		[]FileProcessResult{
@@ -217,6 +231,7 @@ func TestCollect(t *testing.T) {
 	processor2 := new(MockLogProcessor)
 	processor2.On("GetName").Return("处理器2")
 	processor2.On("GetLogPath").Return("/logs/test2.log", nil)
+	processor2.On("GetOutputDir").Return("output2")
 	processor2.On("Collect", mock.Anything, mock.Anything, mock.Anything).Return(
 		filepath.Join(tempDir, "output2.log"),
 		[]FileProcessResult{
@@ -255,3 +270,414 @@ func TestCollect(t *testing.T) {
 	processor1.AssertExpectations(t)
 	processor2.AssertExpectations(t)
 }
+
+func TestCollect_WritesProcessorOutputIntoZip(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "logsnap-test")
+	if err != nil {
+		t.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// 模拟处理器按目录路径写入输出文件
+	processor := new(MockLogProcessor)
+	processor.On("GetName").Return("处理器")
+	processor.On("GetOutputDir").Return("xyz_hmi")
+	processor.On("GetLogPath").Return("/logs", nil)
+	processor.On("Collect", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		outputDir := filepath.Join(args.String(2), "xyz_hmi")
+		assert.NoError(t, MkdirOutputDir(outputDir))
+
+		file, err := CreateOutputFile(filepath.Join(outputDir, "app.log"))
+		assert.NoError(t, err)
+		file.Write([]byte("line\n"))
+		assert.NoError(t, file.Commit())
+
+		discarded, err := CreateOutputFile(filepath.Join(outputDir, "empty.log"))
+		assert.NoError(t, err)
+		assert.NoError(t, discarded.Discard())
+	}).Return("xyz_hmi", []FileProcessResult{{TotalLines: 1, MatchLines: 1}}, nil)
+
+	collector := NewCollector([]LogProcessor{processor}, tempDir)
+	zipPath, err := collector.Collect(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("收集失败: %v", err)
+	}
+
+	// 收集结束后虚拟输出根目录已注销，写入其中的路径返回错误，不会在临时目录中创建文件
+	staleDir := filepath.Join(os.TempDir(), streamRootPrefix+"0_0", "xyz_hmi")
+	assert.Error(t, MkdirOutputDir(staleDir))
+	_, err = CreateOutputFile(filepath.Join(staleDir, "app.log"))
+	assert.Error(t, err)
+	assert.NoDirExists(t, filepath.Dir(staleDir))
+
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("打开ZIP文件失败: %v", err)
+	}
+	defer reader.Close()
+
+	assert.Len(t, reader.File, 3, "ZIP文件中应只有提交的文件、报告和清单")
+	assert.True(t, strings.HasSuffix(reader.File[0].Name, "/xyz_hmi/app.log"), "条目路径应保留快照目录结构")
+	assert.True(t, strings.HasSuffix(reader.File[1].Name, "/report.html"), "快照中应包含摘要报告")
+	assert.True(t, strings.HasSuffix(reader.File[2].Name, "/manifest.json"), "快照中应包含清单")
+
+	// 快照目录中只应有最终的ZIP文件和校验文件
+	files, _ := os.ReadDir(tempDir)
+	assert.Len(t, files, 2, "输出目录中不应残留临时文件")
+}
+
+func TestCollectSnapshot_SplitsIntoVolumes(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "logsnap-test")
+	if err != nil {
+		t.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// 写入多个难以压缩的文件，总大小超过分卷上限
+	processor := new(MockLogProcessor)
+	processor.On("GetName").Return("处理器")
+	processor.On("GetOutputDir").Return("xyz_hmi")
+	processor.On("GetLogPath").Return("/logs", nil)
+	processor.On("Collect", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		outputDir := filepath.Join(args.String(2), "xyz_hmi")
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 4; i++ {
+			file, err := CreateOutputFile(filepath.Join(outputDir, fmt.Sprintf("app_%d.log", i)))
+			assert.NoError(t, err)
+			for j := 0; j < 1000; j++ {
+				fmt.Fprintf(file, "%d %x\n", j, rng.Int63())
+			}
+			assert.NoError(t, file.Commit())
+		}
+	}).Return("xyz_hmi", []FileProcessResult{{TotalLines: 4000, MatchLines: 4000}}, nil)
+
+	collector := NewCollector([]LogProcessor{processor}, tempDir)
+	collector.SetMaxVolumeSize(40 << 10)
+	snap, err := collector.CollectSnapshot(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("收集失败: %v", err)
+	}
+
+	assert.Greater(t, len(snap.Volumes), 1, "应生成多个分卷")
+	assert.Equal(t, snap.IndexPath, snap.Path, "分卷时快照路径应为索引文件")
+
+	index, err := snapshot.ReadIndex(snap.IndexPath)
+	if err != nil {
+		t.Fatalf("读取分卷索引失败: %v", err)
+	}
+	assert.Len(t, index.Volumes, len(snap.Volumes))
+
+	// 每个分卷都应是独立可读的ZIP文件，且包含清单
+	for _, volumePath := range snap.Volumes {
+		info, err := os.Stat(volumePath)
+		if err != nil {
+			t.Fatalf("分卷不存在: %v", err)
+		}
+		assert.LessOrEqual(t, info.Size(), int64(40<<10), "分卷不应超过大小上限")
+
+		reader, err := zip.OpenReader(volumePath)
+		if err != nil {
+			t.Fatalf("打开分卷失败: %v", err)
+		}
+		last := reader.File[len(reader.File)-1]
+		assert.True(t, strings.HasSuffix(last.Name, "/"+snapshot.ManifestFileName), "分卷中应包含清单")
+		reader.Close()
+	}
+
+	// 校验文件、所有分卷的CRC和清单哈希都应校验通过
+	report, err := snapshot.Verify(snap.Path, snapshot.VerifyOptions{})
+	if err != nil {
+		t.Fatalf("校验快照失败: %v", err)
+	}
+	assert.True(t, report.OK(), "生成的快照应校验通过: %+v", report.Checks)
+}
+
+func TestCollectSnapshot_Encrypted(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "logsnap-test")
+	if err != nil {
+		t.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	processor := new(MockLogProcessor)
+	processor.On("GetName").Return("处理器")
+	processor.On("GetOutputDir").Return("xyz_hmi")
+	processor.On("GetLogPath").Return("/logs", nil)
+	processor.On("Collect", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		file, err := CreateOutputFile(filepath.Join(args.String(2), "xyz_hmi", "app.log"))
+		assert.NoError(t, err)
+		file.Write([]byte("secret line\n"))
+		assert.NoError(t, file.Commit())
+	}).Return("xyz_hmi", []FileProcessResult{{TotalLines: 1, MatchLines: 1}}, nil)
+
+	identity, _ := encryption.GenerateIdentity()
+	collector := NewCollector([]LogProcessor{processor}, tempDir)
+	collector.SetRecipients([]*encryption.Recipient{identity.Recipient()})
+	snapPath, err := collector.Collect(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("收集失败: %v", err)
+	}
+	assert.True(t, strings.HasSuffix(snapPath, ".zip.enc"), "加密快照应以 .enc 结尾")
+
+	// 磁盘上的快照不应包含明文
+	data, _ := os.ReadFile(snapPath)
+	assert.NotContains(t, string(data), "secret line")
+
+	report, err := snapshot.Verify(snapPath, snapshot.VerifyOptions{Identity: identity})
+	if err != nil {
+		t.Fatalf("校验快照失败: %v", err)
+	}
+	assert.True(t, report.OK(), "加密快照应校验通过: %+v", report.Checks)
+
+	zipPath := strings.TrimSuffix(snapPath, encryption.FileExtension)
+	if err := encryption.DecryptFile(snapPath, zipPath, identity); err != nil {
+		t.Fatalf("解密失败: %v", err)
+	}
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatalf("解密后的文件不是合法的ZIP文件: %v", err)
+	}
+	defer reader.Close()
+	assert.Len(t, reader.File, 3, "ZIP文件中应包含日志、报告和清单")
+}
+
+func TestCollectSnapshot_TrimsToMaxSize(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "logsnap-test")
+	if err != nil {
+		t.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// writeLogs 写入难以压缩的 glog 格式日志
+	writeLogs := func(path, severity string, hour, lines int, seed int64) {
+		file, err := CreateOutputFile(path)
+		assert.NoError(t, err)
+		rng := rand.New(rand.NewSource(seed))
+		for i := 0; i < lines; i++ {
+			fmt.Fprintf(file, "%s20250228 %02d:%02d:%02d.000000 1 app.cpp:1] %x %x\n", severity, hour, i/60%60, i%60, rng.Int63(), rng.Int63())
+		}
+		assert.NoError(t, file.Commit())
+	}
+
+	// 第一个处理器只有 INFO 日志，不同文件的最新时间不同
+	infoProcessor := new(MockLogProcessor)
+	infoProcessor.On("GetName").Return("xyz-hmi")
+	infoProcessor.On("GetOutputDir").Return("xyz_hmi")
+	infoProcessor.On("GetLogPath").Return("/logs", nil)
+	infoProcessor.On("Collect", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		outputDir := filepath.Join(args.String(2), "xyz_hmi")
+		for i := 0; i < 3; i++ {
+			writeLogs(filepath.Join(outputDir, fmt.Sprintf("info_%d.log", i)), "I", 10+i, 2000, int64(i))
+		}
+	}).Return("xyz_hmi", []FileProcessResult{{TotalLines: 6000, MatchLines: 6000}}, nil)
+
+	// 第二个处理器优先级较低，但包含 ERROR 日志
+	errorProcessor := new(MockLogProcessor)
+	errorProcessor.On("GetName").Return("xyz-studio-max")
+	errorProcessor.On("GetOutputDir").Return("xyz_studio_max")
+	errorProcessor.On("GetLogPath").Return("/logs", nil)
+	errorProcessor.On("Collect", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		writeLogs(filepath.Join(args.String(2), "xyz_studio_max", "error.log"), "E", 9, 1000, 10)
+	}).Return("xyz_studio_max", []FileProcessResult{{TotalLines: 1000, MatchLines: 1000}}, nil)
+
+	const maxSize = 132 << 10
+	collector := NewCollector([]LogProcessor{infoProcessor, errorProcessor}, tempDir)
+	collector.SetMaxSize(maxSize)
+	snap, err := collector.CollectSnapshot(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("收集失败: %v", err)
+	}
+
+	var total int64
+	for _, file := range snap.Files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("快照文件不存在: %v", err)
+		}
+		total += info.Size()
+	}
+	assert.LessOrEqual(t, total, int64(maxSize), "快照不应超过大小上限")
+
+	reader, err := zip.OpenReader(snap.Path)
+	if err != nil {
+		t.Fatalf("打开ZIP文件失败: %v", err)
+	}
+	defer reader.Close()
+	manifest, err := readTestManifest(reader)
+	if err != nil {
+		t.Fatalf("读取清单失败: %v", err)
+	}
+
+	assert.Equal(t, int64(maxSize), manifest.MaxSize)
+	trimmed := make(map[string]snapshot.TrimmedFile)
+	for _, file := range manifest.Trimmed {
+		trimmed[filepath.Base(file.Name)] = file
+	}
+	assert.NotContains(t, trimmed, "error.log", "ERROR 日志应优先完整保留")
+	assert.NotContains(t, trimmed, "info_2.log", "最新的 INFO 日志应优先保留")
+	if assert.Contains(t, trimmed, "info_0.log", "最旧的 INFO 日志应被裁剪") {
+		assert.Equal(t, snapshot.TrimDropped, trimmed["info_0.log"].Action)
+		assert.Equal(t, "xyz-hmi", trimmed["info_0.log"].Program)
+		assert.Equal(t, "INFO", trimmed["info_0.log"].Severity)
+	}
+
+	// 截断的文件只保留末尾的完整行
+	for _, file := range manifest.Files {
+		t.Logf("保留文件: %s (%d 字节)", file.Name, file.Size)
+		if record, ok := trimmed[filepath.Base(file.Name)]; ok {
+			assert.Equal(t, snapshot.TrimTruncated, record.Action)
+			assert.Equal(t, record.KeptSize, file.Size)
+		}
+	}
+
+	report, err := snapshot.Verify(snap.Path, snapshot.VerifyOptions{})
+	if err != nil {
+		t.Fatalf("校验快照失败: %v", err)
+	}
+	assert.True(t, report.OK(), "裁剪后的快照应校验通过: %+v", report.Checks)
+}
+
+// readTestManifest 读取快照ZIP中的清单
+func readTestManifest(reader *zip.ReadCloser) (*snapshot.Manifest, error) {
+	for _, file := range reader.File {
+		if !strings.HasSuffix(file.Name, "/"+snapshot.ManifestFileName) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		var manifest snapshot.Manifest
+		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+			return nil, err
+		}
+		return &manifest, nil
+	}
+	return nil, fmt.Errorf("快照中没有清单")
+}
+
+func TestCollectSnapshot_Timeline(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "logsnap-test")
+	if err != nil {
+		t.Fatalf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	newProcessor := func(name, dir, file, content string) *MockLogProcessor {
+		processor := new(MockLogProcessor)
+		processor.On("GetName").Return(name)
+		processor.On("GetOutputDir").Return(dir)
+		processor.On("GetLogPath").Return("/logs", nil)
+		processor.On("Collect", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			output, err := CreateOutputFile(filepath.Join(args.String(2), dir, file))
+			assert.NoError(t, err)
+			output.Write([]byte(content))
+			assert.NoError(t, output.Commit())
+		}).Return(dir, []FileProcessResult{{TotalLines: 3, MatchLines: 3}}, nil)
+		return processor
+	}
+
+	hmi := newProcessor("xyz-hmi", "xyz_hmi", "user_op.log",
+		"20250228 10:00:01.000] User clicked [Start].\n"+
+			"20250228 10:00:03.000] User clicked [Stop].\n")
+	server := newProcessor("xyz-max-hmi-server", "xyz_max_hmi_server", "all.log",
+		"2025-02-28 10:00:02.000 | ERROR    | request failed\n"+
+			"Traceback (most recent call last):\n"+
+			"  File \"app.py\", line 1\n"+
+			"2025-02-28 10:00:04.000 | INFO     | recovered\n")
+	studio := newProcessor("xyz-studio-max", "xyz_studio_max", "studio.log.INFO.20250228-100000.1",
+		"Log file created at: 2025/02/28 10:00:00\n"+
+			"W20250228 10:00:02.500000 1 a.cpp:1] slow\n")
+
+	collector := NewCollector([]LogProcessor{hmi, server, studio}, tempDir)
+	collector.SetTimeline(true)
+	snapPath, err := collector.Collect(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("收集失败: %v", err)
+	}
+
+	reader, err := zip.OpenReader(snapPath)
+	if err != nil {
+		t.Fatalf("打开ZIP文件失败: %v", err)
+	}
+	defer reader.Close()
+
+	var timeline string
+	for _, file := range reader.File {
+		if strings.HasSuffix(file.Name, "/"+TimelineFileName) {
+			rc, _ := file.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			timeline = string(data)
+		}
+	}
+
+	expected := "[xyz-hmi xyz_hmi/user_op.log -] 20250228 10:00:01.000] User clicked [Start].\n" +
+		"[xyz-max-hmi-server xyz_max_hmi_server/all.log ERROR] 2025-02-28 10:00:02.000 | ERROR    | request failed\n" +
+		"Traceback (most recent call last):\n" +
+		"  File \"app.py\", line 1\n" +
+		"[xyz-studio-max xyz_studio_max/studio.log.INFO.20250228-100000.1 WARNING] W20250228 10:00:02.500000 1 a.cpp:1] slow\n" +
+		"[xyz-hmi xyz_hmi/user_op.log -] 20250228 10:00:03.000] User clicked [Stop].\n" +
+		"[xyz-max-hmi-server xyz_max_hmi_server/all.log INFO] 2025-02-28 10:00:04.000 | INFO     | recovered\n"
+	assert.Equal(t, expected, timeline, "时间线应按时间合并所有程序的日志，多行日志保持完整")
+}
+
+func TestCollectSnapshot_Filter(t *testing.T) {
+	tempDir := t.TempDir()
+
+	newProcessor := func(name, dir, file, content string) *MockLogProcessor {
+		processor := new(MockLogProcessor)
+		processor.On("GetName").Return(name)
+		processor.On("GetOutputDir").Return(dir)
+		processor.On("GetLogPath").Return("/logs", nil)
+		processor.On("Collect", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			output, err := CreateOutputFile(filepath.Join(args.String(2), dir, file))
+			assert.NoError(t, err)
+			output.Write([]byte(content))
+			assert.NoError(t, output.Commit())
+		}).Return(dir, []FileProcessResult{{TotalLines: 3, MatchLines: 3}}, nil)
+		return processor
+	}
+
+	hmi := newProcessor("xyz-hmi", "xyz_hmi", "user_op.log",
+		"# 原始日志文件: /logs/user_op.log\n"+
+			"20250228 10:00:01.000] User clicked [Start].\n")
+	server := newProcessor("xyz-max-hmi-server", "xyz_max_hmi_server", "all.log",
+		"# 原始日志文件: /logs/all.log\n"+
+			"2025-02-28 10:00:01.000 | ERROR    | disk full\n"+
+			"2025-02-28 10:00:02.000 | ERROR    | request failed\n"+
+			"Traceback (most recent call last):\n"+
+			"2025-02-28 10:00:03.000 | INFO     | request done\n")
+
+	filter, err := logentry.NewFilter("error", []string{"request"})
+	assert.NoError(t, err)
+	collector := NewCollector([]LogProcessor{hmi, server}, tempDir)
+	collector.SetReport(false)
+	collector.SetFilter(filter)
+	snapPath, err := collector.Collect(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("收集失败: %v", err)
+	}
+
+	reader, err := zip.OpenReader(snapPath)
+	if err != nil {
+		t.Fatalf("打开ZIP文件失败: %v", err)
+	}
+	defer reader.Close()
+
+	contents := make(map[string]string)
+	for _, file := range reader.File {
+		rc, _ := file.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[file.Name[strings.Index(file.Name, "/")+1:]] = string(data)
+	}
+
+	_, hasHMI := contents["xyz_hmi/user_op.log"]
+	assert.False(t, hasHMI, "没有满足条件的日志的文件不应写入快照")
+	assert.Equal(t, "# 原始日志文件: /logs/all.log\n"+
+		"2025-02-28 10:00:02.000 | ERROR    | request failed\n"+
+		"Traceback (most recent call last):\n",
+		contents["xyz_max_hmi_server/all.log"], "只保留满足级别和内容条件的日志，多行日志保持完整")
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"logsnap/logentry"
+)
+
+// filterOutput 按级别和正则表达式筛选处理器输出的日志条目
+// 文件中有日志条目但没有一条满足条件时，文件不会出现在快照中
+type filterOutput struct {
+	inner  Output
+	filter *logentry.Filter
+}
+
+func newFilterOutput(inner Output, filter *logentry.Filter) *filterOutput {
+	return &filterOutput{inner: inner, filter: filter}
+}
+
+func (o *filterOutput) Create(name string) (OutputFile, error) {
+	file, err := o.inner.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	writer := logentry.NewFilterWriter(file, o.filter, logentry.SeverityFromFileName(name))
+	return &filterFile{file: file, writer: writer}, nil
+}
+
+// filterFile 筛选后写入下层输出文件
+type filterFile struct {
+	file   OutputFile
+	writer *logentry.FilterWriter
+}
+
+func (f *filterFile) Write(p []byte) (int, error) {
+	return f.writer.Write(p)
+}
+
+func (f *filterFile) Commit() error {
+	if err := f.writer.Flush(); err != nil {
+		f.file.Discard()
+		return err
+	}
+	// 没有可识别的日志条目时（如配置文件）保留原内容
+	if f.writer.Entries() > 0 && f.writer.Matched() == 0 {
+		return f.file.Discard()
+	}
+	return f.file.Commit()
+}
+
+func (f *filterFile) Discard() error {
+	return f.file.Discard()
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"logsnap/collector/utils"
+)
+
+// OutputFile 表示处理器输出的单个文件
+// 写入完成后必须调用 Commit 或 Discard 之一
+type OutputFile interface {
+	io.Writer
+
+	// Commit 确认写入，文件出现在输出中
+	Commit() error
+
+	// Discard 放弃写入，文件不会出现在输出中
+	Discard() error
+}
+
+// Output 定义一次收集的输出目标
+type Output interface {
+	// Create 创建输出文件
+	// 参数:
+	//   - name: 相对输出根目录的路径，使用 / 作为分隔符
+	Create(name string) (OutputFile, error)
+}
+
+// streamRootPrefix 收集时注册的虚拟输出根目录的名称前缀，该目录不会在磁盘上创建
+const streamRootPrefix = "logsnap_stream_"
+
+// outputRegistry 以根目录为键记录已注册的输出目标
+// 处理器仍然按目录路径输出文件，路径位于已注册根目录下时写入对应的输出目标，
+// 否则直接写入本地文件系统；虚拟输出根目录下未注册的路径返回错误
+var outputRegistry = struct {
+	sync.RWMutex
+	outputs map[string]Output
+}{outputs: make(map[string]Output)}
+
+// RegisterOutput 注册输出目标，root 下的所有路径都将写入该目标
+func RegisterOutput(root string, output Output) {
+	outputRegistry.Lock()
+	defer outputRegistry.Unlock()
+	outputRegistry.outputs[filepath.Clean(root)] = output
+}
+
+// UnregisterOutput 注销输出目标
+func UnregisterOutput(root string) {
+	outputRegistry.Lock()
+	defer outputRegistry.Unlock()
+	delete(outputRegistry.outputs, filepath.Clean(root))
+}
+
+// lookupOutput 查找路径所属的输出目标，返回输出目标和相对路径
+func lookupOutput(path string) (Output, string, bool) {
+	path = filepath.Clean(path)
+
+	outputRegistry.RLock()
+	defer outputRegistry.RUnlock()
+	for root, output := range outputRegistry.outputs {
+		if path == root {
+			return output, "", true
+		}
+		if strings.HasPrefix(path, root+string(filepath.Separator)) {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				continue
+			}
+			return output, filepath.ToSlash(rel), true
+		}
+	}
+	return nil, "", false
+}
+
+// checkUnregistered 检查没有注册输出目标的路径是否位于虚拟输出根目录下
+// 这类路径通常是收集已结束（输出已注销）或处理器自行拼接了路径，写入本地文件系统会在临时目录中留下明文日志
+func checkUnregistered(path string) error {
+	for _, part := range strings.Split(filepath.Clean(path), string(filepath.Separator)) {
+		if strings.HasPrefix(part, streamRootPrefix) {
+			return fmt.Errorf("输出路径 %s 不属于正在进行的收集", path)
+		}
+	}
+	return nil
+}
+
+// CreateOutputFile 创建处理器输出文件
+// 路径位于已注册的输出根目录下时写入对应的输出目标，否则在本地文件系统中创建文件
+func CreateOutputFile(path string) (OutputFile, error) {
+	if output, name, ok := lookupOutput(path); ok {
+		return output.Create(name)
+	}
+	if err := checkUnregistered(path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &localOutputFile{file: file}, nil
+}
+
+// MkdirOutputDir 创建处理器输出目录
+// 路径位于已注册的输出根目录下时无需创建目录
+func MkdirOutputDir(path string) error {
+	if _, _, ok := lookupOutput(path); ok {
+		return nil
+	}
+	if err := checkUnregistered(path); err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0755)
+}
+
+// localOutputFile 本地文件系统中的输出文件
+type localOutputFile struct {
+	file *os.File
+}
+
+func (f *localOutputFile) Write(p []byte) (int, error) {
+	return f.file.Write(p)
+}
+
+func (f *localOutputFile) Commit() error {
+	return f.file.Close()
+}
+
+func (f *localOutputFile) Discard() error {
+	f.file.Close()
+	return os.Remove(f.file.Name())
+}
+
+// zipOutput 将输出文件直接写入流式ZIP文件
+type zipOutput struct {
+	writer *utils.ZipVolumeWriter
+}
+
+func (o *zipOutput) Create(name string) (OutputFile, error) {
+	if name == "" {
+		return nil, fmt.Errorf("输出文件名不能为空")
+	}
+	return o.writer.Create(name)
+}
@@ -3,6 +3,7 @@ package processor
 import (
 	"fmt"
 	collector "logsnap/collector"
+	"logsnap/logentry"
 	"path/filepath"
 	"time"
 )
@@ -39,6 +40,12 @@ func (p *BaseProcessor) GetOutputDir() string {
 	return p.OutputDir
 }
 
+// GetTimeLayouts 返回日志行的时间戳格式，子类应该覆盖此方法
+func (p *BaseProcessor) GetTimeLayouts() []logentry.Layout {
+	// 默认实现，子类应该覆盖
+	return nil
+}
+
 // Collect 处理日志文件的通用方法，子类可以覆盖
 func (p *BaseProcessor) Collect(startTime, endTime time.Time, rootOutputDir string) (string, []collector.FileProcessResult, error) {
 	// 创建文件处理器
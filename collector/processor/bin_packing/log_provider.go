@@ -9,6 +9,7 @@ import (
 
 	"logsnap/collector"
 	processor "logsnap/collector/processor"
+	"logsnap/logentry"
 )
 
 // 日志文件后缀是动态的数字（例如：2966778）
@@ -21,6 +22,12 @@ var timePatternForProgramLogFile = regexp.MustCompile(`\.(\d{8}-\d{6})\.`)
 // [IWEF]yyyymmdd hh:mm:ss.uuuuuu threadid file:line] msg
 var timePatternForProgramLogLine = regexp.MustCompile(`[IWEF](\d{4}\d{2}\d{2} \d{2}:\d{2}:\d{2}\.\d{6})`)
 
+// 程序日志行的时间格式
+const timeFormatForProgramLogLine = "20060102 15:04:05.000000"
+
+// logTimeLayout 程序日志行的时间戳格式
+var logTimeLayout = logentry.Layout{Pattern: timePatternForProgramLogLine, Format: timeFormatForProgramLogLine}
+
 type LogFileInfoFilter struct{}
 
 func (f *LogFileInfoFilter) parseLogFileInfo(filePath string) (processor.LogFileInfo, error) {
@@ -142,7 +149,7 @@ func (p *LogFileProcessorProvider) ProcessFile(fileInfo processor.LogFileInfo, s
 
 	// 根据日志类型选择不同的时间模式和格式
 	timePattern = timePatternForProgramLogLine
-	timeFormat = "20060102 15:04:05.000000" // 程序日志的时间格式
+	timeFormat = timeFormatForProgramLogLine
 	return processor.ProcessLogWithStrategy(fileInfo, outputDir, &processor.FilterLogProcessor{
 		TimePattern: timePattern,
 		TimeFormat: timeFormat,
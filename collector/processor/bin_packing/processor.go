@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"logsnap/collector"
 	processor "logsnap/collector/processor"
+	"logsnap/logentry"
 	"path/filepath"
 	"time"
 )
@@ -40,4 +41,9 @@ func (p *BinPackingLogProcessor) CreateFileProcessor() []processor.FileProcessor
 		NewJsonFileProcessorProvider(),
 		processor.NewCppLogFileProcessorProvider(),
 	}
-} 
\ No newline at end of file
+}
+
+// GetTimeLayouts 返回日志行的时间戳格式
+func (p *BinPackingLogProcessor) GetTimeLayouts() []logentry.Layout {
+	return []logentry.Layout{logTimeLayout}
+}
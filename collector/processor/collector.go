@@ -32,7 +32,7 @@ func CollectWithProcessor(
 ) (string, []collector.FileProcessResult, error) {
 	logrus.Infof("创建日志输出目录: %s", outputDir)
 
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	if err := collector.MkdirOutputDir(outputDir); err != nil {
 		return "", []collector.FileProcessResult{}, fmt.Errorf("创建日志输出目录失败: %w", err)
 	}
 
@@ -104,7 +104,7 @@ func processDirectory(
 
 		// 创建对应的输出子目录
 		subOutputDir := filepath.Join(outputDir, subDirName)
-		if err := os.MkdirAll(subOutputDir, 0755); err != nil {
+		if err := collector.MkdirOutputDir(subOutputDir); err != nil {
 			logrus.Errorf("创建输出子目录 %s 失败: %v\n", subOutputDir, err)
 			continue
 		}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"logsnap/collector"
 	processor "logsnap/collector/processor"
+	"logsnap/logentry"
 	"path/filepath"
 	"time"
 )
@@ -40,3 +41,8 @@ func (p *CppLogProcessor) CreateFileProcessor() []processor.FileProcessorProvide
 		processor.NewCppLogFileProcessorProvider(),
 	}
 }
+
+// GetTimeLayouts 返回日志行的时间戳格式
+func (p *CppLogProcessor) GetTimeLayouts() []logentry.Layout {
+	return []logentry.Layout{processor.CppLogTimeLayout}
+}
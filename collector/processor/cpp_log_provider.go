@@ -3,6 +3,7 @@ package processor
 import (
 	"fmt"
 	collector "logsnap/collector"
+	"logsnap/logentry"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -19,6 +20,12 @@ var timePatternForProgramLogFile = regexp.MustCompile(`\.(\d{8}-\d{6})\.`)
 // [IWEF]yyyymmdd hh:mm:ss.uuuuuu threadid file:line] msg
 var timePatternForProgramLogLine = regexp.MustCompile(`[IWEF](\d{4}\d{2}\d{2} \d{2}:\d{2}:\d{2}\.\d{6})`)
 
+// 程序日志行的时间格式
+const timeFormatForProgramLogLine = "20060102 15:04:05.000000"
+
+// CppLogTimeLayout 程序日志行的时间戳格式，使用程序日志的处理器通过 GetTimeLayouts 返回它
+var CppLogTimeLayout = logentry.Layout{Pattern: timePatternForProgramLogLine, Format: timeFormatForProgramLogLine}
+
 type CppLogFileInfoFilter struct{}
 
 func (f *CppLogFileInfoFilter) parseLogFileInfo(filePath string) (LogFileInfo, error) {
@@ -140,7 +147,7 @@ func (p *CppLogFileProcessorProvider) ProcessFile(fileInfo LogFileInfo, startTim
 
 	// 根据日志类型选择不同的时间模式和格式
 	timePattern = timePatternForProgramLogLine
-	timeFormat = "20060102 15:04:05.000000" // 程序日志的时间格式
+	timeFormat = timeFormatForProgramLogLine
 	return ProcessLogWithStrategy(fileInfo, outputDir, &FilterLogProcessor{
 		TimePattern: timePattern,
 		TimeFormat: timeFormat,
@@ -130,56 +130,63 @@ func ProcessLogFileByLine(
 	outputFileName := fileNameProcessor(fileInfo)
 	outputPath := filepath.Join(outputDir, outputFileName)
 
-	// 打开输出文件
-	outputFile, err := os.Create(outputPath)
+	// 创建输出文件，位于快照目录下时直接写入快照
+	outputFile, err := collector.CreateOutputFile(outputPath)
 	if err != nil {
 		return 0, 0, 0, "", fmt.Errorf("创建输出文件失败: %w", err)
 	}
-	defer outputFile.Close()
 
 	// 创建读取器
 	reader, err := readerCreator(fileInfo)
 	if err != nil {
+		outputFile.Discard()
 		return 0, 0, 0, "", err
 	}
 	defer reader.Close()
 
-	// 处理日志内容
-	lineCount, matchCount, totalSize, err := ProcessLogContent(reader, outputFile, timePattern, timeFormat, startTime, endTime)
+	// 处理日志内容，在写入第一条匹配的日志前写入文件头
+	headerWriter := &fileHeaderWriter{
+		writer:       outputFile,
+		originalPath: fileInfo.Path,
+		startTime:    startTime,
+		endTime:      endTime,
+	}
+	lineCount, matchCount, totalSize, err := ProcessLogContent(reader, headerWriter, timePattern, timeFormat, startTime, endTime)
 	if err != nil {
+		outputFile.Discard()
 		return lineCount, matchCount, 0, "", err
 	}
 
-	// 如果有匹配的内容，写入文件头
-	if lineCount > 0 && matchCount > 0 {
-		// 重新打开文件以在开头写入头信息
-		outputFile.Seek(0, 0)
-		tempContent, err := io.ReadAll(outputFile)
-		if err != nil {
-			return lineCount, matchCount, 0, "", fmt.Errorf("读取临时内容失败: %w", err)
-		}
+	// 没有匹配的内容时丢弃输出文件
+	if lineCount == 0 || matchCount == 0 {
+		outputFile.Discard()
+		return lineCount, matchCount, totalSize, "", nil
+	}
 
-		outputFile.Seek(0, 0)
-		outputFile.Truncate(0)
+	if err := outputFile.Commit(); err != nil {
+		return lineCount, matchCount, 0, "", fmt.Errorf("写入输出文件失败: %w", err)
+	}
 
-		// 写入文件头
-		err = WriteFileHeader(outputFile, fileInfo.Path, startTime, endTime)
-		if err != nil {
-			return lineCount, matchCount, 0, "", fmt.Errorf("写入文件头失败: %w", err)
-		}
+	return lineCount, matchCount, totalSize, outputPath, nil
+}
 
-		// 写回原内容
-		_, err = outputFile.Write(tempContent)
-		if err != nil {
-			return lineCount, matchCount, 0, "", fmt.Errorf("写回原内容失败: %w", err)
-		}
+// fileHeaderWriter 在第一次写入内容前写入文件头
+type fileHeaderWriter struct {
+	writer        io.Writer
+	originalPath  string
+	startTime     time.Time
+	endTime       time.Time
+	headerWritten bool
+}
 
-		return lineCount, matchCount, totalSize, outputPath, nil
-	} else {
-		// 删除空文件
-		os.Remove(outputPath)
-		return lineCount, matchCount, totalSize, "", nil
+func (w *fileHeaderWriter) Write(p []byte) (int, error) {
+	if !w.headerWritten {
+		w.headerWritten = true
+		if err := WriteFileHeader(w.writer, w.originalPath, w.startTime, w.endTime); err != nil {
+			return 0, fmt.Errorf("写入文件头失败: %w", err)
+		}
 	}
+	return w.writer.Write(p)
 }
 
 // ProcessLogFileByCopying 处理单个日志文件，处理逻辑是直接将原文件内容复制一份到目标目录
@@ -207,19 +214,23 @@ func ProcessLogFileByCopying(
 	}
 	defer sourceFile.Close()
 
-	// 创建目标文件
-	destFile, err := os.Create(outputPath)
+	// 创建目标文件，位于快照目录下时直接写入快照
+	destFile, err := collector.CreateOutputFile(outputPath)
 	if err != nil {
 		return 0, 0, "", fmt.Errorf("创建目标文件失败: %w", err)
 	}
-	defer destFile.Close()
 
 	// 复制文件内容
 	bytesCopied, err := io.Copy(destFile, sourceFile)
 	if err != nil {
+		destFile.Discard()
 		return int64(bytesCopied), 0, "", fmt.Errorf("复制文件内容失败: %w", err)
 	}
 
+	if err := destFile.Commit(); err != nil {
+		return int64(bytesCopied), 0, "", fmt.Errorf("写入目标文件失败: %w", err)
+	}
+
 	return int64(bytesCopied), 1, outputPath, nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"logsnap/collector"
 	processor "logsnap/collector/processor"
+	"logsnap/logentry"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -16,6 +17,10 @@ var timePatternForUserOpLogFile = regexp.MustCompile(`^(\d{8}-\d{6})\.`)
 // 用于从用户操作日志行提取时间戳的正则表达式
 // 例如：20250302 08:41:13.163] User clicked [StartTask].
 var timePatternForUserOpLogLine = regexp.MustCompile(`^(\d{8} \d{2}:\d{2}:\d{2}\.\d{3})]`)
+// 用户操作日志行的时间格式
+const timeFormatForUserOpLogLine = "20060102 15:04:05.000"
+// userOpTimeLayout 用户操作日志行的时间戳格式
+var userOpTimeLayout = logentry.Layout{Pattern: timePatternForUserOpLogLine, Format: timeFormatForUserOpLogLine}
 
 
 type UserOpFileInfoFilter struct {}
@@ -99,7 +104,7 @@ func (p *UserOpFileProcessorProvider) ProcessFile(fileInfo processor.LogFileInfo
 	var timeFormat string
 
 	timePattern = timePatternForUserOpLogLine
-	timeFormat = "20060102 15:04:05.000" // 用户操作日志的时间格式
+	timeFormat = timeFormatForUserOpLogLine
 
 	// 使用通用的日志处理函数，提供自定义的文件名处理器
 	return processor.ProcessLogWithStrategy(
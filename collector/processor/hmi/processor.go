@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"logsnap/collector"
 	processor "logsnap/collector/processor"
+	"logsnap/logentry"
 	"path/filepath"
 	"time"
 )
@@ -42,3 +43,8 @@ func (p *HMILogProcessor) CreateFileProcessor() []processor.FileProcessorProvide
 		processor.NewCppLogFileProcessorProvider(),
 	}
 }
+
+// GetTimeLayouts 返回日志行的时间戳格式
+func (p *HMILogProcessor) GetTimeLayouts() []logentry.Layout {
+	return []logentry.Layout{userOpTimeLayout, processor.CppLogTimeLayout}
+}
@@ -79,7 +79,7 @@ func (p *HMIServerLogFileProcessorProvider) ProcessFile(fileInfo processor.LogFi
 
 	// 根据日志类型选择不同的时间模式和格式
 	timePattern = logTimePattern
-	timeFormat = "2006-01-02 15:04:05.000" // 程序日志的时间格式
+	timeFormat = logTimeFormat
 	return processor.ProcessLogWithStrategy(fileInfo, outputDir, &processor.FilterLogProcessor{
 		TimePattern: timePattern,
 		TimeFormat: timeFormat,
@@ -11,6 +11,7 @@ import (
 
 	"logsnap/collector"
 	processor "logsnap/collector/processor"
+	"logsnap/logentry"
 )
 
 // HMIServer 的日志文件
@@ -29,6 +30,12 @@ import (
 // 例如：2025-02-28 13:35:27.015 |
 var logTimePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3}) \|`)
 
+// 日志行的时间格式
+const logTimeFormat = "2006-01-02 15:04:05.000"
+
+// logTimeLayout 日志行的时间戳格式
+var logTimeLayout = logentry.Layout{Pattern: logTimePattern, Format: logTimeFormat}
+
 // HMIServerFileNameProcessor 为 HMI 服务器日志生成输出文件名
 func HMIServerFileNameProcessor(fileInfo processor.LogFileInfo) string {
 	if fileInfo.FileType == "zip" {
@@ -118,4 +125,9 @@ func (p *HMIServerLogProcessor) CreateFileProcessor() []processor.FileProcessorP
 		NewHMIServerLogFileProcessorProvider(),
 		NewHMIServerArchiveLogFileProcessorProvider(),
 	}
-}
\ No newline at end of file
+}
+
+// GetTimeLayouts 返回日志行的时间戳格式
+func (p *HMIServerLogProcessor) GetTimeLayouts() []logentry.Layout {
+	return []logentry.Layout{logTimeLayout}
+}
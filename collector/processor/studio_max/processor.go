@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"logsnap/collector"
 	processor "logsnap/collector/processor"
+	"logsnap/logentry"
 	"path/filepath"
 	"time"
 )
@@ -41,4 +42,9 @@ func (p *StudioMaxLogProcessor) CreateFileProcessor() []processor.FileProcessorP
 	return []processor.FileProcessorProvider{
 		processor.NewCppLogFileProcessorProvider(),
 	}
-} 
\ No newline at end of file
+}
+
+// GetTimeLayouts 返回日志行的时间戳格式
+func (p *StudioMaxLogProcessor) GetTimeLayouts() []logentry.Layout {
+	return []logentry.Layout{processor.CppLogTimeLayout}
+}
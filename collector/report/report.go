@@ -0,0 +1,290 @@
+// Package report 生成快照中的 report.html 摘要报告
+// 报告是单个自包含的 HTML 文件，不引用任何外部资源，离线即可打开
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"logsnap/logentry"
+)
+
+// FileName 报告在快照目录中的文件名
+const FileName = "report.html"
+
+const (
+	// maxBars 每个直方图最多显示的柱数，时间范围较长时多个分钟合并为一柱
+	maxBars = 120
+	// maxSignatures 每个程序最多记录的不同错误消息数量
+	maxSignatures = 2000
+	// topErrorCount 报告中显示的高频错误数量
+	topErrorCount = 20
+)
+
+//go:embed template.html
+var templateText string
+
+var reportTemplate = template.Must(template.New(FileName).Funcs(template.FuncMap{
+	"size": formatSize,
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+	"mulRate": func(v float64) float64 { return v * 100 },
+	"lastBar": func(bars []Bar) Bar { return bars[len(bars)-1] },
+}).Parse(templateText))
+
+// Report 报告数据
+type Report struct {
+	Version    string             // 生成快照的 logsnap 版本
+	Hostname   string             // 采集主机名
+	CreatedAt  time.Time          // 快照创建时间
+	StartTime  time.Time          // 采集开始时间
+	EndTime    time.Time          // 采集结束时间
+	Processors []ProcessorSummary // 各处理器的统计
+	Programs   []*Program         // 各程序的日志统计
+	Files      []File             // 快照中的文件
+	Timeline   string             // 合并时间线文件的相对路径，未生成时为空
+}
+
+// ProcessorSummary 单个处理器的统计
+type ProcessorSummary struct {
+	Name    string   // 处理器名称
+	Files   int      // 处理的文件数
+	Lines   int      // 处理的总行数
+	Matches int      // 匹配的行数
+	Bytes   int64    // 处理的文件大小
+	Errors  []string // 处理过程中的错误
+}
+
+// File 快照中的单个文件
+type File struct {
+	Name    string // 相对快照目录的路径，同时作为链接地址
+	Program string // 产生该文件的程序
+	Size    uint64 // 文件大小
+	Lines   int    // 行数
+	Trimmed string // 裁剪方式，snapshot.TrimDropped 或 snapshot.TrimTruncated，未裁剪时为空
+}
+
+// Bar 直方图中的一柱
+type Bar struct {
+	Start   time.Time // 开始时间
+	Entries int       // 日志条数
+	Errors  int       // ERROR 和 FATAL 日志条数
+	Height  float64   // 柱高，相对最高柱的百分比
+	Rate    float64   // 错误率
+}
+
+// ErrorSummary 一类重复出现的错误
+type ErrorSummary struct {
+	Program string    // 程序
+	Message string    // 归一化后的错误消息，数字、地址等可变内容被替换为占位符
+	Example string    // 第一次出现时的原始内容
+	Count   int       // 出现次数
+	First   time.Time // 第一次出现的时间
+	Last    time.Time // 最后一次出现的时间
+}
+
+// Program 单个程序的日志统计，按分钟记录日志条数和错误数，并归类错误消息
+type Program struct {
+	Name    string
+	mu      sync.Mutex
+	entries int
+	errors  int
+	minutes map[int64]*minute
+	signs   map[string]*ErrorSummary
+}
+
+type minute struct {
+	entries int
+	errors  int
+}
+
+// NewProgram 创建程序统计
+func NewProgram(name string) *Program {
+	return &Program{
+		Name:    name,
+		minutes: make(map[int64]*minute),
+		signs:   make(map[string]*ErrorSummary),
+	}
+}
+
+// Observe 记录一条日志，可以并发调用
+func (p *Program) Observe(entry logentry.Entry, line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := entry.Time.Truncate(time.Minute).Unix()
+	m := p.minutes[key]
+	if m == nil {
+		m = &minute{}
+		p.minutes[key] = m
+	}
+	m.entries++
+	p.entries++
+
+	if entry.Severity < logentry.SeverityError {
+		return
+	}
+	m.errors++
+	p.errors++
+
+	message := strings.TrimSpace(string(line[entry.Offset:]))
+	signature := logentry.Template(message)
+	summary := p.signs[signature]
+	if summary == nil {
+		if len(p.signs) >= maxSignatures {
+			return
+		}
+		summary = &ErrorSummary{Program: p.Name, Message: signature, Example: message, First: entry.Time}
+		p.signs[signature] = summary
+	}
+	summary.Count++
+	if entry.Time.Before(summary.First) {
+		summary.First = entry.Time
+	}
+	if entry.Time.After(summary.Last) {
+		summary.Last = entry.Time
+	}
+}
+
+// Entries 返回日志条数
+func (p *Program) Entries() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.entries
+}
+
+// Errors 返回 ERROR 和 FATAL 日志条数
+func (p *Program) Errors() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.errors
+}
+
+// Span 返回每柱包含的分钟数
+func (p *Program) Span() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	first, last := p.bounds()
+	return barSpan(first, last)
+}
+
+// Histogram 返回日志条数和错误率的直方图，时间范围超过 maxBars 分钟时多个分钟合并为一柱
+func (p *Program) Histogram() []Bar {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.minutes) == 0 {
+		return nil
+	}
+
+	first, last := p.bounds()
+	span := int64(barSpan(first, last))
+	bars := make([]Bar, (last-first)/60/span+1)
+	for key, m := range p.minutes {
+		bar := &bars[(key-first)/60/span]
+		bar.Entries += m.entries
+		bar.Errors += m.errors
+	}
+
+	highest := 0
+	for i := range bars {
+		bars[i].Start = time.Unix(first+int64(i)*span*60, 0)
+		if bars[i].Entries > highest {
+			highest = bars[i].Entries
+		}
+	}
+	for i := range bars {
+		if bars[i].Entries > 0 {
+			bars[i].Height = float64(bars[i].Entries) / float64(highest) * 100
+			bars[i].Rate = float64(bars[i].Errors) / float64(bars[i].Entries)
+		}
+	}
+	return bars
+}
+
+// bounds 返回最早和最晚的分钟，调用方需持有锁
+func (p *Program) bounds() (int64, int64) {
+	var first, last int64
+	for key := range p.minutes {
+		if first == 0 || key < first {
+			first = key
+		}
+		if key > last {
+			last = key
+		}
+	}
+	return first, last
+}
+
+// barSpan 返回每柱包含的分钟数
+func barSpan(first, last int64) int {
+	minutes := int((last-first)/60) + 1
+	return (minutes + maxBars - 1) / maxBars
+}
+
+// TopErrors 返回所有程序中出现次数最多的错误
+func TopErrors(programs []*Program, n int) []ErrorSummary {
+	var all []ErrorSummary
+	for _, p := range programs {
+		p.mu.Lock()
+		for _, summary := range p.signs {
+			all = append(all, *summary)
+		}
+		p.mu.Unlock()
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Count != all[j].Count {
+			return all[i].Count > all[j].Count
+		}
+		return all[i].First.Before(all[j].First)
+	})
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// Render 将报告渲染为 HTML
+func Render(w io.Writer, r *Report) error {
+	data := struct {
+		*Report
+		TopErrors []ErrorSummary
+	}{r, TopErrors(r.Programs, topErrorCount)}
+	if err := reportTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("生成报告失败: %w", err)
+	}
+	return nil
+}
+
+// formatSize 将字节数格式化为易读的大小
+func formatSize(size interface{}) string {
+	var bytes float64
+	switch v := size.(type) {
+	case int64:
+		bytes = float64(v)
+	case uint64:
+		bytes = float64(v)
+	case int:
+		bytes = float64(v)
+	}
+	units := []string{"B", "KB", "MB", "GB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"logsnap/logentry"
+)
+
+func observe(p *Program, line string) {
+	entry, ok := logentry.Parse([]byte(line))
+	if !ok {
+		panic("无法解析日志行: " + line)
+	}
+	p.Observe(entry, []byte(line))
+}
+
+func TestProgram_HistogramAndTopErrors(t *testing.T) {
+	p := NewProgram("xyz-hmi")
+	observe(p, "I20250228 10:00:01.000000 1 a.cpp:1] start")
+	observe(p, "E20250228 10:00:02.000000 1 a.cpp:9] connect 127.0.0.1:7010 failed, retry 1")
+	observe(p, "E20250228 10:02:02.000000 1 a.cpp:9] connect 127.0.0.1:7010 failed, retry 2")
+	observe(p, "E20250228 10:02:03.000000 1 b.cpp:3] out of memory")
+
+	bars := p.Histogram()
+	if len(bars) != 3 {
+		t.Fatalf("应按分钟生成 3 柱, 实际 %d", len(bars))
+	}
+	if bars[0].Entries != 2 || bars[0].Errors != 1 || bars[0].Rate != 0.5 {
+		t.Errorf("第一柱统计错误: %+v", bars[0])
+	}
+	if bars[1].Entries != 0 || bars[2].Entries != 2 || bars[2].Height != 100 {
+		t.Errorf("柱高或空柱统计错误: %+v", bars)
+	}
+
+	top := TopErrors([]*Program{p}, 10)
+	if len(top) != 2 {
+		t.Fatalf("数字不同的同类错误应归为一类, 实际 %d 类", len(top))
+	}
+	if top[0].Count != 2 || !strings.Contains(top[0].Message, "connect <IP> failed") {
+		t.Errorf("高频错误统计错误: %+v", top[0])
+	}
+	if !top[0].Last.Equal(time.Date(2025, 2, 28, 10, 2, 2, 0, time.Local)) {
+		t.Errorf("最后出现时间错误: %v", top[0].Last)
+	}
+}
+
+func TestProgram_HistogramMergesLongRanges(t *testing.T) {
+	p := NewProgram("xyz-hmi")
+	start := time.Date(2025, 2, 28, 0, 0, 0, 0, time.Local)
+	for i := 0; i < 24*60; i += 7 {
+		p.Observe(logentry.Entry{Time: start.Add(time.Duration(i) * time.Minute), Severity: logentry.SeverityInfo}, nil)
+	}
+	if bars := p.Histogram(); len(bars) > maxBars {
+		t.Fatalf("直方图柱数 %d 超过上限 %d", len(bars), maxBars)
+	}
+	if p.Span() != 12 {
+		t.Errorf("一天的日志每柱应为 12 分钟, 实际 %d", p.Span())
+	}
+}
+
+func TestRender(t *testing.T) {
+	p := NewProgram("xyz-hmi")
+	observe(p, "E20250228 10:00:02.000000 1 a.cpp:9] <script>alert(1)</script>")
+
+	var buf bytes.Buffer
+	err := Render(&buf, &Report{
+		Version:    "v1.0.0",
+		Hostname:   "host",
+		Processors: []ProcessorSummary{{Name: "xyz-hmi", Files: 1, Lines: 10, Matches: 1, Bytes: 2048}},
+		Programs:   []*Program{p},
+		Files:      []File{{Name: "xyz_hmi/app.log", Program: "xyz-hmi", Size: 100, Lines: 1}},
+	})
+	if err != nil {
+		t.Fatalf("生成报告失败: %v", err)
+	}
+
+	html := buf.String()
+	for _, want := range []string{`href="xyz_hmi/app.log"`, "2.0 KB", "v1.0.0", "&lt;script&gt;"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("报告中应包含 %q", want)
+		}
+	}
+	// 报告必须离线可用，不引用外部资源
+	for _, external := range []string{"http://", "https://", "<script", "<link"} {
+		if strings.Contains(html, external) {
+			t.Errorf("报告不应包含外部资源 %q", external)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"logsnap/collector/report"
+	"logsnap/collector/utils"
+	"logsnap/logentry"
+	"logsnap/snapshot"
+)
+
+// reportReserve 限制快照大小时为摘要报告预留的空间
+const reportReserve = 32 << 10
+
+// reportOutput 在写入快照的同时统计每个程序的日志，用于生成 report.html
+type reportOutput struct {
+	inner       Output
+	processors  []LogProcessor
+	snapDirName string
+	mu          sync.Mutex
+	programs    map[string]*report.Program
+	files       []report.File
+}
+
+func newReportOutput(inner Output, processors []LogProcessor, snapDirName string) *reportOutput {
+	return &reportOutput{
+		inner:       inner,
+		processors:  processors,
+		snapDirName: snapDirName,
+		programs:    make(map[string]*report.Program),
+	}
+}
+
+func (o *reportOutput) Create(name string) (OutputFile, error) {
+	file, err := o.inner.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	programName, _ := entryProcessor(o.processors, o.snapDirName, name)
+	program := o.program(programName)
+	stats := &logentry.Stats{OnEntry: program.Observe}
+	return &reportFile{output: o, name: name, program: programName, file: file, stats: stats}, nil
+}
+
+// program 返回程序的统计，不存在时创建
+func (o *reportOutput) program(name string) *report.Program {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	program := o.programs[name]
+	if program == nil {
+		program = report.NewProgram(name)
+		o.programs[name] = program
+	}
+	return program
+}
+
+// reportFile 写入快照的同时统计日志
+type reportFile struct {
+	output  *reportOutput
+	name    string
+	program string
+	file    OutputFile
+	stats   *logentry.Stats
+	size    uint64
+}
+
+func (f *reportFile) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.stats.Write(p[:n])
+	f.size += uint64(n)
+	return n, err
+}
+
+func (f *reportFile) Commit() error {
+	f.stats.Flush()
+	if err := f.file.Commit(); err != nil {
+		return err
+	}
+
+	f.output.mu.Lock()
+	f.output.files = append(f.output.files, report.File{
+		Name:    strings.TrimPrefix(f.name, f.output.snapDirName+"/"),
+		Program: f.program,
+		Size:    f.size,
+		Lines:   f.stats.Lines,
+	})
+	f.output.mu.Unlock()
+	return nil
+}
+
+func (f *reportFile) Discard() error {
+	return f.file.Discard()
+}
+
+// build 汇总处理器结果和日志统计，生成报告数据
+func (o *reportOutput) build(base report.Report, results []ProcessorResult, trimmed []snapshot.TrimmedFile) *report.Report {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	r := base
+	for _, processor := range o.processors {
+		summary := report.ProcessorSummary{Name: processor.GetName()}
+		for _, result := range results {
+			if result.processorName != summary.Name {
+				continue
+			}
+			summary.Files += result.GetFileCount()
+			summary.Lines += result.GetTotalLines()
+			summary.Matches += result.GetMatchLines()
+			summary.Bytes += result.GetFileSize()
+			if result.err != nil {
+				summary.Errors = append(summary.Errors, result.err.Error())
+			}
+			for _, file := range result.results {
+				if file.Err != nil {
+					summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v", file.FilePath, file.Err))
+				}
+			}
+		}
+		r.Processors = append(r.Processors, summary)
+
+		if program := o.programs[summary.Name]; program != nil {
+			r.Programs = append(r.Programs, program)
+		}
+	}
+
+	trimAction := make(map[string]string, len(trimmed))
+	for _, file := range trimmed {
+		trimAction[strings.TrimPrefix(file.Name, o.snapDirName+"/")] = file.Action
+	}
+	for _, file := range o.files {
+		file.Trimmed = trimAction[file.Name]
+		r.Files = append(r.Files, file)
+	}
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Name < r.Files[j].Name })
+	return &r
+}
+
+// writeReport 将报告直接写入快照，报告不参与大小预算的裁剪
+func writeReport(writer *utils.ZipVolumeWriter, snapDirName string, r *report.Report) error {
+	entry, err := writer.Create(path.Join(snapDirName, report.FileName))
+	if err != nil {
+		return err
+	}
+	if err := report.Render(entry, r); err != nil {
+		entry.Discard()
+		return err
+	}
+	return entry.Commit()
+}
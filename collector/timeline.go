@@ -0,0 +1,308 @@
+package collector
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"logsnap/collector/utils"
+	"logsnap/logentry"
+)
+
+// TimelineFileName 合并时间线在快照目录中的文件名
+const TimelineFileName = "timeline.log"
+
+// timelineOutput 在写入快照的同时将处理器输出复制到缓存区域中的临时文件，用于生成合并时间线
+// 临时文件与快照在同一目录下，快照加密时临时文件同样加密
+type timelineOutput struct {
+	inner   Output
+	area    *utils.SpillArea
+	mu      sync.Mutex
+	sources []timelineSource
+}
+
+// timelineSource 参与合并的单个输出文件
+type timelineSource struct {
+	name string          // 快照中的条目名称
+	temp *utils.TempFile // 输出内容的副本
+}
+
+func newTimelineOutput(inner Output, area *utils.SpillArea) *timelineOutput {
+	return &timelineOutput{inner: inner, area: area}
+}
+
+func (o *timelineOutput) Create(name string) (OutputFile, error) {
+	file, err := o.inner.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	temp, err := o.area.CreateTemp("timeline_*")
+	if err != nil {
+		file.Discard()
+		return nil, fmt.Errorf("创建时间线临时文件失败: %w", err)
+	}
+	return &timelineFile{output: o, name: name, file: file, temp: temp}, nil
+}
+
+// cleanup 删除所有临时文件
+func (o *timelineOutput) cleanup() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, source := range o.sources {
+		source.temp.Remove()
+	}
+	o.sources = nil
+}
+
+// timelineFile 同时写入快照和临时文件
+type timelineFile struct {
+	output *timelineOutput
+	name   string
+	file   OutputFile
+	temp   *utils.TempFile
+}
+
+func (f *timelineFile) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if _, err := f.temp.Write(p[:n]); err != nil {
+		return n, fmt.Errorf("写入时间线临时文件失败: %w", err)
+	}
+	return n, nil
+}
+
+func (f *timelineFile) Commit() error {
+	if err := f.temp.Finish(); err != nil {
+		f.file.Discard()
+		f.temp.Remove()
+		return fmt.Errorf("写入时间线临时文件失败: %w", err)
+	}
+	if err := f.file.Commit(); err != nil {
+		f.temp.Remove()
+		return err
+	}
+
+	f.output.mu.Lock()
+	f.output.sources = append(f.output.sources, timelineSource{name: f.name, temp: f.temp})
+	f.output.mu.Unlock()
+	return nil
+}
+
+func (f *timelineFile) Discard() error {
+	f.temp.Remove()
+	return f.file.Discard()
+}
+
+// writeTimeline 将所有输出文件中的日志按时间戳合并写入快照目录下的 timeline.log
+// 每条日志的第一行加上来源标签 "[程序 文件 级别] "，多行日志的后续行原样保留。
+// 返回写入的日志条数，没有可识别时间戳的日志时不生成文件
+func (o *timelineOutput) writeTimeline(processors []LogProcessor, snapDirName string) (int, error) {
+	o.mu.Lock()
+	sources := append([]timelineSource(nil), o.sources...)
+	o.mu.Unlock()
+
+	merger := &timelineMerger{}
+	defer merger.close()
+	for _, source := range sources {
+		rel := strings.TrimPrefix(source.name, snapDirName+"/")
+		program, _ := entryProcessor(processors, snapDirName, source.name)
+		if err := merger.add(source.temp, program, rel); err != nil {
+			return 0, err
+		}
+	}
+
+	file, err := o.inner.Create(path.Join(snapDirName, TimelineFileName))
+	if err != nil {
+		return 0, err
+	}
+	writer := bufio.NewWriterSize(file, 64<<10)
+	count, err := merger.writeTo(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil || count == 0 {
+		file.Discard()
+		return 0, err
+	}
+	if err := file.Commit(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// timelineCursor 单个来源文件的读取位置，每次读取一条完整的（可能是多行的）日志
+type timelineCursor struct {
+	file      io.ReadCloser
+	reader    *bufio.Reader
+	order     int    // 来源顺序，时间相同时保持稳定
+	label     string // 不含级别的来源标签
+	fileSev   logentry.Severity
+	time      time.Time
+	severity  logentry.Severity
+	lines     [][]byte // 当前日志的所有行
+	pending   []byte   // 已读取的下一条日志的第一行
+	pendEntry logentry.Entry
+	eof       bool
+}
+
+// next 读取下一条日志，没有更多日志时返回 false
+// 文件开头没有时间戳的行（例如 glog 的文件头）会被跳过
+func (c *timelineCursor) next() (bool, error) {
+	c.lines = c.lines[:0]
+	if c.pending == nil {
+		for {
+			line, err := c.readLine()
+			if err != nil {
+				return false, err
+			}
+			if line == nil {
+				return false, nil
+			}
+			if entry, ok := logentry.Parse(line); ok {
+				c.pending, c.pendEntry = line, entry
+				break
+			}
+		}
+	}
+
+	c.lines = append(c.lines, c.pending)
+	c.time, c.severity = c.pendEntry.Time, c.pendEntry.Severity
+	if c.severity == logentry.SeverityUnknown {
+		c.severity = c.fileSev
+	}
+	c.pending = nil
+
+	// 后续没有时间戳的行属于同一条日志
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return false, err
+		}
+		if line == nil {
+			break
+		}
+		if entry, ok := logentry.Parse(line); ok {
+			c.pending, c.pendEntry = line, entry
+			break
+		}
+		c.lines = append(c.lines, line)
+	}
+	return true, nil
+}
+
+// readLine 读取一行（包含换行符），文件结束时返回 nil
+func (c *timelineCursor) readLine() ([]byte, error) {
+	if c.eof {
+		return nil, nil
+	}
+	line, err := c.reader.ReadBytes('\n')
+	if err == io.EOF {
+		c.eof = true
+		if len(line) == 0 {
+			return nil, nil
+		}
+		return append(line, '\n'), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取时间线来源文件失败: %w", err)
+	}
+	return line, nil
+}
+
+// timelineMerger 按时间戳对多个来源文件做 k 路归并
+// 每个来源文件内部的日志本身按时间排列，因此只需要比较各来源当前的日志
+type timelineMerger struct {
+	cursors []*timelineCursor
+}
+
+func (m *timelineMerger) add(temp *utils.TempFile, program, name string) error {
+	file, err := temp.Open()
+	if err != nil {
+		return fmt.Errorf("打开时间线来源文件失败: %w", err)
+	}
+	cursor := &timelineCursor{
+		file:    file,
+		reader:  bufio.NewReaderSize(file, 64<<10),
+		order:   len(m.cursors),
+		label:   program + " " + name,
+		fileSev: logentry.SeverityFromFileName(name),
+	}
+	ok, err := cursor.next()
+	if err != nil || !ok {
+		file.Close()
+		return err
+	}
+	m.cursors = append(m.cursors, cursor)
+	return nil
+}
+
+func (m *timelineMerger) writeTo(w io.Writer) (int, error) {
+	h := cursorHeap(m.cursors)
+	heap.Init(&h)
+
+	count := 0
+	for h.Len() > 0 {
+		cursor := h[0]
+		severity := "-"
+		if cursor.severity != logentry.SeverityUnknown {
+			severity = cursor.severity.String()
+		}
+		if _, err := fmt.Fprintf(w, "[%s %s] ", cursor.label, severity); err != nil {
+			return count, err
+		}
+		for _, line := range cursor.lines {
+			if _, err := w.Write(line); err != nil {
+				return count, err
+			}
+		}
+		count++
+
+		ok, err := cursor.next()
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return count, nil
+}
+
+func (m *timelineMerger) close() {
+	for _, cursor := range m.cursors {
+		cursor.file.Close()
+	}
+}
+
+// cursorHeap 按当前日志时间排序的小顶堆
+type cursorHeap []*timelineCursor
+
+func (h cursorHeap) Len() int { return len(h) }
+
+func (h cursorHeap) Less(i, j int) bool {
+	if !h[i].time.Equal(h[j].time) {
+		return h[i].time.Before(h[j].time)
+	}
+	return h[i].order < h[j].order
+}
+
+func (h cursorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *cursorHeap) Push(x interface{}) { *h = append(*h, x.(*timelineCursor)) }
+
+func (h *cursorHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
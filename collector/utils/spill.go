@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// defaultSpillMemory 所有条目在内存中缓存压缩数据的总量上限，超过后新写入的数据溢出到临时文件
+const defaultSpillMemory = 64 << 20
+
+// defaultSpillArea 未指定缓存区域时使用系统临时目录
+var defaultSpillArea = NewSpillArea("", defaultSpillMemory)
+
+// SpillArea 条目压缩数据的缓存区域
+// 同一区域中的所有条目共享内存额度，额度用完后条目的数据写入 dir 中的临时文件，
+// 并行压缩的条目再多，内存中缓存的数据也不会超过 memLimit。
+// 开启加密后临时文件使用只保存在内存中的随机密钥加密（AES-256-CTR），磁盘上不会出现明文
+type SpillArea struct {
+	dir      string // 临时文件所在目录，为空时使用系统临时目录
+	memLimit int64
+	block    cipher.Block // 临时文件的加密密钥，为空时不加密
+
+	mu      sync.Mutex
+	memUsed int64
+}
+
+// NewSpillArea 创建缓存区域，临时文件写入 dir
+func NewSpillArea(dir string, memLimit int64) *SpillArea {
+	return &SpillArea{dir: dir, memLimit: memLimit}
+}
+
+// EnableEncryption 使用随机密钥加密之后创建的临时文件，密钥不会写入磁盘，进程退出后临时文件无法解密
+func (a *SpillArea) EnableEncryption() error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("生成临时文件密钥失败: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("创建临时文件加密器失败: %w", err)
+	}
+	a.block = block
+	return nil
+}
+
+// reserve 申请 n 字节的内存额度，额度不足时返回 false
+func (a *SpillArea) reserve(n int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.memUsed+n > a.memLimit {
+		return false
+	}
+	a.memUsed += n
+	return true
+}
+
+// release 归还 n 字节的内存额度
+func (a *SpillArea) release(n int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.memUsed -= n
+}
+
+// CreateTemp 在区域目录中创建临时文件
+func (a *SpillArea) CreateTemp(pattern string) (*TempFile, error) {
+	file, err := os.CreateTemp(a.dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	temp := &TempFile{file: file, name: file.Name(), block: a.block}
+	if a.block != nil {
+		// 每个文件使用不同的随机 IV，同一密钥不会产生重复的密钥流
+		temp.iv = make([]byte, aes.BlockSize)
+		if _, err := rand.Read(temp.iv); err != nil {
+			temp.Remove()
+			return nil, fmt.Errorf("生成临时文件 IV 失败: %w", err)
+		}
+		temp.stream = cipher.NewCTR(a.block, temp.iv)
+	}
+	return temp, nil
+}
+
+// TempFile 缓存区域中的临时文件，区域开启加密时写入的数据被加密，读取时解密
+type TempFile struct {
+	file   *os.File
+	name   string
+	size   int64
+	block  cipher.Block
+	iv     []byte
+	stream cipher.Stream // 写入使用的密钥流
+	buf    []byte
+}
+
+// Name 返回临时文件路径
+func (f *TempFile) Name() string {
+	return f.name
+}
+
+// Size 返回已写入的字节数
+func (f *TempFile) Size() int64 {
+	return f.size
+}
+
+func (f *TempFile) Write(p []byte) (int, error) {
+	if f.file == nil {
+		return 0, fmt.Errorf("临时文件已关闭: %s", f.name)
+	}
+	data := p
+	if f.stream != nil {
+		if cap(f.buf) < len(p) {
+			f.buf = make([]byte, len(p))
+		}
+		data = f.buf[:len(p)]
+		f.stream.XORKeyStream(data, p)
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	if err != nil && f.stream != nil {
+		// 密钥流已经前进，之后的写入无法与文件内容对应
+		f.Finish()
+	}
+	return n, err
+}
+
+// decrypt 返回解密 r 的读取器，r 从文件开头读取
+func (f *TempFile) decrypt(r io.Reader) io.Reader {
+	if f.block == nil {
+		return r
+	}
+	return cipher.StreamReader{S: cipher.NewCTR(f.block, f.iv), R: r}
+}
+
+// Reader 返回从头读取已写入数据的读取器，读取不影响之后的写入
+func (f *TempFile) Reader() (io.Reader, error) {
+	if f.file == nil {
+		return nil, fmt.Errorf("临时文件已关闭: %s", f.name)
+	}
+	return f.decrypt(io.NewSectionReader(f.file, 0, f.size)), nil
+}
+
+// Finish 结束写入并关闭文件句柄，之后通过 Open 读取
+func (f *TempFile) Finish() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Open 打开已结束写入的临时文件
+func (f *TempFile) Open() (io.ReadCloser, error) {
+	file, err := os.Open(f.name)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{f.decrypt(file), file}, nil
+}
+
+// Remove 关闭并删除临时文件
+func (f *TempFile) Remove() error {
+	err := f.Finish()
+	os.Remove(f.name)
+	return err
+}
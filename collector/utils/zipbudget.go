@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"bufio"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// TrimDropped 条目被整体丢弃
+	TrimDropped = "dropped"
+	// TrimTruncated 条目只保留了末尾部分
+	TrimTruncated = "truncated"
+
+	// minTrimSize 剩余空间小于该值时不再截断条目，直接丢弃
+	minTrimSize = 4 << 10
+	// snapshotReserve 为分卷索引和校验文件预留的空间
+	snapshotReserve = 8 << 10
+)
+
+// TrimmedEntry 描述因超出大小上限被裁剪的条目
+type TrimmedEntry struct {
+	Name     string // 条目名称
+	Action   string // TrimDropped 或 TrimTruncated
+	Size     uint64 // 原始大小
+	KeptSize uint64 // 保留的大小，丢弃时为 0
+}
+
+// ZipEntryPool 暂存压缩完成的条目
+// 条目提交后不会立即写入ZIP文件，而是在全部条目写入完成后，按调用方给出的优先级
+// 在大小上限内写入分卷写入器，放不下的条目被截断或丢弃
+type ZipEntryPool struct {
+	mu      sync.Mutex
+	entries []*ZipStreamEntry
+	closed  bool
+	spill   *SpillArea
+}
+
+// NewZipEntryPool 创建条目暂存池
+func NewZipEntryPool() *ZipEntryPool {
+	return &ZipEntryPool{}
+}
+
+// SetSpillArea 设置暂存条目压缩数据的缓存区域，为空时使用系统临时目录
+func (p *ZipEntryPool) SetSpillArea(area *SpillArea) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.spill = area
+}
+
+// Create 创建一个新条目，修改时间为当前时间
+func (p *ZipEntryPool) Create(name string) (*ZipStreamEntry, error) {
+	return p.CreateWithModTime(name, time.Now())
+}
+
+// CreateWithModTime 创建一个指定修改时间的新条目
+// 返回的条目写入完成后必须调用 Commit 或 Discard
+func (p *ZipEntryPool) CreateWithModTime(name string, modified time.Time) (*ZipStreamEntry, error) {
+	p.mu.Lock()
+	closed, area := p.closed, p.spill
+	p.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("条目暂存池已关闭")
+	}
+
+	return newZipStreamEntry(area, p.hold, name, modified)
+}
+
+// Entries 返回已提交的条目
+func (p *ZipEntryPool) Entries() []*ZipStreamEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*ZipStreamEntry(nil), p.entries...)
+}
+
+// hold 暂存提交的条目，保留其压缩数据
+func (p *ZipEntryPool) hold(e *ZipStreamEntry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("条目暂存池已关闭")
+	}
+	e.retained = true
+	p.entries = append(p.entries, e)
+	return nil
+}
+
+// Fit 按 ordered 的顺序选出总大小不超过 budget 的条目
+// 放不下的条目在剩余空间足够时只保留末尾的完整行，否则整体丢弃，之后的条目仍会尝试放入。
+// 返回应写入的条目（截断的条目已替换为只包含末尾部分的新条目）和被裁剪的条目，
+// 调用后暂存池不再接受新条目
+func (p *ZipEntryPool) Fit(ordered []*ZipStreamEntry, budget int64) ([]*ZipStreamEntry, []TrimmedEntry, error) {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	remaining := budget
+	var selected []*ZipStreamEntry
+	var trimmed []TrimmedEntry
+	for _, e := range ordered {
+		overhead := entryOverhead(e.name)
+		if e.buf.Len()+overhead <= remaining {
+			selected = append(selected, e)
+			remaining -= e.buf.Len() + overhead
+			continue
+		}
+
+		// 被裁剪的条目同时记录在清单的文件列表和裁剪列表中
+		record := TrimmedEntry{Name: e.name, Action: TrimDropped, Size: e.size}
+		if avail := remaining - 2*overhead; avail >= minTrimSize {
+			tail, err := tailTrim(e, avail)
+			if err != nil {
+				return nil, nil, err
+			}
+			if tail != nil {
+				p.mu.Lock()
+				p.entries = append(p.entries, tail)
+				p.mu.Unlock()
+				selected = append(selected, tail)
+				remaining -= tail.buf.Len() + overhead
+				record.Action = TrimTruncated
+				record.KeptSize = tail.size
+			}
+		}
+		remaining -= overhead
+		e.buf.Close()
+
+		trimmed = append(trimmed, record)
+		logrus.Infof("快照超出大小上限，%s: %s (原始大小: %d 字节, 保留: %d 字节)",
+			record.Action, record.Name, record.Size, record.KeptSize)
+	}
+	return selected, trimmed, nil
+}
+
+// WriteTo 将条目依次写入 dst，写入后释放条目的压缩数据
+func (p *ZipEntryPool) WriteTo(dst *ZipVolumeWriter, entries []*ZipStreamEntry) error {
+	for _, e := range entries {
+		err := dst.commit(e)
+		e.buf.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release 释放所有暂存条目的压缩数据
+func (p *ZipEntryPool) Release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, e := range p.entries {
+		e.buf.Close()
+	}
+	p.entries = nil
+}
+
+// Budget 返回快照总大小为 maxSize 时可用于条目的字节数
+// 扣除每个分卷的预留空间、包装写入器的开销以及分卷索引和校验文件的空间
+func (v *ZipVolumeWriter) Budget(maxSize int64) int64 {
+	volumes := int64(1)
+	if v.maxSize > 0 {
+		volumes = (maxSize + v.maxSize - 1) / v.maxSize
+	}
+	budget := maxSize - volumes*volumeReserve - snapshotReserve
+	if v.wrapper != nil {
+		budget -= maxSize/1024 + volumes*(32<<10)
+	}
+	return budget
+}
+
+// tailTrim 截取条目末尾的完整行，使压缩后的大小不超过 maxCompressed
+// 无法保留任何完整行时返回 nil
+func tailTrim(e *ZipStreamEntry, maxCompressed int64) (*ZipStreamEntry, error) {
+	// 按压缩率估算可以保留的原始大小，压缩后仍然超出时逐步缩小
+	ratio := float64(e.size) / float64(e.buf.Len()+1)
+	keep := int64(float64(maxCompressed) * ratio * 0.9)
+	for attempt := 0; attempt < 4 && keep > 0; attempt++ {
+		tail, err := copyTail(e, keep)
+		if err != nil {
+			return nil, err
+		}
+		if tail.size > 0 && tail.buf.Len() <= maxCompressed {
+			return tail, nil
+		}
+		tail.buf.Close()
+		if tail.size == 0 {
+			return nil, nil
+		}
+		keep = keep * 7 / 10
+	}
+	return nil, nil
+}
+
+// copyTail 将条目最后约 keep 字节中的完整行压缩为同名的新条目
+func copyTail(e *ZipStreamEntry, keep int64) (*ZipStreamEntry, error) {
+	reader, err := e.buf.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("读取压缩数据失败 %s: %w", e.name, err)
+	}
+	decompressor := flate.NewReader(reader)
+	defer decompressor.Close()
+	source := bufio.NewReaderSize(decompressor, 64<<10)
+
+	if skip := int64(e.size) - keep; skip > 0 {
+		if _, err := io.CopyN(io.Discard, source, skip); err != nil {
+			return nil, fmt.Errorf("截断条目失败 %s: %w", e.name, err)
+		}
+		// 从下一个完整行开始保留
+		for {
+			_, err := source.ReadSlice('\n')
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("截断条目失败 %s: %w", e.name, err)
+			}
+			break
+		}
+	}
+
+	tail, err := newZipStreamEntry(e.area, nil, e.name, e.modified)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tail, source); err != nil {
+		tail.Discard()
+		return nil, fmt.Errorf("截断条目失败 %s: %w", e.name, err)
+	}
+	tail.done = true
+	if err := tail.fw.Close(); err != nil {
+		tail.buf.Close()
+		return nil, fmt.Errorf("压缩数据失败 %s: %w", e.name, err)
+	}
+	return tail, nil
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestZipEntryPool_FitTruncatesTail(t *testing.T) {
+	pool := NewZipEntryPool()
+	defer pool.Release()
+
+	rng := rand.New(rand.NewSource(1))
+	var entries []*ZipStreamEntry
+	for i, lines := range []int{200, 5000, 200} {
+		entry, err := pool.Create(fmt.Sprintf("logs/%d.log", i))
+		if err != nil {
+			t.Fatalf("创建条目失败: %v", err)
+		}
+		for j := 0; j < lines; j++ {
+			fmt.Fprintf(entry, "line %d %x\n", j, rng.Int63())
+		}
+		if err := entry.Commit(); err != nil {
+			t.Fatalf("提交条目失败: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	// 预算只够第一个条目和第二个条目的一部分，第三个条目很小仍然可以放入
+	budget := entries[0].CompressedSize() + entries[2].CompressedSize() + 4*entryOverhead("logs/0.log") + 20<<10
+	selected, trimmed, err := pool.Fit(entries, budget)
+	if err != nil {
+		t.Fatalf("裁剪失败: %v", err)
+	}
+	if len(selected) != 3 || len(trimmed) != 1 {
+		t.Fatalf("应写入 3 个条目并裁剪 1 个, 实际 %d 和 %d", len(selected), len(trimmed))
+	}
+	if trimmed[0].Name != "logs/1.log" || trimmed[0].Action != TrimTruncated {
+		t.Fatalf("第二个条目应被截断: %+v", trimmed[0])
+	}
+
+	dir := t.TempDir()
+	writer := NewZipVolumeWriter(0, func(int) string { return filepath.Join(dir, "snap.zip") }, nil)
+	if err := pool.WriteTo(writer, selected); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("关闭写入器失败: %v", err)
+	}
+
+	reader, err := zip.OpenReader(filepath.Join(dir, "snap.zip"))
+	if err != nil {
+		t.Fatalf("打开ZIP文件失败: %v", err)
+	}
+	defer reader.Close()
+
+	var total int64
+	for _, file := range reader.File {
+		total += int64(file.CompressedSize64)
+		if file.Name != "logs/1.log" {
+			continue
+		}
+		rc, _ := file.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+
+		// 保留的是末尾的完整行
+		content := string(data)
+		if uint64(len(data)) != trimmed[0].KeptSize || !strings.HasPrefix(content, "line ") ||
+			!strings.HasPrefix(content[strings.LastIndex(strings.TrimSuffix(content, "\n"), "\n")+1:], "line 4999 ") {
+			t.Fatalf("截断后应只保留末尾的完整行")
+		}
+	}
+	if total > budget {
+		t.Fatalf("写入的压缩数据 %d 超过预算 %d", total, budget)
+	}
+}
@@ -0,0 +1,353 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ZipEntryInfo 描述已写入ZIP文件的条目
+type ZipEntryInfo struct {
+	Name           string    // 条目名称
+	Size           uint64    // 原始大小
+	CompressedSize uint64    // 压缩后大小
+	Modified       time.Time // 修改时间
+	SHA256         string    // 原始内容的 SHA-256，十六进制
+}
+
+// ZipStreamWriter 流式ZIP写入器
+// 每个条目在调用方协程中独立压缩，提交时以原始数据写入同一个ZIP文件，
+// 因此多个协程可以并行压缩，最终仍然生成单个合法的ZIP文件，无需临时目录和二次合并
+type ZipStreamWriter struct {
+	mu      sync.Mutex
+	counter *countingWriter
+	zw      *zip.Writer
+	names   map[string]int
+	entries []ZipEntryInfo
+	closed  bool
+	spill   *SpillArea
+}
+
+// NewZipStreamWriter 创建写入到 w 的流式ZIP写入器
+func NewZipStreamWriter(w io.Writer) *ZipStreamWriter {
+	counter := &countingWriter{writer: w}
+	return &ZipStreamWriter{
+		counter: counter,
+		zw:      zip.NewWriter(counter),
+		names:   make(map[string]int),
+	}
+}
+
+// SetSpillArea 设置条目压缩数据的缓存区域，为空时使用系统临时目录
+func (z *ZipStreamWriter) SetSpillArea(area *SpillArea) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.spill = area
+}
+
+// Create 创建一个新条目，修改时间为当前时间
+func (z *ZipStreamWriter) Create(name string) (*ZipStreamEntry, error) {
+	return z.CreateWithModTime(name, time.Now())
+}
+
+// CreateWithModTime 创建一个指定修改时间的新条目
+// 返回的条目写入完成后必须调用 Commit 或 Discard
+func (z *ZipStreamWriter) CreateWithModTime(name string, modified time.Time) (*ZipStreamEntry, error) {
+	z.mu.Lock()
+	closed, area := z.closed, z.spill
+	z.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("ZIP写入器已关闭")
+	}
+
+	return newZipStreamEntry(area, z.commit, name, modified)
+}
+
+// Entries 返回已提交的条目列表
+func (z *ZipStreamWriter) Entries() []ZipEntryInfo {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return append([]ZipEntryInfo(nil), z.entries...)
+}
+
+// Written 返回已写入底层 io.Writer 的字节数
+func (z *ZipStreamWriter) Written() int64 {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if !z.closed {
+		z.zw.Flush()
+	}
+	return z.counter.written
+}
+
+// Close 写入中央目录并关闭写入器，不会关闭底层的 io.Writer
+func (z *ZipStreamWriter) Close() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	return z.zw.Close()
+}
+
+// commit 将压缩完成的条目以原始数据写入ZIP文件
+func (z *ZipStreamWriter) commit(e *ZipStreamEntry) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.closed {
+		return fmt.Errorf("ZIP写入器已关闭")
+	}
+
+	name := uniqueEntryName(z.names, e.name)
+	info, err := writeRawEntry(z.zw, name, e)
+	if err != nil {
+		return err
+	}
+	z.entries = append(z.entries, info)
+	return nil
+}
+
+// writeRawEntry 将压缩完成的条目写入 zw
+func writeRawEntry(zw *zip.Writer, name string, e *ZipStreamEntry) (ZipEntryInfo, error) {
+	header := &zip.FileHeader{
+		Name:               name,
+		Method:             zip.Deflate,
+		Modified:           e.modified,
+		CRC32:              e.crc.Sum32(),
+		CompressedSize64:   uint64(e.buf.Len()),
+		UncompressedSize64: e.size,
+	}
+	header.SetMode(0644)
+
+	writer, err := zw.CreateRaw(header)
+	if err != nil {
+		return ZipEntryInfo{}, fmt.Errorf("创建ZIP条目失败 %s: %w", name, err)
+	}
+	reader, err := e.buf.Reader()
+	if err != nil {
+		return ZipEntryInfo{}, fmt.Errorf("读取压缩数据失败 %s: %w", name, err)
+	}
+	if _, err := io.Copy(writer, reader); err != nil {
+		return ZipEntryInfo{}, fmt.Errorf("写入ZIP条目失败 %s: %w", name, err)
+	}
+
+	return ZipEntryInfo{
+		Name:           name,
+		Size:           e.size,
+		CompressedSize: uint64(e.buf.Len()),
+		Modified:       e.modified,
+		SHA256:         hex.EncodeToString(e.sha.Sum(nil)),
+	}, nil
+}
+
+// uniqueEntryName 保证条目名称在ZIP文件中唯一，重名时在扩展名前追加序号
+func uniqueEntryName(names map[string]int, name string) string {
+	count := names[name]
+	names[name] = count + 1
+	if count == 0 {
+		return name
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for {
+		candidate := fmt.Sprintf("%s_%d%s", base, count+1, ext)
+		if _, exists := names[candidate]; !exists {
+			names[candidate] = 1
+			logrus.Warnf("ZIP条目重名，已重命名: %s -> %s", name, candidate)
+			return candidate
+		}
+		count++
+	}
+}
+
+// ZipStreamEntry 表示正在写入的ZIP条目
+// 写入的数据会被即时压缩并计算CRC和SHA-256，直到 Commit 时才写入ZIP文件
+type ZipStreamEntry struct {
+	commitFn func(*ZipStreamEntry) error
+	area     *SpillArea
+	name     string
+	modified time.Time
+	buf      *spillBuffer
+	fw       *flate.Writer
+	crc      hash.Hash32
+	sha      hash.Hash
+	size     uint64
+	done     bool
+	retained bool // 提交后仍保留压缩数据，由 ZipEntryPool 负责释放
+}
+
+func newZipStreamEntry(area *SpillArea, commitFn func(*ZipStreamEntry) error, name string, modified time.Time) (*ZipStreamEntry, error) {
+	buf := newSpillBuffer(area)
+	fw, err := flate.NewWriter(buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, fmt.Errorf("创建压缩器失败: %w", err)
+	}
+	return &ZipStreamEntry{
+		commitFn: commitFn,
+		area:     area,
+		name:     strings.TrimPrefix(path.Clean(strings.ReplaceAll(name, "\\", "/")), "/"),
+		modified: modified,
+		buf:      buf,
+		fw:       fw,
+		crc:      crc32.NewIEEE(),
+		sha:      sha256.New(),
+	}, nil
+}
+
+// Name 返回条目名称
+func (e *ZipStreamEntry) Name() string {
+	return e.name
+}
+
+// Size 返回已写入的原始字节数
+func (e *ZipStreamEntry) Size() uint64 {
+	return e.size
+}
+
+// CompressedSize 返回压缩后的字节数，Commit 之后才是最终大小
+func (e *ZipStreamEntry) CompressedSize() int64 {
+	return e.buf.Len()
+}
+
+// Modified 返回条目的修改时间
+func (e *ZipStreamEntry) Modified() time.Time {
+	return e.modified
+}
+
+// Write 写入未压缩的数据
+func (e *ZipStreamEntry) Write(p []byte) (int, error) {
+	if e.done {
+		return 0, fmt.Errorf("ZIP条目已结束: %s", e.name)
+	}
+	n, err := e.fw.Write(p)
+	e.crc.Write(p[:n])
+	e.sha.Write(p[:n])
+	e.size += uint64(n)
+	return n, err
+}
+
+// Commit 结束压缩并将条目写入ZIP文件
+func (e *ZipStreamEntry) Commit() error {
+	if e.done {
+		return fmt.Errorf("ZIP条目已结束: %s", e.name)
+	}
+	e.done = true
+	defer func() {
+		if !e.retained {
+			e.buf.Close()
+		}
+	}()
+
+	if err := e.fw.Close(); err != nil {
+		return fmt.Errorf("压缩数据失败 %s: %w", e.name, err)
+	}
+	return e.commitFn(e)
+}
+
+// Discard 丢弃条目，不写入ZIP文件
+func (e *ZipStreamEntry) Discard() error {
+	if e.done {
+		return nil
+	}
+	e.done = true
+	return e.buf.Close()
+}
+
+// countingWriter 统计写入字节数
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// spillBuffer 先在内存中缓存数据，所在区域的内存额度用完后溢出到区域目录中的临时文件
+type spillBuffer struct {
+	area     *SpillArea
+	mem      bytes.Buffer
+	reserved int64 // 从区域申请的内存额度
+	file     *TempFile
+	size     int64
+}
+
+func newSpillBuffer(area *SpillArea) *spillBuffer {
+	if area == nil {
+		area = defaultSpillArea
+	}
+	return &spillBuffer{area: area}
+}
+
+func (b *spillBuffer) Write(p []byte) (int, error) {
+	if b.file == nil && !b.area.reserve(int64(len(p))) {
+		file, err := b.area.CreateTemp("logsnap_entry_*")
+		if err != nil {
+			return 0, fmt.Errorf("创建溢出文件失败: %w", err)
+		}
+		if _, err := file.Write(b.mem.Bytes()); err != nil {
+			file.Remove()
+			return 0, fmt.Errorf("写入溢出文件失败: %w", err)
+		}
+		b.releaseMemory()
+		b.file = file
+	}
+
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.mem.Write(p)
+		b.reserved += int64(len(p))
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+// releaseMemory 释放内存中的数据并归还额度
+func (b *spillBuffer) releaseMemory() {
+	b.mem = bytes.Buffer{}
+	b.area.release(b.reserved)
+	b.reserved = 0
+}
+
+// Len 返回已缓存的字节数
+func (b *spillBuffer) Len() int64 {
+	return b.size
+}
+
+// Reader 返回从头读取已缓存数据的读取器
+func (b *spillBuffer) Reader() (io.Reader, error) {
+	if b.file == nil {
+		return bytes.NewReader(b.mem.Bytes()), nil
+	}
+	return b.file.Reader()
+}
+
+// Close 释放缓存，删除溢出文件
+func (b *spillBuffer) Close() error {
+	b.releaseMemory()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Remove()
+	b.file = nil
+	return err
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestZipStreamWriter_ParallelEntries(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewZipStreamWriter(&buf)
+
+	// 多个协程并行写入条目
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			entry, err := writer.Create(fmt.Sprintf("dir/file_%d.log", index))
+			if err != nil {
+				t.Errorf("创建条目失败: %v", err)
+				return
+			}
+			for j := 0; j < 1000; j++ {
+				fmt.Fprintf(entry, "file %d line %d\n", index, j)
+			}
+			if err := entry.Commit(); err != nil {
+				t.Errorf("提交条目失败: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 丢弃的条目不应出现在ZIP文件中
+	discarded, err := writer.Create("dir/discarded.log")
+	if err != nil {
+		t.Fatalf("创建条目失败: %v", err)
+	}
+	discarded.Write([]byte("discarded"))
+	discarded.Discard()
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("关闭写入器失败: %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("打开ZIP文件失败: %v", err)
+	}
+	if len(reader.File) != 8 {
+		t.Fatalf("ZIP文件中应有 8 个条目, 实际有 %d 个", len(reader.File))
+	}
+
+	// 读取每个条目，CRC校验由 archive/zip 在读取结束时完成
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("打开条目 %s 失败: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("读取条目 %s 失败: %v", file.Name, err)
+		}
+		if lines := strings.Count(string(content), "\n"); lines != 1000 {
+			t.Fatalf("条目 %s 应有 1000 行, 实际有 %d 行", file.Name, lines)
+		}
+	}
+}
+
+func TestZipStreamWriter_DuplicateNames(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewZipStreamWriter(&buf)
+
+	for i := 0; i < 2; i++ {
+		entry, err := writer.Create("app.log")
+		if err != nil {
+			t.Fatalf("创建条目失败: %v", err)
+		}
+		entry.Write([]byte("content"))
+		if err := entry.Commit(); err != nil {
+			t.Fatalf("提交条目失败: %v", err)
+		}
+	}
+	writer.Close()
+
+	entries := writer.Entries()
+	if len(entries) != 2 || entries[0].Name != "app.log" || entries[1].Name != "app_2.log" {
+		t.Fatalf("重名条目应被重命名, 实际为 %v", entries)
+	}
+}
+
+func TestSpillBuffer(t *testing.T) {
+	dir := t.TempDir()
+	area := NewSpillArea(dir, 16)
+	buf := newSpillBuffer(area)
+	defer buf.Close()
+
+	data := strings.Repeat("x", 40)
+	buf.Write([]byte(data[:10]))
+	buf.Write([]byte(data[10:]))
+
+	if buf.file == nil {
+		t.Fatalf("超过内存上限后应溢出到临时文件")
+	}
+	if buf.Len() != 40 {
+		t.Fatalf("缓存大小应为 40, 实际为 %d", buf.Len())
+	}
+
+	reader, err := buf.Reader()
+	if err != nil {
+		t.Fatalf("获取读取器失败: %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	if string(content) != data {
+		t.Fatalf("缓存内容不正确: %s", string(content))
+	}
+	if filepath.Dir(buf.file.Name()) != dir {
+		t.Fatalf("溢出文件应位于缓存区域目录 %s, 实际为 %s", dir, buf.file.Name())
+	}
+
+	// 内存额度由区域中的所有条目共享，释放后其他条目可以继续使用
+	small := newSpillBuffer(area)
+	small.Write([]byte(data[:10]))
+	other := newSpillBuffer(area)
+	other.Write([]byte(data[:10]))
+	if small.file != nil || other.file == nil {
+		t.Fatalf("区域内存额度用完后新写入的条目应溢出到临时文件")
+	}
+	small.Close()
+	other.Close()
+	if area.memUsed != 0 {
+		t.Fatalf("关闭后应归还内存额度, 实际占用 %d", area.memUsed)
+	}
+	buf.Close()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("关闭后应删除溢出文件, 实际剩余 %d 个", len(entries))
+	}
+}
+
+func TestSpillAreaEncryption(t *testing.T) {
+	dir := t.TempDir()
+	area := NewSpillArea(dir, 0)
+	if err := area.EnableEncryption(); err != nil {
+		t.Fatalf("开启加密失败: %v", err)
+	}
+
+	data := strings.Repeat("secret log line\n", 100)
+	temp, err := area.CreateTemp("spill_*")
+	if err != nil {
+		t.Fatalf("创建临时文件失败: %v", err)
+	}
+	defer temp.Remove()
+	temp.Write([]byte(data[:7]))
+	temp.Write([]byte(data[7:]))
+
+	// 写入过程中读取不影响之后的写入
+	reader, err := temp.Reader()
+	if err != nil {
+		t.Fatalf("获取读取器失败: %v", err)
+	}
+	if content, _ := io.ReadAll(reader); string(content) != data {
+		t.Fatalf("解密内容不正确")
+	}
+	if err := temp.Finish(); err != nil {
+		t.Fatalf("结束写入失败: %v", err)
+	}
+
+	onDisk, err := os.ReadFile(temp.Name())
+	if err != nil {
+		t.Fatalf("读取临时文件失败: %v", err)
+	}
+	if len(onDisk) != len(data) || strings.Contains(string(onDisk), "secret") {
+		t.Fatalf("磁盘上的临时文件不应包含明文")
+	}
+
+	file, err := temp.Open()
+	if err != nil {
+		t.Fatalf("打开临时文件失败: %v", err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+	if string(content) != data {
+		t.Fatalf("解密内容不正确")
+	}
+}
@@ -102,137 +102,61 @@ func ZipDirectory(sourceDir, destZip string, concurrency ...int) error {
 	
 	logrus.Infof("使用 %d 个工作协程进行压缩", workerCount)
 
-	// 创建临时目录存放中间文件
-	tempDir, err := os.MkdirTemp("", "zip_temp_*")
+	// 创建目标文件，所有工作协程并行压缩后直接写入同一个ZIP文件
+	destFile, err := os.Create(destZip)
 	if err != nil {
-		return fmt.Errorf("创建临时目录失败: %w", err)
+		return fmt.Errorf("创建ZIP文件失败: %w", err)
 	}
-	defer os.RemoveAll(tempDir)
+	defer destFile.Close()
 
-	// 将文件分成多个批次
-	batchSize := (fileCount + workerCount - 1) / workerCount
-	batches := make([][]string, 0, workerCount)
-	
-	for i := 0; i < fileCount; i += batchSize {
-		end := i + batchSize
-		if end > fileCount {
-			end = fileCount
-		}
-		batches = append(batches, filesToZip[i:end])
-	}
-	
-	// 为每个批次创建一个临时ZIP文件
+	zipWriter := NewZipStreamWriter(destFile)
+	// 压缩数据溢出到目标文件所在的目录，不占用系统临时目录
+	zipWriter.SetSpillArea(NewSpillArea(filepath.Dir(destZip), defaultSpillMemory))
+
+	// 将文件分发给工作协程
 	var wg sync.WaitGroup
-	tempZips := make([]string, len(batches))
-	errors := make([]error, len(batches))
-	
-	for i, batch := range batches {
+	fileChan := make(chan string, fileCount)
+	errChan := make(chan error, fileCount)
+
+	for i := 0; i < workerCount; i++ {
 		wg.Add(1)
-		go func(batchIndex int, fileBatch []string) {
+		go func(workerIndex int) {
 			defer wg.Done()
-			
-			// 创建临时ZIP文件
-			tempZipPath := filepath.Join(tempDir, fmt.Sprintf("temp_%d.zip", batchIndex))
-			tempZips[batchIndex] = tempZipPath
-			
-			// 创建ZIP文件
-			tempZipFile, err := os.Create(tempZipPath)
-			if err != nil {
-				errors[batchIndex] = fmt.Errorf("创建临时ZIP文件失败: %w", err)
-				return
-			}
-			
-			zipWriter := zip.NewWriter(tempZipFile)
-			
-			// 处理批次中的每个文件
-			for _, filePath := range fileBatch {
-				// 获取相对路径
-				relPath, err := filepath.Rel(absSourceDir, filePath)
-				if err != nil {
-					logrus.Warnf("获取相对路径失败 %s: %v", filePath, err)
-					continue
-				}
-				
-				// 打开源文件
-				file, err := os.Open(filePath)
-				if err != nil {
-					logrus.Warnf("无法打开文件 %s: %v", filePath, err)
+
+			for filePath := range fileChan {
+				if err := addFileToZip(zipWriter, absSourceDir, filePath); err != nil {
+					errChan <- err
 					continue
 				}
-				
-				// 使用匿名函数确保文件被关闭
-				func() {
-					defer file.Close()
-					
-					// 获取文件信息
-					info, err := file.Stat()
-					if err != nil {
-						logrus.Warnf("无法获取文件信息 %s: %v", filePath, err)
-						return
-					}
-					
-					// 创建zip文件头
-					header, err := zip.FileInfoHeader(info)
-					if err != nil {
-						logrus.Warnf("无法创建文件头 %s: %v", filePath, err)
-						return
-					}
-					
-					// 设置文件名为相对路径，并统一使用斜杠作为分隔符
-					header.Name = filepath.ToSlash(relPath)
-					header.Method = zip.Deflate
-					
-					// 创建文件条目
-					writer, err := zipWriter.CreateHeader(header)
-					if err != nil {
-						logrus.Warnf("无法创建ZIP条目 %s: %v", filePath, err)
-						return
-					}
-					
-					// 复制文件内容到zip
-					_, err = io.Copy(writer, file)
-					if err != nil {
-						logrus.Warnf("无法写入文件内容 %s: %v", filePath, err)
-						return
-					}
-					
-					logrus.Debugf("工作协程 %d: 已添加文件: %s", batchIndex, relPath)
-				}()
-			}
-			
-			// 关闭ZIP writer
-			if err := zipWriter.Close(); err != nil {
-				errors[batchIndex] = fmt.Errorf("关闭临时ZIP writer失败: %w", err)
-				tempZipFile.Close()
-				return
+				logrus.Debugf("工作协程 %d: 已添加文件: %s", workerIndex, filePath)
 			}
-			
-			// 关闭临时文件
-			if err := tempZipFile.Close(); err != nil {
-				errors[batchIndex] = fmt.Errorf("关闭临时ZIP文件失败: %w", err)
-				return
-			}
-			
-			logrus.Infof("工作协程 %d: 已完成 %d 个文件的压缩", batchIndex, len(fileBatch))
-		}(i, batch)
+		}(i)
 	}
-	
+
+	for _, filePath := range filesToZip {
+		fileChan <- filePath
+	}
+	close(fileChan)
+
 	// 等待所有工作协程完成
 	wg.Wait()
-	
+	close(errChan)
+
 	// 检查是否有错误
-	for i, err := range errors {
+	for err := range errChan {
 		if err != nil {
-			return fmt.Errorf("批次 %d 处理失败: %w", i, err)
+			zipWriter.Close()
+			return err
 		}
 	}
-	
-	// 合并所有临时ZIP文件
-	err = mergeZipFiles(tempZips, destZip)
-	if err != nil {
-		return fmt.Errorf("合并ZIP文件失败: %w", err)
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("关闭ZIP writer失败: %w", err)
 	}
-	
+	if err := destFile.Close(); err != nil {
+		return fmt.Errorf("关闭ZIP文件失败: %w", err)
+	}
+
 	// 验证生成的文件
 	fileInfo, err := os.Stat(destZip)
 	if err != nil {
@@ -245,58 +169,43 @@ func ZipDirectory(sourceDir, destZip string, concurrency ...int) error {
 	return nil
 }
 
-// mergeZipFiles 合并多个ZIP文件到一个目标文件
-func mergeZipFiles(sourceZips []string, destZip string) error {
-	// 创建目标文件
-	destFile, err := os.Create(destZip)
+// addFileToZip 将单个文件压缩写入ZIP文件
+// 打开或读取源文件失败时记录警告并跳过该文件，写入ZIP失败时返回错误
+func addFileToZip(zipWriter *ZipStreamWriter, sourceDir, filePath string) error {
+	// 获取相对路径
+	relPath, err := filepath.Rel(sourceDir, filePath)
 	if err != nil {
-		return fmt.Errorf("创建目标ZIP文件失败: %w", err)
-	}
-	defer destFile.Close()
-	
-	// 创建新的zip writer
-	destZipWriter := zip.NewWriter(destFile)
-	defer destZipWriter.Close()
-	
-	// 处理每个源ZIP文件
-	for _, sourceZip := range sourceZips {
-		// 打开源ZIP文件
-		reader, err := zip.OpenReader(sourceZip)
-		if err != nil {
-			return fmt.Errorf("打开源ZIP文件失败 %s: %w", sourceZip, err)
-		}
-		
-		// 复制每个文件到目标ZIP
-		for _, file := range reader.File {
-			err = copyZipFile(file, destZipWriter)
-			if err != nil {
-				reader.Close()
-				return fmt.Errorf("复制ZIP文件条目失败 %s: %w", file.Name, err)
-			}
-		}
-		
-		reader.Close()
+		logrus.Warnf("获取相对路径失败 %s: %v", filePath, err)
+		return nil
 	}
-	
-	return nil
-}
 
-// copyZipFile 从源ZIP文件复制一个文件到目标ZIP writer
-func copyZipFile(file *zip.File, destZipWriter *zip.Writer) error {
 	// 打开源文件
-	sourceFile, err := file.Open()
+	file, err := os.Open(filePath)
 	if err != nil {
-		return err
+		logrus.Warnf("无法打开文件 %s: %v", filePath, err)
+		return nil
 	}
-	defer sourceFile.Close()
-	
-	// 创建目标文件条目
-	destFile, err := destZipWriter.CreateHeader(&file.FileHeader)
+	defer file.Close()
+
+	// 获取文件信息
+	info, err := file.Stat()
 	if err != nil {
-		return err
+		logrus.Warnf("无法获取文件信息 %s: %v", filePath, err)
+		return nil
 	}
-	
-	// 复制内容
-	_, err = io.Copy(destFile, sourceFile)
-	return err
+
+	// 设置文件名为相对路径，并统一使用斜杠作为分隔符
+	entry, err := zipWriter.CreateWithModTime(filepath.ToSlash(relPath), info.ModTime())
+	if err != nil {
+		return fmt.Errorf("无法创建ZIP条目 %s: %w", filePath, err)
+	}
+
+	// 复制文件内容到zip
+	if _, err := io.Copy(entry, file); err != nil {
+		entry.Discard()
+		logrus.Warnf("无法写入文件内容 %s: %v", filePath, err)
+		return nil
+	}
+
+	return entry.Commit()
 }
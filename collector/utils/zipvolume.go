@@ -0,0 +1,368 @@
+package utils
+
+import (
+	"bufio"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// volumeReserve 每个分卷为中央目录、清单文件等预留的固定空间
+const volumeReserve = 16 << 10
+
+// ZipVolume 描述一个已完成的分卷
+type ZipVolume struct {
+	Index   int            // 分卷序号，从 1 开始
+	Path    string         // 分卷文件路径
+	Size    int64          // 分卷文件大小
+	Entries []ZipEntryInfo // 分卷中的条目
+}
+
+// VolumeFinalizer 在分卷关闭前调用，可向分卷写入额外的条目（例如清单文件）
+type VolumeFinalizer func(volume int, writer *ZipStreamWriter) error
+
+// WriterWrapper 包装分卷文件的写入器，例如对分卷内容加密
+// 返回的写入器在分卷完成时被关闭，但不应关闭底层的 io.Writer
+type WriterWrapper func(w io.Writer) (io.WriteCloser, error)
+
+// ZipVolumeWriter 分卷ZIP写入器
+// 条目按提交顺序写入当前分卷，当前分卷放不下时切换到新的分卷，每个分卷都是独立可读的ZIP文件。
+// 单个条目超过分卷大小时按行切分为多个条目，分别写入不同的分卷。maxSize 为 0 时不分卷
+type ZipVolumeWriter struct {
+	mu        sync.Mutex
+	maxSize   int64
+	pathFunc  func(volume int) string
+	finalizer VolumeFinalizer
+	wrapper   WriterWrapper
+	spill     *SpillArea
+	current   *openVolume
+	volumes   []ZipVolume
+	closed    bool
+}
+
+// openVolume 正在写入的分卷
+type openVolume struct {
+	index    int
+	path     string
+	file     *os.File
+	wrapped  io.WriteCloser
+	writer   *ZipStreamWriter
+	overhead int64 // 已提交条目预估的中央目录及清单开销
+}
+
+// NewZipVolumeWriter 创建分卷ZIP写入器
+// 参数:
+//   - maxSize: 单个分卷的最大字节数，0 表示不分卷
+//   - pathFunc: 根据分卷序号（从 1 开始）返回分卷文件路径
+//   - finalizer: 可选，分卷关闭前的回调
+func NewZipVolumeWriter(maxSize int64, pathFunc func(volume int) string, finalizer VolumeFinalizer) *ZipVolumeWriter {
+	return &ZipVolumeWriter{
+		maxSize:   maxSize,
+		pathFunc:  pathFunc,
+		finalizer: finalizer,
+	}
+}
+
+// SetWrapper 设置分卷文件的写入器包装，必须在写入第一个条目前调用
+func (v *ZipVolumeWriter) SetWrapper(wrapper WriterWrapper) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.wrapper = wrapper
+}
+
+// SetSpillArea 设置条目压缩数据的缓存区域，为空时使用系统临时目录
+func (v *ZipVolumeWriter) SetSpillArea(area *SpillArea) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.spill = area
+}
+
+// Create 创建一个新条目，修改时间为当前时间
+func (v *ZipVolumeWriter) Create(name string) (*ZipStreamEntry, error) {
+	return v.CreateWithModTime(name, time.Now())
+}
+
+// CreateWithModTime 创建一个指定修改时间的新条目
+// 返回的条目写入完成后必须调用 Commit 或 Discard
+func (v *ZipVolumeWriter) CreateWithModTime(name string, modified time.Time) (*ZipStreamEntry, error) {
+	v.mu.Lock()
+	closed, area := v.closed, v.spill
+	v.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("ZIP写入器已关闭")
+	}
+
+	return newZipStreamEntry(area, v.commit, name, modified)
+}
+
+// Entries 返回所有分卷中已提交的条目
+func (v *ZipVolumeWriter) Entries() []ZipEntryInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var entries []ZipEntryInfo
+	for _, volume := range v.volumes {
+		entries = append(entries, volume.Entries...)
+	}
+	if v.current != nil {
+		entries = append(entries, v.current.writer.Entries()...)
+	}
+	return entries
+}
+
+// Volumes 返回已完成的分卷列表
+func (v *ZipVolumeWriter) Volumes() []ZipVolume {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]ZipVolume(nil), v.volumes...)
+}
+
+// Close 完成当前分卷并关闭写入器
+// 即使没有提交任何条目，也至少会生成一个分卷
+func (v *ZipVolumeWriter) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.closed {
+		return nil
+	}
+	v.closed = true
+
+	if v.current == nil {
+		if err := v.openNext(); err != nil {
+			return err
+		}
+	}
+	return v.closeCurrent()
+}
+
+// Abort 关闭写入器并删除所有已生成的分卷
+func (v *ZipVolumeWriter) Abort() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.closed = true
+
+	if v.current != nil {
+		v.current.writer.Close()
+		v.current.file.Close()
+		os.Remove(v.current.file.Name())
+		v.current = nil
+	}
+	for _, volume := range v.volumes {
+		os.Remove(volume.Path)
+	}
+	v.volumes = nil
+}
+
+// commit 将压缩完成的条目写入合适的分卷
+func (v *ZipVolumeWriter) commit(e *ZipStreamEntry) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.closed {
+		return fmt.Errorf("ZIP写入器已关闭")
+	}
+
+	need := e.buf.Len() + entryOverhead(e.name)
+	if v.maxSize > 0 && need+volumeReserve > v.limit() {
+		return v.commitSplit(e)
+	}
+	return v.commitLocked(e)
+}
+
+// commitLocked 将条目写入当前分卷，放不下时先切换到新的分卷，调用方需持有锁
+func (v *ZipVolumeWriter) commitLocked(e *ZipStreamEntry) error {
+	need := e.buf.Len() + entryOverhead(e.name)
+	if v.current != nil && v.maxSize > 0 && len(v.current.writer.Entries()) > 0 &&
+		v.current.writer.Written()+v.current.overhead+need+volumeReserve > v.limit() {
+		if err := v.closeCurrent(); err != nil {
+			return err
+		}
+	}
+
+	if v.current == nil {
+		if err := v.openNext(); err != nil {
+			return err
+		}
+	}
+
+	if err := v.current.writer.commit(e); err != nil {
+		return err
+	}
+	v.current.overhead += entryOverhead(e.name)
+	return nil
+}
+
+// commitSplit 将超过分卷大小的条目按行切分为多个条目写入，调用方需持有锁
+// 切分后的条目命名为 <原名称>.part001、<原名称>.part002 ...
+func (v *ZipVolumeWriter) commitSplit(e *ZipStreamEntry) error {
+	reader, err := e.buf.Reader()
+	if err != nil {
+		return fmt.Errorf("读取压缩数据失败 %s: %w", e.name, err)
+	}
+	decompressor := flate.NewReader(reader)
+	defer decompressor.Close()
+
+	// 按压缩率估算每个分片的原始大小，留出一定余量
+	ratio := float64(e.size) / float64(e.buf.Len()+1)
+	chunkSize := int64(float64(v.limit()-volumeReserve-entryOverhead(e.name)) * ratio * 0.9)
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+
+	logrus.Infof("条目 %s 压缩后 %d 字节，超过分卷大小 %d 字节，将切分写入", e.name, e.buf.Len(), v.maxSize)
+
+	source := bufio.NewReaderSize(decompressor, 64<<10)
+	for part := 1; ; part++ {
+		chunk, err := newZipStreamEntry(v.spill, v.commitLocked, fmt.Sprintf("%s.part%03d", e.name, part), e.modified)
+		if err != nil {
+			return err
+		}
+
+		n, readErr := copyLines(chunk, source, chunkSize)
+		if readErr != nil && readErr != io.EOF {
+			chunk.Discard()
+			return fmt.Errorf("切分条目失败 %s: %w", e.name, readErr)
+		}
+		if n == 0 {
+			chunk.Discard()
+			break
+		}
+
+		// 每个分片从新的分卷开始
+		if v.current != nil && len(v.current.writer.Entries()) > 0 {
+			if err := v.closeCurrent(); err != nil {
+				chunk.Discard()
+				return err
+			}
+		}
+		if err := chunk.Commit(); err != nil {
+			return err
+		}
+		if v.current.writer.Written() > v.limit() {
+			logrus.Warnf("条目分片 %s 压缩后仍超过分卷大小", chunk.name)
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+	return nil
+}
+
+// openNext 打开下一个分卷，写入完成前使用临时文件名，调用方需持有锁
+func (v *ZipVolumeWriter) openNext() error {
+	index := len(v.volumes) + 1
+	path := v.pathFunc(index)
+	file, err := os.Create(path + ".partial")
+	if err != nil {
+		return fmt.Errorf("创建分卷文件失败: %w", err)
+	}
+	volume := &openVolume{
+		index: index,
+		path:  path,
+		file:  file,
+	}
+	var out io.Writer = file
+	if v.wrapper != nil {
+		wrapped, err := v.wrapper(file)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return fmt.Errorf("创建分卷写入器失败: %w", err)
+		}
+		volume.wrapped = wrapped
+		out = wrapped
+	}
+	volume.writer = NewZipStreamWriter(out)
+	v.current = volume
+	return nil
+}
+
+// limit 返回ZIP数据可用的分卷大小，包装写入器（例如加密）的额外开销不计入其中
+func (v *ZipVolumeWriter) limit() int64 {
+	if v.wrapper == nil {
+		return v.maxSize
+	}
+	return v.maxSize - v.maxSize/1024 - 32<<10
+}
+
+// closeCurrent 完成当前分卷，调用方需持有锁
+func (v *ZipVolumeWriter) closeCurrent() error {
+	current := v.current
+	v.current = nil
+
+	cleanup := func() {
+		current.file.Close()
+		os.Remove(current.file.Name())
+	}
+
+	if v.finalizer != nil {
+		if err := v.finalizer(current.index, current.writer); err != nil {
+			current.writer.Close()
+			cleanup()
+			return fmt.Errorf("完成分卷 %d 失败: %w", current.index, err)
+		}
+	}
+	if err := current.writer.Close(); err != nil {
+		cleanup()
+		return fmt.Errorf("关闭分卷 %d 失败: %w", current.index, err)
+	}
+	if current.wrapped != nil {
+		if err := current.wrapped.Close(); err != nil {
+			cleanup()
+			return fmt.Errorf("关闭分卷 %d 失败: %w", current.index, err)
+		}
+	}
+	if err := current.file.Close(); err != nil {
+		os.Remove(current.file.Name())
+		return fmt.Errorf("关闭分卷文件 %d 失败: %w", current.index, err)
+	}
+	if err := os.Rename(current.file.Name(), current.path); err != nil {
+		os.Remove(current.file.Name())
+		return fmt.Errorf("重命名分卷文件 %d 失败: %w", current.index, err)
+	}
+
+	size := current.writer.Written()
+	if info, err := os.Stat(current.path); err == nil {
+		size = info.Size()
+	}
+	v.volumes = append(v.volumes, ZipVolume{
+		Index:   current.index,
+		Path:    current.path,
+		Size:    size,
+		Entries: current.writer.Entries(),
+	})
+	logrus.Infof("已完成分卷 %d: %s (大小: %d 字节)", current.index, current.path, size)
+	return nil
+}
+
+// entryOverhead 预估单个条目在本地文件头、中央目录和清单中的额外开销
+func entryOverhead(name string) int64 {
+	return int64(3*len(name) + 256)
+}
+
+// copyLines 从 src 复制数据到 dst，复制量达到 limit 后在行尾停止
+func copyLines(dst io.Writer, src *bufio.Reader, limit int64) (int64, error) {
+	var copied int64
+	for copied < limit {
+		line, err := src.ReadSlice('\n')
+		if len(line) > 0 {
+			n, writeErr := dst.Write(line)
+			copied += int64(n)
+			if writeErr != nil {
+				return copied, writeErr
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return copied, err
+		}
+	}
+	return copied, nil
+}
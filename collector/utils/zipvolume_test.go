@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestZipVolumeWriter_SplitsOversizedEntry(t *testing.T) {
+	dir := t.TempDir()
+	const maxSize = 64 << 10
+	writer := NewZipVolumeWriter(maxSize, func(volume int) string {
+		return filepath.Join(dir, fmt.Sprintf("snap.part%03d.zip", volume))
+	}, nil)
+
+	// 写入一个压缩后远超分卷大小的条目
+	entry, err := writer.Create("logs/big.log")
+	if err != nil {
+		t.Fatalf("创建条目失败: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(entry, "line %d %x\n", i, rng.Int63())
+	}
+	if err := entry.Commit(); err != nil {
+		t.Fatalf("提交条目失败: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("关闭写入器失败: %v", err)
+	}
+
+	volumes := writer.Volumes()
+	if len(volumes) < 2 {
+		t.Fatalf("应生成多个分卷, 实际有 %d 个", len(volumes))
+	}
+
+	// 依次读取各分卷中的分片，拼接后应为完整的行
+	var lines int
+	for i, volume := range volumes {
+		info, err := os.Stat(volume.Path)
+		if err != nil {
+			t.Fatalf("分卷不存在: %v", err)
+		}
+		if info.Size() > maxSize {
+			t.Fatalf("分卷 %d 大小 %d 超过上限", volume.Index, info.Size())
+		}
+
+		reader, err := zip.OpenReader(volume.Path)
+		if err != nil {
+			t.Fatalf("打开分卷失败: %v", err)
+		}
+		for _, file := range reader.File {
+			if want := fmt.Sprintf("logs/big.log.part%03d", i+1); file.Name != want {
+				t.Fatalf("分片名称应为 %s, 实际为 %s", want, file.Name)
+			}
+			rc, _ := file.Open()
+			content, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("读取分片失败: %v", err)
+			}
+			if !strings.HasSuffix(string(content), "\n") {
+				t.Fatalf("分片 %s 应在行尾切分", file.Name)
+			}
+			lines += strings.Count(string(content), "\n")
+		}
+		reader.Close()
+	}
+	if lines != 20000 {
+		t.Fatalf("分片总行数应为 20000, 实际为 %d", lines)
+	}
+}
+
+func TestZipVolumeWriter_Abort(t *testing.T) {
+	dir := t.TempDir()
+	writer := NewZipVolumeWriter(0, func(int) string {
+		return filepath.Join(dir, "snap.zip")
+	}, nil)
+
+	entry, _ := writer.Create("app.log")
+	entry.Write([]byte("content"))
+	entry.Commit()
+	writer.Abort()
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 0 {
+		t.Fatalf("中止后不应残留文件, 实际有 %d 个", len(files))
+	}
+}
@@ -0,0 +1,127 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// RecipientPrefix 公钥（接收者）字符串前缀
+	RecipientPrefix = "logsnap-pub-"
+	// IdentityPrefix 私钥（身份）字符串前缀
+	IdentityPrefix = "LOGSNAP-KEY-"
+)
+
+// Recipient 快照接收者，即可以解密快照的一方的 X25519 公钥
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// Identity 解密快照使用的 X25519 私钥
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateIdentity 生成新的密钥对
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseRecipient 解析公钥字符串，格式为 logsnap-pub-<base64>
+func ParseRecipient(s string) (*Recipient, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, RecipientPrefix) {
+		return nil, fmt.Errorf("无效的公钥: 缺少前缀 %s", RecipientPrefix)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, RecipientPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("无效的公钥: %w", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("无效的公钥: %w", err)
+	}
+	return &Recipient{key: key}, nil
+}
+
+// ParseRecipients 解析多个公钥字符串，忽略空字符串和重复的公钥
+func ParseRecipients(keys []string) ([]*Recipient, error) {
+	var recipients []*Recipient
+	seen := make(map[string]bool)
+	for _, s := range keys {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[s] {
+			continue
+		}
+		recipient, err := ParseRecipient(s)
+		if err != nil {
+			return nil, err
+		}
+		seen[s] = true
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// String 返回公钥字符串
+func (r *Recipient) String() string {
+	return RecipientPrefix + base64.RawURLEncoding.EncodeToString(r.key.Bytes())
+}
+
+// ParseIdentity 解析私钥字符串，格式为 LOGSNAP-KEY-<base64>
+func ParseIdentity(s string) (*Identity, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, IdentityPrefix) {
+		return nil, fmt.Errorf("无效的私钥: 缺少前缀 %s", IdentityPrefix)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, IdentityPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("无效的私钥: %w", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("无效的私钥: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// LoadIdentity 从密钥文件读取私钥，以 # 开头的行视为注释
+func LoadIdentity(path string) (*Identity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开密钥文件失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return ParseIdentity(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	return nil, fmt.Errorf("密钥文件中没有私钥: %s", path)
+}
+
+// String 返回私钥字符串
+func (i *Identity) String() string {
+	return IdentityPrefix + base64.RawURLEncoding.EncodeToString(i.key.Bytes())
+}
+
+// Recipient 返回私钥对应的公钥
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{key: i.key.PublicKey()}
+}
@@ -0,0 +1,385 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 加密文件格式:
+//
+//	header  = magic(8) | version(1) | count(1) | salt(16) | count * stanza
+//	stanza  = ephemeral public key(32) | wrapped file key(48)
+//	payload = chunk*，每块最多 chunkSize 字节明文，使用 AES-256-GCM 加密
+//
+// 文件密钥随机生成，对每个接收者使用临时 X25519 密钥协商出的共享密钥包装。
+// 分块的 nonce 由块序号和结束标记组成，可以发现块的截断、重排和删除；
+// 所有分块都以完整的文件头作为附加数据，文件头被篡改时解密失败。
+const (
+	formatVersion = 1
+	chunkSize     = 64 << 10
+	saltSize      = 16
+	fileKeySize   = 32
+	stanzaSize    = 32 + fileKeySize + 16
+	// FileExtension 加密快照文件的扩展名
+	FileExtension = ".enc"
+)
+
+// magic 加密文件的文件头标识
+var magic = []byte("LSNAPENC")
+
+// ErrNoMatchingIdentity 私钥与快照的任何接收者都不匹配
+var ErrNoMatchingIdentity = errors.New("私钥与快照的接收者不匹配")
+
+// IsEncrypted 判断文件是否为加密快照
+func IsEncrypted(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(file, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(header, magic), nil
+}
+
+// NewWriter 返回加密写入器，写入的明文加密后写入 w
+// 写入完成后必须调用 Close 写入最后一个分块，Close 不会关闭 w
+func NewWriter(w io.Writer, recipients []*Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("至少需要一个接收者公钥")
+	}
+	if len(recipients) > 255 {
+		return nil, errors.New("接收者数量不能超过 255 个")
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, fmt.Errorf("生成文件密钥失败: %w", err)
+	}
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %w", err)
+	}
+
+	header := bytes.NewBuffer(nil)
+	header.Write(magic)
+	header.WriteByte(formatVersion)
+	header.WriteByte(byte(len(recipients)))
+	header.Write(salt)
+	for _, recipient := range recipients {
+		stanza, err := wrapFileKey(fileKey, recipient)
+		if err != nil {
+			return nil, err
+		}
+		header.Write(stanza)
+	}
+
+	aead, err := payloadCipher(fileKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return nil, fmt.Errorf("写入文件头失败: %w", err)
+	}
+
+	return &writer{
+		dst:    w,
+		aead:   aead,
+		header: header.Bytes(),
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// NewReader 返回解密读取器，从 r 读取加密数据并返回明文
+func NewReader(r io.Reader, identity *Identity) (io.Reader, error) {
+	src := bufio.NewReaderSize(r, chunkSize+aes.BlockSize+64)
+
+	fixed := make([]byte, len(magic)+2+saltSize)
+	if _, err := io.ReadFull(src, fixed); err != nil {
+		return nil, fmt.Errorf("读取文件头失败: %w", err)
+	}
+	if !bytes.Equal(fixed[:len(magic)], magic) {
+		return nil, errors.New("不是加密的快照文件")
+	}
+	if fixed[len(magic)] != formatVersion {
+		return nil, fmt.Errorf("不支持的加密格式版本: %d", fixed[len(magic)])
+	}
+	count := int(fixed[len(magic)+1])
+	salt := fixed[len(magic)+2:]
+
+	stanzas := make([]byte, count*stanzaSize)
+	if _, err := io.ReadFull(src, stanzas); err != nil {
+		return nil, fmt.Errorf("读取文件头失败: %w", err)
+	}
+
+	var fileKey []byte
+	for i := 0; i < count; i++ {
+		key, err := unwrapFileKey(stanzas[i*stanzaSize:(i+1)*stanzaSize], identity)
+		if err == nil {
+			fileKey = key
+			break
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrNoMatchingIdentity
+	}
+
+	aead, err := payloadCipher(fileKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{
+		src:    src,
+		aead:   aead,
+		header: append(fixed, stanzas...),
+	}, nil
+}
+
+// EncryptFile 加密文件 src 并写入 dst
+func EncryptFile(src, dst string, recipients []*Recipient) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("创建加密文件失败: %w", err)
+	}
+	w, err := NewWriter(out, recipients)
+	if err == nil {
+		_, err = io.Copy(w, in)
+		if err == nil {
+			err = w.Close()
+		}
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("加密文件失败: %w", err)
+	}
+	return nil
+}
+
+// DecryptFile 使用私钥解密文件 src 并写入 dst
+func DecryptFile(src, dst string, identity *Identity) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer in.Close()
+
+	r, err := NewReader(in, identity)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("创建解密文件失败: %w", err)
+	}
+	_, err = io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("解密文件失败: %w", err)
+	}
+	return nil
+}
+
+// writer 分块加密写入器
+type writer struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("加密写入器已关闭")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// 缓冲区已满且还有数据时才写出分块，保证最后一块总是由 Close 写出
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+func (w *writer) flush(final bool) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.counter, final), w.buf, w.header)
+	w.counter++
+	w.buf = w.buf[:0]
+	if _, err := w.dst.Write(sealed); err != nil {
+		return fmt.Errorf("写入加密数据失败: %w", err)
+	}
+	return nil
+}
+
+// reader 分块解密读取器
+type reader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	plain   []byte
+	counter uint64
+	done    bool
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// next 读取并解密下一个分块
+func (r *reader) next() error {
+	sealed := make([]byte, chunkSize+r.aead.Overhead())
+	n, err := io.ReadFull(r.src, sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return errors.New("加密数据被截断")
+		}
+		return fmt.Errorf("读取加密数据失败: %w", err)
+	}
+	sealed = sealed[:n]
+
+	// 后面没有数据时当前块为最后一块
+	final := err == io.ErrUnexpectedEOF
+	if !final {
+		if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+			final = true
+		}
+	}
+
+	plain, openErr := r.aead.Open(nil, chunkNonce(r.counter, final), sealed, r.header)
+	if openErr != nil {
+		return errors.New("解密失败: 数据已损坏或被篡改")
+	}
+	r.counter++
+	r.plain = plain
+	r.done = final
+	return nil
+}
+
+// wrapFileKey 使用临时密钥为接收者包装文件密钥
+func wrapFileKey(fileKey []byte, recipient *Recipient) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成临时密钥失败: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient.key)
+	if err != nil {
+		return nil, fmt.Errorf("密钥协商失败: %w", err)
+	}
+
+	aead, err := newGCM(hkdf(shared, append(ephemeral.PublicKey().Bytes(), recipient.key.Bytes()...), "logsnap-v1-wrap"))
+	if err != nil {
+		return nil, err
+	}
+	wrapped := aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil)
+	return append(ephemeral.PublicKey().Bytes(), wrapped...), nil
+}
+
+// unwrapFileKey 使用私钥解开文件密钥
+func unwrapFileKey(stanza []byte, identity *Identity) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().NewPublicKey(stanza[:32])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := identity.key.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(hkdf(shared, append(ephemeral.Bytes(), identity.key.PublicKey().Bytes()...), "logsnap-v1-wrap"))
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), stanza[32:], nil)
+}
+
+// payloadCipher 由文件密钥派生数据加密使用的密钥
+func payloadCipher(fileKey, salt []byte) (cipher.AEAD, error) {
+	return newGCM(hkdf(fileKey, salt, "logsnap-v1-payload"))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 返回分块的 nonce: 11 字节大端块序号 + 1 字节结束标记
+func chunkNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// hkdf 使用 HKDF-SHA256 派生 32 字节密钥
+func hkdf(secret, salt []byte, info string) []byte {
+	extractor := hmac.New(sha256.New, salt)
+	extractor.Write(secret)
+	prk := extractor.Sum(nil)
+
+	expander := hmac.New(sha256.New, prk)
+	expander.Write([]byte(info))
+	expander.Write([]byte{1})
+	return expander.Sum(nil)
+}
@@ -0,0 +1,104 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func encrypt(t *testing.T, plain []byte, recipients ...*Recipient) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, recipients)
+	if err != nil {
+		t.Fatalf("创建加密写入器失败: %v", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("关闭失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+
+	// 覆盖空数据、不足一块、恰好整块和多块的情况
+	for _, size := range []int{0, 100, chunkSize, 3*chunkSize + 7} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		sealed := encrypt(t, plain, alice.Recipient(), bob.Recipient())
+
+		for _, identity := range []*Identity{alice, bob} {
+			r, err := NewReader(bytes.NewReader(sealed), identity)
+			if err != nil {
+				t.Fatalf("创建解密读取器失败: %v", err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("解密 %d 字节失败: %v", size, err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatalf("解密 %d 字节后内容不一致", size)
+			}
+		}
+	}
+}
+
+func TestWrongIdentity(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	mallory, _ := GenerateIdentity()
+	sealed := encrypt(t, []byte("secret"), alice.Recipient())
+
+	if _, err := NewReader(bytes.NewReader(sealed), mallory); err != ErrNoMatchingIdentity {
+		t.Fatalf("使用错误的私钥应返回 ErrNoMatchingIdentity, 实际为 %v", err)
+	}
+}
+
+func TestTamperingDetected(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	plain := make([]byte, 2*chunkSize+10)
+	sealed := encrypt(t, plain, alice.Recipient())
+
+	cases := map[string][]byte{
+		"修改数据": func() []byte {
+			b := append([]byte(nil), sealed...)
+			b[len(b)-1] ^= 1
+			return b
+		}(),
+		"截断最后一块": sealed[:len(sealed)-(10+16)],
+	}
+	for name, data := range cases {
+		r, err := NewReader(bytes.NewReader(data), alice)
+		if err != nil {
+			t.Fatalf("%s: 创建解密读取器失败: %v", name, err)
+		}
+		if _, err := io.ReadAll(r); err == nil {
+			t.Fatalf("%s: 应该解密失败", name)
+		}
+	}
+}
+
+func TestKeyStrings(t *testing.T) {
+	identity, _ := GenerateIdentity()
+
+	parsed, err := ParseIdentity(identity.String())
+	if err != nil {
+		t.Fatalf("解析私钥失败: %v", err)
+	}
+	recipient, err := ParseRecipient(identity.Recipient().String())
+	if err != nil {
+		t.Fatalf("解析公钥失败: %v", err)
+	}
+	if parsed.Recipient().String() != recipient.String() {
+		t.Fatalf("私钥和公钥不匹配")
+	}
+
+	if _, err := ParseRecipient("ssh-ed25519 AAAA"); err == nil {
+		t.Fatalf("无效的公钥应解析失败")
+	}
+}
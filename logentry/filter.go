@@ -0,0 +1,163 @@
+package logentry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Filter 按级别和正则表达式筛选日志条目
+type Filter struct {
+	MinSeverity Severity         // 最低级别，SeverityUnknown 表示不限制级别
+	Patterns    []*regexp.Regexp // 条目内容匹配其中任意一个时保留，为空表示不限制内容
+}
+
+// NewFilter 根据级别名称和正则表达式创建过滤器，level 为空时不限制级别
+func NewFilter(level string, patterns []string) (*Filter, error) {
+	filter := &Filter{}
+	if level != "" {
+		filter.MinSeverity = ParseSeverity(level)
+		if filter.MinSeverity == SeverityUnknown {
+			return nil, fmt.Errorf("无效的日志级别: %s, 可选 debug, info, warning, error, fatal", level)
+		}
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式 %q: %w", pattern, err)
+		}
+		filter.Patterns = append(filter.Patterns, re)
+	}
+	return filter, nil
+}
+
+// Active 返回过滤器是否设置了任何条件
+func (f *Filter) Active() bool {
+	return f != nil && (f.MinSeverity != SeverityUnknown || len(f.Patterns) > 0)
+}
+
+// Match 判断日志条目是否满足条件，entry 为条目的全部内容（包括多行日志的后续行）
+// 无法识别级别的条目按 INFO 处理
+func (f *Filter) Match(severity Severity, entry []byte) bool {
+	if f == nil {
+		return true
+	}
+	if severity == SeverityUnknown {
+		severity = SeverityInfo
+	}
+	if severity < f.MinSeverity {
+		return false
+	}
+	if len(f.Patterns) == 0 {
+		return true
+	}
+	for _, re := range f.Patterns {
+		if re.Match(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterWriter 按条目筛选写入的日志内容，只将满足条件的条目写入下层
+// 第一条可识别时间戳的日志之前的内容（如文件头）原样保留，内容不需要按行写入
+type FilterWriter struct {
+	w            io.Writer
+	filter       *Filter
+	fileSeverity Severity
+
+	partial  []byte   // 未结束的行
+	entry    []byte   // 当前条目的内容
+	severity Severity // 当前条目的级别
+	inEntry  bool     // 是否已经遇到第一条日志
+
+	entries int // 条目总数
+	matched int // 满足条件的条目数
+}
+
+// NewFilterWriter 创建按条目筛选的写入器，fileSeverity 为根据文件名识别的级别，条目本身没有级别时使用
+func NewFilterWriter(w io.Writer, filter *Filter, fileSeverity Severity) *FilterWriter {
+	return &FilterWriter{w: w, filter: filter, fileSeverity: fileSeverity}
+}
+
+// Write 按行拆分内容，遇到新条目时处理上一个条目
+func (fw *FilterWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			fw.partial = append(fw.partial, p...)
+			break
+		}
+		fw.partial = append(fw.partial, p[:i+1]...)
+		p = p[i+1:]
+		if err := fw.line(fw.partial); err != nil {
+			return n - len(p), err
+		}
+		fw.partial = fw.partial[:0]
+	}
+	return n, nil
+}
+
+// line 处理一个完整的行
+func (fw *FilterWriter) line(line []byte) error {
+	parsed, ok := Parse(line)
+	if !ok {
+		if !fw.inEntry {
+			_, err := fw.w.Write(line)
+			return err
+		}
+		fw.entry = append(fw.entry, line...)
+		return nil
+	}
+
+	if err := fw.flushEntry(); err != nil {
+		return err
+	}
+	fw.inEntry = true
+	fw.entries++
+	fw.severity = parsed.Severity
+	if fw.severity == SeverityUnknown {
+		fw.severity = fw.fileSeverity
+	}
+	fw.entry = append(fw.entry[:0], line...)
+	return nil
+}
+
+// flushEntry 当前条目满足条件时写入下层
+func (fw *FilterWriter) flushEntry() error {
+	if len(fw.entry) == 0 {
+		return nil
+	}
+	entry := fw.entry
+	fw.entry = fw.entry[:0]
+	if !fw.filter.Match(fw.severity, entry) {
+		return nil
+	}
+	fw.matched++
+	_, err := fw.w.Write(entry)
+	return err
+}
+
+// Flush 处理最后一个没有换行符的行和最后一个条目
+func (fw *FilterWriter) Flush() error {
+	if len(fw.partial) > 0 {
+		line := fw.partial
+		fw.partial = nil
+		if err := fw.line(line); err != nil {
+			return err
+		}
+	}
+	return fw.flushEntry()
+}
+
+// Entries 返回条目总数
+func (fw *FilterWriter) Entries() int {
+	return fw.entries
+}
+
+// Matched 返回满足条件的条目数
+func (fw *FilterWriter) Matched() int {
+	return fw.matched
+}
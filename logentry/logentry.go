@@ -0,0 +1,240 @@
+// Package logentry 识别各程序日志行中的时间戳和日志级别
+// 收集器在不依赖具体处理器的情况下，使用它对快照中的日志内容进行分级和排序
+package logentry
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Severity 日志级别
+type Severity int
+
+const (
+	// SeverityUnknown 无法识别级别
+	SeverityUnknown Severity = iota
+	// SeverityDebug 调试日志
+	SeverityDebug
+	// SeverityInfo 普通日志
+	SeverityInfo
+	// SeverityWarning 警告日志
+	SeverityWarning
+	// SeverityError 错误日志
+	SeverityError
+	// SeverityFatal 致命错误日志
+	SeverityFatal
+
+	severityCount = iota
+)
+
+// severityNames 日志级别名称，与 Severity 的取值一一对应
+var severityNames = []string{"UNKNOWN", "DEBUG", "INFO", "WARNING", "ERROR", "FATAL"}
+
+// String 返回日志级别名称
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return severityNames[SeverityUnknown]
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity 解析日志级别名称，支持 glog 的单字母级别，无法识别时返回 SeverityUnknown
+func ParseSeverity(s string) Severity {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "F", "FATAL", "CRITICAL", "PANIC":
+		return SeverityFatal
+	case "E", "ERROR", "ERR":
+		return SeverityError
+	case "W", "WARN", "WARNING":
+		return SeverityWarning
+	case "I", "INFO", "NOTICE", "SUCCESS":
+		return SeverityInfo
+	case "D", "DEBUG", "TRACE":
+		return SeverityDebug
+	}
+	return SeverityUnknown
+}
+
+// Layout 日志行中的时间戳格式，由各处理器声明其日志使用的格式
+type Layout struct {
+	Pattern *regexp.Regexp // 匹配时间戳，第一个分组为时间戳；紧挨分组之前的 glog 级别字母作为日志级别
+	Format  string         // 时间戳的解析格式
+}
+
+// genericLayouts 没有所属处理器的文件使用的通用时间戳格式
+var genericLayouts = []Layout{
+	// glog: E20250228 13:33:03.344947 3495818 file.cpp:160] msg
+	{regexp.MustCompile(`^[IWEF](\d{8} \d{2}:\d{2}:\d{2}\.\d{6})`), "20060102 15:04:05.000000"},
+	// glog 默认格式，不含年份: E0228 13:33:03.344947 ...
+	{regexp.MustCompile(`^[IWEF](\d{4} \d{2}:\d{2}:\d{2}\.\d{6})`), "0102 15:04:05.000000"},
+	// 20250228 08:41:13.163] msg
+	{regexp.MustCompile(`^(\d{8} \d{2}:\d{2}:\d{2}\.\d{3})\]`), "20060102 15:04:05.000"},
+	// 2025-02-28 11:35:22.383 | ERROR    | msg
+	{regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3})`), "2006-01-02 15:04:05.000"},
+	// ISO 8601: 2025-02-28T11:35:22 ...
+	{regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2})`), "2006-01-02T15:04:05"},
+	// 2025-02-28 11:35:22 ...
+	{regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})`), "2006-01-02 15:04:05"},
+}
+
+// levelPattern 匹配行首附近的级别关键字，例如 "| ERROR |"、"[WARN]"、" - INFO - "
+var levelPattern = regexp.MustCompile(`(?:^|[\s\[|(<-])(FATAL|CRITICAL|ERROR|WARNING|WARN|INFO|DEBUG|TRACE)(?:$|[\s\]|):>-])`)
+
+// levelSearchLimit 只在行首的这些字节中查找级别关键字，避免把消息内容误认为级别
+const levelSearchLimit = 64
+
+// Entry 描述一条日志的行首信息
+type Entry struct {
+	Time     time.Time // 时间戳，无法识别时为零值
+	Severity Severity  // 日志级别，无法识别时为 SeverityUnknown
+	Offset   int       // 时间戳之后的内容在行中的起始位置
+}
+
+// Parse 使用通用格式识别日志行的时间戳和级别，用于没有所属处理器的文件
+// 行首没有可识别的时间戳时返回 false，这样的行通常是多行日志的后续行
+func Parse(line []byte) (Entry, bool) {
+	return ParseLayouts(line, genericLayouts)
+}
+
+// ParseLayouts 依次使用 layouts 识别日志行的时间戳和级别
+// 时间戳之前紧挨着 glog 级别字母时以它作为级别，否则在时间戳之后的内容中查找级别关键字
+func ParseLayouts(line []byte, layouts []Layout) (Entry, bool) {
+	for _, layout := range layouts {
+		loc := layout.Pattern.FindSubmatchIndex(line)
+		if loc == nil || len(loc) < 4 || loc[2] < 0 {
+			continue
+		}
+		timestamp, err := time.ParseInLocation(layout.Format, string(line[loc[2]:loc[3]]), time.Local)
+		if err != nil {
+			continue
+		}
+		if timestamp.Year() == 0 {
+			timestamp = withCurrentYear(timestamp, time.Now())
+		}
+
+		entry := Entry{Time: timestamp, Offset: loc[1]}
+		if loc[2] == loc[0]+1 && strings.IndexByte("IWEF", line[loc[0]]) >= 0 {
+			entry.Severity = ParseSeverity(string(line[loc[0]]))
+		} else {
+			entry.Severity = DetectSeverity(line[loc[1]:])
+		}
+		return entry, true
+	}
+	return Entry{}, false
+}
+
+// withCurrentYear 为不含年份的时间戳补上年份
+// 取不晚于当前时间的最近一年，跨年后分析上一年 12 月的日志不会得到未来的时间
+func withCurrentYear(timestamp, now time.Time) time.Time {
+	timestamp = timestamp.AddDate(now.Year(), 0, 0)
+	if timestamp.After(now) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+	return timestamp
+}
+
+// DetectSeverity 在行首附近查找级别关键字
+func DetectSeverity(line []byte) Severity {
+	if len(line) > levelSearchLimit {
+		line = line[:levelSearchLimit]
+	}
+	matches := levelPattern.FindSubmatch(line)
+	if matches == nil {
+		return SeverityUnknown
+	}
+	return ParseSeverity(string(matches[1]))
+}
+
+// SeverityFromFileName 根据文件名识别日志级别，例如 glog 的 xxx.log.ERROR.20250228-133303.123
+func SeverityFromFileName(name string) Severity {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	for _, part := range strings.Split(base, ".") {
+		switch part {
+		case "FATAL", "ERROR", "WARNING", "INFO":
+			return ParseSeverity(part)
+		}
+	}
+	return SeverityUnknown
+}
+
+// Stats 统计写入的日志内容中各级别的条目数和最新时间戳
+// 实现 io.Writer，可以在写入日志文件的同时统计，内容不需要按行写入
+type Stats struct {
+	Counts  [severityCount]int // 各级别的条目数
+	Entries int                // 可识别时间戳的条目数
+	Lines   int                // 总行数
+	First   time.Time          // 最早的时间戳
+	Latest  time.Time          // 最新的时间戳
+	// OnEntry 可选，每识别到一条日志时调用，line 只包含该行开头的部分内容
+	OnEntry func(entry Entry, line []byte)
+
+	partial []byte // 未结束的行的开头部分
+	dropped bool   // 当前行是否超出了 partial 的长度
+}
+
+// maxLinePrefix 统计时每行只保留开头的这些字节
+const maxLinePrefix = 256
+
+// Write 统计写入的内容
+func (s *Stats) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.appendPartial(p)
+			break
+		}
+		s.appendPartial(p[:i])
+		s.endLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Flush 统计最后一个没有换行符的行
+func (s *Stats) Flush() {
+	if len(s.partial) > 0 || s.dropped {
+		s.endLine()
+	}
+}
+
+// MaxSeverity 返回出现过的最高日志级别
+func (s *Stats) MaxSeverity() Severity {
+	for severity := SeverityFatal; severity > SeverityUnknown; severity-- {
+		if s.Counts[severity] > 0 {
+			return severity
+		}
+	}
+	return SeverityUnknown
+}
+
+func (s *Stats) appendPartial(p []byte) {
+	room := maxLinePrefix - len(s.partial)
+	if len(p) > room {
+		p = p[:room]
+		s.dropped = true
+	}
+	s.partial = append(s.partial, p...)
+}
+
+func (s *Stats) endLine() {
+	s.Lines++
+	if entry, ok := Parse(s.partial); ok {
+		s.Entries++
+		s.Counts[entry.Severity]++
+		if s.First.IsZero() || entry.Time.Before(s.First) {
+			s.First = entry.Time
+		}
+		if entry.Time.After(s.Latest) {
+			s.Latest = entry.Time
+		}
+		if s.OnEntry != nil {
+			s.OnEntry(entry, s.partial)
+		}
+	}
+	s.partial = s.partial[:0]
+	s.dropped = false
+}
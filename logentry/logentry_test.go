@@ -0,0 +1,166 @@
+package logentry
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line     string
+		ok       bool
+		time     time.Time
+		severity Severity
+	}{
+		{"E20250228 13:33:03.344947 3495818 file.cpp:160] failed", true,
+			time.Date(2025, 2, 28, 13, 33, 3, 344947000, time.Local), SeverityError},
+		{"20250228 08:41:13.163] User clicked [StartTask].", true,
+			time.Date(2025, 2, 28, 8, 41, 13, 163000000, time.Local), SeverityUnknown},
+		{"2025-02-28 11:35:22.383 | WARNING  | xyz.peer:status:17 - ERROR in message", true,
+			time.Date(2025, 2, 28, 11, 35, 22, 383000000, time.Local), SeverityWarning},
+		{"2025-02-28 11:35:22,383 - root - ERROR - boom", true,
+			time.Date(2025, 2, 28, 11, 35, 22, 0, time.Local), SeverityError},
+		{"Log file created at: 2025/02/28 13:33:03", false, time.Time{}, SeverityUnknown},
+		{"    at continuation line", false, time.Time{}, SeverityUnknown},
+	}
+
+	for _, tt := range tests {
+		entry, ok := Parse([]byte(tt.line))
+		if ok != tt.ok {
+			t.Errorf("Parse(%q) ok = %v, 期望 %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !entry.Time.Equal(tt.time) {
+			t.Errorf("Parse(%q) 时间 = %v, 期望 %v", tt.line, entry.Time, tt.time)
+		}
+		if entry.Severity != tt.severity {
+			t.Errorf("Parse(%q) 级别 = %v, 期望 %v", tt.line, entry.Severity, tt.severity)
+		}
+	}
+}
+
+func TestParseLayouts(t *testing.T) {
+	layouts := []Layout{
+		{regexp.MustCompile(`^(\d{8} \d{2}:\d{2}:\d{2}\.\d{3})]`), "20060102 15:04:05.000"},
+		{regexp.MustCompile(`[IWEF](\d{8} \d{2}:\d{2}:\d{2}\.\d{6})`), "20060102 15:04:05.000000"},
+	}
+
+	entry, ok := ParseLayouts([]byte("W20250228 13:33:03.344947 3495818 file.cpp:160] slow"), layouts)
+	if !ok || entry.Severity != SeverityWarning ||
+		!entry.Time.Equal(time.Date(2025, 2, 28, 13, 33, 3, 344947000, time.Local)) {
+		t.Errorf("glog 行识别错误: %+v, %v", entry, ok)
+	}
+	entry, ok = ParseLayouts([]byte("20250228 08:41:13.163] User clicked [StartTask]."), layouts)
+	if !ok || entry.Severity != SeverityUnknown || entry.Offset != len("20250228 08:41:13.163]") {
+		t.Errorf("用户操作日志行识别错误: %+v, %v", entry, ok)
+	}
+	// 处理器没有声明的格式不识别
+	if _, ok := ParseLayouts([]byte("2025-02-28 11:35:22,383 - root - ERROR - boom"), layouts); ok {
+		t.Errorf("不应识别处理器没有声明的格式")
+	}
+}
+
+func TestWithCurrentYear(t *testing.T) {
+	now := time.Date(2026, 1, 2, 8, 0, 0, 0, time.Local)
+	december := time.Date(0, 12, 31, 23, 59, 0, 0, time.Local)
+	if got := withCurrentYear(december, now); !got.Equal(time.Date(2025, 12, 31, 23, 59, 0, 0, time.Local)) {
+		t.Errorf("跨年后上一年 12 月的日志应为上一年: %v", got)
+	}
+	january := time.Date(0, 1, 2, 7, 0, 0, 0, time.Local)
+	if got := withCurrentYear(january, now); !got.Equal(time.Date(2026, 1, 2, 7, 0, 0, 0, time.Local)) {
+		t.Errorf("不晚于当前时间的日志应为当前年份: %v", got)
+	}
+}
+
+func TestSeverityFromFileName(t *testing.T) {
+	if got := SeverityFromFileName("logs/xyz_hmi_bin.host.xyz.log.ERROR.20250228-133303.3495738"); got != SeverityError {
+		t.Errorf("期望 ERROR, 实际 %v", got)
+	}
+	if got := SeverityFromFileName("all.2025-02-28_11-35-22_430010.log"); got != SeverityUnknown {
+		t.Errorf("期望 UNKNOWN, 实际 %v", got)
+	}
+}
+
+func TestStats(t *testing.T) {
+	var stats Stats
+	// 内容分多次写入，行可能被拆开
+	stats.Write([]byte("I20250228 10:00:00.000000 1 a.cpp:1] start\nE2025022"))
+	stats.Write([]byte("8 10:05:00.000000 1 a.cpp:2] failed\n  detail line\nW20250228 10:03:00.000000 1 a.cpp:3] slow"))
+	stats.Flush()
+
+	if stats.Lines != 4 || stats.Entries != 3 {
+		t.Fatalf("行数 = %d, 条目数 = %d, 期望 4 和 3", stats.Lines, stats.Entries)
+	}
+	if stats.MaxSeverity() != SeverityError {
+		t.Errorf("最高级别 = %v, 期望 ERROR", stats.MaxSeverity())
+	}
+	if want := time.Date(2025, 2, 28, 10, 5, 0, 0, time.Local); !stats.Latest.Equal(want) {
+		t.Errorf("最新时间 = %v, 期望 %v", stats.Latest, want)
+	}
+	if want := time.Date(2025, 2, 28, 10, 0, 0, 0, time.Local); !stats.First.Equal(want) {
+		t.Errorf("最早时间 = %v, 期望 %v", stats.First, want)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"connect 192.168.1.10:7010 failed, retry 3", "connect <IP> failed, retry <NUM>"},
+		{"open /data/logs/app.log failed", "open <PATH> failed"},
+		{`load C:\xyz\config.json`, "load <PATH>"},
+		{"task 3f2b8c1e-6a4d-4f1e-9c3b-2d7e8f9a0b1c timeout after 1.5s", "task <UUID> timeout after <NUM>s"},
+		{"bad pointer 0x7ffe3a2c, hash deadbeef42, id 1234567890", "bad pointer <HEX>, hash <HEX>, id <NUM>"},
+	}
+	for _, tt := range tests {
+		if got := Template(tt.message); got != tt.want {
+			t.Errorf("Template(%q) = %q, 期望 %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestFilterWriter(t *testing.T) {
+	filter, err := NewFilter("warning", []string{"timeout", "refused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	fw := NewFilterWriter(&out, filter, SeverityInfo)
+	input := "Log file created at: 2025/02/28 13:33:03\n" +
+		"E20250228 13:33:03.344947 3495818 file.cpp:160] connect refused\n" +
+		"    at continuation line\n" +
+		"I20250228 13:33:04.000000 3495818 file.cpp:161] connect refused\n" +
+		"W20250228 13:33:05.000000 3495818 file.cpp:162] slow\n" +
+		"E20250228 13:33:06.000000 3495818 file.cpp:163] read timeout"
+	// 分多次写入，条目跨越写入边界
+	for _, chunk := range []string{input[:50], input[50:130], input[130:]} {
+		if _, err := fw.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Log file created at: 2025/02/28 13:33:03\n" +
+		"E20250228 13:33:03.344947 3495818 file.cpp:160] connect refused\n" +
+		"    at continuation line\n" +
+		"E20250228 13:33:06.000000 3495818 file.cpp:163] read timeout"
+	if out.String() != want {
+		t.Errorf("输出 = %q, 期望 %q", out.String(), want)
+	}
+	if fw.Entries() != 4 || fw.Matched() != 2 {
+		t.Errorf("条目 = %d/%d, 期望 2/4", fw.Matched(), fw.Entries())
+	}
+
+	if _, err := NewFilter("verbose", nil); err == nil {
+		t.Error("无效的级别应返回错误")
+	}
+	if _, err := NewFilter("", []string{"("}); err == nil {
+		t.Error("无效的正则表达式应返回错误")
+	}
+}
@@ -0,0 +1,53 @@
+package logentry
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 模板中替换可变内容使用的占位符
+const (
+	PlaceholderUUID   = "<UUID>"
+	PlaceholderPath   = "<PATH>"
+	PlaceholderIP     = "<IP>"
+	PlaceholderHex    = "<HEX>"
+	PlaceholderNumber = "<NUM>"
+)
+
+// maxTemplateLength 模板的最大长度（字节），超出部分被截断
+const maxTemplateLength = 200
+
+// templateRules 按顺序替换日志消息中的可变内容，先替换较长、较具体的模式
+var templateRules = []struct {
+	pattern *regexp.Regexp
+	replace func(match string) string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), constant(PlaceholderUUID)},
+	{regexp.MustCompile(`(?:[A-Za-z]:)?[/\\][\w.\-]+(?:[/\\][\w.\-]+)+[/\\]?`), constant(PlaceholderPath)},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), constant(PlaceholderIP)},
+	{regexp.MustCompile(`\b(?:0[xX][0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`), func(match string) string {
+		// 只由数字组成的长串按数字处理
+		if strings.Trim(match, "0123456789") == "" {
+			return PlaceholderNumber
+		}
+		return PlaceholderHex
+	}},
+	{regexp.MustCompile(`\d+(?:\.\d+)?`), constant(PlaceholderNumber)},
+}
+
+func constant(placeholder string) func(string) string {
+	return func(string) string { return placeholder }
+}
+
+// Template 将日志消息中的数字、十六进制串、路径、IP 地址和 UUID 替换为占位符
+// 只有这些内容不同的日志得到相同的模板，可以归为同一类
+func Template(message string) string {
+	template := strings.TrimSpace(message)
+	for _, rule := range templateRules {
+		template = rule.pattern.ReplaceAllStringFunc(template, rule.replace)
+	}
+	if len(template) > maxTemplateLength {
+		template = strings.ToValidUTF8(template[:maxTemplateLength], "") + "…"
+	}
+	return template
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package queue
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile 对文件加排他锁，其他进程加锁时阻塞到锁释放
+func lockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
+
+// unlockFile 释放文件锁
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,302 @@
+// Package queue 持久化的上传队列：上传失败的快照记录到配置目录中，按指数退避重试，
+// 程序重启后继续上传，适用于网络经常中断的现场设备
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileName 配置目录中记录上传队列的文件名
+const FileName = "upload_queue.json"
+
+const (
+	BaseDelay = time.Minute   // 第一次失败后的重试间隔
+	MaxDelay  = 6 * time.Hour // 重试间隔的上限
+
+	// ClaimTimeout 队列项被认领超过该时间仍未完成时，认为认领的进程已退出，其他进程可以重新认领
+	ClaimTimeout = 12 * time.Hour
+)
+
+// Item 等待上传的快照
+type Item struct {
+	ID          string     `json:"id"`                   // 快照名称
+	Files       []string   `json:"files"`                // 要上传到同一目录的所有文件
+	KeepLocal   bool       `json:"keep_local"`           // 上传成功后是否保留本地文件
+	Source      string     `json:"source,omitempty"`     // 触发收集的来源，例如 collect、watch
+	Start       *time.Time `json:"start,omitempty"`      // 快照时间范围的开始时间，随文件提交给支持的上传服务
+	End         *time.Time `json:"end,omitempty"`        // 快照时间范围的结束时间
+	Attempts    int        `json:"attempts"`             // 已尝试上传的次数
+	LastError   string     `json:"last_error,omitempty"` // 最后一次上传失败的原因
+	QueuedAt    time.Time  `json:"queued_at"`            // 加入队列的时间
+	NextAttempt time.Time  `json:"next_attempt"`         // 下次重试的时间
+	ClaimedBy   string     `json:"claimed_by,omitempty"` // 正在上传该快照的进程，其他进程不再上传
+	ClaimedAt   *time.Time `json:"claimed_at,omitempty"` // 认领的时间
+}
+
+// claimed 返回队列项是否已被其他上传认领且认领未超时
+func (i Item) claimed(now time.Time) bool {
+	return i.ClaimedBy != "" && i.ClaimedAt != nil && now.Sub(*i.ClaimedAt) < ClaimTimeout
+}
+
+// Result 一次重试的结果
+type Result struct {
+	Item    Item     // 重试后的队列项，失败时 Attempts、LastError 和 NextAttempt 已更新
+	URLs    []string // 上传成功时每个文件的链接
+	Err     error    // 上传失败的原因
+	Dropped bool     // 快照文件已不存在，已从队列中移除
+}
+
+// UploadFunc 上传一个队列项的所有文件，返回每个文件的链接
+type UploadFunc func(item Item) ([]string, error)
+
+// Queue 上传队列
+type Queue struct {
+	path string
+	mu   *sync.Mutex
+}
+
+// locks 每个队列文件一把锁，同一进程中多次打开同一个队列（例如 watch 收集时和后台重试时）不会相互覆盖
+// 不同进程之间（例如 schedule 和手动执行的 upload --retry）通过队列文件旁的 .lock 文件加锁
+var locks sync.Map
+
+// claimSeq 同一进程中每次认领使用不同的标识
+var claimSeq atomic.Int64
+
+// Backoff 返回第 attempts 次失败后的重试间隔：从 BaseDelay 开始每次翻倍，不超过 MaxDelay
+func Backoff(attempts int) time.Duration {
+	delay := BaseDelay
+	for i := 1; i < attempts && delay < MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > MaxDelay {
+		delay = MaxDelay
+	}
+	return delay
+}
+
+// Open 打开目录中的上传队列，目录不存在时创建
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建上传队列目录失败: %w", err)
+	}
+	path := filepath.Join(dir, FileName)
+	mu, _ := locks.LoadOrStore(path, &sync.Mutex{})
+	return &Queue{path: path, mu: mu.(*sync.Mutex)}, nil
+}
+
+// List 返回队列中的所有快照，按加入队列的时间排序
+func (q *Queue) List() ([]Item, error) {
+	var items []Item
+	err := q.locked(func() error {
+		var err error
+		items, err = q.load()
+		return err
+	})
+	return items, err
+}
+
+// Add 将上传失败的快照加入队列，ID 相同的队列项被替换
+// item.Attempts 为已失败的次数，下次重试时间按 Backoff 计算
+func (q *Queue) Add(item Item, now time.Time) error {
+	if item.QueuedAt.IsZero() {
+		item.QueuedAt = now
+	}
+	item.NextAttempt = now.Add(Backoff(item.Attempts))
+	return q.modify(func(items []Item) []Item {
+		for i := range items {
+			if items[i].ID == item.ID {
+				items[i] = item
+				return items
+			}
+		}
+		return append(items, item)
+	})
+}
+
+// Remove 从队列中移除快照
+func (q *Queue) Remove(id string) error {
+	return q.modify(func(items []Item) []Item {
+		kept := items[:0]
+		for _, item := range items {
+			if item.ID != id {
+				kept = append(kept, item)
+			}
+		}
+		return kept
+	})
+}
+
+// Process 重试到期的队列项，all 为 true 时不考虑退避时间重试所有队列项
+// 上传成功或文件已不存在的队列项从队列中移除，失败的队列项更新下次重试时间。
+// 上传前先认领队列项，已被其他进程认领的队列项跳过，同一快照不会被多个进程同时上传
+func (q *Queue) Process(now time.Time, all bool, upload UploadFunc) ([]Result, error) {
+	items, err := q.claim(now, all)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for i, item := range items {
+		item.ClaimedBy, item.ClaimedAt = "", nil
+		result := Result{Item: item}
+		if err := checkFiles(item.Files); err != nil {
+			result.Err = err
+			result.Dropped = true
+			err = q.Remove(item.ID)
+			results = append(results, result)
+			if err != nil {
+				q.release(items[i+1:])
+				return results, err
+			}
+			continue
+		}
+
+		// 上传期间不持有锁，其他快照可以同时加入队列
+		result.URLs, result.Err = upload(item)
+		if result.Err == nil {
+			err = q.Remove(item.ID)
+		} else {
+			result.Item.Attempts++
+			result.Item.LastError = result.Err.Error()
+			err = q.Add(result.Item, now)
+			result.Item.NextAttempt = now.Add(Backoff(result.Item.Attempts))
+		}
+		results = append(results, result)
+		if err != nil {
+			q.release(items[i+1:])
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// claim 认领到期且未被认领的队列项，返回认领后的队列项
+func (q *Queue) claim(now time.Time, all bool) ([]Item, error) {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s/%d/%d", host, os.Getpid(), claimSeq.Add(1))
+	var claimed []Item
+	err := q.modify(func(items []Item) []Item {
+		for i := range items {
+			if (!all && now.Before(items[i].NextAttempt)) || items[i].claimed(now) {
+				continue
+			}
+			claimedAt := now
+			items[i].ClaimedBy, items[i].ClaimedAt = owner, &claimedAt
+			claimed = append(claimed, items[i])
+		}
+		return items
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// release 取消未处理的队列项的认领，队列项保持原来的重试时间
+func (q *Queue) release(claimed []Item) {
+	if len(claimed) == 0 {
+		return
+	}
+	owners := make(map[string]string)
+	for _, item := range claimed {
+		owners[item.ID] = item.ClaimedBy
+	}
+	q.modify(func(items []Item) []Item {
+		for i := range items {
+			if owner, ok := owners[items[i].ID]; ok && items[i].ClaimedBy == owner {
+				items[i].ClaimedBy, items[i].ClaimedAt = "", nil
+			}
+		}
+		return items
+	})
+}
+
+// checkFiles 检查队列项的文件是否都存在
+func checkFiles(files []string) error {
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("快照文件已不存在: %w", err)
+		}
+	}
+	return nil
+}
+
+// modify 读取队列，由 fn 修改后写回
+func (q *Queue) modify(fn func([]Item) []Item) error {
+	return q.locked(func() error {
+		items, err := q.load()
+		if err != nil {
+			return err
+		}
+		return q.save(fn(items))
+	})
+}
+
+// locked 在持有进程内和跨进程的锁时执行 fn，其他进程的读取-修改-写入不会覆盖本次修改
+func (q *Queue) locked(fn func() error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	file, err := os.OpenFile(q.path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("打开上传队列锁文件失败: %w", err)
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return fmt.Errorf("锁定上传队列失败: %w", err)
+	}
+	defer unlockFile(file)
+	return fn()
+}
+
+// load 读取队列文件，文件不存在时返回空队列
+func (q *Queue) load() ([]Item, error) {
+	data, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return []Item{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取上传队列失败: %w", err)
+	}
+	var items []Item
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("解析上传队列 %s 失败: %w", q.path, err)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].QueuedAt.Before(items[j].QueuedAt)
+	})
+	if items == nil {
+		items = []Item{}
+	}
+	return items, nil
+}
+
+// save 写入队列文件，先写临时文件再改名，避免中断时损坏队列
+func (q *Queue) save(items []Item) error {
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化上传队列失败: %w", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(q.path), FileName+".*")
+	if err != nil {
+		return fmt.Errorf("写入上传队列失败: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("写入上传队列失败: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("写入上传队列失败: %w", err)
+	}
+	if err := os.Rename(temp.Name(), q.path); err != nil {
+		return fmt.Errorf("写入上传队列失败: %w", err)
+	}
+	return nil
+}
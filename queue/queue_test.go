@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, Backoff(0))
+	assert.Equal(t, time.Minute, Backoff(1))
+	assert.Equal(t, 2*time.Minute, Backoff(2))
+	assert.Equal(t, 16*time.Minute, Backoff(5))
+	assert.Equal(t, MaxDelay, Backoff(20))
+	assert.Equal(t, MaxDelay, Backoff(1000))
+}
+
+func TestProcess(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	require.NoError(t, err)
+
+	writeSnapshot := func(name string) string {
+		path := filepath.Join(dir, name+".zip")
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+		return path
+	}
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	require.NoError(t, q.Add(Item{ID: "first", Files: []string{writeSnapshot("first")}, Attempts: 1}, now))
+	require.NoError(t, q.Add(Item{ID: "second", Files: []string{writeSnapshot("second")}, Attempts: 1}, now.Add(time.Second)))
+	require.NoError(t, q.Add(Item{ID: "gone", Files: []string{filepath.Join(dir, "gone.zip")}, Attempts: 3}, now.Add(2*time.Second)))
+
+	items, err := q.List()
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+	assert.Equal(t, "first", items[0].ID)
+	assert.True(t, now.Add(time.Minute).Equal(items[0].NextAttempt))
+
+	// 未到重试时间时不重试
+	uploaded := 0
+	results, err := q.Process(now.Add(30*time.Second), false, func(Item) ([]string, error) {
+		uploaded++
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Zero(t, uploaded)
+
+	// 第一个上传成功，第二个上传失败，第三个文件已不存在
+	later := now.Add(10 * time.Minute)
+	results, err = q.Process(later, false, func(item Item) ([]string, error) {
+		if item.ID == "second" {
+			return nil, errors.New("network is unreachable")
+		}
+		return []string{"https://example.com/" + item.ID}, nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, []string{"https://example.com/first"}, results[0].URLs)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, 2, results[1].Item.Attempts)
+	assert.Equal(t, later.Add(2*time.Minute), results[1].Item.NextAttempt)
+	assert.True(t, results[2].Dropped)
+
+	// 队列保存在文件中，重新打开后仍然存在
+	reopened, err := Open(dir)
+	require.NoError(t, err)
+	items, err = reopened.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "second", items[0].ID)
+	assert.Equal(t, 2, items[0].Attempts)
+	assert.Equal(t, "network is unreachable", items[0].LastError)
+	assert.True(t, now.Add(time.Second).Equal(items[0].QueuedAt))
+
+	// all 为 true 时不等待重试间隔
+	results, err = reopened.Process(later, true, func(item Item) ([]string, error) {
+		return []string{"https://example.com/" + item.ID}, nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].Err)
+	items, err = reopened.List()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+// otherProcess 返回不共享进程内锁的队列，模拟另一个进程打开同一个队列
+func otherProcess(q *Queue) *Queue {
+	return &Queue{path: q.path, mu: &sync.Mutex{}}
+}
+
+func TestConcurrentProcesses(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	require.NoError(t, err)
+	other := otherProcess(q)
+
+	// 两个进程同时加入队列项，锁文件保证不会相互覆盖
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, queue := range []*Queue{q, other} {
+			wg.Add(1)
+			go func(queue *Queue, id string) {
+				defer wg.Done()
+				assert.NoError(t, queue.Add(Item{ID: id}, now))
+			}(queue, fmt.Sprintf("%p-%d", queue, i))
+		}
+	}
+	wg.Wait()
+	items, err := q.List()
+	require.NoError(t, err)
+	assert.Len(t, items, 40)
+}
+
+func TestProcessClaimsItems(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	require.NoError(t, err)
+	other := otherProcess(q)
+
+	path := filepath.Join(dir, "snap.zip")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	require.NoError(t, q.Add(Item{ID: "snap", Files: []string{path}}, now))
+
+	// 上传期间另一个进程重试时跳过已认领的队列项
+	uploads := 0
+	results, err := q.Process(now, true, func(item Item) ([]string, error) {
+		uploads++
+		assert.Empty(t, item.ClaimedBy)
+		items, err := other.List()
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.NotEmpty(t, items[0].ClaimedBy)
+
+		results, err := other.Process(now, true, func(Item) ([]string, error) {
+			uploads++
+			return nil, nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, results)
+		return nil, errors.New("network is unreachable")
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, uploads)
+
+	// 上传失败后取消认领，可以再次重试
+	items, err := q.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Empty(t, items[0].ClaimedBy)
+	assert.Nil(t, items[0].ClaimedAt)
+
+	// 认领超时的队列项可以被其他进程重新认领
+	_, err = q.claim(now, true)
+	require.NoError(t, err)
+	results, err = other.Process(now.Add(time.Hour), true, func(Item) ([]string, error) { return nil, nil })
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	results, err = other.Process(now.Add(ClaimTimeout), true, func(Item) ([]string, error) { return nil, nil })
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].Err)
+	items, err = q.List()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
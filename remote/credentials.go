@@ -0,0 +1,260 @@
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 凭证引用的前缀，配置中的 access_key、secret_key、password、passphrase 可以使用引用代替明文，在上传时解析
+const (
+	credentialEnv     = "env:"     // env:NAME 读取环境变量
+	credentialFile    = "file:"    // file:/path 读取文件内容，文件只允许所有者读写
+	credentialKeyring = "keyring:" // keyring:service/account 从 Secret Service 密钥环读取
+)
+
+const (
+	maskedSecret    = "******" // 日志中代替凭证的文本
+	minSecretLength = 4        // 短于该长度的凭证不屏蔽，避免屏蔽日志中的普通字符
+)
+
+// keyringLookup 从密钥环读取凭证，测试时替换
+var keyringLookup = secretToolLookup
+
+// ResolveCredential 解析凭证引用，不是引用的值原样返回
+// 解析得到的凭证和明文凭证都会在之后的日志输出中屏蔽
+func ResolveCredential(value string) (string, error) {
+	var secret string
+	var err error
+	switch {
+	case strings.HasPrefix(value, credentialEnv):
+		secret, err = envCredential(strings.TrimPrefix(value, credentialEnv))
+	case strings.HasPrefix(value, credentialFile):
+		secret, err = fileCredential(strings.TrimPrefix(value, credentialFile))
+	case strings.HasPrefix(value, credentialKeyring):
+		secret, err = keyringCredential(strings.TrimPrefix(value, credentialKeyring))
+	default:
+		secret = value
+	}
+	if err != nil {
+		return "", err
+	}
+	RegisterSecret(secret)
+	return secret, nil
+}
+
+// IsCredentialReference 返回值是否为凭证引用
+func IsCredentialReference(value string) bool {
+	return strings.HasPrefix(value, credentialEnv) ||
+		strings.HasPrefix(value, credentialFile) ||
+		strings.HasPrefix(value, credentialKeyring)
+}
+
+// WithResolvedCredentials 返回解析了凭证引用的提供商配置副本，原配置中仍只保存引用
+func (p UploadConfigProvider) WithResolvedCredentials() (UploadConfigProvider, error) {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"access_key", &p.AccessKey},
+		{"secret_key", &p.SecretKey},
+		{"password", &p.Password},
+		{"passphrase", &p.Passphrase},
+	}
+	for _, field := range fields {
+		secret, err := ResolveCredential(*field.value)
+		if err != nil {
+			return p, fmt.Errorf("读取提供商 %s 的 %s 失败: %w", p.DisplayName(), field.name, err)
+		}
+		*field.value = secret
+	}
+	return p, nil
+}
+
+// registerSecrets 屏蔽上传配置中的明文凭证，引用在解析时屏蔽
+func (u *UploadConfig) registerSecrets() {
+	for _, p := range u.Providers {
+		for _, value := range []string{p.AccessKey, p.SecretKey, p.Password, p.Passphrase} {
+			if !IsCredentialReference(value) {
+				RegisterSecret(value)
+			}
+		}
+	}
+}
+
+func envCredential(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("凭证引用 env: 缺少环境变量名")
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("环境变量 %s 未设置", name)
+	}
+	return value, nil
+}
+
+// fileCredential 读取凭证文件，去掉末尾的换行；文件允许组或其他用户访问时拒绝读取（Windows 不检查）
+func fileCredential(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("凭证引用 file: 缺少文件路径")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("读取凭证文件失败: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("凭证文件 %s 的权限 %04o 过宽，只允许所有者读写 (chmod 600)", path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取凭证文件失败: %w", err)
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("凭证文件 %s 为空", path)
+	}
+	return secret, nil
+}
+
+// keyringCredential 解析 keyring:service/account，account 为空时只按 service 查找
+func keyringCredential(ref string) (string, error) {
+	service, account, _ := strings.Cut(ref, "/")
+	if service == "" {
+		return "", errors.New("凭证引用 keyring: 缺少服务名，格式为 keyring:service/account")
+	}
+	secret, err := keyringLookup(service, account)
+	if err != nil {
+		return "", fmt.Errorf("从密钥环读取 %s 失败: %w", ref, err)
+	}
+	if secret == "" {
+		return "", fmt.Errorf("密钥环中没有 %s", ref)
+	}
+	return secret, nil
+}
+
+// secretToolLookup 通过 secret-tool 从 Secret Service（GNOME Keyring、KWallet 等）读取凭证
+// 凭证可以通过 secret-tool store --label=logsnap service <service> account <account> 保存
+func secretToolLookup(service, account string) (string, error) {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return "", errors.New("未找到 secret-tool，请安装 libsecret-tools")
+	}
+	args := []string{"lookup", "service", service}
+	if account != "" {
+		args = append(args, "account", account)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// secretMasker 在日志输出前把已注册的凭证替换为 maskedSecret
+type secretMasker struct {
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}
+
+var (
+	masker         = &secretMasker{secrets: make(map[string]bool)}
+	maskerHookOnce sync.Once
+)
+
+// RegisterSecret 注册需要在日志中屏蔽的凭证，第一次注册时为 logrus 添加屏蔽钩子
+func RegisterSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	maskerHookOnce.Do(func() { logrus.AddHook(masker) })
+	masker.add(secret)
+}
+
+// MaskSecrets 返回屏蔽了已注册凭证的文本
+func MaskSecrets(text string) string {
+	return masker.mask(text)
+}
+
+// MaskError 返回错误信息中屏蔽了已注册凭证的错误，errors.Is、errors.As 仍能匹配原错误
+// 用于会写入文件或直接输出到终端、不经过日志屏蔽的错误
+func MaskError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &maskedError{err: err}
+}
+
+type maskedError struct {
+	err error
+}
+
+func (e *maskedError) Error() string {
+	return MaskSecrets(e.err.Error())
+}
+
+func (e *maskedError) Unwrap() error {
+	return e.err
+}
+
+func (m *secretMasker) add(secret string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.secrets[secret] {
+		return
+	}
+	m.secrets[secret] = true
+
+	// 较长的凭证优先替换，避免凭证中包含另一个凭证时只屏蔽一部分
+	secrets := make([]string, 0, len(m.secrets))
+	for s := range m.secrets {
+		secrets = append(secrets, s)
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	pairs := make([]string, 0, len(secrets)*2)
+	for _, s := range secrets {
+		pairs = append(pairs, s, maskedSecret)
+	}
+	m.replacer = strings.NewReplacer(pairs...)
+}
+
+func (m *secretMasker) mask(text string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.replacer == nil {
+		return text
+	}
+	return m.replacer.Replace(text)
+}
+
+// Levels 对所有级别的日志生效
+func (m *secretMasker) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 屏蔽日志消息和字段中的凭证
+func (m *secretMasker) Fire(entry *logrus.Entry) error {
+	entry.Message = m.mask(entry.Message)
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = m.mask(v)
+		case error:
+			entry.Data[key] = m.mask(v.Error())
+		case fmt.Stringer:
+			entry.Data[key] = m.mask(v.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveCredential(t *testing.T) {
+	// 不是引用的值原样返回
+	secret, err := ResolveCredential("plain-password")
+	require.NoError(t, err)
+	assert.Equal(t, "plain-password", secret)
+
+	t.Setenv("LOGSNAP_TEST_PASSWORD", "from-env")
+	secret, err = ResolveCredential("env:LOGSNAP_TEST_PASSWORD")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", secret)
+	_, err = ResolveCredential("env:LOGSNAP_TEST_MISSING")
+	assert.ErrorContains(t, err, "LOGSNAP_TEST_MISSING")
+
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+	secret, err = ResolveCredential("file:" + path)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", secret)
+	_, err = ResolveCredential("file:" + filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+	if runtime.GOOS != "windows" {
+		// 其他用户可以读取的凭证文件
+		require.NoError(t, os.Chmod(path, 0644))
+		_, err = ResolveCredential("file:" + path)
+		assert.ErrorContains(t, err, "权限")
+	}
+
+	lookup := keyringLookup
+	t.Cleanup(func() { keyringLookup = lookup })
+	keyringLookup = func(service, account string) (string, error) {
+		if service == "logsnap" && account == "cloudreve" {
+			return "from-keyring", nil
+		}
+		return "", errors.New("No such secret")
+	}
+	secret, err = ResolveCredential("keyring:logsnap/cloudreve")
+	require.NoError(t, err)
+	assert.Equal(t, "from-keyring", secret)
+	_, err = ResolveCredential("keyring:logsnap/s3")
+	assert.ErrorContains(t, err, "logsnap/s3")
+	_, err = ResolveCredential("keyring:")
+	assert.Error(t, err)
+}
+
+func TestWithResolvedCredentials(t *testing.T) {
+	t.Setenv("LOGSNAP_TEST_SECRET_KEY", "secret-key")
+	provider := UploadConfigProvider{
+		Name:      "cloud",
+		Provider:  "s3",
+		AccessKey: "access-key",
+		SecretKey: "env:LOGSNAP_TEST_SECRET_KEY",
+	}
+	resolved, err := provider.WithResolvedCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "access-key", resolved.AccessKey)
+	assert.Equal(t, "secret-key", resolved.SecretKey)
+	// 原配置中仍只保存引用
+	assert.Equal(t, "env:LOGSNAP_TEST_SECRET_KEY", provider.SecretKey)
+
+	provider.Password = "env:LOGSNAP_TEST_MISSING"
+	_, err = provider.WithResolvedCredentials()
+	assert.ErrorContains(t, err, "cloud 的 password")
+}
+
+func TestSecretsMaskedInLogs(t *testing.T) {
+	var buf bytes.Buffer
+	output := logrus.StandardLogger().Out
+	logrus.SetOutput(&buf)
+	t.Cleanup(func() { logrus.SetOutput(output) })
+
+	t.Setenv("LOGSNAP_TEST_TOKEN", "tok3n-from-env")
+	_, err := ResolveCredential("env:LOGSNAP_TEST_TOKEN")
+	require.NoError(t, err)
+	(&UploadConfig{Providers: []UploadConfigProvider{{Password: "Plain-Passw0rd", SecretKey: "env:LOGSNAP_TEST_TOKEN"}}}).registerSecrets()
+	RegisterSecret("abc")
+
+	logrus.Infof("登录 admin:Plain-Passw0rd@example.com，令牌 tok3n-from-env，abc")
+	logrus.WithError(errors.New("401 for tok3n-from-env")).WithField("password", "Plain-Passw0rd").Warn("失败")
+
+	logged := buf.String()
+	assert.NotContains(t, logged, "Plain-Passw0rd")
+	assert.NotContains(t, logged, "tok3n-from-env")
+	assert.Contains(t, logged, "admin:******@example.com")
+	// 过短的凭证不屏蔽
+	assert.Contains(t, logged, "abc")
+	assert.Equal(t, "令牌 ******", MaskSecrets("令牌 tok3n-from-env"))
+
+	// 屏蔽后的错误仍能匹配原错误
+	err = MaskError(fmt.Errorf("上传失败: %w", os.ErrPermission))
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.NoError(t, MaskError(nil))
+	assert.Equal(t, "401 for ******", MaskError(errors.New("401 for tok3n-from-env")).Error())
+}
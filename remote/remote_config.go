@@ -28,30 +28,57 @@ type DownloadConfig struct {
 type UploadConfig struct {
 	Providers       []UploadConfigProvider `json:"providers"`
 	DefaultProvider string                 `json:"default_provider"`
+	Fallback        []string               `json:"fallback,omitempty"`   // 默认提供商上传失败时依次尝试的提供商
+	FanOut          []string               `json:"fan_out,omitempty"`    // 与默认提供商同时上传的其他提供商，任一目标上传成功即视为成功
+	EncryptTo       []string               `json:"encrypt_to,omitempty"` // 快照接收者公钥，配置后该站点的快照均加密
 }
 
 type UploadConfigProvider struct {
-	Provider   string `json:"provider"`    // 提供商: s3, local, webdav
+	Name string `json:"name,omitempty"` // 提供商名称，供 default_provider、fallback、fan_out 引用，为空时使用 provider
+
+	Provider   string `json:"provider"`    // 提供商: s3, local, webdav, sftp, http, cloudreve
 	Endpoint   string `json:"endpoint"`    // 服务端点
 	Bucket     string `json:"bucket"`      // 存储桶名称
-	AccessKey  string `json:"access_key"`  // 访问密钥
-	SecretKey  string `json:"secret_key"`  // 访问密钥
+	AccessKey  string `json:"access_key"`  // 访问密钥，可以使用 env:、file:、keyring: 凭证引用
+	SecretKey  string `json:"secret_key"`  // 访问密钥，可以使用凭证引用
 	Region     string `json:"region"`      // 区域 (S3适用)
 	FolderPath string `json:"folder_path"` // 上传目录路径
 	Username   string `json:"username"`    // 用户名 (WebDAV适用)
-	Password   string `json:"password"`    // 密码 (WebDAV适用)
+	Password   string `json:"password"`    // 密码，可以使用凭证引用 (WebDAV适用)
+
+	PathStyle   bool   `json:"path_style,omitempty"`   // 使用路径形式 endpoint/bucket/key 访问存储桶，MinIO、Ceph 等需要开启 (S3适用)
+	ShareExpiry string `json:"share_expiry,omitempty"` // 分享链接的有效期，例如 24h、7d，默认 7d；S3 预签名URL最长 7d，Cloudreve 为 0 时永久有效 (S3、Cloudreve适用)
+
+	ShareDownloads int    `json:"share_downloads,omitempty"` // 分享链接允许下载的次数，0 表示不限制 (Cloudreve适用)
+	SharePassword  string `json:"share_password,omitempty"`  // 分享链接的提取码，为空时不需要 (Cloudreve适用)
+	SharePreview   bool   `json:"share_preview,omitempty"`   // 分享链接是否允许预览 (Cloudreve适用)
+	APIVersion     string `json:"api_version,omitempty"`     // 服务器的 API 版本 v3 或 v4，为空时自动检测 (Cloudreve适用)
+
+	PrivateKey string `json:"private_key,omitempty"` // 私钥文件路径或 PEM 格式的私钥内容 (SFTP适用)
+	Passphrase string `json:"passphrase,omitempty"`  // 私钥的密码，可以使用凭证引用 (SFTP适用)
+	KnownHosts string `json:"known_hosts,omitempty"` // known_hosts 文件路径，默认 ~/.ssh/known_hosts (SFTP适用)
+	HostKey    string `json:"host_key,omitempty"`    // 服务器公钥（authorized_keys 格式）或其 SHA256 指纹，配置后不再读取 known_hosts (SFTP适用)
+
+	Method     string            `json:"method,omitempty"`      // 上传请求的方法，默认 POST (http适用)
+	FieldName  string            `json:"field_name,omitempty"`  // 文件的表单字段名，默认 file (http适用)
+	FormFields map[string]string `json:"form_fields,omitempty"` // 额外的表单字段，值为模板，例如 {"host": "{{.Host}}"}，为空时提交 host、window、description、tags (http适用)
+	Headers    map[string]string `json:"headers,omitempty"`     // 请求头，值为模板，例如 {"Authorization": "Bearer {{.Password}}"} (http适用)
+	URLPath    string            `json:"url_path,omitempty"`    // 响应 JSON 中下载链接的 JSONPath，例如 $.data.url，默认 $.url (http适用)
+
+	BaseURL string `json:"base_url,omitempty"` // 返回链接的前缀，例如 \\nas\logs 或 https://nas.example.com/logs，为空时返回 file:// 链接 (local适用)
 }
 
 func (u *UploadConfig) GetDefaultProvider() *UploadConfigProvider {
-	for _, provider := range u.Providers {
-		if provider.Provider == u.DefaultProvider {
-			return &provider
-		}
-	}
-	return nil
+	return u.GetProvider(u.DefaultProvider)
 }
 
+// GetProvider 按名称查找提供商，没有同名的提供商时返回第一个该类型的提供商
 func (u *UploadConfig) GetProvider(provider string) *UploadConfigProvider {
+	for _, p := range u.Providers {
+		if p.Name != "" && p.Name == provider {
+			return &p
+		}
+	}
 	for _, p := range u.Providers {
 		if p.Provider == provider {
 			return &p
@@ -60,6 +87,14 @@ func (u *UploadConfig) GetProvider(provider string) *UploadConfigProvider {
 	return nil
 }
 
+// DisplayName 返回提供商的名称，未配置 name 时为提供商类型
+func (p *UploadConfigProvider) DisplayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Provider
+}
+
 // DownloadURL 下载URL信息
 type DownloadURL struct {
 	Windows string `json:"windows"`
@@ -152,6 +187,9 @@ func (cm *ConfigManager) FetchUploadConfig() (*UploadConfig, error) {
 		return nil, fmt.Errorf("解析上传配置失败: %w", err)
 	}
 
+	// 配置中的明文凭证不出现在日志中
+	uploadConfig.registerSecrets()
+
 	// 更新本地配置的最后检查时间
 	cm.updateLastCheckTime()
 
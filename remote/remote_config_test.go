@@ -1,6 +1,7 @@
 package remote
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -114,6 +115,40 @@ func TestUploadConfigGetProvider(t *testing.T) {
 	assert.Nil(t, provider, "不存在的提供商应该返回nil")
 }
 
+// 测试按名称查找提供商
+func TestUploadConfigGetProviderByName(t *testing.T) {
+	uploadConfig := &UploadConfig{
+		Providers: []UploadConfigProvider{
+			{Name: "customer-nas", Provider: "webdav", Endpoint: "https://nas.example.com"},
+			{Provider: "webdav", Endpoint: "https://webdav.example.com"},
+			{Name: "webdav", Provider: "sftp", Endpoint: "sftp.example.com"},
+		},
+		DefaultProvider: "customer-nas",
+	}
+
+	provider := uploadConfig.GetDefaultProvider()
+	assert.NotNil(t, provider)
+	assert.Equal(t, "https://nas.example.com", provider.Endpoint)
+	assert.Equal(t, "customer-nas", provider.DisplayName())
+
+	// 名称优先于提供商类型
+	provider = uploadConfig.GetProvider("webdav")
+	assert.NotNil(t, provider)
+	assert.Equal(t, "sftp", provider.Provider)
+}
+
+// 测试未设置的可选字段不写入配置
+func TestUploadConfigOmitsEmptyOptionalFields(t *testing.T) {
+	data, err := json.Marshal(UploadConfig{DefaultProvider: "s3"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "encrypt_to")
+	assert.NotContains(t, string(data), "fallback")
+
+	data, err = json.Marshal(UploadConfig{EncryptTo: []string{"age1example"}})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"encrypt_to":["age1example"]`)
+}
+
 // 测试ConfigManager的基本功能
 func TestConfigManagerBasic(t *testing.T) {
 	// 跳过此测试，需要更多的模拟工作
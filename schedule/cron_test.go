@@ -0,0 +1,48 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 17, 30, 0, time.Local) // 星期五
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 * * * *", time.Date(2024, 3, 1, 11, 0, 0, 0, time.Local)},
+		{"@hourly", time.Date(2024, 3, 1, 11, 0, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2024, 3, 1, 10, 30, 0, 0, time.Local)},
+		{"30 2 * * *", time.Date(2024, 3, 2, 2, 30, 0, 0, time.Local)},
+		{"0 9-17/4 * * *", time.Date(2024, 3, 1, 13, 0, 0, 0, time.Local)},
+		{"0 0 * * 1", time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.Local)},
+		{"0 0 1,15 * *", time.Date(2024, 3, 15, 0, 0, 0, 0, time.Local)},
+		// 日和星期都有限制时满足其一即可
+		{"0 0 15 * 6", time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)},
+	}
+
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q) 错误: %v", tt.expr, err)
+			continue
+		}
+		if got := cron.Next(base); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next() = %v, 期望 %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) 应返回错误", expr)
+		}
+	}
+
+	cron, _ := ParseCron("0 0 30 2 *")
+	if got := cron.Next(base); !got.IsZero() {
+		t.Errorf("永远无法满足的表达式应返回零值，实际为 %v", got)
+	}
+}
@@ -0,0 +1,268 @@
+// Package schedule 按 cron 表达式定期收集快照，并按保留策略清理本地快照
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"logsnap/queue"
+	"logsnap/snapshot"
+	"logsnap/store"
+	"logsnap/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Schedule 一个定期收集计划
+type Schedule struct {
+	Name              string    `json:"name"`                          // 计划名称，默认输出目录以此命名
+	Cron              string    `json:"cron"`                          // cron 表达式，例如 "0 * * * *" 或 "@daily"
+	Range             string    `json:"range"`                         // 每次收集最近多长时间的日志，例如 1h, 1d
+	Programs          []string  `json:"programs,omitempty"`            // 要收集的程序，为空表示全部
+	Level             string    `json:"level,omitempty"`               // 只收集不低于该级别的日志
+	Grep              []string  `json:"grep,omitempty"`                // 只收集内容匹配正则表达式的日志
+	Timeline          bool      `json:"timeline,omitempty"`            // 是否生成 timeline.log
+	MaxSize           string    `json:"max_size,omitempty"`            // 快照的最大大小，例如 100M
+	Upload            bool      `json:"upload,omitempty"`              // 是否上传
+	KeepLocalSnapshot bool      `json:"keep_local_snapshot,omitempty"` // 上传后是否保留本地快照
+	OutputDir         string    `json:"output_dir,omitempty"`          // 输出目录，为空时使用默认快照目录下以计划名称命名的子目录
+	Retention         Retention `json:"retention,omitempty"`           // 输出目录中本地快照的保留策略
+}
+
+// Retention 配置文件中的保留策略
+type Retention struct {
+	KeepLast     int    `json:"keep_last,omitempty"`      // 最多保留的快照数量
+	MaxAge       string `json:"max_age,omitempty"`        // 快照的最长保留时间，例如 7d
+	MaxTotalSize string `json:"max_total_size,omitempty"` // 所有快照的最大总大小，例如 2G
+}
+
+// Config 定期收集配置
+type Config struct {
+	Schedules []Schedule `json:"schedules"`
+}
+
+// LoadConfig 从 JSON 文件加载定期收集配置
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取定期收集配置失败: %w", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("解析定期收集配置 %s 失败: %w", path, err)
+	}
+	if len(config.Schedules) == 0 {
+		return nil, fmt.Errorf("定期收集配置 %s 中没有任何计划", path)
+	}
+	return &config, nil
+}
+
+// Job 一个已校验的计划
+type Job struct {
+	Name      string
+	Schedule  Schedule           // 配置文件中的原始计划
+	Cron      *Cron              // 解析后的 cron 表达式
+	Range     time.Duration      // 每次收集的时长
+	MaxSize   int64              // 快照的最大字节数，0 表示不限制
+	Retention snapshot.Retention // 本地快照的保留策略
+	OutputDir string             // 输出目录
+}
+
+// compile 校验计划并解析其中的表达式、时长和大小，outputRoot 为默认快照目录
+func compile(s Schedule, outputRoot string) (*Job, error) {
+	if s.Name == "" {
+		return nil, fmt.Errorf("计划缺少名称")
+	}
+	job := &Job{Name: s.Name, Schedule: s, OutputDir: s.OutputDir}
+	var err error
+	if job.Cron, err = ParseCron(s.Cron); err != nil {
+		return nil, fmt.Errorf("计划 %s: %w", s.Name, err)
+	}
+	if job.Range, err = utils.ParseDuration(s.Range); err != nil {
+		return nil, fmt.Errorf("计划 %s: %w", s.Name, err)
+	}
+	if job.Range <= 0 {
+		return nil, fmt.Errorf("计划 %s 缺少收集范围 range", s.Name)
+	}
+	if job.MaxSize, err = utils.ParseSize(s.MaxSize); err != nil {
+		return nil, fmt.Errorf("计划 %s: %w", s.Name, err)
+	}
+	job.Retention.KeepLast = s.Retention.KeepLast
+	if job.Retention.MaxAge, err = utils.ParseDuration(s.Retention.MaxAge); err != nil {
+		return nil, fmt.Errorf("计划 %s 的保留时间: %w", s.Name, err)
+	}
+	if job.Retention.MaxTotalSize, err = utils.ParseSize(s.Retention.MaxTotalSize); err != nil {
+		return nil, fmt.Errorf("计划 %s 的保留大小: %w", s.Name, err)
+	}
+	if job.OutputDir == "" {
+		job.OutputDir = filepath.Join(outputRoot, s.Name)
+	}
+	return job, nil
+}
+
+// RunFunc 收集一次快照，start 和 end 为收集的时间范围，由调用方实现（通常调用 service.CollectAndUploadLogs）
+type RunFunc func(ctx context.Context, job *Job, start, end time.Time) error
+
+// Scheduler 按计划定期收集快照
+type Scheduler struct {
+	jobs      []*Job
+	run       RunFunc
+	configDir string // 上传队列所在目录，为空时不检查上传队列
+	storeDir  string // 快照记录所在目录，为空时不更新记录
+}
+
+// New 创建调度器，outputRoot 为未指定输出目录的计划使用的默认快照目录
+func New(config *Config, outputRoot string, run RunFunc) (*Scheduler, error) {
+	s := &Scheduler{run: run}
+	names := make(map[string]bool)
+	for _, schedule := range config.Schedules {
+		job, err := compile(schedule, outputRoot)
+		if err != nil {
+			return nil, err
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("计划名称重复: %s", job.Name)
+		}
+		names[job.Name] = true
+		s.jobs = append(s.jobs, job)
+	}
+	return s, nil
+}
+
+// SetStateDirs 设置上传队列和快照记录所在的目录
+// 清理本地快照时跳过仍在上传队列中的快照，并在快照记录中标记被删除的快照
+func (s *Scheduler) SetStateDirs(configDir, storeDir string) {
+	s.configDir = configDir
+	s.storeDir = storeDir
+}
+
+// Jobs 返回所有计划
+func (s *Scheduler) Jobs() []*Job {
+	return s.jobs
+}
+
+// Run 按计划执行收集，直到 ctx 结束
+// 同一时刻到期的计划依次执行；执行期间错过的时间点不补执行，从执行结束后的下一个时间点继续
+func (s *Scheduler) Run(ctx context.Context) error {
+	next := make(map[*Job]time.Time)
+	now := time.Now()
+	for _, job := range s.jobs {
+		next[job] = job.Cron.Next(now)
+		logrus.Infof("计划 %s 下次执行时间: %s", job.Name, next[job].Format("2006-01-02 15:04:05"))
+	}
+
+	for {
+		due := s.earliest(next)
+		if due.IsZero() {
+			return fmt.Errorf("所有计划都没有下次执行时间")
+		}
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		for _, job := range s.jobs {
+			if next[job].After(due) {
+				continue
+			}
+			s.Execute(ctx, job, next[job])
+		}
+		now = time.Now()
+		for _, job := range s.jobs {
+			if !next[job].After(due) {
+				next[job] = job.Cron.Next(now)
+			}
+		}
+	}
+}
+
+// earliest 返回最早的下次执行时间
+func (s *Scheduler) earliest(next map[*Job]time.Time) time.Time {
+	var earliest time.Time
+	for _, t := range next {
+		if !t.IsZero() && (earliest.IsZero() || t.Before(earliest)) {
+			earliest = t
+		}
+	}
+	return earliest
+}
+
+// Execute 执行一次计划：收集 at 之前 Range 时长的日志，然后按保留策略清理输出目录
+// 收集失败时仍然执行清理，错误只记录日志
+func (s *Scheduler) Execute(ctx context.Context, job *Job, at time.Time) {
+	logrus.Infof("执行计划 %s: 收集 %s 到 %s 的日志", job.Name,
+		at.Add(-job.Range).Format("2006-01-02 15:04:05"), at.Format("2006-01-02 15:04:05"))
+	if err := os.MkdirAll(job.OutputDir, 0755); err != nil {
+		logrus.Errorf("计划 %s 创建输出目录失败: %v", job.Name, err)
+		return
+	}
+	if err := s.run(ctx, job, at.Add(-job.Range), at); err != nil {
+		logrus.Errorf("计划 %s 收集失败: %v", job.Name, err)
+	}
+
+	if !job.Retention.Active() {
+		return
+	}
+	pending, err := s.pendingUploads()
+	if err != nil {
+		// 无法确定哪些快照还未上传时不清理，避免删除等待重试的快照
+		logrus.Errorf("计划 %s 读取上传队列失败，跳过清理本地快照: %v", job.Name, err)
+		return
+	}
+	removed, err := snapshot.ApplyRetention(job.OutputDir, job.Retention, time.Now(), func(name string) bool {
+		return pending[name]
+	})
+	for _, snap := range removed {
+		logrus.Infof("计划 %s 按保留策略删除快照: %s", job.Name, snap.Name)
+	}
+	s.markDeleted(removed)
+	if err != nil {
+		logrus.Errorf("计划 %s 清理本地快照失败: %v", job.Name, err)
+	}
+}
+
+// pendingUploads 返回上传队列中的快照名称
+func (s *Scheduler) pendingUploads() (map[string]bool, error) {
+	pending := make(map[string]bool)
+	if s.configDir == "" {
+		return pending, nil
+	}
+	q, err := queue.Open(s.configDir)
+	if err != nil {
+		return nil, err
+	}
+	items, err := q.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		pending[item.ID] = true
+	}
+	return pending, nil
+}
+
+// markDeleted 在快照记录中标记被清理的快照，没有记录的快照忽略
+func (s *Scheduler) markDeleted(removed []snapshot.LocalSnapshot) {
+	if s.storeDir == "" || len(removed) == 0 {
+		return
+	}
+	st, err := store.Open(s.storeDir)
+	if err != nil {
+		logrus.Warnf("更新快照记录失败: %v", err)
+		return
+	}
+	for _, snap := range removed {
+		err := st.Update(snap.Name, func(entry *store.Entry) { entry.Deleted = true })
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			logrus.Warnf("更新快照 %s 的记录失败: %v", snap.Name, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package schedule
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"logsnap/queue"
+	"logsnap/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"schedules": [
+			{"name": "hourly", "cron": "0 * * * *", "range": "1h", "retention": {"keep_last": 24}},
+			{"name": "nightly", "cron": "@daily", "range": "1d", "level": "error", "output_dir": "/data/nightly",
+			 "retention": {"max_age": "30d", "max_total_size": "2G"}}
+		]
+	}`), 0644))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	scheduler, err := New(config, "/root/.logsnap/snapshots", nil)
+	require.NoError(t, err)
+	jobs := scheduler.Jobs()
+	require.Len(t, jobs, 2)
+
+	assert.Equal(t, time.Hour, jobs[0].Range)
+	assert.Equal(t, filepath.Join("/root/.logsnap/snapshots", "hourly"), jobs[0].OutputDir)
+	assert.Equal(t, 24, jobs[0].Retention.KeepLast)
+
+	assert.Equal(t, 24*time.Hour, jobs[1].Range)
+	assert.Equal(t, "/data/nightly", jobs[1].OutputDir)
+	assert.Equal(t, "error", jobs[1].Schedule.Level)
+	assert.Equal(t, 30*24*time.Hour, jobs[1].Retention.MaxAge)
+	assert.Equal(t, int64(2<<30), jobs[1].Retention.MaxTotalSize)
+
+	for _, schedule := range []Schedule{
+		{Cron: "@daily", Range: "1d"},
+		{Name: "bad-cron", Cron: "* *", Range: "1d"},
+		{Name: "no-range", Cron: "@daily"},
+		{Name: "bad-size", Cron: "@daily", Range: "1d", Retention: Retention{MaxTotalSize: "lots"}},
+	} {
+		_, err := New(&Config{Schedules: []Schedule{schedule}}, "", nil)
+		assert.Error(t, err, schedule.Name)
+	}
+	_, err = New(&Config{Schedules: []Schedule{config.Schedules[0], config.Schedules[0]}}, "", nil)
+	assert.Error(t, err, "名称重复")
+}
+
+func TestExecute(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "hourly")
+	config := &Config{Schedules: []Schedule{{
+		Name:      "hourly",
+		Cron:      "@hourly",
+		Range:     "1h",
+		OutputDir: outputDir,
+		Retention: Retention{KeepLast: 2},
+	}}}
+
+	var ranges [][2]time.Time
+	scheduler, err := New(config, "", func(ctx context.Context, job *Job, start, end time.Time) error {
+		ranges = append(ranges, [2]time.Time{start, end})
+		// 模拟收集生成的快照
+		name := "logsnap_" + start.Format("20060102_150405") + "_" + end.Format("20060102_150405")
+		require.NoError(t, os.WriteFile(filepath.Join(job.OutputDir, name+".zip"), []byte("zip"), 0644))
+		modified := end
+		return os.Chtimes(filepath.Join(job.OutputDir, name+".zip"), modified, modified)
+	})
+	require.NoError(t, err)
+
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		scheduler.Execute(context.Background(), scheduler.Jobs()[0], at.Add(time.Duration(i)*time.Hour))
+	}
+
+	require.Len(t, ranges, 3)
+	assert.Equal(t, at.Add(-time.Hour), ranges[0][0])
+	assert.Equal(t, at, ranges[0][1])
+
+	// 只保留最近 2 个快照
+	entries, err := os.ReadDir(outputDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{
+		"logsnap_20240301_100000_20240301_110000.zip",
+		"logsnap_20240301_110000_20240301_120000.zip",
+	}, names)
+}
+
+func TestExecuteKeepsQueuedSnapshots(t *testing.T) {
+	configDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "hourly")
+	config := &Config{Schedules: []Schedule{{
+		Name:      "hourly",
+		Cron:      "@hourly",
+		Range:     "1h",
+		OutputDir: outputDir,
+		Retention: Retention{KeepLast: 1},
+	}}}
+
+	st, err := store.Open(configDir)
+	require.NoError(t, err)
+	q, err := queue.Open(configDir)
+	require.NoError(t, err)
+	var names []string
+	scheduler, err := New(config, "", func(ctx context.Context, job *Job, start, end time.Time) error {
+		name := "logsnap_" + start.Format("20060102_150405") + "_" + end.Format("20060102_150405")
+		path := filepath.Join(job.OutputDir, name+".zip")
+		names = append(names, name)
+		require.NoError(t, os.WriteFile(path, []byte("zip"), 0644))
+		require.NoError(t, st.Add(store.Entry{ID: name, Path: path, Files: []string{path}}))
+		return os.Chtimes(path, end, end)
+	})
+	require.NoError(t, err)
+	scheduler.SetStateDirs(configDir, configDir)
+
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	scheduler.Execute(context.Background(), scheduler.Jobs()[0], at)
+	// 第一个快照上传失败，在队列中等待重试
+	require.NoError(t, q.Add(queue.Item{ID: names[0]}, at))
+	scheduler.Execute(context.Background(), scheduler.Jobs()[0], at.Add(time.Hour))
+	scheduler.Execute(context.Background(), scheduler.Jobs()[0], at.Add(2*time.Hour))
+
+	assert.FileExists(t, filepath.Join(outputDir, names[0]+".zip"))
+	assert.NoFileExists(t, filepath.Join(outputDir, names[1]+".zip"))
+	assert.FileExists(t, filepath.Join(outputDir, names[2]+".zip"))
+
+	// 被清理的快照在记录中标记为已删除
+	entry, err := st.Get(names[1])
+	require.NoError(t, err)
+	assert.True(t, entry.Deleted)
+	entry, err = st.Get(names[0])
+	require.NoError(t, err)
+	assert.False(t, entry.Deleted)
+}
@@ -20,6 +20,16 @@ type Config struct {
 	SkipVersionCheck bool             // 跳过版本检查
 	ProgressCallback ProgressCallback // 进度回调函数
 	Programs         []string         // 日志类型过滤（可选）
+	MaxVolumeSize    int64            // 单个快照分卷的最大字节数，0 表示不分卷
+	MaxSize          int64            // 快照的最大字节数，超出时按优先级裁剪日志，0 表示不限制
+	Timeline         bool             // 是否生成按时间合并所有程序日志的 timeline.log
+	NoReport         bool             // 不生成 report.html 摘要报告
+	Level            string           // 只收集不低于该级别的日志条目，为空时不限制
+	Grep             []string         // 只收集内容匹配其中任意一个正则表达式的日志条目
+	EncryptTo        []string         // 快照接收者公钥，为空时不加密
+	SigningKeyPath   string           // 校验文件签名私钥的路径，为空时不签名
+	StoreDir         string           // 快照存储目录，设置后每个快照的时间范围、大小和上传状态都记录到存储中
+	Source           string           // 触发收集的来源，记录到快照存储中，例如 collect、watch
 }
 
 // EnsureDefaultValues 确保配置具有默认值
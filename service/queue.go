@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"logsnap/collector"
+	"logsnap/queue"
+	"logsnap/remote"
+	"logsnap/store"
+	"logsnap/uploader"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrUploadQueued 上传失败，快照已加入上传队列，稍后自动重试
+var ErrUploadQueued = errors.New("上传失败，快照已加入上传队列")
+
+// enqueueUpload 将上传失败的快照加入配置目录中的上传队列
+// 快照不在存储目录中时先移动到存储目录，避免留在当前目录等不确定的位置
+func enqueueUpload(config *Config, snap *collector.Snapshot, uploadErr error) error {
+	if config.ConfigDir == "" {
+		return errors.New("未设置配置目录")
+	}
+	q, err := queue.Open(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	if config.StoreDir != "" {
+		if err := moveSnapshot(snap, config.StoreDir); err != nil {
+			logrus.Warnf("移动快照到存储目录失败，快照保留在原位置: %v", err)
+		}
+	}
+
+	files := snap.Files()
+	for i, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			files[i] = abs
+		}
+	}
+	return q.Add(queue.Item{
+		ID:        snapshotID(snap.Path),
+		Files:     files,
+		KeepLocal: config.KeepLocalSnap,
+		Source:    config.Source,
+		Start:     config.StartTime,
+		End:       config.EndTime,
+		Attempts:  1,
+		LastError: uploadErr.Error(),
+	}, time.Now())
+}
+
+// moveSnapshot 将快照的所有文件移动到 dir，已在 dir 中的快照不移动
+func moveSnapshot(snap *collector.Snapshot, dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	current, err := filepath.Abs(filepath.Dir(snap.Path))
+	if err != nil {
+		return err
+	}
+	if current == absDir || strings.HasPrefix(current, absDir+string(filepath.Separator)) {
+		return nil
+	}
+	if err := os.MkdirAll(absDir, 0755); err != nil {
+		return err
+	}
+
+	moved := make(map[string]string)
+	for _, file := range snap.Files() {
+		target := filepath.Join(absDir, filepath.Base(file))
+		if err := os.Rename(file, target); err != nil {
+			// 移动失败时恢复已移动的文件，快照的文件始终在同一个目录中
+			for from, to := range moved {
+				os.Rename(to, from)
+			}
+			return err
+		}
+		moved[file] = target
+	}
+
+	for i, volume := range snap.Volumes {
+		snap.Volumes[i] = moved[volume]
+	}
+	if snap.IndexPath != "" {
+		snap.IndexPath = moved[snap.IndexPath]
+	}
+	if snap.SidecarPath != "" {
+		snap.SidecarPath = moved[snap.SidecarPath]
+	}
+	snap.Path = filepath.Join(absDir, filepath.Base(snap.Path))
+	return nil
+}
+
+// RetryPendingUploads 重试上传队列中的快照，all 为 false 时只重试已到重试时间的快照
+// 重试结果同步到 storeDir 中的快照记录，上传成功且不需要保留本地快照时删除本地文件
+func RetryPendingUploads(configDir, storeDir string, uploadConfig *remote.UploadConfig, all bool) ([]queue.Result, error) {
+	if uploadConfig == nil {
+		return nil, errors.New("上传配置为空")
+	}
+	q, err := queue.Open(configDir)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := q.Process(time.Now(), all, func(item queue.Item) ([]string, error) {
+		logrus.Infof("重试上传快照 %s (第 %d 次)", item.ID, item.Attempts+1)
+		u := uploader.NewUploader(*uploadConfig)
+		metadata := uploader.Metadata{Host: hostname(), Description: "通过CLI上传的日志"}
+		if item.Start != nil && item.End != nil {
+			metadata.Start, metadata.End = *item.Start, *item.End
+		}
+		u.SetMetadata(metadata)
+		// 失败原因会写入上传队列和快照记录，并由调用方直接输出
+		urls, err := u.UploadFiles(item.Files)
+		return urls, remote.MaskError(err)
+	})
+	for _, result := range results {
+		updatePendingRecord(storeDir, result)
+	}
+	if err != nil {
+		return results, fmt.Errorf("更新上传队列失败: %w", err)
+	}
+	return results, nil
+}
+
+// updatePendingRecord 将一次重试的结果同步到快照记录
+func updatePendingRecord(storeDir string, result queue.Result) {
+	item := result.Item
+	if result.Err == nil && !item.KeepLocal {
+		for _, file := range item.Files {
+			os.Remove(file)
+			logrus.Infof("已删除上传后的文件: %s", file)
+		}
+	}
+	if storeDir == "" {
+		return
+	}
+
+	s, err := store.Open(storeDir)
+	if err == nil {
+		err = s.Update(item.ID, func(entry *store.Entry) {
+			switch {
+			case result.Err == nil:
+				uploadedAt := time.Now()
+				entry.Upload = store.Upload{Status: store.UploadDone, URLs: result.URLs, UploadedAt: &uploadedAt}
+				entry.Deleted = !item.KeepLocal
+			case result.Dropped:
+				entry.Upload = store.Upload{Status: store.UploadFailed, Error: result.Err.Error()}
+			default:
+				entry.Upload = store.Upload{Status: store.UploadPending, Error: result.Err.Error()}
+			}
+		})
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		logrus.Warnf("更新快照记录失败: %v", err)
+	}
+}
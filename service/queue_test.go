@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"logsnap/collector"
+	"logsnap/queue"
+	"logsnap/remote"
+	"logsnap/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueueAndRetryPendingUploads(t *testing.T) {
+	root := t.TempDir()
+	configDir := filepath.Join(root, "config")
+	storeDir := store.DefaultDir(configDir)
+	outputDir := filepath.Join(root, "output")
+	require.NoError(t, os.MkdirAll(outputDir, 0755))
+
+	name := "logsnap_20240310_100000_20240310_110000"
+	snap := &collector.Snapshot{
+		Path:        filepath.Join(outputDir, name+".zip"),
+		Volumes:     []string{filepath.Join(outputDir, name+".zip")},
+		SidecarPath: filepath.Join(outputDir, name+".zip.sha256"),
+	}
+	for _, file := range snap.Files() {
+		require.NoError(t, os.WriteFile(file, []byte("data"), 0644))
+	}
+	start, end := time.Now().Add(-time.Hour), time.Now()
+	config := &Config{StartTime: &start, EndTime: &end, ConfigDir: configDir, StoreDir: storeDir, Source: "collect"}
+
+	// 上传失败的快照移动到存储目录并加入上传队列
+	require.NoError(t, enqueueUpload(config, snap, errors.New("network is unreachable")))
+	assert.Equal(t, filepath.Join(storeDir, name+".zip"), snap.Path)
+	assert.NoFileExists(t, filepath.Join(outputDir, name+".zip"))
+	assert.FileExists(t, filepath.Join(storeDir, name+".zip.sha256"))
+	recordSnapshot(config, snap, []string{"xyz-studio-max"}, store.Upload{Status: store.UploadPending})
+
+	q, err := queue.Open(configDir)
+	require.NoError(t, err)
+	items, err := q.List()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, name, items[0].ID)
+	assert.Equal(t, snap.Files(), items[0].Files)
+
+	// 未到重试时间
+	uploadConfig := &remote.UploadConfig{
+		DefaultProvider: "local",
+		Providers:       []remote.UploadConfigProvider{{Provider: "local", Endpoint: filepath.Join(root, "remote"), Bucket: "logs"}},
+	}
+	results, err := RetryPendingUploads(configDir, storeDir, uploadConfig, false)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// 上传失败时保留在队列中，快照记录保持等待重试
+	results, err = RetryPendingUploads(configDir, storeDir, &remote.UploadConfig{DefaultProvider: "missing"}, true)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Error(t, results[0].Err)
+	assert.Equal(t, 2, results[0].Item.Attempts)
+	s, err := store.Open(storeDir)
+	require.NoError(t, err)
+	entry, err := s.Get(name)
+	require.NoError(t, err)
+	assert.Equal(t, store.UploadPending, entry.Upload.Status)
+	assert.NotEmpty(t, entry.Upload.Error)
+
+	// 上传成功后移出队列，更新快照记录并删除本地文件
+	results, err = RetryPendingUploads(configDir, storeDir, uploadConfig, true)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Err)
+	assert.Len(t, results[0].URLs, 2)
+	items, err = q.List()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+	entry, err = s.Get(name)
+	require.NoError(t, err)
+	assert.Equal(t, store.UploadDone, entry.Upload.Status)
+	assert.Equal(t, results[0].URLs, entry.Upload.URLs)
+	assert.False(t, entry.Local())
+	assert.NoFileExists(t, snap.Path)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"logsnap/collector"
+	"logsnap/encryption"
+	"logsnap/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+// recordSnapshot 将快照记录到快照存储中，未设置存储目录时不记录
+// 记录失败不影响收集结果，只输出警告
+func recordSnapshot(config *Config, snap *collector.Snapshot, programs []string, upload store.Upload) {
+	if config.StoreDir == "" {
+		return
+	}
+	s, err := store.Open(config.StoreDir)
+	if err != nil {
+		logrus.Warnf("记录快照失败: %v", err)
+		return
+	}
+
+	entry := store.Entry{
+		ID:        snapshotID(snap.Path),
+		Path:      snap.Path,
+		Files:     snap.Files(),
+		Start:     *config.StartTime,
+		End:       *config.EndTime,
+		Programs:  programs,
+		CreatedAt: time.Now(),
+		Upload:    upload,
+		Source:    config.Source,
+	}
+	for _, path := range entry.Files {
+		if info, err := os.Stat(path); err == nil {
+			entry.Size += info.Size()
+		}
+		if strings.HasSuffix(path, encryption.FileExtension) {
+			entry.Encrypted = true
+		}
+	}
+	if err := s.Add(entry); err != nil {
+		logrus.Warnf("记录快照失败: %v", err)
+	}
+}
+
+// markDeleted 在快照存储中标记快照的本地文件已删除
+func markDeleted(config *Config, snap *collector.Snapshot) {
+	if config.StoreDir == "" {
+		return
+	}
+	s, err := store.Open(config.StoreDir)
+	if err == nil {
+		err = s.Update(snapshotID(snap.Path), func(entry *store.Entry) { entry.Deleted = true })
+	}
+	if err != nil {
+		logrus.Warnf("更新快照记录失败: %v", err)
+	}
+}
+
+// snapshotID 返回快照的名称，即快照主文件名去掉扩展名
+func snapshotID(path string) string {
+	name := filepath.Base(path)
+	if i := strings.Index(name, "."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
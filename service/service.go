@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"logsnap/collector"
 	"logsnap/collector/factory"
+	"logsnap/encryption"
+	"logsnap/logentry"
+	"logsnap/snapshot"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"logsnap/remote"
+	"logsnap/store"
 
 	"github.com/sirupsen/logrus"
 )
@@ -70,12 +76,35 @@ func (s *Service) UploadLogSnapFile(file *LogFile, description string, tags []st
 		Reporter:    s.progressReporter,
 		Description: description,
 		Tags:        tags,
+		Host:        hostname(),
+		StartTime:   s.Config.StartTime,
+		EndTime:     s.Config.EndTime,
 	}
 
 	// 执行上传
 	return s.uploadManager.Upload(request)
 }
 
+// UploadLogSnapFiles 将多个文件（例如快照的所有分卷）上传到同一个目录
+func (s *Service) UploadLogSnapFiles(files []*LogFile, description string, tags []string) (*UploadResult, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("没有要上传的文件")
+	}
+
+	request := &UploadRequest{
+		Files:       files,
+		Config:      s.UploadConfig,
+		Reporter:    s.progressReporter,
+		Description: description,
+		Tags:        tags,
+		Host:        hostname(),
+		StartTime:   s.Config.StartTime,
+		EndTime:     s.Config.EndTime,
+	}
+
+	return s.uploadManager.Upload(request)
+}
+
 // SetProgressCallback 设置进度回调函数
 func (s *Service) SetProgressCallback(callback ProgressCallback) {
 	if callback != nil {
@@ -194,10 +223,12 @@ func CollectAndUploadLogs(config *Config, uploadConfig *remote.UploadConfig) (st
 	// 当LogTypes长度为0时，加载所有支持的处理器
 	// 否则根据LogTypes中的类型加载相应的处理器
 	loadAll := len(config.Programs) == 0
+	programs := config.Programs
 
 	if loadAll {
 		supportedProcessors := factory.GetSupportedProcessorTypes()
 		for _, processorType := range supportedProcessors {
+			programs = append(programs, string(processorType))
 			processor, err := factory.CreateProcessor(processorType, config.LogRootDir, "")
 			if err != nil {
 				logrus.Errorf("创建 %s 处理器失败: %v", processorType, err)
@@ -221,53 +252,99 @@ func CollectAndUploadLogs(config *Config, uploadConfig *remote.UploadConfig) (st
 
 	// 创建收集器
 	collect := collector.NewCollector(processors, config.OutputDir)
+	collect.SetMaxVolumeSize(config.MaxVolumeSize)
+	collect.SetMaxSize(config.MaxSize)
+	collect.SetTimeline(config.Timeline)
+	collect.SetReport(!config.NoReport)
+
+	filter, err := logentry.NewFilter(config.Level, config.Grep)
+	if err != nil {
+		return "", "", err
+	}
+	collect.SetFilter(filter)
+
+	// 命令行指定的公钥和站点配置中的公钥都可以解密快照
+	encryptTo := append([]string(nil), config.EncryptTo...)
+	if uploadConfig != nil {
+		encryptTo = append(encryptTo, uploadConfig.EncryptTo...)
+	}
+	recipients, err := encryption.ParseRecipients(encryptTo)
+	if err != nil {
+		return "", "", fmt.Errorf("解析加密公钥失败: %v", err)
+	}
+	collect.SetRecipients(recipients)
+
+	if config.SigningKeyPath != "" {
+		signingKey, err := snapshot.LoadSigningKey(config.SigningKeyPath)
+		if err != nil {
+			return "", "", fmt.Errorf("加载签名私钥失败: %v", err)
+		}
+		collect.SetSigningKey(signingKey)
+	}
 
 	// 使用collector收集日志
-	snapPath, err := collect.Collect(*config.StartTime, *config.EndTime)
+	snap, err := collect.CollectSnapshot(*config.StartTime, *config.EndTime)
 	if err != nil {
 		return "", "", fmt.Errorf("收集日志失败: %v", err)
 	}
+	snapPath := snap.Path
 
 	// 如果不需要上传，直接返回结果
 	if !config.ShouldUpload {
+		recordSnapshot(config, snap, programs, store.Upload{})
 		return snapPath, "", nil
 	}
 
-	// 准备上传文件
-	file, err := os.Stat(snapPath)
-	if err != nil {
-		return snapPath, "", fmt.Errorf("获取日志文件信息失败: %v", err)
-	}
-
-	// 创建LogPath和LogFile对象
+	// 准备上传文件，分卷时上传所有分卷及索引文件
 	logPath := &LogPath{
 		Name: "快照",
 		Path: filepath.Dir(snapPath),
 	}
 
-	logFile := &LogFile{
-		Name:     file.Name(),
-		Path:     snapPath,
-		Size:     file.Size(),
-		ModTime:  file.ModTime(),
-		IsDir:    file.IsDir(),
-		LogPath:  logPath,
-		RootPath: file.Name(),
-		Selected: true,
+	var logFiles []*LogFile
+	for _, path := range snap.Files() {
+		file, err := os.Stat(path)
+		if err != nil {
+			return snapPath, "", fmt.Errorf("获取日志文件信息失败: %v", err)
+		}
+		logFiles = append(logFiles, &LogFile{
+			Name:     file.Name(),
+			Path:     path,
+			Size:     file.Size(),
+			ModTime:  file.ModTime(),
+			IsDir:    file.IsDir(),
+			LogPath:  logPath,
+			RootPath: file.Name(),
+			Selected: true,
+		})
 	}
 
 	// 上传文件
-	result, err := service.UploadLogSnapFile(logFile, "通过CLI上传的日志", nil)
+	result, err := service.UploadLogSnapFiles(logFiles, "通过CLI上传的日志", nil)
 	if err != nil {
-		return snapPath, "", fmt.Errorf("上传日志失败: %v", err)
+		// 错误信息会写入上传队列和快照记录，先屏蔽其中的凭证
+		err = remote.MaskError(err)
+		// 加入上传队列，稍后或下次运行时自动重试
+		if queueErr := enqueueUpload(config, snap, err); queueErr != nil {
+			logrus.Warnf("加入上传队列失败: %v", queueErr)
+			recordSnapshot(config, snap, programs, store.Upload{Status: store.UploadFailed, Error: err.Error()})
+			return snapPath, "", fmt.Errorf("上传日志失败: %v", err)
+		}
+		recordSnapshot(config, snap, programs, store.Upload{Status: store.UploadPending, Error: err.Error()})
+		return snap.Path, "", fmt.Errorf("%w: %v", ErrUploadQueued, err)
 	}
+	uploadedAt := time.Now()
+	recordSnapshot(config, snap, programs, store.Upload{Status: store.UploadDone, URLs: result.URLs, UploadedAt: &uploadedAt})
 
 	// 如果需要，删除上传后的文件
 	if !config.KeepLocalSnap {
-		os.Remove(snapPath)
-		logrus.Infof("已删除上传后的文件: %s", snapPath)
+		for _, path := range snap.Files() {
+			os.Remove(path)
+			logrus.Infof("已删除上传后的文件: %s", path)
+		}
+		markDeleted(config, snap)
 	}
 
-	// 返回结果
-	return snapPath, result.URL, nil
+	// 返回结果，多个文件时每行一个链接
+	return snapPath, strings.Join(result.URLs, "\n"), nil
 }
@@ -2,6 +2,8 @@ package service
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"logsnap/remote"
@@ -11,20 +13,27 @@ import (
 // UploadRequest 定义上传请求结构
 type UploadRequest struct {
 	File        *LogFile             // 要上传的文件
+	Files       []*LogFile           // 要上传到同一目录的多个文件（例如快照分卷），设置后忽略 File
 	Config      *remote.UploadConfig // 配置信息
 	Reporter    ProgressReporter     // 进度报告器
 	Description string               // 上传描述
 	Tags        []string             // 标签
+	Host        string               // 采集主机名
+	StartTime   *time.Time           // 快照时间范围的开始时间
+	EndTime     *time.Time           // 快照时间范围的结束时间
 }
 
 // UploadResult 定义上传结果结构
 type UploadResult struct {
 	Success      bool      // 是否成功
 	Message      string    // 消息
-	URL          string    // 上传后的URL
+	URL          string    // 上传后的URL，多个文件时为第一个文件的URL
+	URLs         []string  // 每个文件上传后的URL，配置了 fan_out 时依次包含每个上传成功的目标的链接
 	UploadedTime time.Time // 上传时间
 	FileCount    int       // 文件数量
 	TotalSize    int64     // 总大小
+
+	Destinations []uploader.DestinationResult // 每个尝试过的上传目标的结果
 }
 
 // UploadManager 定义上传管理器接口
@@ -46,12 +55,22 @@ func NewUploadManager(uploadConfig *remote.UploadConfig) UploadManager {
 
 // Upload 执行上传操作
 func (m *DefaultUploadManager) Upload(request *UploadRequest) (*UploadResult, error) {
+	files := request.Files
+	if len(files) == 0 && request.File != nil {
+		files = []*LogFile{request.File}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("没有要上传的文件")
+	}
+
 	// 计算总大小
 	var totalSize int64
-	if request.File != nil {
-		if !request.File.IsDir {
-			totalSize += request.File.Size
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir {
+			totalSize += file.Size
 		}
+		paths = append(paths, file.Path)
 	}
 
 	// 更新进度
@@ -61,9 +80,21 @@ func (m *DefaultUploadManager) Upload(request *UploadRequest) (*UploadResult, er
 
 	// 创建上传器
 	uploaderInstance := uploader.NewUploader(*m.uploadConfig)
+	if request.Reporter != nil {
+		// 上传进度对应整体进度的 50% ~ 100%
+		uploaderInstance.SetProgress(func(file string, sent, total int64) {
+			percentage := 50
+			if total > 0 {
+				percentage += int(sent * 50 / total)
+			}
+			request.Reporter.Report("upload", percentage, fmt.Sprintf("正在上传 %s", filepath.Base(file)))
+		})
+	}
+
+	uploaderInstance.SetMetadata(request.metadata())
 
 	// 执行上传操作
-	url, err := uploaderInstance.Upload(request.File.Path)
+	destinations, err := uploaderInstance.UploadDestinations(paths)
 	if err != nil {
 		if request.Reporter != nil {
 			request.Reporter.Report("upload", 100, fmt.Sprintf("上传失败: %v", err))
@@ -76,13 +107,47 @@ func (m *DefaultUploadManager) Upload(request *UploadRequest) (*UploadResult, er
 		request.Reporter.Report("upload", 100, "上传完成")
 	}
 
+	var urls []string
+	for _, destination := range destinations {
+		if destination.Err == nil {
+			urls = append(urls, destination.URLs...)
+		}
+	}
+
 	// 返回上传结果
 	return &UploadResult{
 		Success:      true,
 		Message:      "上传成功",
-		URL:          url,
+		URL:          urls[0],
+		URLs:         urls,
 		UploadedTime: time.Now(),
-		FileCount:    1,
+		FileCount:    len(files),
 		TotalSize:    totalSize,
+		Destinations: destinations,
 	}, nil
 }
+
+// metadata 返回随文件提交给上传服务的快照描述信息
+func (r *UploadRequest) metadata() uploader.Metadata {
+	metadata := uploader.Metadata{
+		Host:        r.Host,
+		Description: r.Description,
+		Tags:        r.Tags,
+	}
+	if r.StartTime != nil {
+		metadata.Start = *r.StartTime
+	}
+	if r.EndTime != nil {
+		metadata.End = *r.EndTime
+	}
+	return metadata
+}
+
+// hostname 返回本机主机名，获取失败时返回空字符串
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
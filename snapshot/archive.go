@@ -0,0 +1,152 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrUnsupportedArchive 快照文件不是任何已注册的归档格式
+var ErrUnsupportedArchive = errors.New("不支持的快照格式")
+
+// archiveHeaderSize 识别归档格式时读取的文件开头字节数
+const archiveHeaderSize = 512
+
+// ArchiveFile 归档中的单个文件
+type ArchiveFile struct {
+	Name           string    // 文件在归档中的路径，使用 / 作为分隔符
+	Size           uint64    // 原始大小
+	CompressedSize uint64    // 压缩后大小，归档不压缩时与原始大小相同
+	Modified       time.Time // 修改时间
+}
+
+// Archive 以只读方式访问快照归档
+type Archive interface {
+	// Files 返回归档中的所有文件，不包含目录
+	Files() []ArchiveFile
+
+	// Open 打开归档中的文件
+	Open(name string) (io.ReadCloser, error)
+
+	// Close 关闭归档
+	Close() error
+}
+
+// ArchiveFormat 描述一种快照归档格式
+type ArchiveFormat struct {
+	Name   string                             // 格式名称
+	Detect func(header []byte) bool           // 根据文件开头的内容判断是否为该格式
+	Open   func(path string) (Archive, error) // 打开归档
+}
+
+// archiveFormats 已注册的归档格式，按注册顺序识别
+var archiveFormats = struct {
+	sync.RWMutex
+	formats []ArchiveFormat
+}{}
+
+func init() {
+	RegisterArchiveFormat(ArchiveFormat{
+		Name: "zip",
+		Detect: func(header []byte) bool {
+			// 本地文件头，或者空ZIP文件的中央目录结束标记
+			return bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06"))
+		},
+		Open: openZipArchive,
+	})
+}
+
+// RegisterArchiveFormat 注册快照归档格式，同名格式会被替换
+func RegisterArchiveFormat(format ArchiveFormat) {
+	archiveFormats.Lock()
+	defer archiveFormats.Unlock()
+	for i, existing := range archiveFormats.formats {
+		if existing.Name == format.Name {
+			archiveFormats.formats[i] = format
+			return
+		}
+	}
+	archiveFormats.formats = append(archiveFormats.formats, format)
+}
+
+// OpenArchive 识别快照文件的格式并打开，返回归档和格式名称
+func OpenArchive(path string) (Archive, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("打开快照失败: %w", err)
+	}
+	header := make([]byte, archiveHeaderSize)
+	n, err := io.ReadFull(file, header)
+	file.Close()
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, "", fmt.Errorf("读取快照失败: %w", err)
+	}
+	header = header[:n]
+
+	archiveFormats.RLock()
+	formats := append([]ArchiveFormat(nil), archiveFormats.formats...)
+	archiveFormats.RUnlock()
+
+	for _, format := range formats {
+		if !format.Detect(header) {
+			continue
+		}
+		archive, err := format.Open(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("打开 %s 快照失败: %w", format.Name, err)
+		}
+		return archive, format.Name, nil
+	}
+	return nil, "", ErrUnsupportedArchive
+}
+
+// zipArchive ZIP格式的快照
+type zipArchive struct {
+	reader *zip.ReadCloser
+	files  map[string]*zip.File
+}
+
+func openZipArchive(path string) (Archive, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	archive := &zipArchive{reader: reader, files: make(map[string]*zip.File)}
+	for _, file := range reader.File {
+		archive.files[file.Name] = file
+	}
+	return archive, nil
+}
+
+func (a *zipArchive) Files() []ArchiveFile {
+	var files []ArchiveFile
+	for _, file := range a.reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, ArchiveFile{
+			Name:           file.Name,
+			Size:           file.UncompressedSize64,
+			CompressedSize: file.CompressedSize64,
+			Modified:       file.Modified,
+		})
+	}
+	return files
+}
+
+func (a *zipArchive) Open(name string) (io.ReadCloser, error) {
+	file, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("快照中不存在文件: %s", name)
+	}
+	return file.Open()
+}
+
+func (a *zipArchive) Close() error {
+	return a.reader.Close()
+}
@@ -0,0 +1,248 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"logsnap/encryption"
+	"logsnap/logentry"
+)
+
+// InspectOptions 快照查看选项
+type InspectOptions struct {
+	Identity *encryption.Identity // 解密加密快照使用的私钥
+}
+
+// Inspection 快照内容概览
+type Inspection struct {
+	Path      string             `json:"path"`               // 快照路径
+	Format    string             `json:"format"`             // 归档格式
+	Encrypted bool               `json:"encrypted"`          // 是否加密
+	Volumes   []string           `json:"volumes"`            // 分卷文件名，未分卷时只有快照本身
+	Manifest  *Manifest          `json:"manifest,omitempty"` // 快照清单，分卷时合并所有分卷的文件列表，旧版本快照没有清单时为空
+	Programs  []InspectedProgram `json:"programs"`           // 按程序分组的文件
+}
+
+// Version 返回生成快照的 logsnap 版本，没有清单时为空
+func (i *Inspection) Version() string {
+	if i.Manifest == nil {
+		return ""
+	}
+	return i.Manifest.Version
+}
+
+// InspectedProgram 单个程序的文件和日志时间覆盖范围
+type InspectedProgram struct {
+	Name  string          `json:"name"`            // 程序名称，快照自身生成的文件（报告、时间线等）为空
+	Files []InspectedFile `json:"files"`           // 文件列表，按路径排序
+	Size  uint64          `json:"size"`            // 文件总大小
+	Lines int             `json:"lines"`           // 总行数
+	First *time.Time      `json:"first,omitempty"` // 最早的日志时间
+	Last  *time.Time      `json:"last,omitempty"`  // 最晚的日志时间
+}
+
+// InspectedFile 快照中的单个文件
+type InspectedFile struct {
+	Name           string     `json:"name"`             // 相对快照目录的路径
+	Volume         int        `json:"volume,omitempty"` // 所在分卷，未分卷时为 0
+	Size           uint64     `json:"size"`             // 原始大小
+	CompressedSize uint64     `json:"compressed_size"`  // 压缩后大小
+	Lines          int        `json:"lines"`            // 行数
+	First          *time.Time `json:"first,omitempty"`  // 最早的日志时间，没有可识别时间戳时为空
+	Last           *time.Time `json:"last,omitempty"`   // 最晚的日志时间
+}
+
+// Inspect 读取快照的清单和每个文件的内容，统计文件大小、行数和日志时间覆盖范围
+// snapPath 可以是任意已注册格式的快照文件、加密快照或分卷索引文件
+func Inspect(snapPath string, opts InspectOptions) (*Inspection, error) {
+	if _, err := os.Stat(snapPath); err != nil {
+		return nil, fmt.Errorf("快照不存在或无法访问: %w", err)
+	}
+
+	volumes, err := snapshotVolumes(snapPath, opts.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	inspection := &Inspection{Path: snapPath}
+	programs := make(map[string]*InspectedProgram)
+	for i, volume := range volumes {
+		volumeNumber := 0
+		if len(volumes) > 1 {
+			volumeNumber = i + 1
+		}
+		inspection.Volumes = append(inspection.Volumes, filepath.Base(volume))
+		if err := inspectVolume(inspection, programs, volume, volumeNumber, opts.Identity); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(volume), err)
+		}
+	}
+
+	inspection.Programs = sortPrograms(programs, inspection.Manifest)
+	return inspection, nil
+}
+
+// inspectVolume 统计单个分卷中的文件，并将分卷清单合并到 inspection.Manifest
+func inspectVolume(inspection *Inspection, programs map[string]*InspectedProgram, volumePath string,
+	volume int, identity *encryption.Identity) error {
+	archive, format, encrypted, err := openVolume(volumePath, identity)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	inspection.Format = format
+	inspection.Encrypted = inspection.Encrypted || encrypted
+
+	files := archive.Files()
+	manifest, err := readArchiveManifest(archive, files)
+	if err != nil {
+		return err
+	}
+	fileProgram := manifestPrograms(manifest)
+	if manifest != nil {
+		if inspection.Manifest == nil {
+			merged := *manifest
+			merged.Volume = 0
+			merged.Files = nil
+			inspection.Manifest = &merged
+		}
+		inspection.Manifest.Files = append(inspection.Manifest.Files, manifest.Files...)
+	}
+
+	for _, file := range files {
+		if isManifest(file.Name) {
+			continue
+		}
+		inspected, err := inspectFile(archive, file)
+		if err != nil {
+			return err
+		}
+		inspected.Volume = volume
+
+		programName := programOf(fileProgram, file.Name, inspected.Name)
+		program := programs[programName]
+		if program == nil {
+			program = &InspectedProgram{Name: programName}
+			programs[programName] = program
+		}
+		program.add(inspected)
+	}
+	return nil
+}
+
+// inspectFile 读取文件内容，统计行数和日志时间范围
+func inspectFile(archive Archive, file ArchiveFile) (InspectedFile, error) {
+	inspected := InspectedFile{
+		Name:           relativeName(file.Name),
+		Size:           file.Size,
+		CompressedSize: file.CompressedSize,
+	}
+
+	rc, err := archive.Open(file.Name)
+	if err != nil {
+		return inspected, fmt.Errorf("打开 %s 失败: %w", file.Name, err)
+	}
+	defer rc.Close()
+
+	var stats logentry.Stats
+	if _, err := io.Copy(&stats, rc); err != nil {
+		return inspected, fmt.Errorf("读取 %s 失败: %w", file.Name, err)
+	}
+	stats.Flush()
+
+	inspected.Lines = stats.Lines
+	if stats.Entries > 0 {
+		first, last := stats.First, stats.Latest
+		inspected.First, inspected.Last = &first, &last
+	}
+	return inspected, nil
+}
+
+// readArchiveManifest 读取归档中的快照清单，没有清单时返回 nil
+func readArchiveManifest(archive Archive, files []ArchiveFile) (*Manifest, error) {
+	for _, file := range files {
+		if !isManifest(file.Name) {
+			continue
+		}
+		rc, err := archive.Open(file.Name)
+		if err != nil {
+			return nil, fmt.Errorf("打开清单失败: %w", err)
+		}
+		defer rc.Close()
+
+		var manifest Manifest
+		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("解析清单失败: %w", err)
+		}
+		return &manifest, nil
+	}
+	return nil, nil
+}
+
+// add 将文件计入程序的统计
+func (p *InspectedProgram) add(file InspectedFile) {
+	p.Files = append(p.Files, file)
+	p.Size += file.Size
+	p.Lines += file.Lines
+	if file.First != nil && (p.First == nil || file.First.Before(*p.First)) {
+		p.First = file.First
+	}
+	if file.Last != nil && (p.Last == nil || file.Last.After(*p.Last)) {
+		p.Last = file.Last
+	}
+}
+
+// relativeName 去掉条目名称开头的快照目录
+func relativeName(name string) string {
+	if i := strings.Index(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// programFromPath 清单中没有记录程序时（旧版本快照），以快照目录下的第一级目录作为程序名称
+// 快照目录顶层的文件返回空
+func programFromPath(rel string) string {
+	if i := strings.Index(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return ""
+}
+
+// sortPrograms 按清单中的程序顺序排列，其余程序按名称排序，快照自身生成的文件排在最后
+func sortPrograms(programs map[string]*InspectedProgram, manifest *Manifest) []InspectedProgram {
+	order := make(map[string]int)
+	if manifest != nil {
+		for i, name := range manifest.Programs {
+			order[name] = i + 1
+		}
+	}
+	rank := func(name string) int {
+		if name == "" {
+			return len(order) + 2
+		}
+		if i, ok := order[name]; ok {
+			return i
+		}
+		return len(order) + 1
+	}
+
+	result := make([]InspectedProgram, 0, len(programs))
+	for _, program := range programs {
+		sort.Slice(program.Files, func(i, j int) bool { return program.Files[i].Name < program.Files[j].Name })
+		result = append(result, *program)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ri, rj := rank(result[i].Name), rank(result[j].Name)
+		if ri != rj {
+			return ri < rj
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,51 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	content := "I20250228 10:00:01.000000 1 a.cpp:1] start\n" +
+		"  continuation\n" +
+		"E20250228 10:05:00.000000 1 a.cpp:9] failed\n"
+	snapPath := writeTestSnapshot(t, dir, content)
+
+	inspection, err := Inspect(snapPath, InspectOptions{})
+	if err != nil {
+		t.Fatalf("查看快照失败: %v", err)
+	}
+	if inspection.Format != "zip" || inspection.Manifest == nil {
+		t.Fatalf("应识别为带清单的ZIP快照: %+v", inspection)
+	}
+	if len(inspection.Programs) != 1 {
+		t.Fatalf("应有 1 个程序, 实际 %d", len(inspection.Programs))
+	}
+
+	// 清单中没有记录程序时按第一级目录分组
+	program := inspection.Programs[0]
+	if program.Name != "xyz_hmi" || len(program.Files) != 1 {
+		t.Fatalf("程序分组错误: %+v", program)
+	}
+	file := program.Files[0]
+	if file.Name != "xyz_hmi/app.log" || file.Lines != 3 || file.Size != uint64(len(content)) {
+		t.Errorf("文件统计错误: %+v", file)
+	}
+	if file.First == nil || !file.First.Equal(time.Date(2025, 2, 28, 10, 0, 1, 0, time.Local)) ||
+		file.Last == nil || !file.Last.Equal(time.Date(2025, 2, 28, 10, 5, 0, 0, time.Local)) {
+		t.Errorf("时间覆盖范围错误: %v ~ %v", file.First, file.Last)
+	}
+}
+
+func TestOpenArchive_UnsupportedFormat(t *testing.T) {
+	snapPath := filepath.Join(t.TempDir(), "logsnap_test.tar")
+	os.WriteFile(snapPath, []byte("not an archive"), 0644)
+
+	if _, _, err := OpenArchive(snapPath); !errors.Is(err, ErrUnsupportedArchive) {
+		t.Fatalf("未注册的格式应返回 ErrUnsupportedArchive, 实际 %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ManifestFileName 快照清单在快照目录中的文件名
+const ManifestFileName = "manifest.json"
+
+// IndexFileSuffix 分卷索引文件的后缀
+const IndexFileSuffix = ".index.json"
+
+// Manifest 快照清单，写入每个快照ZIP文件（分卷时写入每个分卷）
+type Manifest struct {
+	Version   string         `json:"version"`            // 生成快照的 logsnap 版本
+	CreatedAt time.Time      `json:"created_at"`         // 快照创建时间
+	Hostname  string         `json:"hostname"`           // 采集主机名
+	StartTime time.Time      `json:"start_time"`         // 采集开始时间
+	EndTime   time.Time      `json:"end_time"`           // 采集结束时间
+	Programs  []string       `json:"programs"`           // 参与采集的程序
+	Volume    int            `json:"volume,omitempty"`   // 分卷序号，未分卷时为 0
+	Files     []ManifestFile `json:"files"`              // 当前ZIP文件中的日志文件
+	MaxSize   int64          `json:"max_size,omitempty"` // 快照大小上限，0 表示不限制
+	Trimmed   []TrimmedFile  `json:"trimmed,omitempty"`  // 因超出大小上限被裁剪的文件，分卷时每个分卷都记录完整列表
+}
+
+// ManifestFile 描述快照中的单个文件
+type ManifestFile struct {
+	Name           string    `json:"name"`              // ZIP中的条目名称
+	Size           uint64    `json:"size"`              // 原始大小
+	CompressedSize uint64    `json:"compressed_size"`   // 压缩后大小
+	Modified       time.Time `json:"modified"`          // 修改时间
+	SHA256         string    `json:"sha256"`            // 原始内容的 SHA-256
+	Program        string    `json:"program,omitempty"` // 产生该文件的程序，报告等快照自身生成的文件为空
+}
+
+// 裁剪方式
+const (
+	TrimDropped   = "dropped"   // 整个文件被丢弃
+	TrimTruncated = "truncated" // 只保留了文件末尾的完整行
+)
+
+// TrimmedFile 描述因超出快照大小上限被裁剪的文件
+type TrimmedFile struct {
+	Name     string `json:"name"`      // ZIP中的条目名称
+	Action   string `json:"action"`    // 裁剪方式，TrimDropped 或 TrimTruncated
+	Size     uint64 `json:"size"`      // 原始大小
+	KeptSize uint64 `json:"kept_size"` // 保留的大小，丢弃时为 0
+	Program  string `json:"program"`   // 产生该文件的程序
+	Severity string `json:"severity"`  // 文件中出现的最高日志级别
+}
+
+// Index 分卷索引，记录一个快照的所有分卷，与分卷文件放在同一目录
+type Index struct {
+	Version   string        `json:"version"`
+	CreatedAt time.Time     `json:"created_at"`
+	Hostname  string        `json:"hostname"`
+	StartTime time.Time     `json:"start_time"`
+	EndTime   time.Time     `json:"end_time"`
+	Programs  []string      `json:"programs"`
+	Volumes   []IndexVolume `json:"volumes"`
+	MaxSize   int64         `json:"max_size,omitempty"`
+	Trimmed   []TrimmedFile `json:"trimmed,omitempty"`
+}
+
+// IndexVolume 描述单个分卷
+type IndexVolume struct {
+	Volume int      `json:"volume"` // 分卷序号，从 1 开始
+	Name   string   `json:"name"`   // 分卷文件名
+	Size   int64    `json:"size"`   // 分卷文件大小
+	Files  []string `json:"files"`  // 分卷中的日志文件
+}
+
+// VolumeFileName 返回分卷文件名，例如 logsnap_xxx.part001.zip
+func VolumeFileName(baseName string, volume int) string {
+	return fmt.Sprintf("%s.part%03d.zip", baseName, volume)
+}
+
+// ParseIndex 解析分卷索引内容
+func ParseIndex(data []byte) (*Index, error) {
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("解析分卷索引失败: %w", err)
+	}
+	return &index, nil
+}
+
+// WriteIndex 将分卷索引写入文件
+func WriteIndex(path string, index *Index) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化分卷索引失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入分卷索引失败: %w", err)
+	}
+	return nil
+}
+
+// ReadIndex 读取分卷索引文件
+func ReadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取分卷索引失败: %w", err)
+	}
+	return ParseIndex(data)
+}
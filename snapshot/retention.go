@@ -0,0 +1,116 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// snapshotNamePattern 快照文件名的公共前缀，同一快照的 ZIP、分卷、索引、加密文件和校验文件都以此开头
+var snapshotNamePattern = regexp.MustCompile(`^logsnap_\d{8}_\d{6}_\d{8}_\d{6}`)
+
+// LocalSnapshot 目录中的一个快照
+type LocalSnapshot struct {
+	Name     string    // 快照名称，例如 logsnap_20240301_100000_20240301_110000
+	Files    []string  // 属于该快照的所有文件路径
+	Size     int64     // 所有文件的总大小
+	Modified time.Time // 所有文件中最新的修改时间
+}
+
+// ListLocal 列出目录中的快照，按修改时间从新到旧排序
+func ListLocal(dir string) ([]LocalSnapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取快照目录失败: %w", err)
+	}
+
+	byName := make(map[string]*LocalSnapshot)
+	var snapshots []*LocalSnapshot
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := snapshotNamePattern.FindString(entry.Name())
+		if name == "" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshot := byName[name]
+		if snapshot == nil {
+			snapshot = &LocalSnapshot{Name: name}
+			byName[name] = snapshot
+			snapshots = append(snapshots, snapshot)
+		}
+		snapshot.Files = append(snapshot.Files, filepath.Join(dir, entry.Name()))
+		snapshot.Size += info.Size()
+		if info.ModTime().After(snapshot.Modified) {
+			snapshot.Modified = info.ModTime()
+		}
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].Modified.Equal(snapshots[j].Modified) {
+			return snapshots[i].Modified.After(snapshots[j].Modified)
+		}
+		return snapshots[i].Name > snapshots[j].Name
+	})
+	result := make([]LocalSnapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		result[i] = *snapshot
+	}
+	return result, nil
+}
+
+// Retention 本地快照的保留策略，各项为 0 表示不限制
+type Retention struct {
+	KeepLast     int           // 最多保留的快照数量
+	MaxAge       time.Duration // 快照的最长保留时间
+	MaxTotalSize int64         // 所有快照的最大总大小
+}
+
+// Active 返回是否设置了任何限制
+func (r Retention) Active() bool {
+	return r.KeepLast > 0 || r.MaxAge > 0 || r.MaxTotalSize > 0
+}
+
+// ApplyRetention 删除目录中超出保留策略的快照，返回被删除的快照
+// 从新到旧依次保留，超出数量、时间或总大小限制的快照被删除；最新的快照总是保留。
+// keep 对快照名称返回 true 时不删除该快照（例如仍在上传队列中等待重试），但其大小仍计入总大小
+func ApplyRetention(dir string, policy Retention, now time.Time, keep func(name string) bool) ([]LocalSnapshot, error) {
+	if !policy.Active() {
+		return nil, nil
+	}
+	snapshots, err := ListLocal(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []LocalSnapshot
+	var total int64
+	for i, snapshot := range snapshots {
+		total += snapshot.Size
+		if i == 0 {
+			continue
+		}
+		expired := (policy.KeepLast > 0 && i >= policy.KeepLast) ||
+			(policy.MaxAge > 0 && now.Sub(snapshot.Modified) > policy.MaxAge) ||
+			(policy.MaxTotalSize > 0 && total > policy.MaxTotalSize)
+		if !expired || (keep != nil && keep(snapshot.Name)) {
+			continue
+		}
+		for _, file := range snapshot.Files {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return removed, fmt.Errorf("删除快照 %s 失败: %w", snapshot.Name, err)
+			}
+		}
+		total -= snapshot.Size
+		removed = append(removed, snapshot)
+	}
+	return removed, nil
+}
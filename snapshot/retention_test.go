@@ -0,0 +1,80 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	writeSnapshot := func(dir string, day int, files map[string]int) {
+		name := time.Date(2024, 3, day, 0, 0, 0, 0, time.Local).Format("logsnap_20060102_150405_20060102_150405")
+		for suffix, size := range files {
+			path := filepath.Join(dir, name+suffix)
+			require.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+			modified := time.Date(2024, 3, day, 1, 0, 0, 0, time.Local)
+			require.NoError(t, os.Chtimes(path, modified, modified))
+		}
+	}
+	setup := func() string {
+		dir := t.TempDir()
+		// 分卷快照的所有文件属于同一个快照
+		writeSnapshot(dir, 1, map[string]int{".part001.zip": 400, ".part002.zip": 400, ".index.json": 10})
+		writeSnapshot(dir, 5, map[string]int{".zip.enc": 300, ".zip.enc.sha256": 10})
+		writeSnapshot(dir, 8, map[string]int{".zip": 200, ".zip.sha256": 10})
+		writeSnapshot(dir, 9, map[string]int{".zip": 200})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0644))
+		return dir
+	}
+
+	snapshots, err := ListLocal(setup())
+	require.NoError(t, err)
+	require.Len(t, snapshots, 4)
+	assert.Equal(t, "logsnap_20240309_000000_20240309_000000", snapshots[0].Name)
+	assert.Equal(t, int64(810), snapshots[3].Size)
+	assert.Len(t, snapshots[3].Files, 3)
+
+	tests := []struct {
+		name    string
+		policy  Retention
+		removed []string
+	}{
+		{"不限制", Retention{}, nil},
+		{"保留数量", Retention{KeepLast: 2}, []string{"logsnap_20240305_000000_20240305_000000", "logsnap_20240301_000000_20240301_000000"}},
+		{"保留时间", Retention{MaxAge: 4 * 24 * time.Hour}, []string{"logsnap_20240305_000000_20240305_000000", "logsnap_20240301_000000_20240301_000000"}},
+		{"总大小", Retention{MaxTotalSize: 800}, []string{"logsnap_20240301_000000_20240301_000000"}},
+		{"最新的快照总是保留", Retention{MaxTotalSize: 1}, []string{"logsnap_20240308_000000_20240308_000000", "logsnap_20240305_000000_20240305_000000", "logsnap_20240301_000000_20240301_000000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setup()
+			removed, err := ApplyRetention(dir, tt.policy, now, nil)
+			require.NoError(t, err)
+			var names []string
+			for _, snapshot := range removed {
+				names = append(names, snapshot.Name)
+				for _, file := range snapshot.Files {
+					assert.NoFileExists(t, file)
+				}
+			}
+			assert.Equal(t, tt.removed, names)
+			assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+		})
+	}
+
+	// 仍在上传队列中的快照不删除
+	dir := setup()
+	removed, err := ApplyRetention(dir, Retention{KeepLast: 1}, now, func(name string) bool {
+		return name == "logsnap_20240305_000000_20240305_000000"
+	})
+	require.NoError(t, err)
+	require.Len(t, removed, 2)
+	assert.Equal(t, "logsnap_20240308_000000_20240308_000000", removed[0].Name)
+	assert.Equal(t, "logsnap_20240301_000000_20240301_000000", removed[1].Name)
+	assert.FileExists(t, filepath.Join(dir, "logsnap_20240305_000000_20240305_000000.zip.enc"))
+}
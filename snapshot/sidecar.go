@@ -0,0 +1,223 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SidecarSuffix 校验文件的后缀，校验文件与快照放在同一目录
+	SidecarSuffix = ".sha256"
+	// SigningKeyPrefix 签名私钥字符串前缀
+	SigningKeyPrefix = "LOGSNAP-SIGN-KEY-"
+	// VerifyKeyPrefix 签名公钥字符串前缀
+	VerifyKeyPrefix = "logsnap-sig-"
+
+	sidecarHeader   = "# logsnap snapshot checksums"
+	signaturePrefix = "# signature: ed25519 "
+)
+
+// Sidecar 快照校验文件，格式与 sha256sum 兼容，可选附带 Ed25519 签名
+//
+//	# logsnap snapshot checksums
+//	<sha256>  <文件名>
+//	# signature: ed25519 <公钥> <签名>
+//
+// 签名覆盖签名行之前的全部内容
+type Sidecar struct {
+	Checksums []Checksum        // 快照文件的校验和
+	Signer    ed25519.PublicKey // 签名公钥，未签名时为空
+	signed    []byte            // 被签名的内容
+	signature []byte            // 签名
+}
+
+// Checksum 单个文件的校验和
+type Checksum struct {
+	Name   string // 文件名，相对于校验文件所在目录
+	SHA256 string // 十六进制 SHA-256
+}
+
+// SidecarPath 返回快照对应的校验文件路径
+func SidecarPath(snapPath string) string {
+	return snapPath + SidecarSuffix
+}
+
+// WriteSidecar 计算 files 的 SHA-256 并写入校验文件，signKey 不为空时附带签名
+func WriteSidecar(path string, files []string, signKey ed25519.PrivateKey) error {
+	var content bytes.Buffer
+	content.WriteString(sidecarHeader + "\n")
+	for _, file := range files {
+		sum, err := FileSHA256(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&content, "%s  %s\n", sum, filepath.Base(file))
+	}
+
+	if signKey != nil {
+		signature := ed25519.Sign(signKey, content.Bytes())
+		fmt.Fprintf(&content, "%s%s %s\n", signaturePrefix,
+			encodeVerifyKey(signKey.Public().(ed25519.PublicKey)),
+			base64.StdEncoding.EncodeToString(signature))
+	}
+
+	if err := os.WriteFile(path, content.Bytes(), 0644); err != nil {
+		return fmt.Errorf("写入校验文件失败: %w", err)
+	}
+	return nil
+}
+
+// ReadSidecar 读取校验文件
+func ReadSidecar(path string) (*Sidecar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取校验文件失败: %w", err)
+	}
+
+	sidecar := &Sidecar{}
+	offset := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineStart := offset
+		offset += len(line) + 1
+
+		switch {
+		case strings.HasPrefix(line, signaturePrefix):
+			fields := strings.Fields(strings.TrimPrefix(line, signaturePrefix))
+			if len(fields) != 2 {
+				return nil, errors.New("校验文件签名格式错误")
+			}
+			signer, err := ParseVerifyKey(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			signature, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("校验文件签名格式错误: %w", err)
+			}
+			sidecar.Signer = signer
+			sidecar.signature = signature
+			sidecar.signed = data[:lineStart]
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		default:
+			if sidecar.Signer != nil {
+				return nil, errors.New("校验文件签名之后不应有其他内容")
+			}
+			fields := strings.SplitN(line, "  ", 2)
+			if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+				return nil, fmt.Errorf("校验文件格式错误: %s", line)
+			}
+			sidecar.Checksums = append(sidecar.Checksums, Checksum{
+				Name:   strings.TrimPrefix(fields[1], "*"),
+				SHA256: strings.ToLower(fields[0]),
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取校验文件失败: %w", err)
+	}
+	return sidecar, nil
+}
+
+// Signed 返回校验文件是否带有签名
+func (s *Sidecar) Signed() bool {
+	return s.Signer != nil
+}
+
+// VerifySignature 验证签名本身是否有效，不检查签名者是否可信
+func (s *Sidecar) VerifySignature() bool {
+	return s.Signer != nil && ed25519.Verify(s.Signer, s.signed, s.signature)
+}
+
+// FileSHA256 计算文件的 SHA-256
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GenerateSigningKey 生成新的签名密钥
+func GenerateSigningKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成签名密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// EncodeSigningKey 返回签名私钥字符串
+func EncodeSigningKey(key ed25519.PrivateKey) string {
+	return SigningKeyPrefix + base64.RawURLEncoding.EncodeToString(key.Seed())
+}
+
+// ParseSigningKey 解析签名私钥字符串
+func ParseSigningKey(s string) (ed25519.PrivateKey, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, SigningKeyPrefix) {
+		return nil, fmt.Errorf("无效的签名私钥: 缺少前缀 %s", SigningKeyPrefix)
+	}
+	seed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, SigningKeyPrefix))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("无效的签名私钥")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// LoadSigningKey 从密钥文件读取签名私钥，以 # 开头的行视为注释
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取签名密钥文件失败: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return ParseSigningKey(line)
+	}
+	return nil, fmt.Errorf("密钥文件中没有签名私钥: %s", path)
+}
+
+// EncodeVerifyKey 返回签名私钥对应的公钥字符串
+func EncodeVerifyKey(key ed25519.PrivateKey) string {
+	return encodeVerifyKey(key.Public().(ed25519.PublicKey))
+}
+
+// ParseVerifyKey 解析签名公钥字符串
+func ParseVerifyKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, VerifyKeyPrefix) {
+		return nil, fmt.Errorf("无效的签名公钥: 缺少前缀 %s", VerifyKeyPrefix)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, VerifyKeyPrefix))
+	if err != nil || len(data) != ed25519.PublicKeySize {
+		return nil, errors.New("无效的签名公钥")
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+func encodeVerifyKey(key ed25519.PublicKey) string {
+	return VerifyKeyPrefix + base64.RawURLEncoding.EncodeToString(key)
+}
@@ -0,0 +1,359 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"logsnap/encryption"
+)
+
+// VerifyOptions 快照校验选项
+type VerifyOptions struct {
+	Identity    *encryption.Identity // 解密加密快照使用的私钥，为空时跳过加密快照的内容校验
+	TrustedKeys []ed25519.PublicKey  // 可信的签名公钥，不为空时要求校验文件由其中之一签名
+}
+
+// VerifyCheck 单项校验结果
+type VerifyCheck struct {
+	Name    string // 校验项
+	OK      bool   // 是否通过
+	Skipped bool   // 是否跳过
+	Message string // 说明
+}
+
+// VerifyReport 快照校验报告
+type VerifyReport struct {
+	Checks []VerifyCheck
+}
+
+// OK 返回是否所有校验项都通过（跳过的校验项不算失败）
+func (r *VerifyReport) OK() bool {
+	for _, check := range r.Checks {
+		if !check.OK && !check.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *VerifyReport) pass(name, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, VerifyCheck{Name: name, OK: true, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *VerifyReport) fail(name, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, VerifyCheck{Name: name, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *VerifyReport) skip(name, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, VerifyCheck{Name: name, Skipped: true, Message: fmt.Sprintf(format, args...)})
+}
+
+// Verify 校验快照的完整性
+// 依次检查校验文件（及其签名）、每个ZIP条目的CRC，以及清单中记录的每个文件的 SHA-256。
+// snapPath 可以是快照ZIP文件、加密快照或分卷索引文件。
+// 返回的 error 仅表示无法进行校验，校验结果见 VerifyReport
+func Verify(snapPath string, opts VerifyOptions) (*VerifyReport, error) {
+	if _, err := os.Stat(snapPath); err != nil {
+		return nil, fmt.Errorf("快照不存在或无法访问: %w", err)
+	}
+
+	report := &VerifyReport{}
+	verifySidecar(report, snapPath, opts)
+
+	volumes, err := snapshotVolumes(snapPath, opts.Identity)
+	if err != nil {
+		report.fail("分卷索引", "%v", err)
+		return report, nil
+	}
+	for _, volume := range volumes {
+		verifyVolume(report, volume, opts.Identity)
+	}
+	return report, nil
+}
+
+// verifySidecar 检查校验文件中记录的文件哈希和签名
+func verifySidecar(report *VerifyReport, snapPath string, opts VerifyOptions) {
+	sidecarPath := SidecarPath(snapPath)
+	if _, err := os.Stat(sidecarPath); os.IsNotExist(err) {
+		report.fail("校验文件", "未找到校验文件 %s", filepath.Base(sidecarPath))
+		return
+	}
+
+	sidecar, err := ReadSidecar(sidecarPath)
+	if err != nil {
+		report.fail("校验文件", "%v", err)
+		return
+	}
+
+	switch {
+	case sidecar.Signed() && !sidecar.VerifySignature():
+		report.fail("签名", "签名无效，校验文件可能被篡改")
+	case sidecar.Signed() && len(opts.TrustedKeys) > 0 && !isTrusted(sidecar.Signer, opts.TrustedKeys):
+		report.fail("签名", "签名有效，但签名者 %s 不在可信公钥中", encodeVerifyKey(sidecar.Signer))
+	case sidecar.Signed() && len(opts.TrustedKeys) > 0:
+		report.pass("签名", "签名有效，签名者 %s", encodeVerifyKey(sidecar.Signer))
+	case sidecar.Signed():
+		// 签名者公钥来自校验文件本身，篡改者可以用自己的密钥重新签名，未指定可信公钥时无法确认来源
+		report.skip("签名", "由不可信的公钥 %s 签名，使用 --trusted-key 指定可信公钥后才能确认签名者", encodeVerifyKey(sidecar.Signer))
+	case len(opts.TrustedKeys) > 0:
+		report.fail("签名", "校验文件未签名")
+	default:
+		report.skip("签名", "校验文件未签名")
+	}
+
+	dir := filepath.Dir(snapPath)
+	for _, checksum := range sidecar.Checksums {
+		name := "文件 " + checksum.Name
+		if err := checkLocalName(checksum.Name); err != nil {
+			report.fail(name, "%v", err)
+			continue
+		}
+		sum, err := FileSHA256(filepath.Join(dir, checksum.Name))
+		if err != nil {
+			report.fail(name, "%v", err)
+		} else if sum != checksum.SHA256 {
+			report.fail(name, "SHA-256 不匹配: 期望 %s, 实际 %s", checksum.SHA256, sum)
+		} else {
+			report.pass(name, "SHA-256 一致")
+		}
+	}
+}
+
+// checkLocalName 检查校验文件和分卷索引中记录的文件名，只允许快照所在目录中的文件，
+// 避免被篡改的校验文件读取快照目录之外的文件
+func checkLocalName(name string) error {
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("文件名 %q 不在快照所在目录中", name)
+	}
+	return nil
+}
+
+func isTrusted(signer ed25519.PublicKey, trusted []ed25519.PublicKey) bool {
+	for _, key := range trusted {
+		if signer.Equal(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotVolumes 返回快照的所有ZIP分卷路径
+func snapshotVolumes(snapPath string, identity *encryption.Identity) ([]string, error) {
+	if !strings.HasSuffix(strings.TrimSuffix(snapPath, encryption.FileExtension), IndexFileSuffix) {
+		return []string{snapPath}, nil
+	}
+
+	data, err := readMaybeEncrypted(snapPath, identity)
+	if err != nil {
+		return nil, err
+	}
+	index, err := ParseIndex(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var volumes []string
+	for _, volume := range index.Volumes {
+		if err := checkLocalName(volume.Name); err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, filepath.Join(filepath.Dir(snapPath), volume.Name))
+	}
+	return volumes, nil
+}
+
+// readMaybeEncrypted 读取文件内容，文件加密时使用私钥解密
+func readMaybeEncrypted(filePath string, identity *encryption.Identity) ([]byte, error) {
+	encrypted, err := encryption.IsEncrypted(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return os.ReadFile(filePath)
+	}
+	if identity == nil {
+		return nil, fmt.Errorf("%s 已加密，需要提供私钥", filepath.Base(filePath))
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := encryption.NewReader(file, identity)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// decryptToTemp 将加密的分卷流式解密到分卷所在目录中的隐藏临时文件，返回临时文件路径，调用方负责删除
+// 临时文件不放在系统临时目录，避免明文快照留在可能空间不足、也不受快照目录权限保护的 /tmp 中
+func decryptToTemp(volumePath string, identity *encryption.Identity) (string, error) {
+	in, err := os.Open(volumePath)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer in.Close()
+	reader, err := encryption.NewReader(in, identity)
+	if err != nil {
+		return "", err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(volumePath), "."+filepath.Base(volumePath)+".*.zip")
+	if err != nil {
+		return "", fmt.Errorf("在快照所在目录创建解密临时文件失败: %w", err)
+	}
+	_, err = io.Copy(temp, reader)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", fmt.Errorf("解密文件失败: %w", err)
+	}
+	return temp.Name(), nil
+}
+
+// verifyVolume 检查单个分卷中每个条目的CRC和清单中的哈希
+func verifyVolume(report *VerifyReport, volumePath string, identity *encryption.Identity) {
+	name := "分卷 " + filepath.Base(volumePath)
+
+	encrypted, err := encryption.IsEncrypted(volumePath)
+	if err != nil {
+		report.fail(name, "%v", err)
+		return
+	}
+	if encrypted {
+		if identity == nil {
+			report.skip(name, "快照已加密，未提供私钥，跳过内容校验")
+			return
+		}
+		decrypted, err := decryptToTemp(volumePath, identity)
+		if err != nil {
+			report.fail(name, "%v", err)
+			return
+		}
+		defer os.Remove(decrypted)
+		volumePath = decrypted
+	}
+
+	reader, err := zip.OpenReader(volumePath)
+	if err != nil {
+		report.fail(name, "无法打开ZIP文件: %v", err)
+		return
+	}
+	defer reader.Close()
+
+	manifest, err := readManifest(&reader.Reader)
+	if err != nil {
+		report.fail(name, "%v", err)
+		return
+	}
+
+	expected := make(map[string]string)
+	if manifest != nil {
+		for _, file := range manifest.Files {
+			expected[file.Name] = file.SHA256
+		}
+	}
+
+	failures := 0
+	matched := 0
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || isManifest(file.Name) {
+			continue
+		}
+		sum, err := entrySHA256(file)
+		if err != nil {
+			failures++
+			if errors.Is(err, zip.ErrChecksum) {
+				report.fail(name, "%s: CRC 校验失败", file.Name)
+			} else {
+				report.fail(name, "%s: %v", file.Name, err)
+			}
+			continue
+		}
+
+		want, listed := expected[file.Name]
+		delete(expected, file.Name)
+		if manifest == nil || !listed || want == "" {
+			continue
+		}
+		if want != sum {
+			failures++
+			report.fail(name, "%s: SHA-256 与清单不一致", file.Name)
+			continue
+		}
+		matched++
+	}
+	for missing := range expected {
+		failures++
+		report.fail(name, "%s: 清单中的文件不存在", missing)
+	}
+
+	if failures > 0 {
+		return
+	}
+	if manifest == nil {
+		report.pass(name, "%d 个条目 CRC 校验通过（快照中没有清单，未校验文件哈希）", len(reader.File))
+		return
+	}
+	report.pass(name, "%d 个条目 CRC 校验通过，%d 个文件与清单哈希一致", len(reader.File), matched)
+}
+
+// readManifest 读取ZIP中的快照清单，没有清单时返回 nil
+func readManifest(reader *zip.Reader) (*Manifest, error) {
+	for _, file := range reader.File {
+		if !isManifest(file.Name) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("打开清单失败: %w", err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取清单失败: %w", err)
+		}
+
+		var manifest Manifest
+		if err := json.NewDecoder(bytes.NewReader(data)).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("解析清单失败: %w", err)
+		}
+		return &manifest, nil
+	}
+	return nil, nil
+}
+
+// isManifest 判断条目是否为快照清单，清单位于快照目录的顶层
+func isManifest(name string) bool {
+	return path.Base(name) == ManifestFileName && strings.Count(strings.Trim(name, "/"), "/") <= 1
+}
+
+// entrySHA256 读取ZIP条目的全部内容并计算 SHA-256，读取结束时 archive/zip 会校验CRC
+func entrySHA256(file *zip.File) (string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,211 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"logsnap/encryption"
+)
+
+// writeTestSnapshot 生成包含一个日志文件和清单的快照ZIP
+func writeTestSnapshot(t *testing.T, dir string, content string) string {
+	t.Helper()
+	snapPath := filepath.Join(dir, "logsnap_test.zip")
+	file, err := os.Create(snapPath)
+	if err != nil {
+		t.Fatalf("创建快照失败: %v", err)
+	}
+	defer file.Close()
+
+	zw := zip.NewWriter(file)
+	w, _ := zw.Create("logsnap_test/xyz_hmi/app.log")
+	w.Write([]byte(content))
+
+	sum := sha256.Sum256([]byte(content))
+	manifest := Manifest{Files: []ManifestFile{{
+		Name:   "logsnap_test/xyz_hmi/app.log",
+		Size:   uint64(len(content)),
+		SHA256: hex.EncodeToString(sum[:]),
+	}}}
+	data, _ := json.Marshal(manifest)
+	w, _ = zw.Create("logsnap_test/" + ManifestFileName)
+	w.Write(data)
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("写入快照失败: %v", err)
+	}
+	return snapPath
+}
+
+func TestVerify_ValidSignedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	snapPath := writeTestSnapshot(t, dir, strings.Repeat("log line\n", 100))
+	key, _ := GenerateSigningKey()
+	if err := WriteSidecar(SidecarPath(snapPath), []string{snapPath}, key); err != nil {
+		t.Fatalf("写入校验文件失败: %v", err)
+	}
+
+	trusted := []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}
+	report, err := Verify(snapPath, VerifyOptions{TrustedKeys: trusted})
+	if err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("完整的快照应校验通过: %+v", report.Checks)
+	}
+
+	// 签名者不在可信公钥中时校验失败
+	other, _ := GenerateSigningKey()
+	report, _ = Verify(snapPath, VerifyOptions{TrustedKeys: []ed25519.PublicKey{other.Public().(ed25519.PublicKey)}})
+	if report.OK() {
+		t.Fatalf("签名者不可信时应校验失败")
+	}
+
+	// 未指定可信公钥时不能把校验文件中自带的公钥当作可信
+	report, _ = Verify(snapPath, VerifyOptions{})
+	for _, check := range report.Checks {
+		if check.Name == "签名" && (check.OK || !strings.Contains(check.Message, "不可信")) {
+			t.Fatalf("未指定可信公钥时应报告签名者不可信: %+v", check)
+		}
+	}
+}
+
+func TestVerify_RejectsNamesOutsideSnapshotDir(t *testing.T) {
+	dir := t.TempDir()
+	snapPath := writeTestSnapshot(t, dir, "line\n")
+	outside := filepath.Join(dir, "secret.txt")
+	os.WriteFile(outside, []byte("secret"), 0644)
+	sum, _ := FileSHA256(outside)
+
+	sub := filepath.Join(dir, "snapshots")
+	os.Mkdir(sub, 0755)
+	moved := filepath.Join(sub, filepath.Base(snapPath))
+	os.Rename(snapPath, moved)
+	content := sum + "  ../secret.txt\n" + sum + "  " + outside + "\n"
+	os.WriteFile(SidecarPath(moved), []byte(content), 0644)
+
+	report, err := Verify(moved, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	failed := 0
+	for _, check := range report.Checks {
+		if strings.HasPrefix(check.Name, "文件 ") {
+			if check.OK || !strings.Contains(check.Message, "不在快照所在目录中") {
+				t.Fatalf("应拒绝快照目录之外的文件: %+v", check)
+			}
+			failed++
+		}
+	}
+	if failed != 2 {
+		t.Fatalf("应拒绝 2 个文件, 实际 %d 个: %+v", failed, report.Checks)
+	}
+}
+
+func TestVerify_DetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	snapPath := writeTestSnapshot(t, dir, strings.Repeat("log line\n", 100))
+	if err := WriteSidecar(SidecarPath(snapPath), []string{snapPath}, nil); err != nil {
+		t.Fatalf("写入校验文件失败: %v", err)
+	}
+
+	// 修改压缩数据中的一个字节，模拟传输过程中的损坏
+	data, _ := os.ReadFile(snapPath)
+	offset := strings.Index(string(data), "app.log") + len("app.log") + 5
+	data[offset] ^= 0xff
+	os.WriteFile(snapPath, data, 0644)
+
+	report, err := Verify(snapPath, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	if report.OK() {
+		t.Fatalf("损坏的快照应校验失败")
+	}
+
+	var sidecarFailed bool
+	for _, check := range report.Checks {
+		if !check.OK && strings.Contains(check.Message, "SHA-256 不匹配") {
+			sidecarFailed = true
+		}
+	}
+	if !sidecarFailed {
+		t.Fatalf("应报告校验文件中的哈希不匹配: %+v", report.Checks)
+	}
+}
+
+func TestReadSidecar_RejectsTamperedSignature(t *testing.T) {
+	dir := t.TempDir()
+	snapPath := writeTestSnapshot(t, dir, "line\n")
+	key, _ := GenerateSigningKey()
+	sidecarPath := SidecarPath(snapPath)
+	WriteSidecar(sidecarPath, []string{snapPath}, key)
+
+	// 修改校验和后签名应失效
+	data, _ := os.ReadFile(sidecarPath)
+	lines := strings.Split(string(data), "\n")
+	lines[1] = strings.Repeat("0", 64) + lines[1][64:]
+	os.WriteFile(sidecarPath, []byte(strings.Join(lines, "\n")), 0644)
+
+	sidecar, err := ReadSidecar(sidecarPath)
+	if err != nil {
+		t.Fatalf("读取校验文件失败: %v", err)
+	}
+	if !sidecar.Signed() || sidecar.VerifySignature() {
+		t.Fatalf("被修改的校验文件签名应无效")
+	}
+}
+
+func TestVerify_EncryptedSnapshotDecryptsNextToInput(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("log line\n", 100)
+	plain := writeTestSnapshot(t, dir, content)
+	identity, _ := encryption.GenerateIdentity()
+	snapPath := plain + encryption.FileExtension
+	if err := encryption.EncryptFile(plain, snapPath, []*encryption.Recipient{identity.Recipient()}); err != nil {
+		t.Fatalf("加密快照失败: %v", err)
+	}
+	os.Remove(plain)
+	WriteSidecar(SidecarPath(snapPath), []string{snapPath}, nil)
+
+	// 解密的明文不写入系统临时目录
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	report, err := Verify(snapPath, VerifyOptions{Identity: identity})
+	if err != nil || !report.OK() {
+		t.Fatalf("加密快照应校验通过: %v %+v", err, report)
+	}
+	err = Walk(snapPath, identity, func(name, program string, r io.Reader) error {
+		data, _ := io.ReadAll(r)
+		if string(data) != content {
+			t.Fatalf("解密内容不正确")
+		}
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), "."+filepath.Base(snapPath)) {
+				return nil
+			}
+		}
+		t.Fatalf("解密临时文件应位于快照所在目录")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("读取加密快照失败: %v", err)
+	}
+
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Fatalf("系统临时目录中不应有解密文件: %v", entries)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("解密临时文件应在使用后删除: %v", entries)
+	}
+}
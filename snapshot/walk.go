@@ -0,0 +1,168 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"logsnap/encryption"
+)
+
+// WalkFunc 处理快照中的单个文件
+// name 为相对快照目录的路径，program 为产生该文件的程序，快照目录顶层的文件为空
+type WalkFunc func(name, program string, r io.Reader) error
+
+// Walk 依次读取快照中除清单以外的每个文件
+// snapPath 可以是任意已注册格式的快照文件、加密快照、分卷索引文件，也可以是解压后的快照目录或普通的日志目录
+func Walk(snapPath string, identity *encryption.Identity, fn WalkFunc) error {
+	info, err := os.Stat(snapPath)
+	if err != nil {
+		return fmt.Errorf("快照不存在或无法访问: %w", err)
+	}
+	if info.IsDir() {
+		return walkDir(snapPath, fn)
+	}
+
+	volumes, err := snapshotVolumes(snapPath, identity)
+	if err != nil {
+		return err
+	}
+	for _, volume := range volumes {
+		if err := walkVolume(volume, identity, fn); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(volume), err)
+		}
+	}
+	return nil
+}
+
+// walkVolume 读取单个分卷中的文件
+func walkVolume(volumePath string, identity *encryption.Identity, fn WalkFunc) error {
+	archive, _, _, err := openVolume(volumePath, identity)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	files := archive.Files()
+	manifest, err := readArchiveManifest(archive, files)
+	if err != nil {
+		return err
+	}
+	programs := manifestPrograms(manifest)
+
+	for _, file := range files {
+		if isManifest(file.Name) {
+			continue
+		}
+		rc, err := archive.Open(file.Name)
+		if err != nil {
+			return fmt.Errorf("打开 %s 失败: %w", file.Name, err)
+		}
+		rel := relativeName(file.Name)
+		err = fn(rel, programOf(programs, file.Name, rel), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkDir 读取目录中的文件，目录中有快照清单时按清单确定每个文件的程序
+func walkDir(dir string, fn WalkFunc) error {
+	var manifest *Manifest
+	if data, err := os.ReadFile(filepath.Join(dir, ManifestFileName)); err == nil {
+		manifest = &Manifest{}
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return fmt.Errorf("解析清单失败: %w", err)
+		}
+	}
+	programs := make(map[string]string)
+	for name, program := range manifestPrograms(manifest) {
+		programs[relativeName(name)] = program
+	}
+
+	return filepath.WalkDir(dir, func(filePath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ManifestFileName {
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("打开 %s 失败: %w", rel, err)
+		}
+		defer file.Close()
+		return fn(rel, programOf(programs, rel, rel), file)
+	})
+}
+
+// manifestPrograms 返回清单中每个文件对应的程序
+func manifestPrograms(manifest *Manifest) map[string]string {
+	programs := make(map[string]string)
+	if manifest != nil {
+		for _, file := range manifest.Files {
+			programs[file.Name] = file.Program
+		}
+	}
+	return programs
+}
+
+// programOf 返回文件的程序，清单中没有记录时以快照目录下的第一级目录作为程序
+// name 为查找清单使用的名称，rel 为相对快照目录的路径
+func programOf(programs map[string]string, name, rel string) string {
+	if program := programs[name]; program != "" {
+		return program
+	}
+	return programFromPath(rel)
+}
+
+// openVolume 打开单个分卷，加密的分卷先解密到分卷所在目录中的临时文件，临时文件在归档关闭时删除
+func openVolume(volumePath string, identity *encryption.Identity) (Archive, string, bool, error) {
+	encrypted, err := encryption.IsEncrypted(volumePath)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if !encrypted {
+		archive, format, err := OpenArchive(volumePath)
+		return archive, format, false, err
+	}
+
+	if identity == nil {
+		return nil, "", true, fmt.Errorf("快照已加密，需要提供私钥")
+	}
+	decrypted, err := decryptToTemp(volumePath, identity)
+	if err != nil {
+		return nil, "", true, err
+	}
+	archive, format, err := OpenArchive(decrypted)
+	if err != nil {
+		os.Remove(decrypted)
+		return nil, "", true, err
+	}
+	return &tempArchive{Archive: archive, path: decrypted}, format, true, nil
+}
+
+// tempArchive 关闭时删除解密生成的临时文件
+type tempArchive struct {
+	Archive
+	path string
+}
+
+func (a *tempArchive) Close() error {
+	err := a.Archive.Close()
+	os.Remove(a.path)
+	return err
+}
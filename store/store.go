@@ -0,0 +1,283 @@
+// Package store 记录本机收集的快照：时间范围、程序、大小、上传状态和分享链接
+// 快照文件可以在任意目录，存储目录中的 snapshots.json 记录每个快照的信息；
+// 上传后需要保留的本地快照默认保存在存储目录中
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexFileName 存储目录中记录快照信息的文件名
+const IndexFileName = "snapshots.json"
+
+// 上传状态
+const (
+	UploadNone    = ""         // 未上传
+	UploadDone    = "uploaded" // 已上传
+	UploadFailed  = "failed"   // 上传失败
+	UploadPending = "pending"  // 上传失败，已加入上传队列等待重试
+)
+
+// ErrNotFound 存储中没有匹配的快照
+var ErrNotFound = errors.New("未找到快照")
+
+// Entry 一个快照的记录
+type Entry struct {
+	ID        string    `json:"id"`                // 快照名称，例如 logsnap_20240301_100000_20240301_110000
+	Path      string    `json:"path"`              // 快照主文件路径，分卷时为索引文件
+	Files     []string  `json:"files"`             // 快照的所有文件（分卷、索引、校验文件）
+	Start     time.Time `json:"start"`             // 收集的开始时间
+	End       time.Time `json:"end"`               // 收集的结束时间
+	Programs  []string  `json:"programs"`          // 收集的程序
+	Size      int64     `json:"size"`              // 所有文件的总大小
+	Encrypted bool      `json:"encrypted"`         // 是否加密
+	CreatedAt time.Time `json:"created_at"`        // 收集完成的时间
+	Upload    Upload    `json:"upload"`            // 上传状态
+	Source    string    `json:"source,omitempty"`  // 触发收集的来源，例如 collect、watch、schedule:hourly
+	Deleted   bool      `json:"deleted,omitempty"` // 本地文件已删除（上传后删除或被清理）
+}
+
+// Upload 快照的上传状态
+type Upload struct {
+	Status     string     `json:"status,omitempty"`      // 上传状态，UploadNone、UploadDone、UploadFailed 或 UploadPending
+	URLs       []string   `json:"urls,omitempty"`        // 分享链接，每个文件一个
+	Error      string     `json:"error,omitempty"`       // 最后一次上传失败的原因
+	UploadedAt *time.Time `json:"uploaded_at,omitempty"` // 上传完成的时间
+}
+
+// Local 返回快照的本地文件是否还存在
+func (e *Entry) Local() bool {
+	if e.Deleted {
+		return false
+	}
+	_, err := os.Stat(e.Path)
+	return err == nil
+}
+
+// Store 快照存储
+type Store struct {
+	dir string
+	mu  *sync.Mutex
+}
+
+// locks 每个存储目录一把锁，同一进程中多次打开同一个存储不会相互覆盖记录
+var locks sync.Map
+
+// DefaultDir 返回配置目录中的默认存储目录
+func DefaultDir(configDir string) string {
+	return filepath.Join(configDir, "snapshots")
+}
+
+// Open 打开存储目录，目录不存在时创建
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建快照存储目录失败: %w", err)
+	}
+	mu, _ := locks.LoadOrStore(filepath.Clean(dir), &sync.Mutex{})
+	return &Store{dir: dir, mu: mu.(*sync.Mutex)}, nil
+}
+
+// Dir 返回存储目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// List 返回所有快照，按创建时间从新到旧排序
+func (s *Store) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Get 返回 ID 或 ID 前缀匹配的快照，前缀匹配多个快照时返回错误
+func (s *Store) Get(id string) (*Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var matched []Entry
+	for _, entry := range entries {
+		if entry.ID == id {
+			return &entry, nil
+		}
+		if strings.HasPrefix(entry.ID, id) {
+			matched = append(matched, entry)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 1:
+		return &matched[0], nil
+	}
+	return nil, fmt.Errorf("%s 匹配多个快照，请指定完整的快照名称", id)
+}
+
+// Add 添加快照记录，ID 相同的记录被替换
+func (s *Store) Add(entry Entry) error {
+	return s.modify(func(entries []Entry) []Entry {
+		for i := range entries {
+			if entries[i].ID == entry.ID {
+				entries[i] = entry
+				return entries
+			}
+		}
+		return append(entries, entry)
+	})
+}
+
+// Update 修改快照记录
+func (s *Store) Update(id string, fn func(*Entry)) error {
+	found := false
+	err := s.modify(func(entries []Entry) []Entry {
+		for i := range entries {
+			if entries[i].ID == id {
+				fn(&entries[i])
+				found = true
+			}
+		}
+		return entries
+	})
+	if err == nil && !found {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return err
+}
+
+// Remove 删除快照的本地文件和记录
+func (s *Store) Remove(id string) error {
+	var removeErr error
+	found := false
+	err := s.modify(func(entries []Entry) []Entry {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.ID != id {
+				kept = append(kept, entry)
+				continue
+			}
+			found = true
+			if err := removeFiles(entry); err != nil {
+				removeErr = err
+				kept = append(kept, entry)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return removeErr
+}
+
+// Prune 删除创建时间早于 before 的快照的本地文件和记录，dryRun 为 true 时只返回将被删除的快照
+func (s *Store) Prune(before time.Time, dryRun bool) ([]Entry, error) {
+	var pruned []Entry
+	var pruneErr error
+	err := s.modify(func(entries []Entry) []Entry {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if !entry.CreatedAt.Before(before) {
+				kept = append(kept, entry)
+				continue
+			}
+			if !dryRun {
+				if err := removeFiles(entry); err != nil {
+					pruneErr = err
+					kept = append(kept, entry)
+					continue
+				}
+			}
+			pruned = append(pruned, entry)
+		}
+		if dryRun {
+			return nil
+		}
+		return kept
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pruned, pruneErr
+}
+
+// removeFiles 删除快照的本地文件
+func removeFiles(entry Entry) error {
+	for _, file := range entry.Files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除快照 %s 的文件失败: %w", entry.ID, err)
+		}
+	}
+	return nil
+}
+
+// modify 读取记录，由 fn 修改后写回；fn 返回 nil 时不写回
+func (s *Store) modify(fn func([]Entry) []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	entries = fn(entries)
+	if entries == nil {
+		return nil
+	}
+	return s.save(entries)
+}
+
+// load 读取记录文件，文件不存在时返回空列表
+func (s *Store) load() ([]Entry, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, IndexFileName))
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取快照记录失败: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析快照记录 %s 失败: %w", filepath.Join(s.dir, IndexFileName), err)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+	if entries == nil {
+		entries = []Entry{}
+	}
+	return entries, nil
+}
+
+// save 写入记录文件，先写临时文件再改名，避免中断时损坏记录
+func (s *Store) save(entries []Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化快照记录失败: %w", err)
+	}
+	temp, err := os.CreateTemp(s.dir, IndexFileName+".*")
+	if err != nil {
+		return fmt.Errorf("写入快照记录失败: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("写入快照记录失败: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("写入快照记录失败: %w", err)
+	}
+	if err := os.Rename(temp.Name(), filepath.Join(s.dir, IndexFileName)); err != nil {
+		return fmt.Errorf("写入快照记录失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "snapshots"))
+	require.NoError(t, err)
+
+	entries, err := s.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	addSnapshot := func(id string, created time.Time) Entry {
+		path := filepath.Join(s.Dir(), id+".zip")
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+		require.NoError(t, os.WriteFile(path+".sha256", []byte("sum"), 0644))
+		entry := Entry{
+			ID:        id,
+			Path:      path,
+			Files:     []string{path, path + ".sha256"},
+			Programs:  []string{"xyz-studio-max"},
+			Size:      7,
+			CreatedAt: created,
+		}
+		require.NoError(t, s.Add(entry))
+		return entry
+	}
+	old := addSnapshot("logsnap_20240301_100000_20240301_110000", now.Add(-9*24*time.Hour))
+	addSnapshot("logsnap_20240309_100000_20240309_110000", now.Add(-24*time.Hour))
+	addSnapshot("logsnap_20240310_100000_20240310_110000", now)
+
+	// 按创建时间从新到旧排序
+	entries, err = s.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "logsnap_20240310_100000_20240310_110000", entries[0].ID)
+	assert.Equal(t, old.ID, entries[2].ID)
+
+	// ID 相同的记录被替换
+	old.Upload = Upload{Status: UploadFailed, Error: "timeout"}
+	require.NoError(t, s.Add(old))
+	entries, err = s.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// 前缀匹配
+	entry, err := s.Get("logsnap_20240301")
+	require.NoError(t, err)
+	assert.Equal(t, UploadFailed, entry.Upload.Status)
+	assert.True(t, entry.Local())
+	_, err = s.Get("logsnap_202403")
+	assert.Error(t, err)
+	_, err = s.Get("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	uploaded := now
+	require.NoError(t, s.Update(old.ID, func(entry *Entry) {
+		entry.Upload = Upload{Status: UploadDone, URLs: []string{"https://example.com/s/abc"}, UploadedAt: &uploaded}
+		entry.Deleted = true
+	}))
+	entry, err = s.Get(old.ID)
+	require.NoError(t, err)
+	assert.Equal(t, UploadDone, entry.Upload.Status)
+	assert.Equal(t, []string{"https://example.com/s/abc"}, entry.Upload.URLs)
+	assert.False(t, entry.Local())
+	assert.True(t, errors.Is(s.Update("missing", func(*Entry) {}), ErrNotFound))
+
+	// dryRun 时不删除任何内容
+	pruned, err := s.Prune(now.Add(-7*24*time.Hour), true)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.Equal(t, old.ID, pruned[0].ID)
+	assert.FileExists(t, old.Path)
+	entries, err = s.List()
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	pruned, err = s.Prune(now.Add(-7*24*time.Hour), false)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.NoFileExists(t, old.Path)
+	assert.NoFileExists(t, old.Path+".sha256")
+	entries, err = s.List()
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	require.NoError(t, s.Remove("logsnap_20240309_100000_20240309_110000"))
+	assert.NoFileExists(t, filepath.Join(s.Dir(), "logsnap_20240309_100000_20240309_110000.zip"))
+	entries, err = s.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, errors.Is(s.Remove("missing"), ErrNotFound))
+}
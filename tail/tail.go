@@ -0,0 +1,364 @@
+// Package tail 实时跟踪多个程序的日志目录，按条目输出新写入的日志
+// 目录中新建的文件（日志轮转）会自动开始跟踪，被截断的文件从头读取
+package tail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"logsnap/logentry"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// flushDelay 条目在没有后续行写入这么久之后输出，多行日志的后续行通常紧随其后写入
+	flushDelay = 200 * time.Millisecond
+	// maxEntrySize 单个条目保留的最大字节数，超出部分被丢弃
+	maxEntrySize = 64 << 10
+	// maxMoved 最多记住的被改名文件数量
+	maxMoved = 16
+)
+
+// Source 跟踪的一个程序
+type Source struct {
+	Name string // 程序名称，作为每条日志的来源标签
+	Dir  string // 日志目录，其中的文件和子目录都会被跟踪
+}
+
+// Entry 一条新写入的日志
+type Entry struct {
+	Source   string            // 来源程序
+	File     string            // 相对日志目录的文件路径
+	Severity logentry.Severity // 日志级别，条目本身没有级别时使用文件名中的级别
+	Text     string            // 条目内容，多行日志包含所有行，不含末尾的换行符
+}
+
+// Follower 跟踪多个程序的日志目录
+type Follower struct {
+	sources []Source
+	filter  *logentry.Filter
+	watcher *fsnotify.Watcher
+
+	dirs  map[string]*Source       // 已监听的目录
+	files map[string]*followedFile // 正在跟踪的文件
+	moved []*followedFile          // 被改名的文件，改名后的文件出现在目录中时继续从原来的位置读取
+	emit  func(Entry)
+}
+
+// followedFile 单个文件的跟踪状态
+type followedFile struct {
+	source   *Source
+	path     string
+	name     string
+	info     os.FileInfo // 开始跟踪时的文件信息，用于识别改名后的同一个文件
+	handle   *os.File    // 第一次写入时打开，改名后仍然可以读取改名前写入的内容
+	offset   int64
+	partial  []byte // 未结束的行
+	entry    []byte // 当前条目
+	severity logentry.Severity
+	fileSev  logentry.Severity
+	updated  time.Time // 当前条目最后一次追加内容的时间
+}
+
+// New 创建跟踪器，filter 为空时输出所有日志
+func New(sources []Source, filter *logentry.Filter) *Follower {
+	return &Follower{
+		sources: sources,
+		filter:  filter,
+		dirs:    make(map[string]*Source),
+		files:   make(map[string]*followedFile),
+	}
+}
+
+// Run 开始跟踪，直到 ctx 结束，每条满足过滤条件的新日志调用一次 emit
+// 已有文件从末尾开始跟踪，只输出启动之后写入的内容
+func (f *Follower) Run(ctx context.Context, emit func(Entry)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建文件监听失败: %w", err)
+	}
+	defer watcher.Close()
+	defer f.closeAll()
+	f.watcher = watcher
+	f.emit = emit
+
+	for i := range f.sources {
+		source := &f.sources[i]
+		if err := f.watchDir(source, source.Dir, false); err != nil {
+			logrus.Warnf("无法跟踪 %s 的日志目录 %s: %v", source.Name, source.Dir, err)
+		}
+	}
+	if len(f.dirs) == 0 {
+		return fmt.Errorf("没有可以跟踪的日志目录")
+	}
+
+	ticker := time.NewTicker(flushDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			f.flushAll(time.Time{})
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			f.handle(event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logrus.Warnf("文件监听出错: %v", err)
+		case now := <-ticker.C:
+			f.flushAll(now.Add(-flushDelay))
+		}
+	}
+}
+
+// watchDir 监听目录及其子目录，fromStart 为 true 时目录中已有的文件从头读取（新建的目录）
+func (f *Follower) watchDir(source *Source, dir string, fromStart bool) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if _, watched := f.dirs[path]; watched {
+				return nil
+			}
+			if err := f.watcher.Add(path); err != nil {
+				return err
+			}
+			f.dirs[path] = source
+			return nil
+		}
+		f.follow(source, path, fromStart)
+		return nil
+	})
+}
+
+// follow 开始跟踪文件
+func (f *Follower) follow(source *Source, path string, fromStart bool) *followedFile {
+	if file := f.files[path]; file != nil {
+		return file
+	}
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || isDuplicate(path) {
+		// 符号链接指向的文件本身也在目录中，不重复跟踪
+		return nil
+	}
+
+	rel, err := filepath.Rel(source.Dir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	file := &followedFile{
+		source:  source,
+		path:    path,
+		name:    filepath.ToSlash(rel),
+		info:    info,
+		fileSev: logentry.SeverityFromFileName(path),
+	}
+	if !fromStart {
+		file.offset = info.Size()
+	}
+	for i, moved := range f.moved {
+		if os.SameFile(moved.info, info) {
+			// 日志轮转时旧文件改名，改名前的内容已经读取过
+			file.offset = moved.offset
+			f.moved = append(f.moved[:i], f.moved[i+1:]...)
+			break
+		}
+	}
+	f.files[path] = file
+	return file
+}
+
+// isDuplicate 判断文件是否为 glog 按级别拆分的重复日志
+// glog 的 INFO 文件包含所有级别的日志，WARNING、ERROR、FATAL 文件中的内容都是重复的
+func isDuplicate(path string) bool {
+	return logentry.SeverityFromFileName(path) > logentry.SeverityInfo
+}
+
+// handle 处理文件变化
+func (f *Follower) handle(event fsnotify.Event) {
+	switch {
+	case event.Has(fsnotify.Create):
+		source := f.dirs[filepath.Dir(event.Name)]
+		if source == nil {
+			return
+		}
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			if err := f.watchDir(source, event.Name, true); err != nil {
+				logrus.Warnf("无法跟踪新目录 %s: %v", event.Name, err)
+			}
+			return
+		}
+		// 日志轮转生成的新文件从头读取
+		if file := f.follow(source, event.Name, true); file != nil {
+			logrus.Debugf("开始跟踪新文件: %s", event.Name)
+			f.read(file)
+		}
+	case event.Has(fsnotify.Write):
+		if file := f.files[event.Name]; file != nil {
+			f.read(file)
+		}
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		if file := f.files[event.Name]; file != nil && !f.replaced(file) {
+			// 读取改名或删除前写入的剩余内容
+			if file.handle != nil {
+				f.read(file)
+			}
+			f.flush(file)
+			f.close(file)
+			delete(f.files, event.Name)
+			if event.Has(fsnotify.Rename) {
+				f.remember(file)
+			}
+		}
+		if _, watched := f.dirs[event.Name]; watched {
+			delete(f.dirs, event.Name)
+		}
+	}
+}
+
+// replaced 判断文件是否已经切换到同名的新文件，此时改名或删除事件针对的是旧文件
+func (f *Follower) replaced(file *followedFile) bool {
+	info, err := os.Lstat(file.path)
+	return err == nil && os.SameFile(info, file.info)
+}
+
+// remember 记住被改名的文件
+func (f *Follower) remember(file *followedFile) {
+	f.moved = append(f.moved, file)
+	if len(f.moved) > maxMoved {
+		f.moved = f.moved[1:]
+	}
+}
+
+// read 读取文件新写入的内容
+func (f *Follower) read(file *followedFile) {
+	if file.handle == nil {
+		handle, err := os.Open(file.path)
+		if err != nil {
+			return
+		}
+		if info, err := handle.Stat(); err == nil && !os.SameFile(info, file.info) {
+			// 开始跟踪后文件已经被改名（日志轮转），改名后的文件出现时继续读取，当前文件从头读取
+			f.flush(file)
+			f.remember(&followedFile{info: file.info, offset: file.offset})
+			file.info = info
+			file.offset = 0
+			file.partial = nil
+		}
+		file.handle = handle
+	}
+	handle := file.handle
+
+	info, err := handle.Stat()
+	if err != nil {
+		return
+	}
+	if info.Size() < file.offset {
+		// 文件被截断（例如 copytruncate 方式的轮转），从头读取
+		f.flush(file)
+		file.offset = 0
+		file.partial = nil
+	}
+	if _, err := handle.Seek(file.offset, io.SeekStart); err != nil {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(handle, info.Size()-file.offset))
+	if err != nil {
+		logrus.Warnf("读取 %s 失败: %v", file.path, err)
+		return
+	}
+	file.offset += int64(len(data))
+
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			file.partial = append(file.partial, data...)
+			break
+		}
+		line := append(file.partial, data[:i]...)
+		file.partial = nil
+		data = data[i+1:]
+		f.line(file, bytes.TrimRight(line, "\r"))
+	}
+}
+
+// line 处理一个完整的行，新条目开始时输出上一个条目
+func (f *Follower) line(file *followedFile, line []byte) {
+	entry, ok := logentry.Parse(line)
+	if !ok {
+		// 后续行属于当前条目，启动后第一个条目之前的后续行被忽略
+		if len(file.entry) > 0 && len(file.entry) < maxEntrySize {
+			file.entry = append(append(file.entry, '\n'), line...)
+			file.updated = time.Now()
+		}
+		return
+	}
+
+	f.flush(file)
+	file.entry = append(file.entry[:0], line...)
+	file.severity = entry.Severity
+	if file.severity == logentry.SeverityUnknown {
+		file.severity = file.fileSev
+	}
+	file.updated = time.Now()
+}
+
+// flush 输出文件的当前条目
+func (f *Follower) flush(file *followedFile) {
+	if len(file.entry) == 0 {
+		return
+	}
+	text := file.entry
+	file.entry = file.entry[:0]
+	if !f.filter.Match(file.severity, text) {
+		return
+	}
+	f.emit(Entry{
+		Source:   file.source.Name,
+		File:     file.name,
+		Severity: file.severity,
+		Text:     strings.ToValidUTF8(string(text), "�"),
+	})
+}
+
+// flushAll 输出所有在 before 之前更新的条目，before 为零值时输出全部
+func (f *Follower) flushAll(before time.Time) {
+	for _, file := range f.files {
+		if len(file.entry) > 0 && (before.IsZero() || file.updated.Before(before)) {
+			f.flush(file)
+		}
+	}
+}
+
+// close 关闭文件
+func (f *Follower) close(file *followedFile) {
+	if file.handle != nil {
+		file.handle.Close()
+		file.handle = nil
+	}
+}
+
+// closeAll 关闭所有打开的文件
+func (f *Follower) closeAll() {
+	for _, file := range f.files {
+		f.close(file)
+	}
+}
@@ -0,0 +1,85 @@
+package tail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"logsnap/logentry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendFile(t *testing.T, path, content string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestFollower(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "app.log")
+	appendFile(t, current, "2024-03-01 10:00:00.000 ERROR 启动之前的日志\n")
+	// glog 的 ERROR 文件与 INFO 文件内容重复，不跟踪
+	appendFile(t, filepath.Join(dir, "app.host.user.log.ERROR.20240301-100000.1"), "")
+
+	filter, err := logentry.NewFilter("warning", nil)
+	require.NoError(t, err)
+	follower := New([]Source{{Name: "app", Dir: dir}}, filter)
+
+	entries := make(chan Entry, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- follower.Run(ctx, func(entry Entry) { entries <- entry })
+	}()
+	// 等待开始监听
+	time.Sleep(200 * time.Millisecond)
+
+	appendFile(t, current, "2024-03-01 10:00:01.000 INFO 普通日志\n"+
+		"2024-03-01 10:00:02.000 ERROR 连接失败\n\tat main.go:10\n")
+
+	// 日志轮转：旧文件改名，新文件从头读取
+	require.NoError(t, os.Rename(current, current+".1"))
+	appendFile(t, current, "2024-03-01 10:00:03.000 WARNING 新文件中的日志\n")
+
+	var got []Entry
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case entry := <-entries:
+			got = append(got, entry)
+		case <-timeout:
+			t.Fatalf("只收到 %d 条日志: %+v", len(got), got)
+		}
+	}
+	// 启动之前的日志和改名后的旧文件不会重复输出
+	select {
+	case entry := <-entries:
+		t.Fatalf("多余的日志: %+v", entry)
+	case <-time.After(500 * time.Millisecond):
+	}
+	cancel()
+	require.NoError(t, <-done)
+
+	// 不同文件的日志按读取顺序输出，轮转前写入的日志可能在新文件之后读取
+	texts := make(map[string]Entry)
+	for _, entry := range got {
+		assert.Equal(t, "app", entry.Source)
+		texts[entry.Text] = entry
+	}
+	assert.Equal(t, logentry.SeverityError, texts["2024-03-01 10:00:02.000 ERROR 连接失败\n\tat main.go:10"].Severity)
+	assert.Equal(t, logentry.SeverityWarning, texts["2024-03-01 10:00:03.000 WARNING 新文件中的日志"].Severity)
+	assert.Equal(t, "app.log", texts["2024-03-01 10:00:03.000 WARNING 新文件中的日志"].File)
+}
+
+func TestFollower_NoDirs(t *testing.T) {
+	follower := New([]Source{{Name: "app", Dir: filepath.Join(t.TempDir(), "missing")}}, nil)
+	err := follower.Run(context.Background(), func(Entry) {})
+	assert.Error(t, err)
+}
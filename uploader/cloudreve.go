@@ -3,26 +3,54 @@ package uploader
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"logsnap/remote"
+	"logsnap/utils"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	cloudreveDefaultShareExpiry = 7 * 24 * time.Hour
+	cloudreveUnlimitedDownloads = 1 << 20 // 不限制下载次数但设置了有效期时使用的下载次数
+)
+
 // CloudreveUploader 实现Cloudreve存储上传
+// 支持 v3 和 v4：v3 通过会话cookie登录、经 WebDAV 上传；v4 通过令牌登录、使用原生的分块上传会话
 type CloudreveUploader struct {
-	config  remote.UploadConfigProvider
-	session *http.Client
+	config   remote.UploadConfigProvider
+	session  *http.Client // v3 带会话cookie的客户端
+	progress ProgressFunc
+	version  string
+
+	// v4
+	api    *http.Client // 不带文件内容的请求
+	upload *http.Client // 分块上传，不限制传输时间
+	token  *cloudreveToken
+	now    func() time.Time
 }
 
 func NewCloudreveUploader(config remote.UploadConfigProvider) *CloudreveUploader {
-	return &CloudreveUploader{config: config}
+	return &CloudreveUploader{
+		config: config,
+		api:    &http.Client{Timeout: 30 * time.Second},
+		upload: newStreamingClient(),
+		now:    time.Now,
+	}
+}
+
+// SetProgress 设置上传进度回调
+func (c *CloudreveUploader) SetProgress(fn ProgressFunc) {
+	c.progress = fn
 }
 
 func (c *CloudreveUploader) login() error {
@@ -168,6 +196,7 @@ func (c *CloudreveUploader) getWebdavCredentials() (remote.UploadConfigProvider,
 	if len(response.Data.Accounts) == 0 {
 		return remote.UploadConfigProvider{}, fmt.Errorf("未找到webdav账户")
 	}
+	remote.RegisterSecret(response.Data.Accounts[0].Password)
 
 	return remote.UploadConfigProvider{
 		Endpoint:   c.config.Endpoint + "/dav",
@@ -177,110 +206,141 @@ func (c *CloudreveUploader) getWebdavCredentials() (remote.UploadConfigProvider,
 	}, nil
 }
 
-// /api/v3/file/search/keywords%2F11baf85e5b680d85ea338599e309ab64_logs.zip?path=%2Fsnapshots%2F2025%2F03%2F02
-func (c *CloudreveUploader) getFileID(fileURL string) (string, error) {
-	// /dav/snapshots/2025/03/02/de322746df94435e5b403a6e629dfc34_logs.zip
-	// 需要获取de322746df94435e5b403a6e629dfc34_logs.zip的fileID
-	logrus.Infof("开始获取文件ID，文件URL: %s", fileURL)
+// cloudreveObject 目录中的文件或子目录
+type cloudreveObject struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"` // file 或 dir
+}
 
-	// 从 WebDAV URL 中提取路径
-	objectKey := strings.TrimPrefix(fileURL, c.config.Endpoint+"/dav/")
-	logrus.Infof("提取的对象键: %s", objectKey)
+// getFileID 列出文件所在的目录，按文件名精确匹配获取文件ID
+// 文件在 Cloudreve 中的路径为 WebDAV 账户的根目录加对象键，例如 /HFR-Cloud/snapshots/2025/03/02/xxx_logs.zip；
+// 不使用关键字搜索，搜索结果可能包含其他目录中的同名文件，也可能因为索引延迟找不到刚上传的文件
+func (c *CloudreveUploader) getFileID(root, objectKey string) (string, error) {
+	filePath := path.Join("/", filepath.ToSlash(root), filepath.ToSlash(objectKey))
+	dir, name := path.Dir(filePath), path.Base(filePath)
 
-	splitItems := strings.Split(objectKey, "/")
-	if len(splitItems) == 0 {
-		return "", fmt.Errorf("无效的文件URL: %s", fileURL)
+	objects, err := c.listDirectory(dir)
+	if err != nil {
+		return "", err
 	}
+	for _, object := range objects {
+		if object.Type == "file" && object.Name == name {
+			logrus.Infof("找到文件 ID: %s, 路径: %s", object.ID, filePath)
+			return object.ID, nil
+		}
+	}
+	return "", fmt.Errorf("目录 %s 中没有文件 %s", dir, name)
+}
 
-	// de322746df94435e5b403a6e629dfc34_logs.zip
-	fileName := splitItems[len(splitItems)-1]
-
-	logrus.Infof("解析结果 - 文件名: %s", fileName)
-
-	// URL 编码文件名和路径
-	encodedFileName := url.QueryEscape(fileName)
-
-	// 创建HTTP请求
-	searchURL := fmt.Sprintf("%s/api/v3/file/search/keywords%%2F%s",
-		c.config.Endpoint, encodedFileName)
-
-	logrus.Infof("搜索URL: %s", searchURL)
+// listDirectory 列出目录中的文件和子目录
+func (c *CloudreveUploader) listDirectory(dir string) ([]cloudreveObject, error) {
+	segments := strings.Split(strings.Trim(dir, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	listURL := fmt.Sprintf("%s/api/v3/directory/%s", strings.TrimSuffix(c.config.Endpoint, "/"), strings.Join(segments, "/"))
 
-	req, err := http.NewRequest("GET", searchURL, nil)
+	req, err := http.NewRequest("GET", listURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("创建搜索请求失败: %w", err)
+		return nil, fmt.Errorf("创建列目录请求失败: %w", err)
 	}
-
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	// 发送请求，使用带有cookie的会话
 	resp, err := c.session.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
-
-	// 读取响应体
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("读取响应体失败: %w", err)
-	}
-
-	logrus.Infof("搜索响应: %s", string(respBody))
-
-	// 检查响应状态
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("搜索文件失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("列出目录 %s 失败，状态码: %d, 响应: %s", dir, resp.StatusCode, string(body))
 	}
 
-	// 解析响应
 	var response struct {
-		Code int `json:"code"`
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
 		Data struct {
-			Objects []struct {
-				ID   string `json:"id"`
-				Name string `json:"name"`
-				Path string `json:"path"`
-			} `json:"objects"`
+			Objects []cloudreveObject `json:"objects"`
 		} `json:"data"`
 	}
-
-	err = json.Unmarshal(respBody, &response)
-	if err != nil {
-		return "", fmt.Errorf("解析响应失败: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
 	}
-
-	if len(response.Data.Objects) == 0 {
-		return "", fmt.Errorf("未找到文件")
+	if response.Code != 0 {
+		return nil, fmt.Errorf("列出目录 %s 失败: %s (code %d)", dir, response.Msg, response.Code)
 	}
+	return response.Data.Objects, nil
+}
+
+// cloudreveShare 创建分享链接的请求
+type cloudreveShare struct {
+	ID        string `json:"id"`
+	IsDir     bool   `json:"is_dir"`
+	Password  string `json:"password"`
+	Downloads int    `json:"downloads"` // 允许下载的次数，-1 表示永久有效且不限制次数
+	Expire    int64  `json:"expire"`    // 有效期（秒），只在 downloads 大于 0 时生效
+	Preview   bool   `json:"preview"`
+}
 
-	fileID := response.Data.Objects[0].ID
-	logrus.Infof("找到文件 ID: %s, 名称: %s, 路径: %s",
-		fileID, response.Data.Objects[0].Name, response.Data.Objects[0].Path)
+// sharePolicy 返回配置的分享链接有效期，share_expiry 默认 7d，为 0 时永久有效；同时校验 share_downloads
+func (c *CloudreveUploader) sharePolicy() (time.Duration, error) {
+	expiry := cloudreveDefaultShareExpiry
+	if c.config.ShareExpiry != "" {
+		parsed, err := utils.ParseDuration(c.config.ShareExpiry)
+		if err != nil {
+			return 0, fmt.Errorf("无效的分享链接有效期 share_expiry: %q", c.config.ShareExpiry)
+		}
+		expiry = parsed
+	}
+	if c.config.ShareDownloads < 0 {
+		return 0, fmt.Errorf("无效的分享链接下载次数 share_downloads: %d", c.config.ShareDownloads)
+	}
+	return expiry, nil
+}
 
-	return fileID, nil
+// shareRequest 根据配置的分享策略生成 v3 创建分享链接的请求，share_downloads 为 0 时不限制下载次数
+func (c *CloudreveUploader) shareRequest(fileID string) (cloudreveShare, error) {
+	expiry, err := c.sharePolicy()
+	if err != nil {
+		return cloudreveShare{}, err
+	}
+
+	share := cloudreveShare{
+		ID:       fileID,
+		Password: c.config.SharePassword,
+		Preview:  c.config.SharePreview,
+	}
+	// Cloudreve 只在限制下载次数时使用有效期，不限制下载次数但需要有效期时使用足够大的次数
+	switch {
+	case expiry == 0 && c.config.ShareDownloads == 0:
+		share.Downloads = -1
+	case expiry == 0:
+		return cloudreveShare{}, errors.New("Cloudreve 限制下载次数时必须设置分享链接有效期 share_expiry")
+	case c.config.ShareDownloads == 0:
+		share.Downloads = cloudreveUnlimitedDownloads
+		share.Expire = int64(expiry / time.Second)
+	default:
+		share.Downloads = c.config.ShareDownloads
+		share.Expire = int64(expiry / time.Second)
+	}
+	return share, nil
 }
 
 func (c *CloudreveUploader) createShareURL(fileID string) (string, error) {
-	// 创建HTTP请求
-	loginURL := fmt.Sprintf("%s/api/v3/share", c.config.Endpoint)
-	shareData := map[string]interface{}{
-		"id":        fileID,
-		"is_dir":    false,
-		"password":  "",
-		"downloads": 10,
-		"expire":    3600, // 1小时
-		"preview":   false,
-	}
-	jsonData, err := json.Marshal(shareData)
+	share, err := c.shareRequest(fileID)
+	if err != nil {
+		return "", err
+	}
+	jsonData, err := json.Marshal(share)
 	if err != nil {
 		return "", fmt.Errorf("序列化分享数据失败: %w", err)
 	}
-	req, err := http.NewRequest("POST", loginURL, bytes.NewBuffer(jsonData))
+	shareURL := fmt.Sprintf("%s/api/v3/share", strings.TrimSuffix(c.config.Endpoint, "/"))
+	req, err := http.NewRequest("POST", shareURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建登录请求失败: %w", err)
+		return "", fmt.Errorf("创建分享请求失败: %w", err)
 	}
 
 	// 设置请求头
@@ -297,6 +357,7 @@ func (c *CloudreveUploader) createShareURL(fileID string) (string, error) {
 	// 解析响应
 	var response struct {
 		Code int    `json:"code"`
+		Msg  string `json:"msg"`
 		Data string `json:"data"`
 	}
 
@@ -304,11 +365,27 @@ func (c *CloudreveUploader) createShareURL(fileID string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("解析响应失败: %w", err)
 	}
+	if response.Code != 0 || response.Data == "" {
+		return "", fmt.Errorf("创建分享链接失败: %s (code %d)", response.Msg, response.Code)
+	}
 
 	return response.Data, nil
 }
 
+// Upload 上传文件并创建分享链接，根据服务器版本使用 v3（通过 WebDAV 上传）或 v4（原生上传会话）接口
 func (c *CloudreveUploader) Upload(localPath, objectKey string) (string, error) {
+	version, err := c.apiVersion()
+	if err != nil {
+		return "", err
+	}
+	if version == cloudreveV4 {
+		return c.uploadV4(localPath, objectKey)
+	}
+	return c.uploadV3(localPath, objectKey)
+}
+
+// uploadV3 登录后获取 WebDAV 账户，通过 WebDAV 上传文件，再列出目录获取文件ID并创建分享链接
+func (c *CloudreveUploader) uploadV3(localPath, objectKey string) (string, error) {
 	// 确保已登录
 	if c.session == nil {
 		if err := c.login(); err != nil {
@@ -323,17 +400,14 @@ func (c *CloudreveUploader) Upload(localPath, objectKey string) (string, error)
 
 	logrus.Infof("开始通过 WebDAV 上传文件: %s", localPath)
 	webdavUploader := NewWebdavUploader(webdavConfig)
+	webdavUploader.SetProgress(c.progress)
 	webdavURL, err := webdavUploader.Upload(localPath, objectKey)
 	if err != nil {
 		return "", fmt.Errorf("上传失败: %w", err)
 	}
 	logrus.Infof("WebDAV 上传成功，URL: %s", webdavURL)
 
-	// 等待文件索引完成
-	time.Sleep(1 * time.Second)
-
-	logrus.Infof("开始获取文件 ID...")
-	fileID, err := c.getFileID(webdavURL)
+	fileID, err := c.getFileID(webdavConfig.FolderPath, objectKey)
 	if err != nil {
 		logrus.Warnf("获取文件ID失败: %v，将使用 WebDAV URL", err)
 		return webdavURL, nil
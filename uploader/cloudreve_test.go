@@ -0,0 +1,126 @@
+package uploader
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"logsnap/remote"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCloudreve 模拟 Cloudreve v3 的登录、WebDAV 账户、列目录和创建分享接口，文件通过 /dav 下的 fakeDAV 上传
+// WebDAV 账户的根目录为 /HFR-Cloud，/dav/x 对应 Cloudreve 中的 /HFR-Cloud/x
+type fakeCloudreve struct {
+	dav    *fakeDAV
+	shares []cloudreveShare
+	lists  []string
+}
+
+func newFakeCloudreve(t *testing.T) (*fakeCloudreve, *httptest.Server) {
+	fake := &fakeCloudreve{dav: newFakeDAV()}
+	fake.dav.dirs["/dav/"] = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/user/session", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "cloudreve-session", Value: "session", Path: "/"})
+		w.Write([]byte(`{"code":0}`))
+	})
+	mux.HandleFunc("/api/v3/webdav/accounts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":0,"data":{"accounts":[{"Password":"dav-password","Root":"/HFR-Cloud"}]}}`))
+	})
+	mux.HandleFunc("/api/v3/directory/", func(w http.ResponseWriter, r *http.Request) {
+		dir := strings.TrimPrefix(r.URL.Path, "/api/v3/directory")
+		fake.lists = append(fake.lists, dir)
+		davDir := "/dav" + strings.TrimPrefix(dir, "/HFR-Cloud")
+		var objects []cloudreveObject
+		fake.dav.mu.Lock()
+		for name := range fake.dav.files {
+			if path.Dir(name) == davDir {
+				objects = append(objects, cloudreveObject{ID: "id:" + name, Name: path.Base(name), Path: dir, Type: "file"})
+			}
+		}
+		fake.dav.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": map[string]interface{}{"objects": objects}})
+	})
+	mux.HandleFunc("/api/v3/share", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("cloudreve-session"); err != nil {
+			w.Write([]byte(`{"code":401,"msg":"未登录"}`))
+			return
+		}
+		var share cloudreveShare
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&share))
+		fake.shares = append(fake.shares, share)
+		w.Write([]byte(`{"code":0,"data":"https://cloud.example.com/s/` + strings.TrimPrefix(share.ID, "id:/dav/") + `"}`))
+	})
+	mux.Handle("/dav/", fake.dav)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func TestCloudreveUploadSharesExactFile(t *testing.T) {
+	fake, server := newFakeCloudreve(t)
+
+	// 其他目录中有同名文件，关键字搜索可能返回这些文件
+	fake.dav.files["/dav/snapshots/2024/02/29/abc_logs.zip"] = []byte("old")
+	fake.dav.files["/dav/snapshots/2024/03/01/abc_logs.zip.bak"] = []byte("other")
+
+	path, _ := writeRandomFile(t, 1000)
+	uploader := NewCloudreveUploader(remote.UploadConfigProvider{Provider: ProviderCloudreve, Endpoint: server.URL})
+	shareURL, err := uploader.Upload(path, "snapshots/2024/03/01/abc_logs.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "https://cloud.example.com/s/snapshots/2024/03/01/abc_logs.zip", shareURL)
+	assert.Equal(t, []string{"/HFR-Cloud/snapshots/2024/03/01"}, fake.lists)
+
+	// 默认分享 7 天，不限制下载次数
+	require.Len(t, fake.shares, 1)
+	assert.Equal(t, cloudreveShare{
+		ID:        "id:/dav/snapshots/2024/03/01/abc_logs.zip",
+		Downloads: cloudreveUnlimitedDownloads,
+		Expire:    7 * 24 * 3600,
+	}, fake.shares[0])
+}
+
+func TestCloudreveSharePolicy(t *testing.T) {
+	fake, server := newFakeCloudreve(t)
+
+	path, _ := writeRandomFile(t, 10)
+	uploader := NewCloudreveUploader(remote.UploadConfigProvider{
+		Endpoint:       server.URL,
+		ShareExpiry:    "2d",
+		ShareDownloads: 5,
+		SharePassword:  "x7k2",
+		SharePreview:   true,
+	})
+	_, err := uploader.Upload(path, "snapshots/a.zip")
+	require.NoError(t, err)
+	require.Len(t, fake.shares, 1)
+	assert.Equal(t, cloudreveShare{
+		ID:        "id:/dav/snapshots/a.zip",
+		Password:  "x7k2",
+		Downloads: 5,
+		Expire:    2 * 24 * 3600,
+		Preview:   true,
+	}, fake.shares[0])
+}
+
+func TestCloudreveShareRequest(t *testing.T) {
+	share, err := NewCloudreveUploader(remote.UploadConfigProvider{ShareExpiry: "0"}).shareRequest("1")
+	require.NoError(t, err)
+	assert.Equal(t, -1, share.Downloads)
+	assert.Zero(t, share.Expire)
+
+	_, err = NewCloudreveUploader(remote.UploadConfigProvider{ShareExpiry: "0", ShareDownloads: 3}).shareRequest("1")
+	assert.Error(t, err)
+	_, err = NewCloudreveUploader(remote.UploadConfigProvider{ShareExpiry: "tomorrow"}).shareRequest("1")
+	assert.Error(t, err)
+	_, err = NewCloudreveUploader(remote.UploadConfigProvider{ShareDownloads: -2}).shareRequest("1")
+	assert.Error(t, err)
+}
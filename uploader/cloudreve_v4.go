@@ -0,0 +1,376 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"logsnap/remote"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	cloudreveV3 = "v3"
+	cloudreveV4 = "v4"
+
+	cloudreveChunkRetries = 3           // 每块失败后的重试次数
+	cloudreveTokenMargin  = time.Minute // 访问令牌在过期前该时间内刷新，避免请求途中过期
+)
+
+var cloudreveRetryDelay = 2 * time.Second // 分块失败后第一次重试前的等待时间，之后每次增加
+
+// cloudreveToken v4 的登录令牌，访问令牌过期后使用刷新令牌获取新的令牌
+type cloudreveToken struct {
+	AccessToken    string    `json:"access_token"`
+	RefreshToken   string    `json:"refresh_token"`
+	AccessExpires  time.Time `json:"access_expires"`
+	RefreshExpires time.Time `json:"refresh_expires"`
+}
+
+// cloudreveResponse v4 接口的响应，code 为 0 表示成功
+type cloudreveResponse struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// cloudreveUploadSession v4 的上传会话
+type cloudreveUploadSession struct {
+	SessionID     string `json:"session_id"`
+	ChunkSize     int64  `json:"chunk_size"` // 每块的大小，0 表示整个文件作为一块
+	StoragePolicy struct {
+		Type  string `json:"type"`
+		Relay bool   `json:"relay"` // 由 Cloudreve 中转到存储
+	} `json:"storage_policy"`
+}
+
+// errCloudreveUnauthorized 访问令牌无效或已过期
+var errCloudreveUnauthorized = errors.New("Cloudreve访问令牌无效")
+
+// apiVersion 返回服务器的 API 版本，配置了 api_version 时使用配置的版本，否则自动检测
+func (c *CloudreveUploader) apiVersion() (string, error) {
+	if c.version != "" {
+		return c.version, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(c.config.APIVersion)) {
+	case "v3", "3":
+		c.version = cloudreveV3
+	case "v4", "4":
+		c.version = cloudreveV4
+	case "":
+		version, err := c.detectVersion()
+		if err != nil {
+			return "", err
+		}
+		c.version = version
+		logrus.Infof("检测到Cloudreve %s 接口", version)
+	default:
+		return "", fmt.Errorf("不支持的Cloudreve接口版本 api_version: %q", c.config.APIVersion)
+	}
+	return c.version, nil
+}
+
+// detectVersion 通过 /api/v4/site/ping 检测服务器版本，v3 服务器没有该接口
+func (c *CloudreveUploader) detectVersion() (string, error) {
+	resp, err := c.api.Get(c.apiURL("/api/v4/site/ping"))
+	if err != nil {
+		return "", fmt.Errorf("连接Cloudreve服务器失败: %w", err)
+	}
+	defer resp.Body.Close()
+	var response cloudreveResponse
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&response) != nil || response.Code != 0 {
+		return cloudreveV3, nil
+	}
+	var version string
+	if json.Unmarshal(response.Data, &version) != nil || !strings.HasPrefix(version, "4") {
+		return cloudreveV3, nil
+	}
+	return cloudreveV4, nil
+}
+
+// uploadV4 通过原生上传会话分块上传文件，再以文件路径创建分享链接
+func (c *CloudreveUploader) uploadV4(localPath, objectKey string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("获取文件信息失败: %w", err)
+	}
+	size := info.Size()
+
+	objectKey = strings.Trim(filepath.ToSlash(objectKey), "/")
+	uri := cloudreveURI(objectKey)
+	if dir := path.Dir(objectKey); dir != "." {
+		err := c.requestV4(http.MethodPost, "/api/v4/file/create", map[string]interface{}{
+			"type":            "folder",
+			"uri":             cloudreveURI(dir),
+			"err_on_conflict": false,
+		}, nil)
+		if err != nil {
+			return "", fmt.Errorf("创建Cloudreve目录失败: %w", err)
+		}
+	}
+
+	var session cloudreveUploadSession
+	err = c.requestV4(http.MethodPut, "/api/v4/file/upload", map[string]interface{}{
+		"uri":           uri,
+		"size":          size,
+		"last_modified": info.ModTime().UnixMilli(),
+		"mime_type":     "application/octet-stream",
+	}, &session)
+	if err != nil {
+		return "", fmt.Errorf("创建Cloudreve上传会话失败: %w", err)
+	}
+	if session.StoragePolicy.Type != "local" && !session.StoragePolicy.Relay {
+		c.deleteUploadSession(session.SessionID, uri)
+		return "", fmt.Errorf("不支持直接上传到 %s 类型的Cloudreve存储策略，请在存储策略中开启中转上传", session.StoragePolicy.Type)
+	}
+
+	if err := c.uploadChunks(file, size, session); err != nil {
+		c.deleteUploadSession(session.SessionID, uri)
+		return "", err
+	}
+	logrus.Infof("成功上传到Cloudreve: %s -> %s", localPath, uri)
+
+	shareURL, err := c.createShareV4(uri)
+	if err != nil {
+		return "", fmt.Errorf("创建分享链接失败: %w", err)
+	}
+	logrus.Infof("创建分享链接成功: %s", shareURL)
+	return shareURL, nil
+}
+
+// uploadChunks 按会话的分块大小依次上传每一块，失败的块重试
+func (c *CloudreveUploader) uploadChunks(file *os.File, size int64, session cloudreveUploadSession) error {
+	chunkSize := session.ChunkSize
+	if chunkSize <= 0 || chunkSize > size {
+		chunkSize = size
+	}
+	tracker := newProgressTracker(file.Name(), 0, size, c.progress)
+
+	for index, offset := 0, int64(0); index == 0 || offset < size; index, offset = index+1, offset+chunkSize {
+		length := chunkSize
+		if offset+length > size {
+			length = size - offset
+		}
+		var err error
+		for attempt := 0; attempt <= cloudreveChunkRetries; attempt++ {
+			if attempt > 0 {
+				logrus.Warnf("上传第 %d 块失败，%v 后重试 (%d/%d): %v", index+1, cloudreveRetryDelay*time.Duration(attempt), attempt, cloudreveChunkRetries, err)
+				time.Sleep(cloudreveRetryDelay * time.Duration(attempt))
+			}
+			if err = c.sendChunk(session.SessionID, index, file, offset, length, tracker); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("上传第 %d 块失败: %w", index+1, err)
+		}
+	}
+	return nil
+}
+
+// sendChunk 上传一块，上传期间连接超过 uploadStallTimeout 没有进展时断开
+func (c *CloudreveUploader) sendChunk(sessionID string, index int, file *os.File, offset, length int64, tracker *progressTracker) error {
+	if err := c.ensureToken(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body := newProgressReader(io.NewSectionReader(file, offset, length), length, tracker)
+	go body.watchStall(ctx, uploadStallTimeout, cancel)
+
+	var reqBody io.Reader = body
+	if length == 0 {
+		reqBody = http.NoBody
+	}
+	chunkURL := c.apiURL(fmt.Sprintf("/api/v4/file/upload/%s/%d", url.PathEscape(sessionID), index))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, chunkURL, reqBody)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
+
+	resp, err := c.upload.Do(req)
+	if err != nil {
+		body.rewind()
+		return err
+	}
+	defer resp.Body.Close()
+	if err := decodeCloudreveResponse(resp, nil); err != nil {
+		body.rewind()
+		if errors.Is(err, errCloudreveUnauthorized) {
+			// 下次重试时重新登录
+			c.token = nil
+		}
+		return err
+	}
+	return nil
+}
+
+// deleteUploadSession 删除上传失败的会话，释放服务器上已上传的块，失败时只输出警告
+func (c *CloudreveUploader) deleteUploadSession(sessionID, uri string) {
+	err := c.requestV4(http.MethodDelete, "/api/v4/file/upload", map[string]string{"id": sessionID, "uri": uri}, nil)
+	if err != nil {
+		logrus.Warnf("删除Cloudreve上传会话失败: %v", err)
+	}
+}
+
+// createShareV4 为文件创建分享链接，share_expiry 为 0 时永久有效，share_downloads 为 0 时不限制下载次数
+func (c *CloudreveUploader) createShareV4(uri string) (string, error) {
+	expiry, err := c.sharePolicy()
+	if err != nil {
+		return "", err
+	}
+	var shareURL string
+	err = c.requestV4(http.MethodPut, "/api/v4/share", map[string]interface{}{
+		"uri":        uri,
+		"is_private": c.config.SharePassword != "",
+		"password":   c.config.SharePassword,
+		"downloads":  c.config.ShareDownloads,
+		"expire":     int64(expiry / time.Second),
+		"share_view": c.config.SharePreview,
+	}, &shareURL)
+	if err != nil {
+		return "", err
+	}
+	if shareURL == "" {
+		return "", errors.New("Cloudreve没有返回分享链接")
+	}
+	return shareURL, nil
+}
+
+// ensureToken 确保有可用的访问令牌：没有令牌时登录，访问令牌即将过期时刷新，刷新失败时重新登录
+func (c *CloudreveUploader) ensureToken() error {
+	now := c.now()
+	if c.token != nil && now.Add(cloudreveTokenMargin).Before(c.token.AccessExpires) {
+		return nil
+	}
+	if c.token != nil && now.Before(c.token.RefreshExpires) {
+		var token cloudreveToken
+		err := c.call(http.MethodPost, "/api/v4/session/token/refresh", map[string]string{"refresh_token": c.token.RefreshToken}, false, &token)
+		if err == nil {
+			c.setToken(&token)
+			logrus.Infof("Cloudreve访问令牌已刷新")
+			return nil
+		}
+		logrus.Warnf("刷新Cloudreve访问令牌失败，重新登录: %v", err)
+	}
+
+	var login struct {
+		Token cloudreveToken `json:"token"`
+	}
+	err := c.call(http.MethodPost, "/api/v4/session/token", map[string]string{
+		"email":    c.config.Username,
+		"password": c.config.Password,
+	}, false, &login)
+	if err != nil {
+		return fmt.Errorf("登录失败: %w", err)
+	}
+	c.setToken(&login.Token)
+	logrus.Infof("Cloudreve登录成功")
+	return nil
+}
+
+// setToken 保存令牌，令牌不出现在日志中
+func (c *CloudreveUploader) setToken(token *cloudreveToken) {
+	remote.RegisterSecret(token.AccessToken)
+	remote.RegisterSecret(token.RefreshToken)
+	c.token = token
+}
+
+// requestV4 发送需要登录的 v4 接口请求，访问令牌被拒绝时重新获取令牌后重试一次
+func (c *CloudreveUploader) requestV4(method, apiPath string, body, out interface{}) error {
+	if err := c.ensureToken(); err != nil {
+		return err
+	}
+	err := c.call(method, apiPath, body, true, out)
+	if errors.Is(err, errCloudreveUnauthorized) {
+		c.token = nil
+		if err := c.ensureToken(); err != nil {
+			return err
+		}
+		err = c.call(method, apiPath, body, true, out)
+	}
+	return err
+}
+
+// call 发送 JSON 请求并把响应的 data 解析到 out
+func (c *CloudreveUploader) call(method, apiPath string, body interface{}, auth bool, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %w", err)
+	}
+	req, err := http.NewRequest(method, c.apiURL(apiPath), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if auth {
+		req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
+	}
+	resp, err := c.api.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	return decodeCloudreveResponse(resp, out)
+}
+
+// decodeCloudreveResponse 检查 v4 响应的状态码和 code，并把 data 解析到 out
+func decodeCloudreveResponse(resp *http.Response, out interface{}) error {
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	var response cloudreveResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		if resp.StatusCode == http.StatusUnauthorized {
+			return errCloudreveUnauthorized
+		}
+		return fmt.Errorf("解析响应失败，状态码: %d, 响应: %s", resp.StatusCode, truncate(respBody, 4096))
+	}
+	if resp.StatusCode == http.StatusUnauthorized || response.Code == http.StatusUnauthorized {
+		return fmt.Errorf("%w: %s", errCloudreveUnauthorized, response.Msg)
+	}
+	if response.Code != 0 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s (code %d, 状态码 %d)", response.Msg, response.Code, resp.StatusCode)
+	}
+	if out != nil && len(response.Data) > 0 {
+		if err := json.Unmarshal(response.Data, out); err != nil {
+			return fmt.Errorf("解析响应数据失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// apiURL 返回接口的完整地址
+func (c *CloudreveUploader) apiURL(apiPath string) string {
+	return strings.TrimSuffix(c.config.Endpoint, "/") + apiPath
+}
+
+// cloudreveURI 返回对象键在用户文件中的 URI，例如 cloudreve://my/snapshots/2025/03/02/xxx_logs.zip
+func cloudreveURI(objectKey string) string {
+	segments := strings.Split(strings.Trim(objectKey, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "cloudreve://my/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,269 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"logsnap/remote"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCloudreveV4 模拟 Cloudreve v4 的令牌登录与刷新、创建目录、分块上传会话和创建分享接口
+type fakeCloudreveV4 struct {
+	mu         sync.Mutex
+	chunkSize  int64
+	policyType string
+	failChunk  map[int]int // 块序号 -> 剩余的失败次数
+	clock      time.Time   // 签发令牌时使用的当前时间，与上传器共用
+
+	tokens    map[string]bool // 有效的访问令牌
+	refresh   map[string]bool // 有效的刷新令牌
+	issued    int
+	logins    int
+	refreshes int
+
+	folders  []string
+	sessions map[string]*fakeV4Session
+	files    map[string][]byte
+	deleted  []string
+	shares   []map[string]interface{}
+}
+
+type fakeV4Session struct {
+	uri    string
+	size   int64
+	chunks map[int][]byte
+}
+
+func newFakeCloudreveV4(t *testing.T) (*fakeCloudreveV4, *httptest.Server) {
+	fake := &fakeCloudreveV4{
+		chunkSize:  1 << 10,
+		policyType: "local",
+		failChunk:  make(map[int]int),
+		clock:      time.Now(),
+		tokens:     make(map[string]bool),
+		refresh:    make(map[string]bool),
+		sessions:   make(map[string]*fakeV4Session),
+		files:      make(map[string][]byte),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeCloudreveV4) issueToken() map[string]interface{} {
+	f.issued++
+	access, refresh := fmt.Sprintf("access-%d", f.issued), fmt.Sprintf("refresh-%d", f.issued)
+	f.tokens[access], f.refresh[refresh] = true, true
+	return map[string]interface{}{
+		"access_token":    access,
+		"refresh_token":   refresh,
+		"access_expires":  f.clock.Add(time.Hour),
+		"refresh_expires": f.clock.Add(7 * 24 * time.Hour),
+	}
+}
+
+// revokeAll 使所有访问令牌失效，模拟服务器重启等情况
+func (f *fakeCloudreveV4) revokeAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = make(map[string]bool)
+}
+
+func reply(w http.ResponseWriter, code int, msg string, data interface{}) {
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": msg, "data": data})
+}
+
+func (f *fakeCloudreveV4) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body map[string]interface{}
+	if r.Header.Get("Content-Type") == "application/json" {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	switch {
+	case r.URL.Path == "/api/v4/site/ping":
+		reply(w, 0, "", "4.0.0")
+		return
+	case r.URL.Path == "/api/v4/session/token":
+		if body["email"] != "admin@example.com" || body["password"] != "secret" {
+			reply(w, 40020, "Incorrect password or email address", nil)
+			return
+		}
+		f.logins++
+		reply(w, 0, "", map[string]interface{}{"user": map[string]string{"id": "u1"}, "token": f.issueToken()})
+		return
+	case r.URL.Path == "/api/v4/session/token/refresh":
+		token, _ := body["refresh_token"].(string)
+		if !f.refresh[token] {
+			reply(w, 401, "Invalid refresh token", nil)
+			return
+		}
+		delete(f.refresh, token)
+		f.refreshes++
+		reply(w, 0, "", f.issueToken())
+		return
+	}
+
+	if !f.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		w.WriteHeader(http.StatusUnauthorized)
+		reply(w, 401, "Login required", nil)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v4/file/create":
+		f.folders = append(f.folders, body["uri"].(string))
+		reply(w, 0, "", map[string]string{"id": "folder"})
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v4/file/upload":
+		id := fmt.Sprintf("session-%d", len(f.sessions)+1)
+		f.sessions[id] = &fakeV4Session{uri: body["uri"].(string), size: int64(body["size"].(float64)), chunks: make(map[int][]byte)}
+		reply(w, 0, "", map[string]interface{}{
+			"session_id":     id,
+			"chunk_size":     f.chunkSize,
+			"storage_policy": map[string]interface{}{"id": "p1", "type": f.policyType},
+		})
+	case r.Method == http.MethodDelete && r.URL.Path == "/api/v4/file/upload":
+		f.deleted = append(f.deleted, body["id"].(string))
+		delete(f.sessions, body["id"].(string))
+		reply(w, 0, "", nil)
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/api/v4/file/upload/"):
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v4/file/upload/"), "/")
+		session, ok := f.sessions[parts[0]]
+		index, _ := strconv.Atoi(parts[1])
+		data, _ := io.ReadAll(r.Body)
+		if !ok {
+			reply(w, 404, "Upload session not exist or expired", nil)
+			return
+		}
+		if f.failChunk[index] > 0 {
+			f.failChunk[index]--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		session.chunks[index] = data
+		var assembled []byte
+		for i := 0; i < len(session.chunks); i++ {
+			assembled = append(assembled, session.chunks[i]...)
+		}
+		if int64(len(assembled)) == session.size {
+			f.files[session.uri] = assembled
+		}
+		reply(w, 0, "", nil)
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v4/share":
+		if _, ok := f.files[body["uri"].(string)]; !ok {
+			reply(w, 404, "File not found", nil)
+			return
+		}
+		f.shares = append(f.shares, body)
+		reply(w, 0, "", fmt.Sprintf("https://cloud.example.com/s/%d", len(f.shares)))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestV4Uploader(fake *fakeCloudreveV4, server *httptest.Server) *CloudreveUploader {
+	uploader := NewCloudreveUploader(remote.UploadConfigProvider{
+		Provider: ProviderCloudreve,
+		Endpoint: server.URL + "/",
+		Username: "admin@example.com",
+		Password: "secret",
+	})
+	uploader.now = func() time.Time { return fake.clock }
+	return uploader
+}
+
+func TestCloudreveV4Upload(t *testing.T) {
+	retryDelay := cloudreveRetryDelay
+	cloudreveRetryDelay = time.Millisecond
+	t.Cleanup(func() { cloudreveRetryDelay = retryDelay })
+
+	fake, server := newFakeCloudreveV4(t)
+	fake.failChunk[2] = 1
+
+	path, data := writeRandomFile(t, 5000)
+	uploader := newTestV4Uploader(fake, server)
+	var last int64
+	uploader.SetProgress(func(file string, sent, total int64) { last = sent })
+	shareURL, err := uploader.Upload(path, "snapshots/2024/03/01/abc logs.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "https://cloud.example.com/s/1", shareURL)
+	assert.Equal(t, cloudreveV4, uploader.version)
+	assert.Equal(t, int64(len(data)), last)
+
+	uri := "cloudreve://my/snapshots/2024/03/01/abc%20logs.zip"
+	assert.Equal(t, data, fake.files[uri])
+	assert.Equal(t, []string{"cloudreve://my/snapshots/2024/03/01"}, fake.folders)
+	// 默认分享 7 天，不限制下载次数
+	require.Len(t, fake.shares, 1)
+	assert.Equal(t, map[string]interface{}{
+		"uri":        uri,
+		"is_private": false,
+		"password":   "",
+		"downloads":  float64(0),
+		"expire":     float64(7 * 24 * 3600),
+		"share_view": false,
+	}, fake.shares[0])
+	assert.Equal(t, 1, fake.logins)
+}
+
+func TestCloudreveV4TokenRefresh(t *testing.T) {
+	fake, server := newFakeCloudreveV4(t)
+	path, _ := writeRandomFile(t, 100)
+	uploader := newTestV4Uploader(fake, server)
+
+	_, err := uploader.Upload(path, "a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.logins)
+	assert.Empty(t, fake.folders)
+
+	// 访问令牌即将过期时使用刷新令牌获取新的令牌
+	fake.clock = fake.clock.Add(time.Hour)
+	_, err = uploader.Upload(path, "b.zip")
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.logins)
+	assert.Equal(t, 1, fake.refreshes)
+
+	// 服务器拒绝访问令牌时重新登录
+	fake.revokeAll()
+	_, err = uploader.Upload(path, "c.zip")
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.logins)
+
+	// 密码错误
+	uploader = newTestV4Uploader(fake, server)
+	uploader.config.Password = "wrong"
+	_, err = uploader.Upload(path, "d.zip")
+	assert.ErrorContains(t, err, "Incorrect password")
+}
+
+func TestCloudreveV4UnsupportedPolicy(t *testing.T) {
+	fake, server := newFakeCloudreveV4(t)
+	fake.policyType = "s3"
+
+	path, _ := writeRandomFile(t, 100)
+	_, err := newTestV4Uploader(fake, server).Upload(path, "a.zip")
+	assert.ErrorContains(t, err, "s3")
+	assert.Equal(t, []string{"session-1"}, fake.deleted)
+	assert.Empty(t, fake.shares)
+}
+
+func TestCloudreveAPIVersion(t *testing.T) {
+	for configured, want := range map[string]string{"v3": cloudreveV3, "V4": cloudreveV4, "4": cloudreveV4} {
+		version, err := NewCloudreveUploader(remote.UploadConfigProvider{APIVersion: configured}).apiVersion()
+		require.NoError(t, err)
+		assert.Equal(t, want, version)
+	}
+	_, err := NewCloudreveUploader(remote.UploadConfigProvider{APIVersion: "v5"}).apiVersion()
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DestinationResult 一个上传目标的上传结果
+type DestinationResult struct {
+	Provider string   // 提供商名称
+	URLs     []string // 上传成功时与文件一一对应的链接
+	Err      error    // 上传失败的原因
+	Fallback bool     // 是否为默认提供商失败后使用的备用提供商
+	FanOut   bool     // 是否为 fan_out 中同时上传的目标
+}
+
+// UploadDestinations 将文件上传到所有配置的目标，返回每个尝试过的目标的结果
+// 默认提供商失败时依次尝试 fallback 中的提供商，直到有一个成功；同时上传到 fan_out 中的每个提供商（不使用备用提供商，也不上报进度）
+// 所有目标都失败时返回错误，部分目标失败时只输出警告
+func (u *Uploader) UploadDestinations(filePaths []string) ([]DestinationResult, error) {
+	keys, err := objectKeys(filePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := u.chain()
+	fanOut := u.fanOut(chain)
+	if len(chain) == 0 && len(fanOut) == 0 {
+		return nil, errors.New("未配置上传提供商 (default_provider)")
+	}
+	results := make([][]DestinationResult, len(fanOut)+1)
+	var wg sync.WaitGroup
+	for i, name := range fanOut {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			result := u.uploadTo(name, filePaths, keys, false)
+			result.FanOut = true
+			results[i+1] = []DestinationResult{result}
+		}(i, name)
+	}
+	results[0] = u.uploadChain(chain, filePaths, keys)
+	wg.Wait()
+
+	var all []DestinationResult
+	var errs []error
+	for _, group := range results {
+		for _, result := range group {
+			all = append(all, result)
+			if result.Err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", result.Provider, result.Err))
+			}
+		}
+	}
+	if len(errs) == len(all) {
+		if len(all) == 1 {
+			return all, all[0].Err
+		}
+		return all, fmt.Errorf("所有上传目标均失败: %w", errors.Join(errs...))
+	}
+	// 默认提供商和备用提供商都失败、只有 fan_out 中的目标成功时，同样给出每个失败目标的警告
+	chainFailed := len(results[0]) > 0 && results[0][len(results[0])-1].Err != nil
+	for _, result := range all {
+		if result.Err != nil && (result.FanOut || chainFailed) {
+			logrus.Warnf("上传到 %s 失败: %v", result.Provider, result.Err)
+		}
+	}
+	return all, nil
+}
+
+// chain 返回依次尝试的提供商：默认提供商及 fallback 中的提供商，去除重复
+func (u *Uploader) chain() []string {
+	return uniqueNames(append([]string{u.config.DefaultProvider}, u.config.Fallback...), nil)
+}
+
+// fanOut 返回同时上传的提供商，已在 chain 中的提供商不重复上传
+func (u *Uploader) fanOut(chain []string) []string {
+	return uniqueNames(u.config.FanOut, chain)
+}
+
+// uniqueNames 返回去除空值、重复值和 exclude 中的名称后的 names
+func uniqueNames(names, exclude []string) []string {
+	seen := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		seen[name] = true
+	}
+	var unique []string
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, name)
+	}
+	return unique
+}
+
+// uploadChain 依次上传到 chain 中的提供商，直到有一个成功，返回每个尝试过的提供商的结果
+func (u *Uploader) uploadChain(chain, filePaths, keys []string) []DestinationResult {
+	var results []DestinationResult
+	for i, name := range chain {
+		result := u.uploadTo(name, filePaths, keys, true)
+		result.Fallback = i > 0
+		results = append(results, result)
+		if result.Err == nil {
+			break
+		}
+		if i+1 < len(chain) {
+			logrus.Warnf("上传到 %s 失败: %v，改为上传到备用提供商 %s", result.Provider, result.Err, chain[i+1])
+		}
+	}
+	return results
+}
+
+// uploadTo 将所有文件上传到一个提供商的 folder_path 下，某个文件失败时停止
+func (u *Uploader) uploadTo(name string, filePaths, keys []string, progress bool) DestinationResult {
+	result := DestinationResult{Provider: name}
+	uploader, provider, err := u.createUploader(name, progress)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Provider = provider.DisplayName()
+
+	for i, filePath := range filePaths {
+		if len(filePaths) > 1 {
+			logrus.Infof("上传文件到 %s (%d/%d): %s", result.Provider, i+1, len(filePaths), filePath)
+		}
+		url, err := uploader.Upload(filePath, filepath.Join(provider.FolderPath, keys[i]))
+		if err != nil {
+			if len(filePaths) > 1 {
+				err = fmt.Errorf("上传 %s 失败: %w", filepath.Base(filePath), err)
+			}
+			result.Err = err
+			return result
+		}
+		result.URLs = append(result.URLs, url)
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package uploader
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"logsnap/remote"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadFallsBackToNextProvider(t *testing.T) {
+	root := t.TempDir()
+	// 目标目录是一个普通文件，无法在其中创建目录
+	blocked := filepath.Join(root, "blocked")
+	require.NoError(t, os.WriteFile(blocked, nil, 0644))
+
+	u := NewUploader(remote.UploadConfig{
+		Providers: []remote.UploadConfigProvider{
+			{Name: "nas", Provider: ProviderLocal, Endpoint: blocked},
+			{Name: "backup", Provider: ProviderLocal, Endpoint: filepath.Join(root, "backup")},
+		},
+		DefaultProvider: "nas",
+		Fallback:        []string{"missing", "backup"},
+	})
+	var last int64
+	u.SetProgress(func(file string, sent, total int64) { last = sent })
+
+	path, data := writeRandomFile(t, 100)
+	results, err := u.UploadDestinations([]string{path})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "nas", results[0].Provider)
+	assert.Error(t, results[0].Err)
+	assert.False(t, results[0].Fallback)
+	assert.Equal(t, "missing", results[1].Provider)
+	assert.ErrorContains(t, results[1].Err, "不支持的云存储提供商")
+	assert.Equal(t, "backup", results[2].Provider)
+	require.NoError(t, results[2].Err)
+	assert.True(t, results[2].Fallback)
+	require.Len(t, results[2].URLs, 1)
+	assert.Equal(t, int64(len(data)), last)
+
+	// 默认提供商成功时不尝试备用提供商
+	u.config.DefaultProvider = "backup"
+	urls, err := u.UploadFiles([]string{path})
+	require.NoError(t, err)
+	assert.Equal(t, results[2].URLs, urls)
+}
+
+func TestUploadFanOut(t *testing.T) {
+	root := t.TempDir()
+	u := NewUploader(remote.UploadConfig{
+		Providers: []remote.UploadConfigProvider{
+			{Provider: ProviderLocal, Endpoint: filepath.Join(root, "cloud"), FolderPath: "snapshots"},
+			{Name: "customer-nas", Provider: ProviderLocal, Endpoint: filepath.Join(root, "nas")},
+		},
+		DefaultProvider: ProviderLocal,
+		FanOut:          []string{"customer-nas", ProviderLocal, "missing"},
+	})
+
+	dir := t.TempDir()
+	part1, part2 := filepath.Join(dir, "logsnap_test.part001.zip"), filepath.Join(dir, "logsnap_test.part002.zip")
+	data1, data2 := []byte("part1"), []byte("part2")
+	require.NoError(t, os.WriteFile(part1, data1, 0644))
+	require.NoError(t, os.WriteFile(part2, data2, 0644))
+	results, err := u.UploadDestinations([]string{part1, part2})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, ProviderLocal, results[0].Provider)
+	assert.Equal(t, "customer-nas", results[1].Provider)
+	assert.True(t, results[1].FanOut)
+	assert.Equal(t, "missing", results[2].Provider)
+	assert.Error(t, results[2].Err)
+
+	// 每个目标中的文件在同一目录下，目录结构相对于各自的 folder_path
+	keys, err := objectKeys([]string{part1, part2})
+	require.NoError(t, err)
+	for i, want := range [][]byte{data1, data2} {
+		written, err := os.ReadFile(filepath.Join(root, "cloud", "snapshots", keys[i]))
+		require.NoError(t, err)
+		assert.Equal(t, want, written)
+		written, err = os.ReadFile(filepath.Join(root, "nas", keys[i]))
+		require.NoError(t, err)
+		assert.Equal(t, want, written)
+	}
+	assert.Equal(t, filepath.Dir(keys[0]), filepath.Dir(keys[1]))
+
+	urls, err := u.UploadFiles([]string{part1, part2})
+	require.NoError(t, err)
+	assert.Equal(t, append(results[0].URLs, results[1].URLs...), urls)
+}
+
+func TestUploadWarnsFailedChainWhenFanOutSucceeds(t *testing.T) {
+	var buf bytes.Buffer
+	output := logrus.StandardLogger().Out
+	logrus.SetOutput(&buf)
+	t.Cleanup(func() { logrus.SetOutput(output) })
+
+	root := t.TempDir()
+	u := NewUploader(remote.UploadConfig{
+		Providers: []remote.UploadConfigProvider{
+			{Name: "customer-nas", Provider: ProviderLocal, Endpoint: filepath.Join(root, "nas")},
+		},
+		DefaultProvider: "cloud",
+		Fallback:        []string{"backup"},
+		FanOut:          []string{"customer-nas"},
+	})
+	path, _ := writeRandomFile(t, 10)
+	results, err := u.UploadDestinations([]string{path})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.NoError(t, results[2].Err)
+
+	// 默认提供商和备用提供商都失败时每个失败的目标都有警告
+	logged := buf.String()
+	assert.Contains(t, logged, "上传到 cloud 失败")
+	assert.Contains(t, logged, "上传到 backup 失败")
+	assert.NotContains(t, logged, "上传到 customer-nas 失败")
+}
+
+func TestUploadAllDestinationsFail(t *testing.T) {
+	path, _ := writeRandomFile(t, 10)
+
+	_, err := NewUploader(remote.UploadConfig{DefaultProvider: "missing"}).Upload(path)
+	assert.EqualError(t, err, "不支持的云存储提供商: missing")
+
+	_, err = NewUploader(remote.UploadConfig{DefaultProvider: "a", Fallback: []string{"b"}, FanOut: []string{"c"}}).Upload(path)
+	require.Error(t, err)
+	for _, name := range []string{"a: ", "b: ", "c: "} {
+		assert.Contains(t, err.Error(), name)
+	}
+
+	_, err = NewUploader(remote.UploadConfig{}).Upload(path)
+	assert.Error(t, err)
+
+	// 凭证引用在上传时解析
+	_, err = NewUploader(remote.UploadConfig{
+		Providers:       []remote.UploadConfigProvider{{Provider: ProviderLocal, Endpoint: t.TempDir(), Password: "env:LOGSNAP_TEST_MISSING"}},
+		DefaultProvider: ProviderLocal,
+	}).Upload(path)
+	assert.ErrorContains(t, err, "LOGSNAP_TEST_MISSING")
+}
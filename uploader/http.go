@@ -0,0 +1,254 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"logsnap/remote"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	httpDefaultMethod    = http.MethodPost
+	httpDefaultFieldName = "file"
+	httpDefaultURLPath   = "$.url"
+)
+
+// httpDefaultFormFields 未配置 form_fields 时随文件提交的表单字段
+var httpDefaultFormFields = map[string]string{
+	"host":        "{{.Host}}",
+	"window":      "{{.Window}}",
+	"description": "{{.Description}}",
+	"tags":        `{{join .Tags ","}}`,
+}
+
+// quoteEscaper 转义 Content-Disposition 中的引号，与 mime/multipart 的 CreateFormFile 一致
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// HTTPUploader 以 multipart/form-data 请求上传到内部的工单、制品等服务
+// 表单字段和请求头的值为 text/template 模板，下载链接通过 JSONPath 从响应 JSON 中读取，
+// 接入新的接收服务只需要修改配置，不需要新增上传实现
+type HTTPUploader struct {
+	config   remote.UploadConfigProvider
+	client   *http.Client
+	progress ProgressFunc
+	metadata Metadata
+}
+
+// httpTemplateData 表单字段和请求头模板可以使用的数据
+type httpTemplateData struct {
+	Metadata
+	Window    string // 快照时间范围，RFC 3339 格式的 开始时间/结束时间，没有时间范围时为空
+	FileName  string // 上传的文件名
+	ObjectKey string // 对象键，包含 folder_path 和日期目录
+	Size      int64  // 文件大小
+	Username  string
+	Password  string
+	AccessKey string
+	SecretKey string
+}
+
+func NewHTTPUploader(config remote.UploadConfigProvider) *HTTPUploader {
+	return &HTTPUploader{config: config, client: newStreamingClient()}
+}
+
+// SetProgress 设置上传进度回调
+func (h *HTTPUploader) SetProgress(fn ProgressFunc) {
+	h.progress = fn
+}
+
+// SetMetadata 设置随文件提交的快照描述信息
+func (h *HTTPUploader) SetMetadata(metadata Metadata) {
+	h.metadata = metadata
+}
+
+func (h *HTTPUploader) Upload(localPath, objectKey string) (string, error) {
+	if h.config.Endpoint == "" {
+		return "", errors.New("HTTP上传配置缺少上传地址 (endpoint)")
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("获取文件信息失败: %w", err)
+	}
+	size := info.Size()
+
+	data := h.templateData(filepath.Base(localPath), filepath.ToSlash(objectKey), size)
+	formFields := h.config.FormFields
+	if formFields == nil {
+		formFields = httpDefaultFormFields
+	}
+	fields, err := renderTemplates(formFields, data)
+	if err != nil {
+		return "", fmt.Errorf("生成表单字段失败: %w", err)
+	}
+	headers, err := renderTemplates(h.config.Headers, data)
+	if err != nil {
+		return "", fmt.Errorf("生成请求头失败: %w", err)
+	}
+
+	respBody, err := h.send(file, size, fields, headers)
+	if err != nil {
+		return "", err
+	}
+
+	urlPath := h.config.URLPath
+	if urlPath == "" {
+		urlPath = httpDefaultURLPath
+	}
+	downloadURL, err := jsonPathString(respBody, urlPath)
+	if err != nil {
+		return "", fmt.Errorf("读取上传响应中的下载链接失败: %w, 响应: %s", err, truncate(respBody, 4096))
+	}
+	logrus.Infof("成功上传到HTTP服务: %s -> %s", localPath, downloadURL)
+	return downloadURL, nil
+}
+
+// send 发送 multipart 请求并返回响应内容
+// 表单字段在文件之前，请求体的长度预先计算，文件以流的方式发送，不使用 chunked 编码
+// 上传期间连接超过 uploadStallTimeout 没有进展时断开
+func (h *HTTPUploader) send(file *os.File, size int64, fields, headers map[string]string) ([]byte, error) {
+	var head bytes.Buffer
+	writer := multipart.NewWriter(&head)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// 值为空的字段不提交，例如没有标签时的 tags
+		if fields[name] == "" {
+			continue
+		}
+		if err := writer.WriteField(name, fields[name]); err != nil {
+			return nil, fmt.Errorf("生成表单失败: %w", err)
+		}
+	}
+	fieldName := h.config.FieldName
+	if fieldName == "" {
+		fieldName = httpDefaultFieldName
+	}
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(fieldName), quoteEscaper.Replace(filepath.Base(file.Name()))))
+	partHeader.Set("Content-Type", "application/octet-stream")
+	if _, err := writer.CreatePart(partHeader); err != nil {
+		return nil, fmt.Errorf("生成表单失败: %w", err)
+	}
+	tail := "\r\n--" + writer.Boundary() + "--\r\n"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker := newProgressTracker(file.Name(), 0, size, h.progress)
+	body := newProgressReader(file, size, tracker)
+	go body.watchStall(ctx, uploadStallTimeout, cancel)
+
+	method := h.config.Method
+	if method == "" {
+		method = httpDefaultMethod
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), h.config.Endpoint,
+		io.MultiReader(bytes.NewReader(head.Bytes()), body, strings.NewReader(tail)))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP上传请求失败: %w", err)
+	}
+	req.ContentLength = int64(head.Len()) + size + int64(len(tail))
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Accept", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP上传请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取HTTP上传响应失败: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP上传失败，状态码: %d, 响应: %s", resp.StatusCode, truncate(respBody, 4096))
+	}
+	return respBody, nil
+}
+
+// templateData 返回当前文件的模板数据
+func (h *HTTPUploader) templateData(fileName, objectKey string, size int64) httpTemplateData {
+	data := httpTemplateData{
+		Metadata:  h.metadata,
+		FileName:  fileName,
+		ObjectKey: objectKey,
+		Size:      size,
+		Username:  h.config.Username,
+		Password:  h.config.Password,
+		AccessKey: h.config.AccessKey,
+		SecretKey: h.config.SecretKey,
+	}
+	if !h.metadata.Start.IsZero() && !h.metadata.End.IsZero() {
+		data.Window = h.metadata.Start.Format(time.RFC3339) + "/" + h.metadata.End.Format(time.RFC3339)
+	}
+	return data
+}
+
+// renderTemplates 使用 data 渲染每个模板，返回渲染后的值
+func renderTemplates(templates map[string]string, data httpTemplateData) (map[string]string, error) {
+	funcs := template.FuncMap{"join": func(elems []string, sep string) string { return strings.Join(elems, sep) }}
+	rendered := make(map[string]string, len(templates))
+	for name, text := range templates {
+		tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("解析 %s 的模板失败: %w", name, err)
+		}
+		var value strings.Builder
+		if err := tmpl.Execute(&value, data); err != nil {
+			return nil, fmt.Errorf("渲染 %s 的模板失败: %w", name, err)
+		}
+		rendered[name] = value.String()
+	}
+	return rendered, nil
+}
+
+// jsonPathString 从 JSON 内容中读取 JSONPath 指向的字符串
+func jsonPathString(body []byte, path string) (string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", fmt.Errorf("响应不是JSON: %w", err)
+	}
+	value, err := jsonPathLookup(doc, path)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("%s 不是非空字符串", path)
+	}
+	return s, nil
+}
+
+// truncate 截断过长的响应内容，用于错误信息
+func truncate(body []byte, limit int) string {
+	if len(body) > limit {
+		return string(body[:limit]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,186 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"logsnap/remote"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receivedUpload HTTP 接收服务收到的一次上传
+type receivedUpload struct {
+	method        string
+	header        http.Header
+	fields        map[string]string
+	fieldName     string
+	fileName      string
+	data          []byte
+	contentLength int64
+}
+
+// newFakeReceiver 模拟接收 multipart 上传的内部服务，响应中的下载链接位于 data.files[0].url
+func newFakeReceiver(t *testing.T, received *[]receivedUpload) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"unauthorized"}`)
+			return
+		}
+		reader, err := r.MultipartReader()
+		require.NoError(t, err)
+		upload := receivedUpload{method: r.Method, header: r.Header, fields: map[string]string{}, contentLength: r.ContentLength}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, _ := io.ReadAll(part)
+			if part.FileName() != "" {
+				upload.fieldName, upload.fileName, upload.data = part.FormName(), part.FileName(), data
+			} else {
+				upload.fields[part.FormName()] = string(data)
+			}
+		}
+		*received = append(*received, upload)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"files": []map[string]string{{"url": "https://artifacts.example.com/d/" + upload.fileName}},
+			},
+		})
+	}))
+}
+
+func TestHTTPUpload(t *testing.T) {
+	var received []receivedUpload
+	server := newFakeReceiver(t, &received)
+	defer server.Close()
+
+	config := remote.UploadConfigProvider{
+		Provider:  ProviderHTTP,
+		Endpoint:  server.URL + "/api/upload",
+		Method:    "put",
+		FieldName: "attachment",
+		Password:  "token-123",
+		Headers: map[string]string{
+			"Authorization": "Bearer {{.Password}}",
+			"X-Ticket-Host": "{{.Host}}",
+		},
+		URLPath: "$.data.files[0].url",
+	}
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	uploader := NewHTTPUploader(config)
+	uploader.SetMetadata(Metadata{
+		Host:        "site-01",
+		Start:       start,
+		End:         start.Add(time.Hour),
+		Description: "通过CLI上传的日志",
+		Tags:        []string{"crash", "hmi"},
+	})
+	var last int64
+	uploader.SetProgress(func(file string, sent, total int64) { last = sent })
+
+	path, data := writeRandomFile(t, 100*1024)
+	url, err := uploader.Upload(path, "snapshots/2024/03/01/a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "https://artifacts.example.com/d/logsnap_test.zip", url)
+	assert.Equal(t, int64(len(data)), last)
+
+	require.Len(t, received, 1)
+	upload := received[0]
+	assert.Equal(t, http.MethodPut, upload.method)
+	assert.Equal(t, "site-01", upload.header.Get("X-Ticket-Host"))
+	assert.Greater(t, upload.contentLength, int64(len(data)))
+	assert.Equal(t, "attachment", upload.fieldName)
+	assert.Equal(t, "logsnap_test.zip", upload.fileName)
+	assert.Equal(t, data, upload.data)
+	// 未配置 form_fields 时提交默认字段
+	assert.Equal(t, map[string]string{
+		"host":        "site-01",
+		"window":      "2024-03-01T08:00:00Z/2024-03-01T09:00:00Z",
+		"description": "通过CLI上传的日志",
+		"tags":        "crash,hmi",
+	}, upload.fields)
+}
+
+func TestHTTPUploadFormFields(t *testing.T) {
+	var received []receivedUpload
+	server := newFakeReceiver(t, &received)
+	defer server.Close()
+
+	config := remote.UploadConfigProvider{
+		Endpoint: server.URL,
+		Password: "token-123",
+		Headers:  map[string]string{"Authorization": "Bearer {{.Password}}"},
+		FormFields: map[string]string{
+			"project": "logsnap",
+			"path":    "{{.ObjectKey}}",
+			"summary": "{{.Host}} {{.Start.Format \"2006-01-02\"}} ({{.Size}} bytes)",
+			"labels":  `{{join .Tags ";"}}`,
+		},
+		URLPath: "data.files[0]['url']",
+	}
+	uploader := NewHTTPUploader(config)
+	uploader.SetMetadata(Metadata{Host: "site-01", Start: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)})
+
+	path, _ := writeRandomFile(t, 10)
+	_, err := uploader.Upload(path, "snapshots/a.zip")
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	// 值为空的字段不提交
+	assert.Equal(t, map[string]string{
+		"project": "logsnap",
+		"path":    "snapshots/a.zip",
+		"summary": "site-01 2024-03-01 (10 bytes)",
+	}, received[0].fields)
+	assert.Equal(t, "file", received[0].fieldName)
+
+	// 响应中没有下载链接
+	config.URLPath = "$.data.link"
+	_, err = NewHTTPUploader(config).Upload(path, "snapshots/a.zip")
+	assert.ErrorContains(t, err, `"link" 不存在`)
+
+	// 服务返回错误状态码
+	config.Password = "wrong"
+	_, err = NewHTTPUploader(config).Upload(path, "snapshots/a.zip")
+	assert.ErrorContains(t, err, "状态码: 401")
+
+	// 模板错误
+	config.Headers = map[string]string{"Authorization": "Bearer {{.Token}}"}
+	_, err = NewHTTPUploader(config).Upload(path, "snapshots/a.zip")
+	assert.ErrorContains(t, err, "生成请求头失败")
+}
+
+func TestJSONPathLookup(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"url":"a","data":{"files":[{"url":"b"},{"url":"c"}],"a.b":{"url":"d"}},"list":[["e"]]}`), &doc))
+
+	tests := map[string]interface{}{
+		"$.url":                "a",
+		"url":                  "a",
+		"$.data.files[1].url":  "c",
+		"data.files[0]['url']": "b",
+		`$["data"]["a.b"].url`: "d",
+		"$.list[0][0]":         "e",
+		"$.data.files[0]":      map[string]interface{}{"url": "b"},
+	}
+	for path, want := range tests {
+		got, err := jsonPathLookup(doc, path)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, got, path)
+	}
+
+	for _, path := range []string{"$.missing", "$.data.files[2].url", "$.url[0]", "$.data.files[x]", "$.data..url", "$.data.files[0"} {
+		_, err := jsonPathLookup(doc, path)
+		assert.Error(t, err, path)
+	}
+}
@@ -0,0 +1,72 @@
+package uploader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathLookup 返回 JSONPath 在 doc（json.Unmarshal 到 interface{} 的结果）中指向的值
+// 只支持读取单个值的子集：$、.name、['name']、["name"] 和 [n]，例如 $.data.files[0].url；
+// 开头的 $ 可以省略，例如 data.url
+func jsonPathLookup(doc interface{}, path string) (interface{}, error) {
+	rest := strings.TrimSpace(path)
+	rest = strings.TrimPrefix(rest, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	current := doc
+	for rest != "" {
+		var key string
+		index := -1
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key, rest = rest[1:end+1], rest[end+1:]
+			if key == "" {
+				return nil, fmt.Errorf("JSONPath %q 格式错误", path)
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q 缺少 ]", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				key = inner[1 : len(inner)-1]
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("JSONPath %q 中的下标 %q 无效", path, inner)
+				}
+				index = n
+			}
+		default:
+			return nil, fmt.Errorf("JSONPath %q 格式错误", path)
+		}
+
+		if index >= 0 {
+			array, ok := current.([]interface{})
+			if !ok || index >= len(array) {
+				return nil, fmt.Errorf("JSONPath %q 中的下标 [%d] 不存在", path, index)
+			}
+			current = array[index]
+			continue
+		}
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("JSONPath %q 中的 %q 不是对象的字段", path, key)
+		}
+		value, ok := object[key]
+		if !ok {
+			return nil, fmt.Errorf("JSONPath %q 中的字段 %q 不存在", path, key)
+		}
+		current = value
+	}
+	return current, nil
+}
@@ -1,64 +1,164 @@
 package uploader
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"hash"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 
 	"logsnap/remote"
 
 	"github.com/sirupsen/logrus"
 )
 
-// LocalUploader 实现本地存储 - 用于测试
+const localPartPattern = ".logsnap-part-*" // 写入期间临时文件的后缀，与目标文件在同一目录中
+
+// LocalUploader 将快照复制到本地目录或挂载的共享目录（NAS、NFS、SMB），用于离线站点
+// 文件先写入同一目录中的临时文件并 fsync，校验 SHA-256 后再改名为目标文件，
+// 共享目录中不会出现不完整的快照，读取共享目录的一方也不会读到写了一半的文件
 type LocalUploader struct {
-	config remote.UploadConfigProvider
+	config   remote.UploadConfigProvider
+	progress ProgressFunc
 }
 
 func NewLocalUploader(config remote.UploadConfigProvider) *LocalUploader {
 	return &LocalUploader{config: config}
 }
 
+// SetProgress 设置上传进度回调
+func (l *LocalUploader) SetProgress(fn ProgressFunc) {
+	l.progress = fn
+}
+
 func (l *LocalUploader) Upload(localPath, objectKey string) (string, error) {
-	// 确保目标目录存在
-	destDir := filepath.Join(l.config.Endpoint, l.config.Bucket, filepath.Dir(objectKey))
+	root := strings.TrimPrefix(l.config.Endpoint, "file://")
+	if root == "" {
+		return "", fmt.Errorf("本地存储配置缺少目标目录 (endpoint)")
+	}
+	destPath := filepath.Join(root, l.config.Bucket, objectKey)
+	destDir := filepath.Dir(destPath)
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return "", fmt.Errorf("创建目标目录失败: %w", err)
 	}
 
-	// 计算目标文件路径
-	destPath := filepath.Join(l.config.Endpoint, l.config.Bucket, objectKey)
-
-	// 读取源文件
 	src, err := os.Open(localPath)
 	if err != nil {
 		return "", fmt.Errorf("打开源文件失败: %w", err)
 	}
 	defer src.Close()
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return "", fmt.Errorf("获取源文件信息失败: %w", err)
+	}
 
-	// 创建目标文件
-	dst, err := os.Create(destPath)
+	tmp, err := os.CreateTemp(destDir, filepath.Base(destPath)+localPartPattern)
 	if err != nil {
-		return "", fmt.Errorf("创建目标文件失败: %w", err)
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	// 复制时计算源文件的 SHA-256
+	srcHash := sha256.New()
+	tracker := newProgressTracker(localPath, 0, srcInfo.Size(), l.progress)
+	reader := newProgressReader(io.TeeReader(src, srcHash), srcInfo.Size(), tracker)
+	if _, err := io.Copy(tmp, reader); err != nil {
+		return "", fmt.Errorf("写入目标文件失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("同步目标文件到磁盘失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("关闭目标文件失败: %w", err)
+	}
+	// 临时文件权限为 0600，改为与普通文件一致，共享目录的其他用户可以读取
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return "", fmt.Errorf("设置目标文件权限失败: %w", err)
+	}
+
+	if err := verifySHA256(tmpPath, srcHash); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		return "", fmt.Errorf("目标文件改名失败: %w", err)
 	}
-	defer dst.Close()
+	committed = true
+	syncDir(destDir)
 
-	// 复制文件内容
-	_, err = os.ReadFile(localPath)
+	link, err := l.link(destPath, objectKey)
 	if err != nil {
-		return "", fmt.Errorf("读取源文件失败: %w", err)
+		return "", err
 	}
+	logrus.Infof("本地复制: %s -> %s", localPath, destPath)
+	return link, nil
+}
 
-	srcInfo, err := src.Stat()
+// verifySHA256 重新读取写入的文件，确认其 SHA-256 与源文件一致
+func verifySHA256(path string, expected hash.Hash) error {
+	file, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("获取源文件信息失败: %w", err)
+		return fmt.Errorf("读取目标文件失败: %w", err)
+	}
+	defer file.Close()
+	actual := sha256.New()
+	if _, err := io.Copy(actual, file); err != nil {
+		return fmt.Errorf("读取目标文件失败: %w", err)
 	}
+	if !bytes.Equal(actual.Sum(nil), expected.Sum(nil)) {
+		return fmt.Errorf("目标文件的SHA-256 %x 与源文件 %x 不一致", actual.Sum(nil), expected.Sum(nil))
+	}
+	return nil
+}
 
-	_, err = dst.Write(make([]byte, srcInfo.Size()))
+// syncDir 将目录项同步到磁盘，确保改名在断电后仍然有效；Windows 和部分网络文件系统不支持，忽略错误
+func syncDir(dir string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	d, err := os.Open(dir)
 	if err != nil {
-		return "", fmt.Errorf("写入目标文件失败: %w", err)
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// link 返回目标文件的链接
+// 配置了 base_url 时以其为前缀：UNC 路径（\\nas\logs）使用反斜杠连接，其他（http、smb 等）使用正斜杠并编码每一段；
+// 否则返回目标文件的 file:// 链接
+func (l *LocalUploader) link(destPath, objectKey string) (string, error) {
+	key := strings.Trim(filepath.ToSlash(filepath.Join(l.config.Bucket, objectKey)), "/")
+	if base := l.config.BaseURL; base != "" {
+		if strings.HasPrefix(base, `\\`) {
+			return strings.TrimRight(base, `\`) + `\` + strings.ReplaceAll(key, "/", `\`), nil
+		}
+		segments := strings.Split(key, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		return strings.TrimRight(base, "/") + "/" + strings.Join(segments, "/"), nil
 	}
 
-	logrus.Infof("本地复制: %s -> %s\n", localPath, destPath)
-	return "file://" + destPath, nil
+	abs, err := filepath.Abs(destPath)
+	if err != nil {
+		return "", fmt.Errorf("获取目标文件路径失败: %w", err)
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		// Windows 路径 C:/logs -> file:///C:/logs
+		abs = "/" + abs
+	}
+	return (&url.URL{Scheme: "file", Path: abs}).String(), nil
 }
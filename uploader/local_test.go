@@ -0,0 +1,72 @@
+package uploader
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"logsnap/remote"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalUpload(t *testing.T) {
+	share := t.TempDir()
+	uploader := NewLocalUploader(remote.UploadConfigProvider{Provider: ProviderLocal, Endpoint: share, Bucket: "logs"})
+	var last int64
+	uploader.SetProgress(func(file string, sent, total int64) { last = sent })
+
+	path, data := writeRandomFile(t, 200*1024)
+	link, err := uploader.Upload(path, filepath.Join("snapshots", "2024", "03", "01", "a b.zip"))
+	require.NoError(t, err)
+	destPath := filepath.Join(share, "logs", "snapshots", "2024", "03", "01", "a b.zip")
+	linkURL, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "file", linkURL.Scheme)
+	assert.Equal(t, "/"+strings.TrimPrefix(filepath.ToSlash(destPath), "/"), linkURL.Path)
+	assert.Contains(t, link, "a%20b.zip")
+	assert.Equal(t, int64(len(data)), last)
+
+	written, err := os.ReadFile(destPath)
+	require.NoError(t, err)
+	assert.Equal(t, data, written)
+	info, err := os.Stat(destPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// 目录中只有目标文件，没有残留的临时文件
+	entries, err := os.ReadDir(filepath.Dir(destPath))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// 再次上传时替换已有的文件
+	path, data = writeRandomFile(t, 10)
+	_, err = uploader.Upload(path, filepath.Join("snapshots", "2024", "03", "01", "a b.zip"))
+	require.NoError(t, err)
+	written, _ = os.ReadFile(destPath)
+	assert.Equal(t, data, written)
+
+	// 源文件不存在时不创建目标文件
+	_, err = uploader.Upload(filepath.Join(t.TempDir(), "missing.zip"), "snapshots/missing.zip")
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(share, "logs", "snapshots", "missing.zip"))
+}
+
+func TestLocalUploadLink(t *testing.T) {
+	share := t.TempDir()
+	path, _ := writeRandomFile(t, 10)
+
+	uploader := NewLocalUploader(remote.UploadConfigProvider{Endpoint: share, Bucket: "logs", BaseURL: `\\nas\share\`})
+	link, err := uploader.Upload(path, "snapshots/2024/a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, `\\nas\share\logs\snapshots\2024\a.zip`, link)
+
+	uploader = NewLocalUploader(remote.UploadConfigProvider{Endpoint: share, BaseURL: "https://nas.example.com/logs/"})
+	link, err = uploader.Upload(path, "snapshots/2024/a b#1.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "https://nas.example.com/logs/snapshots/2024/a%20b%231.zip", link)
+	assert.FileExists(t, filepath.Join(share, "snapshots", "2024", "a b#1.zip"))
+}
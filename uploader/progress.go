@@ -0,0 +1,155 @@
+package uploader
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// uploadStallTimeout 上传期间连接超过该时间没有进展时断开，也是等待服务器响应的超时时间
+const uploadStallTimeout = 2 * time.Minute
+
+// ProgressFunc 上传进度回调，sent 为文件已发送的字节数，total 为文件大小
+type ProgressFunc func(file string, sent, total int64)
+
+// progressSetter 支持上报上传进度的上传实现
+type progressSetter interface {
+	SetProgress(fn ProgressFunc)
+}
+
+// progressTracker 统计一个文件已发送的字节数，按 10% 的步长输出日志并回调进度
+// 分块并行上传时多个 progressReader 共用一个 progressTracker
+type progressTracker struct {
+	file     string
+	total    int64
+	progress ProgressFunc
+
+	mu     sync.Mutex
+	sent   int64
+	logged int64 // 已输出日志的百分比
+}
+
+func newProgressTracker(file string, sent, total int64, progress ProgressFunc) *progressTracker {
+	return &progressTracker{
+		file:     file,
+		total:    total,
+		progress: progress,
+		sent:     sent,
+		logged:   percent(sent, total) / 10 * 10,
+	}
+}
+
+// add 增加已发送的字节数，失败重试时 n 可以为负数
+func (t *progressTracker) add(n int64) {
+	t.mu.Lock()
+	t.sent += n
+	sent := t.sent
+	pct := percent(sent, t.total)
+	log := pct >= t.logged+10
+	if log {
+		t.logged = pct / 10 * 10
+	}
+	t.mu.Unlock()
+
+	if log {
+		logrus.Infof("上传进度 %s: %d%% (%.1f/%.1f MB)", filepath.Base(t.file), pct,
+			float64(sent)/(1<<20), float64(t.total)/(1<<20))
+	}
+	if t.progress != nil {
+		t.progress(t.file, sent, t.total)
+	}
+}
+
+// progressReader 一次请求的内容，读取时更新 progressTracker，并记录最后一次读取的时间用于检测连接卡住
+type progressReader struct {
+	reader  io.Reader
+	tracker *progressTracker
+	length  int64 // 本次请求要发送的字节数
+
+	mu       sync.Mutex
+	read     int64
+	lastRead time.Time
+}
+
+func newProgressReader(reader io.Reader, length int64, tracker *progressTracker) *progressReader {
+	return &progressReader{
+		reader:   reader,
+		tracker:  tracker,
+		length:   length,
+		lastRead: time.Now(),
+	}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.mu.Lock()
+		r.read += int64(n)
+		r.lastRead = time.Now()
+		r.mu.Unlock()
+		r.tracker.add(int64(n))
+	}
+	return n, err
+}
+
+// rewind 请求失败时撤销本次请求已计入的进度，重试时重新计入
+func (r *progressReader) rewind() {
+	r.mu.Lock()
+	read := r.read
+	r.read = 0
+	r.mu.Unlock()
+	r.tracker.add(-read)
+}
+
+// watchStall 在 timeout 时间内没有读取任何数据时调用 cancel，用于断开卡住的连接
+// 上传不设置总超时时间，大文件在慢速网络上可以一直上传，只有连接停止传输时才中断
+func (r *progressReader) watchStall(ctx context.Context, timeout time.Duration, cancel context.CancelFunc) {
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			// 数据已全部发送时等待服务器响应，由 ResponseHeaderTimeout 控制
+			stalled := r.read < r.length && time.Since(r.lastRead) > timeout
+			r.mu.Unlock()
+			if stalled {
+				logrus.Warnf("上传 %s 超过 %v 没有进展，断开连接", filepath.Base(r.tracker.file), timeout)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// newStreamingClient 创建用于上传大文件的HTTP客户端：只限制建立连接和等待响应的时间，不限制传输时间
+func newStreamingClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   30 * time.Second,
+			ResponseHeaderTimeout: uploadStallTimeout,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+// percent 返回 sent 占 total 的百分比
+func percent(sent, total int64) int64 {
+	if total <= 0 {
+		return 100
+	}
+	return sent * 100 / total
+}
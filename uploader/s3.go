@@ -1,53 +1,393 @@
 package uploader
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"logsnap/remote"
+	"logsnap/utils"
 
 	"github.com/sirupsen/logrus"
 )
 
-// S3Uploader 实现S3存储上传
+const (
+	s3DefaultRegion      = "us-east-1"
+	s3DefaultShareExpiry = 7 * 24 * time.Hour
+	s3PartRetries        = 3 // 每个分片失败后的重试次数
+	s3Concurrency        = 4 // 同时上传的分片数
+)
+
+var (
+	s3PartSize           int64 = 16 << 20        // 分片上传时每个分片的大小，S3 要求除最后一个分片外不小于 5MB
+	s3MultipartThreshold int64 = 64 << 20        // 超过该大小的文件使用分片上传
+	s3RetryDelay               = 2 * time.Second // 分片失败后第一次重试前的等待时间，之后每次增加
+)
+
+// S3Uploader 实现S3存储上传，兼容 MinIO、Ceph、OSS 等 S3 兼容服务
+// 请求使用 AWS Signature Version 4 签名；大文件使用分片上传，多个分片并行发送；
+// 上传成功后返回预签名的下载链接，有效期由 share_expiry 配置
 type S3Uploader struct {
-	config remote.UploadConfigProvider
+	config   remote.UploadConfigProvider
+	client   *http.Client
+	signer   *s3Signer
+	progress ProgressFunc
+	now      func() time.Time
 }
 
 func NewS3Uploader(config remote.UploadConfigProvider) *S3Uploader {
-	return &S3Uploader{config: config}
+	region := config.Region
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	return &S3Uploader{
+		config: config,
+		client: newStreamingClient(),
+		signer: &s3Signer{accessKey: config.AccessKey, secretKey: config.SecretKey, region: region},
+		now:    time.Now,
+	}
+}
+
+// SetProgress 设置上传进度回调
+func (s *S3Uploader) SetProgress(fn ProgressFunc) {
+	s.progress = fn
 }
 
 func (s *S3Uploader) Upload(localPath, objectKey string) (string, error) {
-	// 这里实现S3的上传逻辑
-	// 在真实项目中，应该使用AWS SDK
-	logrus.Infof("模拟上传到S3: %s -> %s/%s\n", localPath, s.config.Bucket, objectKey)
-
-	// 实际项目中替换为真实实现：
-	/*
-		sess := session.Must(session.NewSession(&aws.Config{
-			Region:      aws.String(s.config.Region),
-			Endpoint:    aws.String(s.config.Endpoint),
-			Credentials: credentials.NewStaticCredentials(s.config.AccessKey, s.config.SecretKey, ""),
-		}))
-
-		uploader := s3manager.NewUploader(sess)
-		file, err := os.Open(localPath)
-		if err != nil {
-			return "", fmt.Errorf("无法打开文件: %w", err)
+	if s.config.Bucket == "" {
+		return "", errors.New("S3配置缺少存储桶 (bucket)")
+	}
+	expiry, err := s.shareExpiry()
+	if err != nil {
+		return "", err
+	}
+	objectURL, err := s.objectURL(objectKey)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("无法打开文件: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("获取文件信息失败: %w", err)
+	}
+	size := info.Size()
+
+	tracker := newProgressTracker(localPath, 0, size, s.progress)
+	if size > s3MultipartThreshold {
+		err = s.multipartUpload(objectURL, file, size, tracker)
+	} else {
+		err = s.putObject(objectURL, file, size, tracker)
+	}
+	if err != nil {
+		return "", fmt.Errorf("上传到S3失败: %w", err)
+	}
+
+	logrus.Infof("成功上传到S3: %s -> %s/%s", localPath, s.config.Bucket, strings.TrimPrefix(objectURL.Path, "/"))
+	return s.signer.presign(http.MethodGet, objectURL, expiry, s.now()), nil
+}
+
+// objectURL 返回对象的URL：path_style 时为 endpoint/bucket/key，否则为 bucket.endpoint/key
+// 未配置 endpoint 时使用 AWS 的区域端点
+func (s *S3Uploader) objectURL(objectKey string) (*url.URL, error) {
+	endpoint := s.config.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.signer.region)
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("解析S3端点 %s 失败: %w", endpoint, err)
+	}
+
+	var segments []string
+	if base := strings.Trim(u.Path, "/"); base != "" {
+		segments = append(segments, strings.Split(base, "/")...)
+	}
+	if s.config.PathStyle {
+		segments = append(segments, s.config.Bucket)
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(objectKey), "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = s3Escape(segment)
+	}
+	u.Path = "/" + strings.Join(segments, "/")
+	u.RawPath = "/" + strings.Join(escaped, "/")
+	u.RawQuery = ""
+	return u, nil
+}
+
+// shareExpiry 返回分享链接的有效期
+func (s *S3Uploader) shareExpiry() (time.Duration, error) {
+	if s.config.ShareExpiry == "" {
+		return s3DefaultShareExpiry, nil
+	}
+	expiry, err := utils.ParseDuration(s.config.ShareExpiry)
+	if err != nil || expiry <= 0 {
+		return 0, fmt.Errorf("无效的分享链接有效期 share_expiry: %q", s.config.ShareExpiry)
+	}
+	if expiry > s3MaxPresign {
+		logrus.Warnf("S3预签名链接最长有效 7 天，分享链接有效期 %s 改为 7d", s.config.ShareExpiry)
+		expiry = s3MaxPresign
+	}
+	return expiry, nil
+}
+
+// putObject 以流的方式一次上传整个文件
+func (s *S3Uploader) putObject(objectURL *url.URL, file *os.File, size int64, tracker *progressTracker) error {
+	_, err := s.sendFile(context.Background(), http.MethodPut, objectURL, file, 0, size, tracker)
+	return err
+}
+
+// s3Part 已上传的分片
+type s3Part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// multipartUpload 分片上传：创建上传、并行上传分片、完成上传，失败时取消上传，释放服务器上已上传的分片
+func (s *S3Uploader) multipartUpload(objectURL *url.URL, file *os.File, size int64, tracker *progressTracker) error {
+	uploadID, err := s.createMultipartUpload(objectURL)
+	if err != nil {
+		return err
+	}
+
+	count := int((size + s3PartSize - 1) / s3PartSize)
+	logrus.Infof("S3分片上传 %s: %d 个分片", filepath.Base(file.Name()), count)
+	parts, err := s.uploadParts(objectURL, uploadID, file, size, count, tracker)
+	if err == nil {
+		err = s.completeMultipartUpload(objectURL, uploadID, parts)
+	}
+	if err != nil {
+		s.abortMultipartUpload(objectURL, uploadID)
+		return err
+	}
+	return nil
+}
+
+// uploadParts 并行上传所有分片，任意分片重试后仍失败时停止上传其余分片
+func (s *S3Uploader) uploadParts(objectURL *url.URL, uploadID string, file *os.File, size int64, count int, tracker *progressTracker) ([]s3Part, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	parts := make([]s3Part, count)
+	numbers := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i := 0; i < s3Concurrency && i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range numbers {
+				etag, err := s.uploadPart(ctx, objectURL, uploadID, file, number, size, tracker)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				parts[number-1] = s3Part{PartNumber: number, ETag: etag}
+			}
+		}()
+	}
+	for number := 1; number <= count; number++ {
+		select {
+		case numbers <- number:
+		case <-ctx.Done():
+		}
+	}
+	close(numbers)
+	wg.Wait()
+	return parts, firstErr
+}
+
+// uploadPart 上传一个分片，失败时重试
+func (s *S3Uploader) uploadPart(ctx context.Context, objectURL *url.URL, uploadID string, file *os.File, number int, size int64, tracker *progressTracker) (string, error) {
+	offset := int64(number-1) * s3PartSize
+	length := s3PartSize
+	if size-offset < length {
+		length = size - offset
+	}
+	partURL := *objectURL
+	partURL.RawQuery = url.Values{"partNumber": {fmt.Sprint(number)}, "uploadId": {uploadID}}.Encode()
+
+	var err error
+	for attempt := 0; attempt <= s3PartRetries; attempt++ {
+		if attempt > 0 {
+			logrus.Warnf("S3分片 %d 上传失败，第 %d 次重试: %v", number, attempt, err)
+			select {
+			case <-time.After(time.Duration(attempt) * s3RetryDelay):
+			case <-ctx.Done():
+				return "", err
+			}
+		}
+		var resp *http.Response
+		resp, err = s.sendFile(ctx, http.MethodPut, &partURL, file, offset, length, tracker)
+		if err == nil {
+			etag := resp.Header.Get("ETag")
+			if etag == "" {
+				return "", fmt.Errorf("S3分片 %d 的响应中没有 ETag", number)
+			}
+			return etag, nil
 		}
-		defer file.Close()
-
-		result, err := uploader.Upload(&s3manager.UploadInput{
-			Bucket: aws.String(s.config.Bucket),
-			Key:    aws.String(objectKey),
-			Body:   file,
-		})
-		if err != nil {
-			return "", fmt.Errorf("上传到S3失败: %w", err)
+		if ctx.Err() != nil {
+			return "", err
 		}
-		return result.Location, nil
-	*/
+	}
+	return "", fmt.Errorf("S3分片 %d 上传失败: %w", number, err)
+}
+
+// createMultipartUpload 创建分片上传，返回 UploadId
+func (s *S3Uploader) createMultipartUpload(objectURL *url.URL) (string, error) {
+	u := *objectURL
+	u.RawQuery = "uploads="
+	body, err := s.request(http.MethodPost, &u, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建S3分片上传失败: %w", err)
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("解析S3分片上传响应失败: %s", string(body))
+	}
+	return result.UploadID, nil
+}
+
+// completeMultipartUpload 按分片编号合并所有分片
+func (s *S3Uploader) completeMultipartUpload(objectURL *url.URL, uploadID string, parts []s3Part) error {
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	payload, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	u := *objectURL
+	u.RawQuery = url.Values{"uploadId": {uploadID}}.Encode()
+	body, err := s.request(http.MethodPost, &u, payload)
+	if err != nil {
+		return fmt.Errorf("完成S3分片上传失败: %w", err)
+	}
+	// 合并失败时服务器可能返回 200 和 Error 内容
+	if bytes.Contains(body, []byte("<Error>")) {
+		return fmt.Errorf("完成S3分片上传失败: %w", parseS3Error(http.StatusOK, body))
+	}
+	return nil
+}
+
+// abortMultipartUpload 取消分片上传，失败时只输出警告
+func (s *S3Uploader) abortMultipartUpload(objectURL *url.URL, uploadID string) {
+	u := *objectURL
+	u.RawQuery = url.Values{"uploadId": {uploadID}}.Encode()
+	if _, err := s.request(http.MethodDelete, &u, nil); err != nil {
+		logrus.Warnf("取消S3分片上传失败: %v", err)
+	}
+}
+
+// sendFile 发送文件从 offset 开始的 length 字节，内容不参与签名 (UNSIGNED-PAYLOAD)，避免为计算哈希多读一遍文件
+// 连接超过 uploadStallTimeout 没有进展时断开
+func (s *S3Uploader) sendFile(ctx context.Context, method string, u *url.URL, file *os.File, offset, length int64, tracker *progressTracker) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	body := newProgressReader(io.NewSectionReader(file, offset, length), length, tracker)
+	go body.watchStall(ctx, uploadStallTimeout, cancel)
+
+	var reqBody io.Reader = body
+	if length == 0 {
+		reqBody = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", "application/octet-stream")
+	s.signer.sign(req, s3UnsignedPayload, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		body.rewind()
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body.rewind()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, parseS3Error(resp.StatusCode, respBody)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp, nil
+}
+
+// request 发送内容较小的请求（分片上传的创建、完成和取消），内容参与签名，返回响应内容
+func (s *S3Uploader) request(method string, u *url.URL, payload []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), uploadStallTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	hash := sha256.Sum256(payload)
+	s.signer.sign(req, hex.EncodeToString(hash[:]), s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, parseS3Error(resp.StatusCode, body)
+	}
+	return body, nil
+}
 
-	// 返回模拟的URL
-	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", s.config.Bucket, objectKey), nil
+// parseS3Error 解析S3的错误响应
+func parseS3Error(status int, body []byte) error {
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(body, &s3Err); err == nil && s3Err.Code != "" {
+		return fmt.Errorf("S3错误，状态码: %d, %s: %s", status, s3Err.Code, s3Err.Message)
+	}
+	return fmt.Errorf("S3错误，状态码: %d, 响应: %s", status, string(body))
 }
@@ -0,0 +1,252 @@
+package uploader
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"logsnap/remote"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 内存中的S3服务，只支持路径形式的地址，校验每个请求和预签名链接的签名
+type fakeS3 struct {
+	t      *testing.T
+	signer *s3Signer
+	bucket string
+
+	mu         sync.Mutex
+	objects    map[string][]byte
+	uploads    map[string]map[int][]byte
+	nextID     int
+	failParts  map[int]int // 分片编号 -> 剩余的失败次数
+	maxRunning int
+	running    int
+	aborted    int
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	return &fakeS3{
+		t:         t,
+		signer:    &s3Signer{accessKey: "minioadmin", secretKey: "minio-secret", region: "us-east-1"},
+		bucket:    "logs",
+		objects:   make(map[string][]byte),
+		uploads:   make(map[string]map[int][]byte),
+		failParts: make(map[int]int),
+	}
+}
+
+var authPattern = regexp.MustCompile(`Credential=[^/]+/(\d{8})/[^,]+, SignedHeaders=([^,]+), Signature=([0-9a-f]+)`)
+
+// verify 使用请求中声明参与签名的头重新计算签名
+func (f *fakeS3) verify(r *http.Request) bool {
+	if sig := r.URL.Query().Get("X-Amz-Signature"); sig != "" {
+		query := r.URL.Query()
+		query.Del("X-Amz-Signature")
+		date, _ := time.Parse(s3DateFormat, query.Get("X-Amz-Date"))
+		expires, _ := strconv.Atoi(query.Get("X-Amz-Expires"))
+		u := *r.URL
+		u.Scheme, u.Host = "http", r.Host
+		for _, key := range []string{"X-Amz-Algorithm", "X-Amz-Credential", "X-Amz-Date", "X-Amz-Expires", "X-Amz-SignedHeaders"} {
+			query.Del(key)
+		}
+		u.RawQuery = query.Encode()
+		presigned, _ := url.Parse(f.signer.presign(r.Method, &u, time.Duration(expires)*time.Second, date))
+		return presigned.Query().Get("X-Amz-Signature") == sig
+	}
+
+	match := authPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		return false
+	}
+	date, err := time.Parse(s3DateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	signed, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	for _, name := range strings.Split(match[2], ";") {
+		if name != "host" {
+			signed.Header.Set(name, r.Header.Get(name))
+		}
+	}
+	f.signer.sign(signed, r.Header.Get("X-Amz-Content-Sha256"), date)
+	return signed.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.verify(r) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<Error><Code>SignatureDoesNotMatch</Code><Message>bad signature</Message></Error>")
+		return
+	}
+	prefix := "/" + f.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<Error><Code>NoSuchBucket</Code><Message>no such bucket</Message></Error>")
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if f.failParts[number] > 0 {
+			f.failParts[number]--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := f.uploads[query.Get("uploadId")]
+		var complete struct {
+			Parts []s3Part `xml:"Part"`
+		}
+		require.NoError(f.t, xml.Unmarshal(body, &complete))
+		var data []byte
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				fmt.Fprint(w, "<Error><Code>InvalidPart</Code><Message>invalid part</Message></Error>")
+				return
+			}
+			data = append(data, parts[part.PartNumber]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.aborted++
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestS3Uploader(server *httptest.Server, fake *fakeS3) *S3Uploader {
+	return NewS3Uploader(remote.UploadConfigProvider{
+		Provider:    ProviderS3,
+		Endpoint:    server.URL,
+		Bucket:      fake.bucket,
+		AccessKey:   fake.signer.accessKey,
+		SecretKey:   fake.signer.secretKey,
+		PathStyle:   true,
+		ShareExpiry: "24h",
+	})
+}
+
+func TestS3UploadPresignedURL(t *testing.T) {
+	fake := newFakeS3(t)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	path, data := writeRandomFile(t, 1000)
+	shareURL, err := newTestS3Uploader(server, fake).Upload(path, "snapshots/2024/03/01/a b.zip")
+	require.NoError(t, err)
+	assert.Equal(t, data, fake.objects["snapshots/2024/03/01/a b.zip"])
+	assert.Contains(t, shareURL, "/logs/snapshots/2024/03/01/a%20b.zip?")
+	assert.Contains(t, shareURL, "X-Amz-Expires=86400")
+
+	// 预签名链接可以直接下载
+	resp, err := http.Get(shareURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	downloaded, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, data, downloaded)
+
+	// 密钥错误时返回S3的错误信息
+	uploader := newTestS3Uploader(server, fake)
+	uploader.signer.secretKey = "wrong"
+	_, err = uploader.Upload(path, "b.zip")
+	assert.ErrorContains(t, err, "SignatureDoesNotMatch")
+}
+
+func TestS3MultipartUpload(t *testing.T) {
+	partSize, threshold, retryDelay := s3PartSize, s3MultipartThreshold, s3RetryDelay
+	s3PartSize, s3MultipartThreshold, s3RetryDelay = 1<<10, 2<<10, time.Millisecond
+	t.Cleanup(func() { s3PartSize, s3MultipartThreshold, s3RetryDelay = partSize, threshold, retryDelay })
+
+	fake := newFakeS3(t)
+	fake.failParts[3] = 2
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	path, data := writeRandomFile(t, 10*1024+17)
+	uploader := newTestS3Uploader(server, fake)
+	var last int64
+	uploader.SetProgress(func(file string, sent, total int64) {
+		assert.Equal(t, int64(len(data)), total)
+		last = sent
+	})
+	_, err := uploader.Upload(path, "/snapshots/big.zip")
+	require.NoError(t, err)
+	assert.Equal(t, data, fake.objects["snapshots/big.zip"])
+	assert.Equal(t, int64(len(data)), last)
+	assert.Empty(t, fake.uploads)
+
+	// 分片重试后仍失败时取消分片上传
+	fake.failParts[2] = s3PartRetries + 1
+	_, err = uploader.Upload(path, "snapshots/fail.zip")
+	assert.Error(t, err)
+	assert.NotContains(t, fake.objects, "snapshots/fail.zip")
+	assert.Equal(t, 1, fake.aborted)
+	assert.Empty(t, fake.uploads)
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	uploader := NewS3Uploader(remote.UploadConfigProvider{Endpoint: "https://oss-cn-hangzhou.aliyuncs.com", Bucket: "logs"})
+	u, err := uploader.objectURL("xyz/snapshots/2024/03/01/a+b.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "https://logs.oss-cn-hangzhou.aliyuncs.com/xyz/snapshots/2024/03/01/a%2Bb.zip", u.String())
+
+	uploader = NewS3Uploader(remote.UploadConfigProvider{Endpoint: "http://minio:9000/", Bucket: "logs", PathStyle: true})
+	u, err = uploader.objectURL("/snapshots/a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "http://minio:9000/logs/snapshots/a.zip", u.String())
+
+	uploader = NewS3Uploader(remote.UploadConfigProvider{Bucket: "logs", Region: "eu-west-1"})
+	u, err = uploader.objectURL("a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "https://logs.s3.eu-west-1.amazonaws.com/a.zip", u.String())
+
+	_, err = NewS3Uploader(remote.UploadConfigProvider{Bucket: "logs", ShareExpiry: "30d"}).shareExpiry()
+	assert.NoError(t, err)
+	_, err = NewS3Uploader(remote.UploadConfigProvider{Bucket: "logs", ShareExpiry: "soon"}).shareExpiry()
+	assert.Error(t, err)
+}
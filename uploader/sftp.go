@@ -0,0 +1,289 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"logsnap/remote"
+
+	"github.com/pkg/sftp"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	sftpDefaultPort  = "22"
+	sftpDialTimeout  = 30 * time.Second
+	sftpStallTimeout = 2 * time.Minute
+	sftpPartSuffix   = ".logsnap-part" // 上传期间服务器上的临时文件后缀，上传完成后改名
+)
+
+// SftpUploader 实现SFTP上传，适用于只允许通过SSH访问跳板机的站点
+// 支持密码和私钥认证，通过 known_hosts 或配置的 host_key 验证服务器身份；
+// 文件先上传到临时文件，确认大小后再改名为目标文件，服务器上不会出现不完整的快照
+type SftpUploader struct {
+	config   remote.UploadConfigProvider
+	progress ProgressFunc
+}
+
+func NewSftpUploader(config remote.UploadConfigProvider) *SftpUploader {
+	return &SftpUploader{config: config}
+}
+
+// SetProgress 设置上传进度回调
+func (s *SftpUploader) SetProgress(fn ProgressFunc) {
+	s.progress = fn
+}
+
+func (s *SftpUploader) Upload(localPath, objectKey string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("获取文件信息失败: %w", err)
+	}
+	size := info.Size()
+
+	host, err := s.address()
+	if err != nil {
+		return "", err
+	}
+	clientConfig, err := s.clientConfig()
+	if err != nil {
+		return "", err
+	}
+	conn, err := ssh.Dial("tcp", host, clientConfig)
+	if err != nil {
+		return "", fmt.Errorf("连接SFTP服务器 %s 失败: %w", host, err)
+	}
+	defer conn.Close()
+	client, err := sftp.NewClient(conn, sftp.UseConcurrentWrites(true))
+	if err != nil {
+		return "", fmt.Errorf("启动SFTP会话失败: %w", err)
+	}
+	defer client.Close()
+
+	// 相对路径相对于登录用户的主目录
+	remotePath := filepath.ToSlash(objectKey)
+	if err := client.MkdirAll(path.Dir(remotePath)); err != nil {
+		return "", fmt.Errorf("创建SFTP目录 %s 失败: %w", path.Dir(remotePath), err)
+	}
+
+	partPath := remotePath + sftpPartSuffix
+	if err := s.send(conn, client, file, localPath, size, partPath); err != nil {
+		client.Remove(partPath)
+		return "", err
+	}
+	if err := s.rename(client, partPath, remotePath); err != nil {
+		client.Remove(partPath)
+		return "", err
+	}
+
+	remoteURL := (&url.URL{Scheme: "sftp", User: url.User(s.config.Username), Host: host, Path: remotePath}).String()
+	logrus.Infof("成功上传到SFTP: %s -> %s", localPath, remoteURL)
+	return remoteURL, nil
+}
+
+// send 将文件写入服务器上的临时文件并确认大小
+// 连接超过 sftpStallTimeout 没有进展时断开连接
+func (s *SftpUploader) send(conn *ssh.Client, client *sftp.Client, file *os.File, localPath string, size int64, partPath string) error {
+	remoteFile, err := client.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("创建SFTP文件 %s 失败: %w", partPath, err)
+	}
+	defer remoteFile.Close()
+
+	reader := newProgressReader(file, size, newProgressTracker(localPath, 0, size, s.progress))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stalled := make(chan struct{})
+	go reader.watchStall(ctx, sftpStallTimeout, func() {
+		close(stalled)
+		conn.Close()
+	})
+
+	if _, err := io.Copy(remoteFile, reader); err != nil {
+		select {
+		case <-stalled:
+			return fmt.Errorf("上传SFTP文件超过 %v 没有进展: %w", sftpStallTimeout, err)
+		default:
+		}
+		return fmt.Errorf("上传SFTP文件失败: %w", err)
+	}
+	if err := remoteFile.Close(); err != nil {
+		return fmt.Errorf("关闭SFTP文件失败: %w", err)
+	}
+
+	remoteInfo, err := client.Stat(partPath)
+	if err != nil {
+		return fmt.Errorf("确认SFTP上传结果失败: %w", err)
+	}
+	if remoteInfo.Size() != size {
+		return fmt.Errorf("上传后SFTP服务器上的文件大小为 %d，本地文件为 %d", remoteInfo.Size(), size)
+	}
+	return nil
+}
+
+// rename 将临时文件改名为目标文件，目标文件已存在时覆盖
+// 服务器支持 posix-rename 扩展（OpenSSH）时原子替换；否则先删除目标文件再改名
+func (s *SftpUploader) rename(client *sftp.Client, from, to string) error {
+	if _, ok := client.HasExtension("posix-rename@openssh.com"); ok {
+		if err := client.PosixRename(from, to); err != nil {
+			return fmt.Errorf("SFTP文件改名失败: %w", err)
+		}
+		return nil
+	}
+	if err := client.Remove(to); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("删除SFTP服务器上已有的文件失败: %w", err)
+	}
+	if err := client.Rename(from, to); err != nil {
+		return fmt.Errorf("SFTP文件改名失败: %w", err)
+	}
+	return nil
+}
+
+// address 返回服务器地址 host:port，endpoint 可以为 host、host:port 或 sftp://host:port
+func (s *SftpUploader) address() (string, error) {
+	endpoint := strings.TrimSpace(s.config.Endpoint)
+	if endpoint == "" {
+		return "", errors.New("SFTP配置缺少服务器地址 (endpoint)")
+	}
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", fmt.Errorf("解析SFTP服务器地址 %q 失败: %w", endpoint, err)
+		}
+		if u.Scheme != "sftp" && u.Scheme != "ssh" {
+			return "", fmt.Errorf("不支持的SFTP服务器地址 %q", endpoint)
+		}
+		endpoint = u.Host
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		endpoint = net.JoinHostPort(strings.Trim(endpoint, "[]"), sftpDefaultPort)
+	}
+	return endpoint, nil
+}
+
+// clientConfig 根据配置创建SSH客户端配置
+func (s *SftpUploader) clientConfig() (*ssh.ClientConfig, error) {
+	if s.config.Username == "" {
+		return nil, errors.New("SFTP配置缺少用户名 (username)")
+	}
+	var auths []ssh.AuthMethod
+	if s.config.PrivateKey != "" {
+		signer, err := s.privateKey()
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if s.config.Password != "" {
+		auths = append(auths, ssh.Password(s.config.Password))
+	}
+	if len(auths) == 0 {
+		return nil, errors.New("SFTP配置缺少密码 (password) 或私钥 (private_key)")
+	}
+	hostKeyCallback, err := s.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            s.config.Username,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sftpDialTimeout,
+	}, nil
+}
+
+// privateKey 解析私钥，private_key 可以是私钥文件路径或 PEM 格式的私钥内容
+func (s *SftpUploader) privateKey() (ssh.Signer, error) {
+	pemBytes := []byte(s.config.PrivateKey)
+	if !strings.Contains(s.config.PrivateKey, "-----BEGIN") {
+		data, err := os.ReadFile(expandHome(s.config.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("读取SFTP私钥文件失败: %w", err)
+		}
+		pemBytes = data
+	}
+	var signer ssh.Signer
+	var err error
+	if s.config.Passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(s.config.Passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pemBytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析SFTP私钥失败: %w", err)
+	}
+	return signer, nil
+}
+
+// hostKeyCallback 返回验证服务器公钥的回调，不允许跳过验证
+// 配置了 host_key 时只接受该公钥，否则使用 known_hosts 文件
+func (s *SftpUploader) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if hostKey := strings.TrimSpace(s.config.HostKey); hostKey != "" {
+		if strings.HasPrefix(hostKey, "SHA256:") {
+			return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+				if ssh.FingerprintSHA256(key) != hostKey {
+					return fmt.Errorf("SFTP服务器 %s 的公钥指纹 %s 与配置的 host_key 不一致", hostname, ssh.FingerprintSHA256(key))
+				}
+				return nil
+			}, nil
+		}
+		expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return nil, fmt.Errorf("解析SFTP host_key 失败: %w", err)
+		}
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if !bytes.Equal(key.Marshal(), expected.Marshal()) {
+				return fmt.Errorf("SFTP服务器 %s 的公钥 %s 与配置的 host_key 不一致", hostname, ssh.FingerprintSHA256(key))
+			}
+			return nil
+		}, nil
+	}
+
+	knownHostsPath := s.config.KnownHosts
+	if knownHostsPath == "" {
+		knownHostsPath = "~/.ssh/known_hosts"
+	}
+	callback, err := knownhosts.New(expandHome(knownHostsPath))
+	if err != nil {
+		return nil, fmt.Errorf("读取known_hosts文件失败，无法验证SFTP服务器身份（可以配置 host_key）: %w", err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := callback(hostname, remote, key); err != nil {
+			var keyErr *knownhosts.KeyError
+			if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+				return fmt.Errorf("SFTP服务器 %s 不在known_hosts中（公钥指纹 %s）: %w", hostname, ssh.FingerprintSHA256(key), err)
+			}
+			return fmt.Errorf("SFTP服务器 %s 的公钥与known_hosts不一致: %w", hostname, err)
+		}
+		return nil
+	}, nil
+}
+
+// expandHome 将路径开头的 ~ 替换为用户主目录
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~"))
+}
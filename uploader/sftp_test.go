@@ -0,0 +1,215 @@
+package uploader
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"logsnap/remote"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// fakeSftp 进程内的SSH服务器，只提供 sftp 子系统，支持密码和公钥认证
+type fakeSftp struct {
+	addr    string
+	hostKey ssh.PublicKey
+	userKey ed25519.PrivateKey // 允许登录的用户私钥
+}
+
+func newFakeSftp(t *testing.T) *fakeSftp {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	require.NoError(t, err)
+	_, userPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	userSigner, err := ssh.NewSignerFromKey(userPriv)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "logsnap" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, assert.AnError
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "logsnap" && bytes.Equal(key.Marshal(), userSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, assert.AnError
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSftp(conn, config)
+		}
+	}()
+
+	return &fakeSftp{addr: listener.Addr().String(), hostKey: hostSigner.PublicKey(), userKey: userPriv}
+}
+
+func serveSftp(conn net.Conn, config *ssh.ServerConfig) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range channelRequests {
+				// 子系统请求的负载为 uint32 长度 + 名称
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel)
+					if err != nil {
+						channel.Close()
+						return
+					}
+					server.Serve()
+					server.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (f *fakeSftp) provider(dir string) remote.UploadConfigProvider {
+	return remote.UploadConfigProvider{
+		Provider:   ProviderSftp,
+		Endpoint:   "sftp://" + f.addr,
+		Username:   "logsnap",
+		Password:   "secret",
+		FolderPath: dir,
+		HostKey:    ssh.FingerprintSHA256(f.hostKey),
+	}
+}
+
+func TestSftpUploadWithPassword(t *testing.T) {
+	server := newFakeSftp(t)
+	dir := filepath.ToSlash(t.TempDir())
+
+	path, data := writeRandomFile(t, 300*1024)
+	uploader := NewSftpUploader(server.provider(dir))
+	var last int64
+	uploader.SetProgress(func(file string, sent, total int64) { last = sent })
+	remoteURL, err := uploader.Upload(path, dir+"/snapshots/2024/03/01/a.zip")
+	require.NoError(t, err)
+	assert.Equal(t, "sftp://logsnap@"+server.addr+dir+"/snapshots/2024/03/01/a.zip", remoteURL)
+	assert.Equal(t, int64(len(data)), last)
+
+	uploaded, err := os.ReadFile(filepath.Join(dir, "snapshots/2024/03/01/a.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, data, uploaded)
+	assert.NoFileExists(t, filepath.Join(dir, "snapshots/2024/03/01/a.zip"+sftpPartSuffix))
+
+	// 再次上传时覆盖已有的文件
+	path, data = writeRandomFile(t, 1000)
+	_, err = uploader.Upload(path, dir+"/snapshots/2024/03/01/a.zip")
+	require.NoError(t, err)
+	uploaded, _ = os.ReadFile(filepath.Join(dir, "snapshots/2024/03/01/a.zip"))
+	assert.Equal(t, data, uploaded)
+
+	// 密码错误
+	config := server.provider(dir)
+	config.Password = "wrong"
+	_, err = NewSftpUploader(config).Upload(path, dir+"/b.zip")
+	assert.ErrorContains(t, err, "连接SFTP服务器")
+}
+
+func TestSftpUploadWithKeyAndKnownHosts(t *testing.T) {
+	server := newFakeSftp(t)
+	dir := filepath.ToSlash(t.TempDir())
+
+	block, err := ssh.MarshalPrivateKey(server.userKey, "")
+	require.NoError(t, err)
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{server.addr}, server.hostKey)+"\n"), 0600))
+
+	config := server.provider(dir)
+	config.Password = ""
+	config.HostKey = ""
+	config.Endpoint = server.addr
+	config.PrivateKey = string(pem.EncodeToMemory(block))
+	config.KnownHosts = knownHosts
+
+	path, data := writeRandomFile(t, 2000)
+	_, err = NewSftpUploader(config).Upload(path, dir+"/snapshots/a.zip")
+	require.NoError(t, err)
+	uploaded, _ := os.ReadFile(filepath.Join(dir, "snapshots/a.zip"))
+	assert.Equal(t, data, uploaded)
+
+	// 私钥也可以是文件路径
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+	config.PrivateKey = keyFile
+	_, err = NewSftpUploader(config).Upload(path, dir+"/snapshots/b.zip")
+	require.NoError(t, err)
+
+	// 服务器不在 known_hosts 中时拒绝连接
+	require.NoError(t, os.WriteFile(knownHosts, nil, 0600))
+	_, err = NewSftpUploader(config).Upload(path, dir+"/snapshots/c.zip")
+	assert.ErrorContains(t, err, "不在known_hosts中")
+	assert.NoFileExists(t, filepath.Join(dir, "snapshots/c.zip"))
+
+	// host_key 与服务器公钥不一致时拒绝连接
+	config.HostKey = "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	_, err = NewSftpUploader(config).Upload(path, dir+"/snapshots/c.zip")
+	assert.ErrorContains(t, err, "与配置的 host_key 不一致")
+
+	// host_key 也可以是 authorized_keys 格式的公钥
+	config.HostKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(server.hostKey)))
+	_, err = NewSftpUploader(config).Upload(path, dir+"/snapshots/c.zip")
+	assert.NoError(t, err)
+}
+
+func TestSftpAddress(t *testing.T) {
+	tests := map[string]string{
+		"jump.example.com":            "jump.example.com:22",
+		"jump.example.com:2222":       "jump.example.com:2222",
+		"sftp://jump.example.com":     "jump.example.com:22",
+		"sftp://jump.example.com:222": "jump.example.com:222",
+		"[::1]":                       "[::1]:22",
+	}
+	for endpoint, want := range tests {
+		got, err := NewSftpUploader(remote.UploadConfigProvider{Endpoint: endpoint}).address()
+		require.NoError(t, err, endpoint)
+		assert.Equal(t, want, got, endpoint)
+	}
+
+	_, err := NewSftpUploader(remote.UploadConfigProvider{Endpoint: "https://example.com"}).address()
+	assert.Error(t, err)
+	_, err = NewSftpUploader(remote.UploadConfigProvider{}).address()
+	assert.Error(t, err)
+}
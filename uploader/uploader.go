@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"logsnap/remote"
@@ -19,6 +20,8 @@ const (
 	ProviderLocal     = "local"
 	ProviderWebdav    = "webdav"
 	ProviderCloudreve = "cloudreve"
+	ProviderSftp      = "sftp"
+	ProviderHTTP      = "http"
 )
 
 type CloudUploaderInterface interface {
@@ -27,7 +30,23 @@ type CloudUploaderInterface interface {
 
 // Uploader 负责将日志上传到云存储
 type Uploader struct {
-	config *remote.UploadConfig
+	config   *remote.UploadConfig
+	progress ProgressFunc
+	metadata Metadata
+}
+
+// Metadata 快照的描述信息，支持的上传实现（例如 http）随文件一起提交给服务器
+type Metadata struct {
+	Host        string    // 采集主机名
+	Start       time.Time // 快照时间范围的开始时间
+	End         time.Time // 快照时间范围的结束时间
+	Description string    // 上传描述
+	Tags        []string  // 标签
+}
+
+// metadataSetter 支持提交快照描述信息的上传实现
+type metadataSetter interface {
+	SetMetadata(metadata Metadata)
 }
 
 // NewUploader 创建新的上传器
@@ -37,20 +56,93 @@ func NewUploader(cfg remote.UploadConfig) *Uploader {
 	}
 }
 
-// Upload 上传指定的日志包到云存储
+// SetProgress 设置上传进度回调，上传实现不支持上报进度时不回调
+func (u *Uploader) SetProgress(fn ProgressFunc) {
+	u.progress = fn
+}
+
+// SetMetadata 设置快照的描述信息，上传实现不支持时忽略
+func (u *Uploader) SetMetadata(metadata Metadata) {
+	u.metadata = metadata
+}
+
+// Upload 上传指定的日志包到云存储，返回第一个上传成功的目标中的链接
 func (u *Uploader) Upload(filePath string) (string, error) {
-	// 检查文件是否存在
-	_, err := os.Stat(filePath)
+	urls, err := u.UploadFiles([]string{filePath})
+	if err != nil {
+		return "", err
+	}
+	return urls[0], nil
+}
+
+// UploadFiles 将多个文件（例如快照的所有分卷及索引）上传到同一个云端目录
+// 返回每个上传成功的目标中与 filePaths 一一对应的链接，配置了 fan_out 时依次包含每个目标的链接
+func (u *Uploader) UploadFiles(filePaths []string) ([]string, error) {
+	results, err := u.UploadDestinations(filePaths)
 	if err != nil {
-		return "", fmt.Errorf("上传文件不存在或无法访问: %w", err)
+		return nil, err
 	}
+	var urls []string
+	for _, result := range results {
+		if result.Err == nil {
+			urls = append(urls, result.URLs...)
+		}
+	}
+	return urls, nil
+}
 
-	// 根据配置的提供商选择对应的上传实现
-	var uploader CloudUploaderInterface
-	provider := u.config.GetProvider(u.config.DefaultProvider)
+// objectKeys 返回每个文件相对于提供商 folder_path 的对象键
+// 单个文件为 日期/md5_文件名；多个文件以第一个文件的快照名称作为目录，例如 logsnap_xxx.part001.zip -> 日期/md5_logsnap_xxx/
+func objectKeys(filePaths []string) ([]string, error) {
+	if len(filePaths) == 0 {
+		return nil, errors.New("没有要上传的文件")
+	}
+	var first os.FileInfo
+	for _, filePath := range filePaths {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("上传文件不存在或无法访问: %w", err)
+		}
+		if first == nil {
+			first = info
+		}
+	}
+
+	md5 := md5.Sum([]byte(filePaths[0]))
+	md5Str := hex.EncodeToString(md5[:])
+	if len(filePaths) == 1 {
+		return []string{filepath.Join(dateFolder(first), md5Str+"_"+filepath.Base(filePaths[0]))}, nil
+	}
+
+	snapName := strings.SplitN(filepath.Base(filePaths[0]), ".", 2)[0]
+	folder := filepath.Join(dateFolder(first), md5Str+"_"+snapName)
+	keys := make([]string, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		keys = append(keys, filepath.Join(folder, filepath.Base(filePath)))
+	}
+	return keys, nil
+}
+
+// dateFolder 返回文件的日期目录，例如 2024/03/01
+// 使用文件的修改时间而不是当前时间，上传失败后第二天重试时对象键不变，支持续传的上传实现可以继续上传
+func dateFolder(info os.FileInfo) string {
+	return info.ModTime().Format("2006/01/02")
+}
+
+// createUploader 根据提供商名称创建对应的上传实现，progress 为 false 时不上报进度
+func (u *Uploader) createUploader(name string, progress bool) (CloudUploaderInterface, *remote.UploadConfigProvider, error) {
+	provider := u.config.GetProvider(name)
 	if provider == nil {
-		return "", errors.New("不支持的云存储提供商: " + u.config.DefaultProvider)
+		return nil, nil, errors.New("不支持的云存储提供商: " + name)
+	}
+	// 凭证引用在上传时才解析，解析后的凭证只保存在上传实现中
+	resolved, err := provider.WithResolvedCredentials()
+	if err != nil {
+		return nil, nil, err
 	}
+	provider = &resolved
+
+	var uploader CloudUploaderInterface
 	switch provider.Provider {
 	case ProviderWebdav:
 		uploader = NewWebdavUploader(*provider)
@@ -64,18 +156,20 @@ func (u *Uploader) Upload(filePath string) (string, error) {
 	case ProviderCloudreve:
 		uploader = NewCloudreveUploader(*provider)
 		logrus.Infof("使用Cloudreve上传器")
+	case ProviderSftp:
+		uploader = NewSftpUploader(*provider)
+		logrus.Infof("使用SFTP上传器")
+	case ProviderHTTP:
+		uploader = NewHTTPUploader(*provider)
+		logrus.Infof("使用HTTP上传器")
 	default:
-		return "", errors.New("不支持的云存储提供商: " + provider.Provider)
+		return nil, nil, errors.New("不支持的云存储提供商: " + provider.Provider)
 	}
-
-	// 生成云端对象键
-	fileName := filepath.Base(filePath)
-
-	// 计算文件的md5
-	md5 := md5.Sum([]byte(filePath))
-	md5Str := hex.EncodeToString(md5[:])
-	objectKey := filepath.Join(provider.FolderPath, time.Now().Format("2006/01/02"), md5Str+"_"+fileName)
-
-	// 执行上传
-	return uploader.Upload(filePath, objectKey)
+	if setter, ok := uploader.(progressSetter); ok && progress && u.progress != nil {
+		setter.SetProgress(u.progress)
+	}
+	if setter, ok := uploader.(metadataSetter); ok {
+		setter.SetMetadata(u.metadata)
+	}
+	return uploader, provider, nil
 }
@@ -1,12 +1,16 @@
 package uploader
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"logsnap/remote"
@@ -14,59 +18,343 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	webdavChunkRetries   = 3                // 每块失败后的重试次数
+	webdavPartSuffix     = ".logsnap-part"  // 分块上传期间服务器上的临时文件后缀，上传完成后改名
+	webdavRequestTimeout = 30 * time.Second // 不带文件内容的请求（HEAD、MOVE 等）的超时时间
+)
+
+var (
+	webdavChunkSize  int64 = 16 << 20        // 分块上传时每块的大小，超过该大小的文件分块上传
+	webdavRetryDelay       = 5 * time.Second // 分块上传失败后第一次重试前的等待时间，之后每次增加
+)
+
+// 服务器支持的部分写入方式
+const (
+	partialNone     = iota // 不支持，只能整个文件上传
+	partialRangePut        // PUT 请求带 Content-Range，例如 Apache mod_dav
+	partialSabre           // PATCH 请求带 X-Update-Range，SabreDAV 的 partialupdate 插件
+)
+
+// errPartialUnsupported 服务器不支持部分写入，改为整个文件上传
+var errPartialUnsupported = errors.New("服务器不支持部分写入")
+
+// webdavStatusError 服务器对带文件内容的请求返回的错误状态
+type webdavStatusError struct {
+	code int
+	body string
+}
+
+func (e *webdavStatusError) Error() string {
+	return fmt.Sprintf("WebDAV上传失败，状态码: %d, 响应: %s", e.code, e.body)
+}
+
+// rangeRejected 返回服务器是否拒绝了带 Content-Range 的 PUT
+// SabreDAV、Nextcloud 返回 400，其他服务器可能返回 403、405、411 或 501，这类错误重试也不会成功
+func rangeRejected(err error) bool {
+	var statusErr *webdavStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.code {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusMethodNotAllowed,
+		http.StatusLengthRequired, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
 // WebdavUploader 实现WebDAV存储上传
+// 上传前通过 MKCOL 创建不存在的父目录，上传后通过 PROPFIND 确认服务器上的文件大小；
+// 文件以流的方式上传，不读入内存，也不设置总超时时间；超过 webdavChunkSize 的文件在服务器支持部分写入时
+// 分块上传到临时文件，中断后再次上传同一个对象时从服务器上已有的位置继续
 type WebdavUploader struct {
-	config remote.UploadConfigProvider
+	config      remote.UploadConfigProvider
+	client      *http.Client
+	progress    ProgressFunc
+	collections map[string]bool // 已确认存在的目录，相对于 Endpoint
 }
 
 func NewWebdavUploader(config remote.UploadConfigProvider) *WebdavUploader {
-	return &WebdavUploader{config: config}
+	return &WebdavUploader{config: config, client: newStreamingClient()}
+}
+
+// SetProgress 设置上传进度回调
+func (w *WebdavUploader) SetProgress(fn ProgressFunc) {
+	w.progress = fn
 }
 
 func (w *WebdavUploader) Upload(localPath, objectKey string) (string, error) {
-	// 读取文件内容
-	fileContent, err := os.ReadFile(localPath)
+	file, err := os.Open(localPath)
 	if err != nil {
-		return "", fmt.Errorf("读取文件失败: %w", err)
+		return "", fmt.Errorf("打开文件失败: %w", err)
 	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("获取文件信息失败: %w", err)
+	}
+	size := info.Size()
 
-	// 构建WebDAV URL
-	webdavURL := fmt.Sprintf("%s/%s", w.config.Endpoint, objectKey)
-	webdavURL = filepath.ToSlash(webdavURL) // 确保URL使用正斜杠
+	// 构建WebDAV URL，文件名中的空格、#、? 等字符需要转义
+	objectKey = strings.Trim(filepath.ToSlash(objectKey), "/") // 确保URL使用正斜杠
+	webdavURL := strings.TrimSuffix(w.config.Endpoint, "/") + "/" + escapePath(objectKey)
 
-	// 创建HTTP请求
-	req, err := http.NewRequest("PUT", webdavURL, bytes.NewReader(fileContent))
+	// 部分服务器（例如 Nextcloud、Apache mod_dav）不会自动创建父目录
+	if err := w.ensureCollections(path.Dir(objectKey)); err != nil {
+		return "", err
+	}
+
+	uploaded := false
+	if size > webdavChunkSize {
+		err := w.uploadChunked(file, size, webdavURL)
+		if err != nil && !errors.Is(err, errPartialUnsupported) {
+			return "", err
+		}
+		uploaded = err == nil
+		if !uploaded {
+			logrus.Infof("WebDAV服务器不支持部分写入，整个文件上传: %s", localPath)
+		}
+	}
+	if !uploaded {
+		if err := w.put(webdavURL, file, 0, size, size, false); err != nil {
+			return "", err
+		}
+	}
+
+	// 确认文件已经写入服务器且大小一致
+	if err := w.verify(webdavURL, size); err != nil {
+		return "", err
+	}
+	logrus.Infof("成功上传到WebDAV: %s -> %s", localPath, webdavURL)
+	return webdavURL, nil
+}
+
+// escapePath 转义路径中的每一段，保留分隔的斜杠
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// uploadChunked 分块上传到临时文件，完成后改名为目标文件
+// 临时文件已存在时从其大小处继续上传；服务器不支持部分写入时返回 errPartialUnsupported
+func (w *WebdavUploader) uploadChunked(file *os.File, size int64, target string) error {
+	mode := w.partialMode()
+	if mode == partialNone {
+		return errPartialUnsupported
+	}
+	partURL := target + webdavPartSuffix
+
+	offset, err := w.remoteSize(partURL)
 	if err != nil {
-		return "", fmt.Errorf("创建WebDAV请求失败: %w", err)
+		return err
 	}
+	if offset > size {
+		// 临时文件比本地文件大，不是同一个文件的上传，重新开始
+		w.remove(partURL)
+		offset = 0
+	}
+	if offset > 0 {
+		logrus.Infof("从 %.1f MB 处继续上传 %s", float64(offset)/(1<<20), filepath.Base(file.Name()))
+	}
+
+	for offset < size {
+		length := webdavChunkSize
+		if size-offset < length {
+			length = size - offset
+		}
+		if err := w.sendChunk(mode, partURL, file, offset, length, size); err != nil {
+			if errors.Is(err, errPartialUnsupported) {
+				w.remove(partURL)
+			}
+			return err
+		}
+
+		// 确认服务器上的大小，忽略 Content-Range 的服务器会用本块覆盖整个文件
+		got, err := w.remoteSize(partURL)
+		if err != nil {
+			return err
+		}
+		if got != offset+length {
+			w.remove(partURL)
+			if mode == partialRangePut {
+				return errPartialUnsupported
+			}
+			return fmt.Errorf("WebDAV分块上传后服务器上的大小为 %d，期望 %d", got, offset+length)
+		}
+		offset += length
+	}
+
+	return w.move(partURL, target)
+}
+
+// sendChunk 发送一块数据，失败时重新确认服务器上的大小后重试
+// 服务器拒绝带 Content-Range 的 PUT 时不重试，返回 errPartialUnsupported
+func (w *WebdavUploader) sendChunk(mode int, partURL string, file *os.File, offset, length, size int64) error {
+	var err error
+	for attempt := 0; attempt <= webdavChunkRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * webdavRetryDelay)
+			logrus.Warnf("WebDAV分块上传失败，第 %d 次重试: %v", attempt, err)
+			// 失败的请求可能已写入了部分数据，从服务器上的实际大小继续
+			if got, sizeErr := w.remoteSize(partURL); sizeErr == nil && got > offset && got < offset+length {
+				length -= got - offset
+				offset = got
+			}
+		}
+		switch {
+		case offset == 0:
+			// 第一块创建临时文件，SabreDAV 拒绝带 Content-Range 的 PUT
+			err = w.put(partURL, file, 0, length, size, mode == partialRangePut)
+		case mode == partialSabre:
+			err = w.patch(partURL, file, offset, length, size)
+		default:
+			err = w.put(partURL, file, offset, length, size, true)
+		}
+		if err == nil {
+			return nil
+		}
+		if mode == partialRangePut && rangeRejected(err) {
+			logrus.Debugf("WebDAV服务器拒绝带 Content-Range 的 PUT: %v", err)
+			return errPartialUnsupported
+		}
+	}
+	return err
+}
 
-	// 设置基本认证
+// partialMode 通过 OPTIONS 请求检测服务器支持的部分写入方式
+// SabreDAV 在 DAV 头中声明 sabredav-partialupdate；其他服务器先尝试带 Content-Range 的 PUT，
+// 服务器拒绝该请求或上传后大小不符时改为整个文件上传
+func (w *WebdavUploader) partialMode() int {
+	resp, err := w.do(http.MethodOptions, w.config.Endpoint+"/", nil, nil)
+	if err != nil {
+		return partialNone
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return partialNone
+	}
+	if strings.Contains(resp.Header.Get("DAV"), "sabredav-partialupdate") {
+		return partialSabre
+	}
+	return partialRangePut
+}
+
+// put 以流的方式上传文件从 offset 开始的 length 字节，ranged 为 true 时带 Content-Range 写入服务器上文件的对应位置
+func (w *WebdavUploader) put(url string, file *os.File, offset, length, size int64, ranged bool) error {
+	return w.send(http.MethodPut, url, file, offset, length, size, func(req *http.Request) {
+		if ranged {
+			req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+		}
+	})
+}
+
+// patch 使用 SabreDAV 的 partialupdate 写入从 offset 开始的 length 字节
+func (w *WebdavUploader) patch(url string, file *os.File, offset, length, size int64) error {
+	return w.send(http.MethodPatch, url, file, offset, length, size, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/x-sabredav-partialupdate")
+		req.Header.Set("X-Update-Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	})
+}
+
+// send 发送带文件内容的请求，上传期间连接超过 uploadStallTimeout 没有进展时断开
+func (w *WebdavUploader) send(method, url string, file *os.File, offset, length, size int64, prepare func(*http.Request)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tracker := newProgressTracker(file.Name(), offset, size, w.progress)
+	body := newProgressReader(io.NewSectionReader(file, offset, length), length, tracker)
+	go body.watchStall(ctx, uploadStallTimeout, cancel)
+
+	var reqBody io.Reader = body
+	if length == 0 {
+		// 空文件不发送内容，否则会使用 chunked 编码
+		reqBody = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return fmt.Errorf("创建WebDAV请求失败: %w", err)
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", "application/octet-stream")
 	if w.config.Username != "" {
 		req.SetBasicAuth(w.config.Username, w.config.Password)
 	}
+	prepare(req)
 
-	// 设置内容类型和长度
-	req.Header.Set("Content-Type", "application/octet-stream")
-	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(fileContent)))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("WebDAV上传请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &webdavStatusError{code: resp.StatusCode, body: string(respBody)}
+	}
+	return nil
+}
 
-	// 发送请求
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// move 将临时文件改名为目标文件
+func (w *WebdavUploader) move(from, to string) error {
+	resp, err := w.do("MOVE", from, nil, func(req *http.Request) {
+		req.Header.Set("Destination", to)
+		req.Header.Set("Overwrite", "T")
+	})
 	if err != nil {
-		return "", fmt.Errorf("WebDAV上传请求失败: %w", err)
+		return fmt.Errorf("WebDAV移动文件失败: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("WebDAV移动文件失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
 
-	// 打印响应
-	body, _ := io.ReadAll(resp.Body)
-	logrus.Infof("WebDAV上传响应: %s", string(body))
+// remove 删除服务器上的文件，失败时只输出警告
+func (w *WebdavUploader) remove(url string) {
+	resp, err := w.do(http.MethodDelete, url, nil, nil)
+	if err != nil {
+		logrus.Warnf("删除WebDAV文件 %s 失败: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+}
 
-	// 检查响应状态
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("WebDAV上传失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+// do 发送不带文件内容的请求，body 为 PROPFIND 等请求的 XML 内容
+func (w *WebdavUploader) do(method, url string, body io.Reader, prepare func(*http.Request)) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webdavRequestTimeout)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
+	if w.config.Username != "" {
+		req.SetBasicAuth(w.config.Username, w.config.Password)
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
 
-	logrus.Infof("成功上传到WebDAV: %s -> %s\n", localPath, webdavURL)
-	return webdavURL, nil
+// cancelOnClose 关闭响应时释放请求的上下文
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
 }
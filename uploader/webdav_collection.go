@@ -0,0 +1,171 @@
+package uploader
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// propfindBody 查询资源类型和大小的 PROPFIND 请求
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/></d:prop></d:propfind>`
+
+// davResource 服务器上的文件或目录
+type davResource struct {
+	Exists     bool
+	Collection bool  // 是否为目录
+	Size       int64 // 文件大小，目录为 0
+}
+
+// davMultistatus PROPFIND 的 207 响应
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// stat 通过 PROPFIND (Depth: 0) 查询服务器上的文件或目录，不存在时 Exists 为 false
+func (w *WebdavUploader) stat(url string) (davResource, error) {
+	resp, err := w.do("PROPFIND", url, strings.NewReader(propfindBody), func(req *http.Request) {
+		req.Header.Set("Depth", "0")
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	})
+	if err != nil {
+		return davResource{}, fmt.Errorf("查询WebDAV文件信息失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return davResource{}, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return davResource{}, fmt.Errorf("查询WebDAV文件信息失败，状态码: %d", resp.StatusCode)
+	}
+
+	var multistatus davMultistatus
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&multistatus); err != nil {
+		return davResource{}, fmt.Errorf("解析WebDAV PROPFIND响应失败: %w", err)
+	}
+	if len(multistatus.Responses) == 0 {
+		return davResource{}, fmt.Errorf("WebDAV PROPFIND响应中没有 %s 的信息", url)
+	}
+
+	resource := davResource{Exists: true}
+	for _, propstat := range multistatus.Responses[0].Propstat {
+		// 只使用状态为 200 的属性，不支持的属性在 404 的 propstat 中返回
+		if propstat.Status != "" && !strings.Contains(propstat.Status, " 200") {
+			continue
+		}
+		if propstat.Prop.ResourceType.Collection != nil {
+			resource.Collection = true
+		}
+		if length := strings.TrimSpace(propstat.Prop.ContentLength); length != "" {
+			size, err := strconv.ParseInt(length, 10, 64)
+			if err != nil {
+				return davResource{}, fmt.Errorf("解析WebDAV文件大小 %q 失败: %w", length, err)
+			}
+			resource.Size = size
+		}
+	}
+	return resource, nil
+}
+
+// remoteSize 返回服务器上文件的大小，文件不存在时返回 0
+func (w *WebdavUploader) remoteSize(url string) (int64, error) {
+	resource, err := w.stat(url)
+	if err != nil {
+		return 0, err
+	}
+	return resource.Size, nil
+}
+
+// verify 确认上传后服务器上存在该文件且大小与本地文件一致
+func (w *WebdavUploader) verify(url string, size int64) error {
+	resource, err := w.stat(url)
+	if err != nil {
+		return fmt.Errorf("确认WebDAV上传结果失败: %w", err)
+	}
+	if !resource.Exists || resource.Collection {
+		return fmt.Errorf("上传后WebDAV服务器上不存在文件 %s", url)
+	}
+	if resource.Size != size {
+		return fmt.Errorf("上传后WebDAV服务器上的文件大小为 %d，本地文件为 %d", resource.Size, size)
+	}
+	return nil
+}
+
+// ensureCollections 创建 dir（相对于 Endpoint 的路径，例如 snapshots/2024/03/01）中不存在的目录
+// 先查询最深一级目录，不存在时从上到下逐级 MKCOL，已创建过的目录记录在上传器中，同一次上传的多个文件不重复查询
+func (w *WebdavUploader) ensureCollections(dir string) error {
+	dir = strings.Trim(dir, "/")
+	if dir == "" || dir == "." || w.collections[dir] {
+		return nil
+	}
+	base := strings.TrimSuffix(w.config.Endpoint, "/")
+
+	resource, err := w.stat(base + "/" + escapePath(dir) + "/")
+	if err != nil {
+		return err
+	}
+	if !resource.Exists {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			collection := strings.Join(parts[:i+1], "/")
+			if w.collections[collection] {
+				continue
+			}
+			if err := w.mkcol(base + "/" + escapePath(collection) + "/"); err != nil {
+				return err
+			}
+			w.markCollection(collection)
+		}
+	} else if !resource.Collection {
+		return fmt.Errorf("WebDAV服务器上的 %s 不是目录", dir)
+	}
+	w.markCollection(dir)
+	return nil
+}
+
+// mkcol 创建目录，目录已存在时不返回错误
+func (w *WebdavUploader) mkcol(url string) error {
+	resp, err := w.do("MKCOL", url, nil, nil)
+	if err != nil {
+		return fmt.Errorf("创建WebDAV目录失败: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		// RFC 4918：目标已存在时返回 405
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("创建WebDAV目录 %s 失败，状态码: %d, 响应: %s", url, resp.StatusCode, string(respBody))
+}
+
+// markCollection 记录目录及其所有上级目录已存在
+func (w *WebdavUploader) markCollection(dir string) {
+	if w.collections == nil {
+		w.collections = make(map[string]bool)
+	}
+	for dir != "" && dir != "." {
+		w.collections[dir] = true
+		i := strings.LastIndex(dir, "/")
+		if i < 0 {
+			break
+		}
+		dir = dir[:i]
+	}
+}
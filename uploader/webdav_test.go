@@ -0,0 +1,325 @@
+package uploader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"logsnap/remote"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDAV 内存中的WebDAV服务器，支持 PUT（可选 Content-Range）、PATCH（SabreDAV partialupdate）、
+// PROPFIND、MKCOL、OPTIONS、MOVE 和 DELETE；与 Apache mod_dav 一样，父目录不存在时 PUT 和 MKCOL 返回 409
+type fakeDAV struct {
+	mu          sync.Mutex
+	files       map[string][]byte
+	dirs        map[string]bool
+	mkcols      []string
+	ignoreRange bool // 忽略 Content-Range，模拟不支持部分写入的服务器
+	rejectRange bool // 带 Content-Range 的 PUT 返回 400，与 SabreDAV、Nextcloud 相同
+	sabre       bool // 声明并支持 SabreDAV 的 partialupdate
+	failPut     int  // 接下来失败的带 Content-Range 的 PUT 次数，失败前写入一半数据
+	puts        int
+	patches     int
+	received    int64
+}
+
+func newFakeDAV() *fakeDAV {
+	return &fakeDAV{files: make(map[string][]byte), dirs: map[string]bool{"/": true}}
+}
+
+func (d *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 2")
+		if d.sabre {
+			w.Header().Set("DAV", "1, 2, 3, extended-mkcol, sabredav-partialupdate")
+		}
+	case "PROPFIND":
+		name := strings.TrimSuffix(r.URL.Path, "/")
+		prop := ""
+		if data, ok := d.files[name]; ok {
+			prop = fmt.Sprintf("<d:resourcetype/><d:getcontentlength>%d</d:getcontentlength>", len(data))
+		} else if d.dirs[name+"/"] {
+			prop = "<d:resourcetype><d:collection/></d:resourcetype>"
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:"><d:response><d:href>%s</d:href>`+
+			`<d:propstat><d:prop>%s</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`,
+			r.URL.Path, prop)
+	case "MKCOL":
+		name := strings.TrimSuffix(r.URL.Path, "/") + "/"
+		d.mkcols = append(d.mkcols, name)
+		switch {
+		case d.dirs[name]:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case !d.dirs[path.Dir(strings.TrimSuffix(name, "/"))+"/"] && path.Dir(strings.TrimSuffix(name, "/")) != "/":
+			w.WriteHeader(http.StatusConflict)
+		default:
+			d.dirs[name] = true
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodPut:
+		if dir := path.Dir(r.URL.Path); dir != "/" && !d.dirs[dir+"/"] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		d.puts++
+		if r.Header.Get("Content-Range") != "" && d.rejectRange {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		d.received += int64(len(body))
+		var start, end, total int64
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil || d.ignoreRange {
+			d.files[r.URL.Path] = body
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		data := d.files[r.URL.Path]
+		if int64(len(data)) < start {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if d.failPut > 0 {
+			d.failPut--
+			body = body[:len(body)/2]
+			d.files[r.URL.Path] = append(data[:start:start], body...)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		d.files[r.URL.Path] = append(data[:start:start], body...)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPatch:
+		var start, end int64
+		data, ok := d.files[r.URL.Path]
+		if !d.sabre || r.Header.Get("Content-Type") != "application/x-sabredav-partialupdate" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if _, err := fmt.Sscanf(r.Header.Get("X-Update-Range"), "bytes=%d-%d", &start, &end); err != nil || !ok || int64(len(data)) < start {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		d.patches++
+		body, _ := io.ReadAll(r.Body)
+		d.received += int64(len(body))
+		d.files[r.URL.Path] = append(data[:start:start], body...)
+		w.WriteHeader(http.StatusNoContent)
+	case "MOVE":
+		destination, err := url.Parse(r.Header.Get("Destination"))
+		data, ok := d.files[r.URL.Path]
+		if err != nil || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		d.files[destination.Path] = data
+		delete(d.files, r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		delete(d.files, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeRandomFile(t *testing.T, size int) (string, []byte) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	path := filepath.Join(t.TempDir(), "logsnap_test.zip")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path, data
+}
+
+func useSmallChunks(t *testing.T) {
+	chunkSize, retryDelay := webdavChunkSize, webdavRetryDelay
+	webdavChunkSize, webdavRetryDelay = 1<<10, time.Millisecond
+	t.Cleanup(func() { webdavChunkSize, webdavRetryDelay = chunkSize, retryDelay })
+}
+
+func TestWebdavUploadStreams(t *testing.T) {
+	dav := newFakeDAV()
+	server := httptest.NewServer(dav)
+	defer server.Close()
+
+	path, data := writeRandomFile(t, 512)
+	uploader := NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL})
+	var progress []int64
+	uploader.SetProgress(func(file string, sent, total int64) {
+		assert.Equal(t, int64(len(data)), total)
+		progress = append(progress, sent)
+	})
+
+	url, err := uploader.Upload(path, "logs/2024/03/01/test.zip")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/logs/2024/03/01/test.zip", url)
+	assert.Equal(t, data, dav.files["/logs/2024/03/01/test.zip"])
+	require.NotEmpty(t, progress)
+	assert.Equal(t, int64(len(data)), progress[len(progress)-1])
+}
+
+func TestWebdavUploadChunkedResumes(t *testing.T) {
+	useSmallChunks(t)
+	dav := newFakeDAV()
+	server := httptest.NewServer(dav)
+	defer server.Close()
+
+	path, data := writeRandomFile(t, 5*1024+100)
+	uploader := NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL})
+
+	// 上次中断时服务器上已有 2 块，只需要上传剩余部分
+	dav.dirs["/logs/"] = true
+	dav.files["/logs/test.zip"+webdavPartSuffix] = append([]byte(nil), data[:2048]...)
+	// 第一次发送的块只写入一半后失败，重试时从服务器上的实际大小继续
+	dav.failPut = 1
+
+	_, err := uploader.Upload(path, "logs/test.zip")
+	require.NoError(t, err)
+	assert.Equal(t, data, dav.files["/logs/test.zip"])
+	assert.NotContains(t, dav.files, "/logs/test.zip"+webdavPartSuffix)
+	// 失败的请求中未写入的一半数据需要重新发送
+	assert.Equal(t, int64(len(data)-2048+512), dav.received)
+}
+
+func TestWebdavUploadFallsBackWithoutPartialSupport(t *testing.T) {
+	useSmallChunks(t)
+	dav := newFakeDAV()
+	dav.ignoreRange = true
+	server := httptest.NewServer(dav)
+	defer server.Close()
+
+	path, data := writeRandomFile(t, 3*1024)
+	_, err := NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL}).Upload(path, "logs/test.zip")
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, dav.files["/logs/test.zip"]))
+	for name := range dav.files {
+		assert.False(t, strings.HasSuffix(name, webdavPartSuffix), name)
+	}
+}
+
+func TestWebdavUploadFallsBackWhenRangeRejected(t *testing.T) {
+	useSmallChunks(t)
+	webdavRetryDelay = time.Hour // 拒绝 Content-Range 时不应重试
+	dav := newFakeDAV()
+	dav.rejectRange = true
+	server := httptest.NewServer(dav)
+	defer server.Close()
+
+	path, data := writeRandomFile(t, 3*1024)
+	dav.dirs["/logs/"] = true
+	// 服务器上遗留的临时文件被删除
+	dav.files["/logs/test.zip"+webdavPartSuffix] = data[:1024]
+	_, err := NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL}).Upload(path, "logs/test.zip")
+	require.NoError(t, err)
+	assert.Equal(t, data, dav.files["/logs/test.zip"])
+	assert.NotContains(t, dav.files, "/logs/test.zip"+webdavPartSuffix)
+	assert.Equal(t, 2, dav.puts)
+}
+
+func TestWebdavUploadSabrePartialUpdate(t *testing.T) {
+	useSmallChunks(t)
+	dav := newFakeDAV()
+	dav.sabre = true
+	dav.rejectRange = true
+	server := httptest.NewServer(dav)
+	defer server.Close()
+
+	path, data := writeRandomFile(t, 3*1024+100)
+	_, err := NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL}).Upload(path, "logs/test.zip")
+	require.NoError(t, err)
+	assert.Equal(t, data, dav.files["/logs/test.zip"])
+	assert.NotContains(t, dav.files, "/logs/test.zip"+webdavPartSuffix)
+	// 第一块通过 PUT 创建临时文件，之后的块通过 PATCH 写入
+	assert.Equal(t, 1, dav.puts)
+	assert.Equal(t, 3, dav.patches)
+	assert.Equal(t, int64(len(data)), dav.received)
+}
+
+func TestWebdavUploadEscapesObjectKey(t *testing.T) {
+	useSmallChunks(t)
+	dav := newFakeDAV()
+	server := httptest.NewServer(dav)
+	defer server.Close()
+	uploader := NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL})
+
+	// 小文件直接 PUT，大文件分块上传后通过 MOVE 改名，Destination 同样需要转义
+	for _, size := range []int{100, 3 * 1024} {
+		path, data := writeRandomFile(t, size)
+		key := fmt.Sprintf("my logs/#1?/100%% %d.zip", size)
+		u, err := uploader.Upload(path, key)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%s/my%%20logs/%%231%%3F/100%%25%%20%d.zip", server.URL, size), u)
+		assert.Equal(t, data, dav.files["/"+key])
+	}
+	assert.True(t, dav.dirs["/my logs/#1?/"])
+	assert.NotContains(t, dav.files, "/my logs/#1?/100% 3072.zip"+webdavPartSuffix)
+}
+
+func TestWebdavUploadCreatesCollections(t *testing.T) {
+	dav := newFakeDAV()
+	dav.dirs["/dav/"] = true
+	dav.dirs["/dav/snapshots/"] = true
+	server := httptest.NewServer(dav)
+	defer server.Close()
+
+	path, data := writeRandomFile(t, 100)
+	uploader := NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL + "/dav/"})
+	url, err := uploader.Upload(path, filepath.Join("snapshots", "2024", "03", "01", "a.zip"))
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/dav/snapshots/2024/03/01/a.zip", url)
+	assert.Equal(t, data, dav.files["/dav/snapshots/2024/03/01/a.zip"])
+	// 已存在的 snapshots 目录返回 405，不影响上传
+	assert.Equal(t, []string{"/dav/snapshots/", "/dav/snapshots/2024/", "/dav/snapshots/2024/03/", "/dav/snapshots/2024/03/01/"}, dav.mkcols)
+
+	// 同一个上传器上传到同一目录时不再创建目录
+	_, err = uploader.Upload(path, "snapshots/2024/03/01/b.zip")
+	require.NoError(t, err)
+	assert.Len(t, dav.mkcols, 4)
+
+	// 新的上传器通过 PROPFIND 确认目录已存在
+	_, err = NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL + "/dav"}).Upload(path, "snapshots/2024/03/01/c.zip")
+	require.NoError(t, err)
+	assert.Len(t, dav.mkcols, 4)
+}
+
+func TestWebdavUploadVerifiesSize(t *testing.T) {
+	dav := newFakeDAV()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			// 模拟服务器丢失了部分数据
+			body, _ := io.ReadAll(r.Body)
+			dav.mu.Lock()
+			dav.files[r.URL.Path] = body[:len(body)-1]
+			dav.mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	path, _ := writeRandomFile(t, 100)
+	_, err := NewWebdavUploader(remote.UploadConfigProvider{Endpoint: server.URL}).Upload(path, "a.zip")
+	assert.ErrorContains(t, err, "99")
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	sizePattern     = regexp.MustCompile(`^(\d+)([bBkKmMgG]?)[bB]?$`)
+	durationPattern = regexp.MustCompile(`^(\d+)([mhdw])$`)
+)
+
+// ParseSize 解析大小字符串，支持 B/K/M/G 单位（按 1024 换算），例如 200M, 1G，空字符串返回 0
+func ParseSize(sizeStr string) (int64, error) {
+	if sizeStr == "" {
+		return 0, nil
+	}
+
+	matches := sizePattern.FindStringSubmatch(sizeStr)
+	if matches == nil {
+		return 0, fmt.Errorf("无效的大小格式: %s, 请使用如 500K, 200M, 1G 的格式", sizeStr)
+	}
+
+	value, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效的大小值: %s", matches[1])
+	}
+
+	switch strings.ToUpper(matches[2]) {
+	case "", "B":
+		return value, nil
+	case "K":
+		return value << 10, nil
+	case "M":
+		return value << 20, nil
+	case "G":
+		return value << 30, nil
+	default:
+		return 0, fmt.Errorf("不支持的大小单位: %s", matches[2])
+	}
+}
+
+// ParseDuration 解析时长字符串，支持 30m, 1h, 2d, 1w 以及 time.ParseDuration 的格式（如 1h30m），空字符串返回 0
+func ParseDuration(durationStr string) (time.Duration, error) {
+	if durationStr == "" {
+		return 0, nil
+	}
+
+	if matches := durationPattern.FindStringSubmatch(durationStr); matches != nil {
+		value, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return 0, fmt.Errorf("无效的时间值: %s", matches[1])
+		}
+		switch matches[2] {
+		case "m":
+			return time.Duration(value) * time.Minute, nil
+		case "h":
+			return time.Duration(value) * time.Hour, nil
+		case "d":
+			return time.Duration(value) * 24 * time.Hour, nil
+		case "w":
+			return time.Duration(value) * 7 * 24 * time.Hour, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(durationStr)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("无效的时间格式: %s, 请使用如 30m, 1h, 2d 的格式", durationStr)
+	}
+	return duration, nil
+}
@@ -0,0 +1,184 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"logsnap/logentry"
+	"logsnap/utils"
+)
+
+const (
+	// DefaultBefore 快照默认包含触发之前的日志时长
+	DefaultBefore = 10 * time.Minute
+	// DefaultAfter 快照默认包含触发之后的日志时长
+	DefaultAfter = 2 * time.Minute
+	// DefaultCooldown 规则触发后默认的冷却时间，冷却期间同一规则不再触发
+	DefaultCooldown = 30 * time.Minute
+)
+
+// crashPattern glog 的断言失败和崩溃信号
+var crashPattern = regexp.MustCompile(`Check failed|\*\*\* Aborted at|\*\*\* SIG[A-Z]+|SIGSEGV|SIGABRT|Segmentation fault`)
+
+// Duration 配置文件中以 "10m"、"1h30m"、"1d" 形式书写的时长，格式与 --time 等其他时长设置相同
+type Duration time.Duration
+
+// UnmarshalJSON 解析时长字符串
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("时长必须是字符串，例如 \"10m\": %w", err)
+	}
+	value, err := utils.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(value)
+	return nil
+}
+
+// MarshalJSON 输出时长字符串
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rule 触发规则，设置的所有条件都满足时日志才算匹配
+type Rule struct {
+	Name     string   `json:"name"`               // 规则名称，用于日志输出
+	Programs []string `json:"programs,omitempty"` // 只匹配这些程序的日志，为空表示所有程序
+	Pattern  string   `json:"pattern,omitempty"`  // 日志内容匹配的正则表达式
+	Level    string   `json:"level,omitempty"`    // 日志的最低级别
+	Crash    bool     `json:"crash,omitempty"`    // 匹配 glog 的 Check failed 和 SIGSEGV 等崩溃信息
+	Count    int      `json:"count,omitempty"`    // Window 内匹配到这么多条日志时触发，默认 1
+	Window   Duration `json:"window,omitempty"`   // 统计匹配次数的时间窗口，Count 大于 1 时必须设置
+	Cooldown Duration `json:"cooldown,omitempty"` // 触发后的冷却时间，默认 30 分钟
+	Before   Duration `json:"before,omitempty"`   // 快照包含触发之前的日志时长，默认 10 分钟
+	After    Duration `json:"after,omitempty"`    // 快照包含触发之后的日志时长，默认 2 分钟
+}
+
+// Config 监视配置
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// DefaultConfig 返回没有配置文件时使用的默认配置：任意程序崩溃时触发
+func DefaultConfig() *Config {
+	return &Config{Rules: []Rule{{Name: "crash", Crash: true}}}
+}
+
+// LoadConfig 从 JSON 文件加载监视配置
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取监视规则失败: %w", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("解析监视规则 %s 失败: %w", path, err)
+	}
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("监视规则 %s 中没有任何规则", path)
+	}
+	return &config, nil
+}
+
+// rule 编译后的规则及其运行状态
+type rule struct {
+	Rule
+	pattern     *regexp.Regexp
+	minSeverity logentry.Severity
+	programs    map[string]bool
+
+	matches   []time.Time // 时间窗口内匹配的时间
+	lastFired time.Time
+}
+
+// compile 校验规则并补全默认值
+func compile(r Rule) (*rule, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("规则缺少名称")
+	}
+	compiled := &rule{Rule: r}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("规则 %s 的正则表达式无效: %w", r.Name, err)
+		}
+		compiled.pattern = re
+	}
+	if r.Level != "" {
+		compiled.minSeverity = logentry.ParseSeverity(r.Level)
+		if compiled.minSeverity == logentry.SeverityUnknown {
+			return nil, fmt.Errorf("规则 %s 的日志级别无效: %s", r.Name, r.Level)
+		}
+	}
+	if compiled.pattern == nil && compiled.minSeverity == logentry.SeverityUnknown && !r.Crash {
+		return nil, fmt.Errorf("规则 %s 至少需要设置 pattern、level 或 crash 中的一个", r.Name)
+	}
+	if compiled.Count <= 0 {
+		compiled.Count = 1
+	}
+	if compiled.Count > 1 && compiled.Window <= 0 {
+		return nil, fmt.Errorf("规则 %s 设置了 count，需要同时设置 window", r.Name)
+	}
+	if compiled.Cooldown <= 0 {
+		compiled.Cooldown = Duration(DefaultCooldown)
+	}
+	if compiled.Before <= 0 {
+		compiled.Before = Duration(DefaultBefore)
+	}
+	if compiled.After <= 0 {
+		compiled.After = Duration(DefaultAfter)
+	}
+	if len(r.Programs) > 0 {
+		compiled.programs = make(map[string]bool)
+		for _, program := range r.Programs {
+			compiled.programs[program] = true
+		}
+	}
+	return compiled, nil
+}
+
+// match 判断日志是否满足规则的条件
+func (r *rule) match(program string, severity logentry.Severity, text string) bool {
+	if r.programs != nil && !r.programs[program] {
+		return false
+	}
+	if r.minSeverity != logentry.SeverityUnknown && severity < r.minSeverity {
+		return false
+	}
+	if r.Crash && !crashPattern.MatchString(text) {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(text) {
+		return false
+	}
+	return true
+}
+
+// observe 记录一次匹配，返回规则是否触发
+func (r *rule) observe(now time.Time) bool {
+	if !r.lastFired.IsZero() && now.Sub(r.lastFired) < time.Duration(r.Cooldown) {
+		return false
+	}
+	if r.Count > 1 {
+		// 丢弃时间窗口之外的匹配
+		cutoff := now.Add(-time.Duration(r.Window))
+		kept := r.matches[:0]
+		for _, t := range r.matches {
+			if t.After(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+		r.matches = append(kept, now)
+		if len(r.matches) < r.Count {
+			return false
+		}
+	}
+	r.matches = r.matches[:0]
+	r.lastFired = now
+	return true
+}
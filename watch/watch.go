@@ -0,0 +1,192 @@
+// Package watch 持续跟踪程序日志，日志满足触发规则时自动收集触发前后一段时间的快照
+// 规则触发后进入冷却期，快照等待触发之后的日志写入期间再次触发的规则合并到同一个快照中
+package watch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"logsnap/tail"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxSnapshotSpan 合并触发时快照覆盖的最长时间，持续故障时不会无限延长等待
+const maxSnapshotSpan = time.Hour
+
+// Trigger 一次规则触发
+type Trigger struct {
+	Rule   string    // 触发的规则名称
+	Time   time.Time // 触发时间
+	Source string    // 日志来源程序
+	File   string    // 日志文件
+	Text   string    // 触发的日志内容
+}
+
+// Snapshot 需要收集的快照
+type Snapshot struct {
+	Start    time.Time // 收集的开始时间
+	End      time.Time // 收集的结束时间
+	Triggers []Trigger // 合并到该快照中的所有触发
+}
+
+// CollectFunc 收集快照，由调用方实现（通常调用 service.CollectAndUploadLogs）
+type CollectFunc func(ctx context.Context, snapshot Snapshot) error
+
+// Watcher 根据规则监视日志并收集快照
+type Watcher struct {
+	rules   []*rule
+	collect CollectFunc
+
+	mu      sync.Mutex
+	pending *Snapshot   // 等待触发之后的日志写入的快照
+	timer   *time.Timer // 到 pending.End 时将快照交给收集协程
+	ready   chan Snapshot
+}
+
+// New 根据配置创建监视器
+func New(config *Config, collect CollectFunc) (*Watcher, error) {
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("没有任何监视规则")
+	}
+	w := &Watcher{
+		collect: collect,
+		ready:   make(chan Snapshot, 16),
+	}
+	names := make(map[string]bool)
+	for _, r := range config.Rules {
+		compiled, err := compile(r)
+		if err != nil {
+			return nil, err
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("规则名称重复: %s", r.Name)
+		}
+		names[r.Name] = true
+		w.rules = append(w.rules, compiled)
+	}
+	return w, nil
+}
+
+// Run 跟踪日志并在规则触发时收集快照，直到 ctx 结束
+// 退出时还在等待的快照立即收集，收集失败只记录日志，不中断监视
+func (w *Watcher) Run(ctx context.Context, follower *tail.Follower) error {
+	followCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	followErr := make(chan error, 1)
+	go func() {
+		followErr <- follower.Run(followCtx, w.Observe)
+	}()
+
+	for {
+		select {
+		case snapshot := <-w.ready:
+			w.run(ctx, snapshot)
+		case err := <-followErr:
+			if err != nil {
+				return err
+			}
+			// 跟踪结束（ctx 结束），收集已经就绪和还在等待的快照
+			for len(w.ready) > 0 {
+				w.run(context.Background(), <-w.ready)
+			}
+			if snapshot, ok := w.takePending(); ok {
+				snapshot.End = time.Now()
+				w.run(context.Background(), snapshot)
+			}
+			return nil
+		}
+	}
+}
+
+// run 收集一个快照
+func (w *Watcher) run(ctx context.Context, snapshot Snapshot) {
+	for _, trigger := range snapshot.Triggers {
+		logrus.Infof("规则 %s 触发于 %s: [%s %s] %s", trigger.Rule, trigger.Time.Format("2006-01-02 15:04:05"),
+			trigger.Source, trigger.File, firstLine(trigger.Text))
+	}
+	logrus.Infof("收集 %s 到 %s 的日志快照", snapshot.Start.Format("2006-01-02 15:04:05"),
+		snapshot.End.Format("2006-01-02 15:04:05"))
+	if err := w.collect(ctx, snapshot); err != nil {
+		logrus.Errorf("收集快照失败: %v", err)
+	}
+}
+
+// Observe 检查一条日志是否触发规则
+func (w *Watcher) Observe(entry tail.Entry) {
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, r := range w.rules {
+		if !r.match(entry.Source, entry.Severity, entry.Text) || !r.observe(now) {
+			continue
+		}
+		w.trigger(r, Trigger{
+			Rule:   r.Name,
+			Time:   now,
+			Source: entry.Source,
+			File:   entry.File,
+			Text:   entry.Text,
+		})
+	}
+}
+
+// trigger 将触发加入等待的快照，没有等待的快照时创建新快照
+func (w *Watcher) trigger(r *rule, trigger Trigger) {
+	start := trigger.Time.Add(-time.Duration(r.Before))
+	end := trigger.Time.Add(time.Duration(r.After))
+
+	if w.pending == nil {
+		w.pending = &Snapshot{Start: start, End: end}
+		w.timer = time.AfterFunc(time.Until(end), w.release)
+	} else {
+		// 等待期间再次触发，合并到同一个快照中
+		if start.Before(w.pending.Start) {
+			w.pending.Start = start
+		}
+		if limit := w.pending.Start.Add(maxSnapshotSpan); end.After(limit) {
+			end = limit
+		}
+		if end.After(w.pending.End) {
+			w.pending.End = end
+			w.timer.Reset(time.Until(end))
+		}
+	}
+	w.pending.Triggers = append(w.pending.Triggers, trigger)
+}
+
+// release 等待结束，将快照交给收集协程
+func (w *Watcher) release() {
+	w.mu.Lock()
+	if w.pending == nil || time.Now().Before(w.pending.End) {
+		// 定时器触发时等待时间恰好被延长，由重新设置的定时器处理
+		w.mu.Unlock()
+		return
+	}
+	w.mu.Unlock()
+	if snapshot, ok := w.takePending(); ok {
+		w.ready <- snapshot
+	}
+}
+
+// takePending 取出等待的快照
+func (w *Watcher) takePending() (Snapshot, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending == nil {
+		return Snapshot{}, false
+	}
+	snapshot := *w.pending
+	w.pending = nil
+	w.timer.Stop()
+	return snapshot, true
+}
+
+// firstLine 返回多行日志的第一行
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
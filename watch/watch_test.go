@@ -0,0 +1,127 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"logsnap/logentry"
+	"logsnap/tail"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"rules": [
+			{"name": "timeout", "pattern": "timeout", "level": "error", "count": 3, "window": "5m", "before": "15m", "cooldown": "1d"}
+		]
+	}`), 0644))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, config.Rules, 1)
+	assert.Equal(t, Duration(5*time.Minute), config.Rules[0].Window)
+	assert.Equal(t, Duration(24*time.Hour), config.Rules[0].Cooldown)
+
+	compiled, err := compile(config.Rules[0])
+	require.NoError(t, err)
+	assert.Equal(t, Duration(15*time.Minute), compiled.Before)
+	assert.Equal(t, Duration(DefaultAfter), compiled.After)
+	assert.Equal(t, Duration(24*time.Hour), compiled.Cooldown)
+
+	data, err := json.Marshal(compiled.Rule)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"window":"5m0s"`)
+
+	defaults, err := compile(Rule{Name: "defaults", Level: "error"})
+	require.NoError(t, err)
+	assert.Equal(t, Duration(DefaultCooldown), defaults.Cooldown)
+
+	var invalid Duration
+	assert.Error(t, json.Unmarshal([]byte(`"3x"`), &invalid))
+
+	_, err = compile(Rule{Name: "empty"})
+	assert.Error(t, err)
+	_, err = compile(Rule{Name: "rate", Level: "error", Count: 3})
+	assert.Error(t, err)
+}
+
+func TestRule(t *testing.T) {
+	r, err := compile(Rule{Name: "crash", Crash: true, Programs: []string{"app"}})
+	require.NoError(t, err)
+	assert.True(t, r.match("app", logentry.SeverityFatal, "F0301 10:00:00.000000 1 main.cc:10] Check failed: ptr != nullptr"))
+	assert.True(t, r.match("app", logentry.SeverityUnknown, "*** SIGSEGV (@0x0) received by PID 1"))
+	assert.False(t, r.match("other", logentry.SeverityFatal, "Check failed: x"))
+	assert.False(t, r.match("app", logentry.SeverityError, "connect failed"))
+
+	// 5 分钟内出现 3 次时触发，之后冷却
+	r, err = compile(Rule{Name: "rate", Level: "error", Count: 3, Window: Duration(5 * time.Minute)})
+	require.NoError(t, err)
+	now := time.Now()
+	assert.False(t, r.observe(now))
+	assert.False(t, r.observe(now.Add(time.Minute)))
+	assert.False(t, r.observe(now.Add(7*time.Minute)), "前两次匹配已经超出时间窗口")
+	assert.False(t, r.observe(now.Add(8*time.Minute)))
+	assert.True(t, r.observe(now.Add(9*time.Minute)))
+	for i := 0; i < 3; i++ {
+		assert.False(t, r.observe(now.Add(10*time.Minute)), "冷却期间不再触发")
+	}
+	// 冷却结束后重新计数
+	assert.False(t, r.observe(now.Add(40*time.Minute)))
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(logFile, nil, 0644))
+
+	config := &Config{Rules: []Rule{
+		{Name: "crash", Crash: true, After: Duration(300 * time.Millisecond)},
+		{Name: "error", Level: "error", After: Duration(100 * time.Millisecond)},
+	}}
+	snapshots := make(chan Snapshot, 4)
+	watcher, err := New(config, func(ctx context.Context, snapshot Snapshot) error {
+		snapshots <- snapshot
+		return nil
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Run(ctx, tail.New([]tail.Source{{Name: "app", Dir: dir}}, nil))
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("2024-03-01 10:00:00.000 ERROR Check failed: ptr != nullptr\n" +
+		"2024-03-01 10:00:01.000 INFO next\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	var snapshot Snapshot
+	select {
+	case snapshot = <-snapshots:
+	case <-time.After(5 * time.Second):
+		t.Fatal("规则没有触发")
+	}
+	cancel()
+	require.NoError(t, <-done)
+
+	// 两条规则由同一条日志触发，合并为一个快照
+	require.Len(t, snapshot.Triggers, 2)
+	assert.Equal(t, "crash", snapshot.Triggers[0].Rule)
+	assert.Equal(t, "error", snapshot.Triggers[1].Rule)
+	assert.Equal(t, "app", snapshot.Triggers[0].Source)
+	trigger := snapshot.Triggers[0].Time
+	assert.Equal(t, trigger.Add(-DefaultBefore), snapshot.Start)
+	assert.Equal(t, trigger.Add(300*time.Millisecond), snapshot.End)
+	assert.Empty(t, snapshots)
+}